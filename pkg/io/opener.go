@@ -22,10 +22,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"cloud.google.com/go/storage"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 
 	"github.com/GoogleCloudPlatform/testgrid/util/gcs" // TODO(fejta): move this logic here
@@ -47,12 +49,44 @@ type Opener interface {
 	Writer(ctx context.Context, path string) (WriteCloser, error)
 }
 
+// Attributes describes a stored object.
+type Attributes struct {
+	Size            int64
+	ContentEncoding string
+}
+
+// Storage is an Opener which can also inspect and list the objects it stores.
+type Storage interface {
+	Opener
+	// RangeReader reads length bytes starting at offset, or the rest of
+	// the object when length is negative.
+	RangeReader(ctx context.Context, path string, offset, length int64) (ReadCloser, error)
+	// Attributes returns the size and encoding of the object at path.
+	Attributes(ctx context.Context, path string) (Attributes, error)
+	// List returns the names of all objects under prefix, relative to prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// SignedURL returns a link that can be used to download the object at path.
+	SignedURL(ctx context.Context, path string) (string, error)
+	// Delete removes the object at path, returning an IsNotExist() error when missing.
+	Delete(ctx context.Context, path string) error
+}
+
 type opener struct {
 	gcs storageClient
+	s3  *s3Backend
+}
+
+// NewOpener returns an opener that can read GCS, S3 and local paths.
+func NewOpener(ctx context.Context, creds, s3Creds string) (Opener, error) {
+	return NewStorage(ctx, creds, s3Creds)
 }
 
-// NewOpener returns an opener that can read GCS and local paths.
-func NewOpener(ctx context.Context, creds string) (Opener, error) {
+// NewStorage returns a Storage that can access GCS, S3 and local paths.
+//
+// Paths starting with gs:// use the GCS credentials in creds (or the application
+// default credentials), paths starting with s3:// use the S3 credentials in s3Creds
+// (or the default AWS credential chain) and anything else is a local path.
+func NewStorage(ctx context.Context, creds, s3Creds string) (Storage, error) {
 	var options []option.ClientOption
 	if creds != "" {
 		options = append(options, option.WithCredentialsFile(creds))
@@ -65,12 +99,25 @@ func NewOpener(ctx context.Context, creds string) (Opener, error) {
 		logrus.WithError(err).Debug("Cannot load application default gcp credentials")
 		client = nil
 	}
-	return opener{gcs: client}, nil
+	s3, err := newS3Backend(s3Creds)
+	if err != nil {
+		if s3Creds != "" {
+			return nil, err
+		}
+		logrus.WithError(err).Debug("Cannot load default aws credentials")
+		s3 = nil
+	}
+	o := opener{s3: s3}
+	// Avoid storing a typed nil in the interface.
+	if client != nil {
+		o.gcs = client
+	}
+	return o, nil
 }
 
 // IsNotExist will return true if the error is because the object does not exist.
 func IsNotExist(err error) bool {
-	return os.IsNotExist(err) || err == storage.ErrObjectNotExist
+	return os.IsNotExist(err) || err == storage.ErrObjectNotExist || isS3NotExist(err)
 }
 
 // LogClose will attempt a close an log any error
@@ -97,16 +144,60 @@ func (o opener) openGCS(path string) (*storage.ObjectHandle, error) {
 	return o.gcs.Bucket(p.Bucket()).Object(p.Object()), nil
 }
 
+func (o opener) openS3(path string) (*s3Object, error) {
+	if !strings.HasPrefix(path, "s3://") {
+		return nil, nil
+	}
+	if o.s3 == nil {
+		return nil, errors.New("no s3 client configured")
+	}
+	return o.s3.object(path)
+}
+
+// localPath strips the optional file:// scheme from a local path.
+func localPath(path string) string {
+	return strings.TrimPrefix(path, "file://")
+}
+
 // Reader will open the path for reading, returning an IsNotExist() error when missing
 func (o opener) Reader(ctx context.Context, path string) (io.ReadCloser, error) {
+	return o.RangeReader(ctx, path, 0, -1)
+}
+
+// RangeReader will open a section of the path for reading, returning an IsNotExist() error when missing
+func (o opener) RangeReader(ctx context.Context, path string, offset, length int64) (io.ReadCloser, error) {
 	g, err := o.openGCS(path)
 	if err != nil {
 		return nil, fmt.Errorf("bad gcs path: %v", err)
 	}
-	if g == nil {
-		return os.Open(path)
+	if g != nil {
+		return g.NewRangeReader(ctx, offset, length)
+	}
+	s, err := o.openS3(path)
+	if err != nil {
+		return nil, fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.rangeReader(ctx, offset, length)
+	}
+	f, err := os.Open(localPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if offset == 0 && length < 0 {
+		return f, nil
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
 	}
-	return g.NewReader(ctx)
+	if length < 0 {
+		return f, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
 }
 
 // Writer returns a writer that overwrites the path.
@@ -115,8 +206,134 @@ func (o opener) Writer(ctx context.Context, path string) (io.WriteCloser, error)
 	if err != nil {
 		return nil, fmt.Errorf("bad gcs path: %v", err)
 	}
-	if g == nil {
-		return os.Create(path)
+	if g != nil {
+		return g.NewWriter(ctx), nil
+	}
+	s, err := o.openS3(path)
+	if err != nil {
+		return nil, fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.writer(ctx), nil
+	}
+	p := localPath(path)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+// Attributes returns the size and content encoding of the object at path.
+func (o opener) Attributes(ctx context.Context, path string) (Attributes, error) {
+	g, err := o.openGCS(path)
+	if err != nil {
+		return Attributes{}, fmt.Errorf("bad gcs path: %v", err)
+	}
+	if g != nil {
+		attrs, err := g.Attrs(ctx)
+		if err != nil {
+			return Attributes{}, err
+		}
+		return Attributes{Size: attrs.Size, ContentEncoding: attrs.ContentEncoding}, nil
+	}
+	s, err := o.openS3(path)
+	if err != nil {
+		return Attributes{}, fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.attributes(ctx)
+	}
+	info, err := os.Stat(localPath(path))
+	if err != nil {
+		return Attributes{}, err
+	}
+	return Attributes{Size: info.Size()}, nil
+}
+
+// List returns the names of all objects under prefix, relative to prefix.
+func (o opener) List(ctx context.Context, prefix string) ([]string, error) {
+	if strings.HasPrefix(prefix, "gs://") {
+		if o.gcs == nil {
+			return nil, errors.New("no gcs client configured")
+		}
+		var p gcs.Path
+		if err := p.Set(prefix); err != nil {
+			return nil, fmt.Errorf("bad gcs path: %v", err)
+		}
+		it := o.gcs.Bucket(p.Bucket()).Objects(ctx, &storage.Query{Prefix: p.Object()})
+		var names []string
+		for {
+			attrs, err := it.Next()
+			if err == iterator.Done {
+				return names, nil
+			}
+			if err != nil {
+				return names, err
+			}
+			names = append(names, strings.TrimPrefix(attrs.Name, p.Object()))
+		}
+	}
+	s, err := o.openS3(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.list(ctx)
+	}
+	root := localPath(prefix)
+	var names []string
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	return names, err
+}
+
+// Delete removes the object at path.
+func (o opener) Delete(ctx context.Context, path string) error {
+	g, err := o.openGCS(path)
+	if err != nil {
+		return fmt.Errorf("bad gcs path: %v", err)
+	}
+	if g != nil {
+		return g.Delete(ctx)
+	}
+	s, err := o.openS3(path)
+	if err != nil {
+		return fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.delete(ctx)
+	}
+	return os.Remove(localPath(path))
+}
+
+// SignedURL returns a link to the object at path. Only S3 links are signed,
+// GCS objects are linked to publicly and local paths are returned unchanged.
+func (o opener) SignedURL(ctx context.Context, path string) (string, error) {
+	if strings.HasPrefix(path, "gs://") {
+		var p gcs.Path
+		if err := p.Set(path); err != nil {
+			return "", fmt.Errorf("bad gcs path: %v", err)
+		}
+		return fmt.Sprintf("https://storage.googleapis.com/%s/%s", p.Bucket(), p.Object()), nil
+	}
+	s, err := o.openS3(path)
+	if err != nil {
+		return "", fmt.Errorf("bad s3 path: %v", err)
+	}
+	if s != nil {
+		return s.signedURL()
 	}
-	return g.NewWriter(ctx), nil
+	return path, nil
 }
@@ -0,0 +1,234 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package io
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Credentials are the contents of the file passed as the S3 credentials
+// of an opener. Endpoint, Insecure and S3ForcePathStyle allow pointing the
+// opener at an S3 compatible service such as MinIO.
+type S3Credentials struct {
+	Region           string `json:"region"`
+	Endpoint         string `json:"endpoint"`
+	Insecure         bool   `json:"insecure"`
+	S3ForcePathStyle bool   `json:"s3_force_path_style"`
+	AccessKey        string `json:"access_key"`
+	SecretKey        string `json:"secret_key"`
+}
+
+// signedURLExpiry is how long links returned by SignedURL stay valid.
+const signedURLExpiry = 10 * time.Minute
+
+type s3Backend struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+type s3Object struct {
+	backend *s3Backend
+	bucket  string
+	key     string
+}
+
+// newS3Backend creates an S3 client from the credentials file, or from the
+// default AWS credential chain when no file is given. Default credentials
+// are only resolved once the first request is made.
+func newS3Backend(credsFile string) (*s3Backend, error) {
+	cfg := aws.NewConfig()
+	if credsFile != "" {
+		raw, err := ioutil.ReadFile(credsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read s3 credentials: %v", err)
+		}
+		var creds S3Credentials
+		if err := json.Unmarshal(raw, &creds); err != nil {
+			return nil, fmt.Errorf("parse s3 credentials: %v", err)
+		}
+		if creds.AccessKey == "" || creds.SecretKey == "" {
+			return nil, errors.New("s3 credentials must set access_key and secret_key")
+		}
+		region := creds.Region
+		if region == "" {
+			// MinIO and most other S3 compatible services ignore the region,
+			// but the SDK refuses to sign requests without one.
+			region = "us-east-1"
+		}
+		cfg = cfg.WithRegion(region).
+			WithCredentials(credentials.NewStaticCredentials(creds.AccessKey, creds.SecretKey, "")).
+			WithDisableSSL(creds.Insecure).
+			WithS3ForcePathStyle(creds.S3ForcePathStyle)
+		if creds.Endpoint != "" {
+			cfg = cfg.WithEndpoint(creds.Endpoint)
+		}
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Backend{
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+func (b *s3Backend) object(path string) (*s3Object, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, errors.New("bucket name is empty")
+	}
+	return &s3Object{
+		backend: b,
+		bucket:  u.Host,
+		key:     strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func isS3NotExist(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case s3.ErrCodeNoSuchKey, "NotFound":
+		return true
+	}
+	return false
+}
+
+func (o *s3Object) rangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if o.key == "" {
+		return nil, errors.New("object name is empty")
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	}
+	switch {
+	case length == 0:
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+	out, err := o.backend.client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+func (o *s3Object) attributes(ctx context.Context) (Attributes, error) {
+	out, err := o.backend.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	})
+	if err != nil {
+		return Attributes{}, err
+	}
+	return Attributes{
+		Size:            aws.Int64Value(out.ContentLength),
+		ContentEncoding: aws.StringValue(out.ContentEncoding),
+	}, nil
+}
+
+func (o *s3Object) list(ctx context.Context) ([]string, error) {
+	var names []string
+	err := o.backend.client.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(o.bucket),
+		Prefix: aws.String(o.key),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			names = append(names, strings.TrimPrefix(aws.StringValue(obj.Key), o.key))
+		}
+		return true
+	})
+	return names, err
+}
+
+func (o *s3Object) delete(ctx context.Context) error {
+	_, err := o.backend.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	})
+	return err
+}
+
+func (o *s3Object) signedURL() (string, error) {
+	req, _ := o.backend.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	})
+	return req.Presign(signedURLExpiry)
+}
+
+// s3Writer streams everything written to it into a single S3 upload,
+// which completes when the writer is closed.
+type s3Writer struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func (o *s3Object) writer(ctx context.Context) io.WriteCloser {
+	r, w := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := o.backend.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: aws.String(o.bucket),
+			Key:    aws.String(o.key),
+			Body:   r,
+		})
+		// Unblock any pending writes if the upload failed early.
+		r.CloseWithError(err)
+		done <- err
+	}()
+	return &s3Writer{pipe: w, done: done}
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+func (w *s3Writer) Close() error {
+	if err := w.pipe.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
@@ -52,6 +52,9 @@ type ProwJobState string
 const (
 	// TriggeredState means the job has been created but not yet scheduled.
 	TriggeredState ProwJobState = "triggered"
+	// WaitingState means the job is waiting for the jobs it depends on to
+	// succeed before it can be scheduled.
+	WaitingState ProwJobState = "waiting"
 	// PendingState means the job is scheduled but not yet running.
 	PendingState ProwJobState = "pending"
 	// SuccessState means the job completed without error (exit 0)
@@ -135,6 +138,12 @@ type ProwJobSpec struct {
 	// If this field is unspecified or false, a new pod will be created to replace
 	// the evicted one.
 	ErrorOnEviction bool `json:"error_on_eviction,omitempty"`
+	// DependsOn lists the names of jobs that must succeed against
+	// the same Refs before this job is scheduled
+	DependsOn []string `json:"depends_on,omitempty"`
+	// RetryPolicy determines if and how the job is retried
+	// when it fails because of an infrastructure problem
+	RetryPolicy *RetryPolicy `json:"retry_policy,omitempty"`
 
 	// PodSpec provides the basis for running the test under
 	// a Kubernetes agent
@@ -228,13 +237,33 @@ func (rac *RerunAuthConfig) IsAuthorized(user string, cli prowgithub.RerunClient
 }
 
 type ReporterConfig struct {
-	Slack *SlackReporterConfig `json:"slack,omitempty"`
+	Slack   *SlackReporterConfig   `json:"slack,omitempty"`
+	Webhook *WebhookReporterConfig `json:"webhook,omitempty"`
+	Email   *EmailReporterConfig   `json:"email,omitempty"`
 }
 
 type SlackReporterConfig struct {
 	Channel string `json:"channel"`
 }
 
+// EmailReporterConfig opts a job into the email reporter and can override
+// who it is reported to and when.
+type EmailReporterConfig struct {
+	// Recipients overrides the addresses the report is sent to.
+	Recipients []string `json:"recipients,omitempty"`
+	// JobStatesToReport overrides the job states that are reported.
+	JobStatesToReport []ProwJobState `json:"job_states_to_report,omitempty"`
+}
+
+// WebhookReporterConfig opts a job into the webhook reporter and can
+// override where and when it is reported.
+type WebhookReporterConfig struct {
+	// URL overrides the endpoint the payload is POSTed to.
+	URL string `json:"url,omitempty"`
+	// JobStatesToReport overrides the job states that are reported.
+	JobStatesToReport []ProwJobState `json:"job_states_to_report,omitempty"`
+}
+
 // Duration is a wrapper around time.Duration that parses times in either
 // 'integer number of nanoseconds' or 'duration string' formats and serializes
 // to 'duration string' format.
@@ -267,6 +296,88 @@ func (d *Duration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Duration.String())
 }
 
+// InfraFailureClass identifies the kind of infrastructure problem
+// that made a job fail.
+type InfraFailureClass string
+
+// Various infrastructure failure classes.
+const (
+	// SchedulingFailure means the pod could not be scheduled onto a node.
+	SchedulingFailure InfraFailureClass = "scheduling"
+	// ImagePullFailure means an image of the pod could not be pulled.
+	ImagePullFailure InfraFailureClass = "image_pull"
+	// NodeLostFailure means the node running the pod went away.
+	NodeLostFailure InfraFailureClass = "node_lost"
+	// SidecarFailure means the sidecar reported that the results of
+	// the job could not be recorded.
+	SidecarFailure InfraFailureClass = "sidecar"
+)
+
+// InfraFailureClasses are all the known infrastructure failure classes.
+var InfraFailureClasses = []InfraFailureClass{SchedulingFailure, ImagePullFailure, NodeLostFailure, SidecarFailure}
+
+// RetryPolicy configures how a job is retried when it fails
+// because of an infrastructure problem rather than the test.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the job is run,
+	// including the first attempt. Values below two disable retries.
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Backoff is how long to wait before starting the first retry.
+	// The wait doubles for every further retry.
+	Backoff *Duration `json:"backoff,omitempty"`
+	// FailureClasses restricts the infrastructure failures that are
+	// retried. If unset, all known failure classes are retried.
+	FailureClasses []InfraFailureClass `json:"failure_classes,omitempty"`
+}
+
+// Retries determines if the given failure class should be retried.
+func (r *RetryPolicy) Retries(class InfraFailureClass) bool {
+	if r == nil || r.MaxAttempts < 2 {
+		return false
+	}
+	if len(r.FailureClasses) == 0 {
+		return true
+	}
+	for _, c := range r.FailureClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// BackoffFor returns how long to wait before the given retry,
+// counting from one.
+func (r *RetryPolicy) BackoffFor(retry int) time.Duration {
+	if r == nil || retry < 1 {
+		return 0
+	}
+	return r.Backoff.Get() << uint(retry-1)
+}
+
+// Validate ensures all the values set in the RetryPolicy are valid.
+func (r *RetryPolicy) Validate() error {
+	if r.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts: %d must be a non-negative number", r.MaxAttempts)
+	}
+	if r.Backoff.Get() < 0 {
+		return fmt.Errorf("backoff: %s must not be negative", r.Backoff.Get())
+	}
+	for _, class := range r.FailureClasses {
+		var known bool
+		for _, c := range InfraFailureClasses {
+			if c == class {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown failure class %q, must be one of %q", class, InfraFailureClasses)
+		}
+	}
+	return nil
+}
+
 // DecorationConfig specifies how to augment pods.
 //
 // This is primarily used to provide automatic integration with gubernator
@@ -434,10 +545,21 @@ const (
 	PathStrategyExplicit = "explicit"
 )
 
+// Storage providers select where artifacts are uploaded, based
+// on the scheme of GCSConfiguration.Bucket.
+const (
+	StorageProviderGCS  = "gs"
+	StorageProviderS3   = "s3"
+	StorageProviderFile = "file"
+)
+
 // GCSConfiguration holds options for pushing logs and
 // artifacts to GCS from a job.
 type GCSConfiguration struct {
-	// Bucket is the GCS bucket to upload to
+	// Bucket is the bucket to upload to. A bare bucket name or
+	// gs://bucket uploads to GCS, s3://bucket uploads to S3 or
+	// an S3 compatible service such as MinIO and file:///path
+	// copies into a directory on a shared filesystem.
 	Bucket string `json:"bucket,omitempty"`
 	// PathPrefix is an optional path that follows the
 	// bucket name and comes before any structure
@@ -506,8 +628,35 @@ func (g *GCSConfiguration) ApplyDefault(def *GCSConfiguration) *GCSConfiguration
 	return &merged
 }
 
+// StorageProvider returns the storage provider selected by the
+// scheme of the bucket, defaulting to GCS.
+func (g *GCSConfiguration) StorageProvider() string {
+	if i := strings.Index(g.Bucket, "://"); i != -1 {
+		return g.Bucket[:i]
+	}
+	return StorageProviderGCS
+}
+
+// BucketName returns the bucket without its scheme.
+func (g *GCSConfiguration) BucketName() string {
+	if i := strings.Index(g.Bucket, "://"); i != -1 {
+		return g.Bucket[i+len("://"):]
+	}
+	return g.Bucket
+}
+
+// BucketURL returns the bucket with its scheme, for example gs://bucket.
+func (g *GCSConfiguration) BucketURL() string {
+	return g.StorageProvider() + "://" + g.BucketName()
+}
+
 // Validate ensures all the values set in the GCSConfiguration are valid.
 func (g *GCSConfiguration) Validate() error {
+	switch provider := g.StorageProvider(); provider {
+	case StorageProviderGCS, StorageProviderS3, StorageProviderFile:
+	default:
+		return fmt.Errorf("unsupported storage provider %q in bucket %q: must be one of %q, %q or %q", provider, g.Bucket, StorageProviderGCS, StorageProviderS3, StorageProviderFile)
+	}
 	for _, mediaType := range g.MediaTypes {
 		if _, _, err := mime.ParseMediaType(mediaType); err != nil {
 			return fmt.Errorf("invalid extension media type %q: %v", mediaType, err)
@@ -556,6 +705,64 @@ type ProwJobStatus struct {
 	// PrevReportStates stores the previous reported prowjob state per reporter
 	// So crier won't make duplicated report attempt
 	PrevReportStates map[string]ProwJobState `json:"prev_report_states,omitempty"`
+
+	// Attempts records the previous attempts to run this job
+	// that failed because of an infrastructure problem and
+	// were retried according to the RetryPolicy.
+	Attempts []Attempt `json:"attempts,omitempty"`
+
+	// ReservedResources records the resources the pods of this job
+	// requested and how long they ran. It is set by plank when
+	// the job completes and is used for cost accounting. It is the
+	// capacity reserved for the job, not what its pods actually used.
+	ReservedResources *ReservedResources `json:"reserved_resources,omitempty"`
+}
+
+// ReservedResources describes the resources reserved for the pods of a ProwJob.
+type ReservedResources struct {
+	// Requests are the resources requested by the containers of the job pod.
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	// Limits are the resource limits of the containers of the job pod.
+	Limits corev1.ResourceList `json:"limits,omitempty"`
+	// RunningDuration is how long the job pods were running, summed
+	// over all attempts.
+	RunningDuration metav1.Duration `json:"running_duration,omitempty"`
+}
+
+// CPUCoreSeconds returns the requested CPU cores multiplied by the time the
+// job pods were running.
+func (r *ReservedResources) CPUCoreSeconds() float64 {
+	if r == nil {
+		return 0
+	}
+	cpu := r.Requests[corev1.ResourceCPU]
+	return float64(cpu.MilliValue()) / 1000 * r.RunningDuration.Seconds()
+}
+
+// MemoryByteSeconds returns the requested memory in bytes multiplied by the
+// time the job pods were running.
+func (r *ReservedResources) MemoryByteSeconds() float64 {
+	if r == nil {
+		return 0
+	}
+	memory := r.Requests[corev1.ResourceMemory]
+	return float64(memory.Value()) * r.RunningDuration.Seconds()
+}
+
+// Attempt describes a previous attempt to run a ProwJob.
+type Attempt struct {
+	// PodName is the name of the pod that ran the attempt
+	PodName string `json:"pod_name,omitempty"`
+	// BuildID is the build identifier of the attempt
+	BuildID string `json:"build_id,omitempty"`
+	// StartTime is when the attempt started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is when the attempt was given up on
+	CompletionTime metav1.Time `json:"completionTime,omitempty"`
+	// FailureClass is the infrastructure problem that ended the attempt
+	FailureClass InfraFailureClass `json:"failure_class,omitempty"`
+	// Description is a human-readable explanation of the failure
+	Description string `json:"description,omitempty"`
 }
 
 // Complete returns true if the prow job has finished
@@ -570,6 +777,12 @@ func (j *ProwJob) SetComplete() {
 	*j.Status.CompletionTime = metav1.Now()
 }
 
+// Attempt returns the number of the current attempt to run the job,
+// counting from one.
+func (j *ProwJob) Attempt() int {
+	return len(j.Status.Attempts) + 1
+}
+
 // ClusterAlias specifies the key in the clusters map to use.
 //
 // This allows scheduling a prow job somewhere aside from the default build cluster.
@@ -255,3 +255,142 @@ func TestRefsToString(t *testing.T) {
 		}
 	}
 }
+
+func TestRetryPolicy(t *testing.T) {
+	var tests = []struct {
+		name            string
+		policy          *RetryPolicy
+		class           InfraFailureClass
+		retry           int
+		expectedRetries bool
+		expectedBackoff time.Duration
+		expectedInvalid bool
+	}{
+		{
+			name:  "nil policy does not retry",
+			class: NodeLostFailure,
+			retry: 1,
+		},
+		{
+			name:   "single attempt does not retry",
+			policy: &RetryPolicy{MaxAttempts: 1},
+			class:  NodeLostFailure,
+			retry:  1,
+		},
+		{
+			name:            "all classes are retried by default",
+			policy:          &RetryPolicy{MaxAttempts: 2, Backoff: &Duration{Duration: time.Minute}},
+			class:           SidecarFailure,
+			retry:           1,
+			expectedRetries: true,
+			expectedBackoff: time.Minute,
+		},
+		{
+			name:            "backoff doubles for every retry",
+			policy:          &RetryPolicy{MaxAttempts: 4, Backoff: &Duration{Duration: time.Minute}},
+			class:           SchedulingFailure,
+			retry:           3,
+			expectedRetries: true,
+			expectedBackoff: 4 * time.Minute,
+		},
+		{
+			name:   "only listed classes are retried",
+			policy: &RetryPolicy{MaxAttempts: 2, FailureClasses: []InfraFailureClass{ImagePullFailure}},
+			class:  NodeLostFailure,
+			retry:  1,
+		},
+		{
+			name:            "unknown classes are invalid",
+			policy:          &RetryPolicy{MaxAttempts: 2, FailureClasses: []InfraFailureClass{"flaky-test"}},
+			class:           NodeLostFailure,
+			retry:           1,
+			expectedInvalid: true,
+		},
+		{
+			name:            "negative attempts are invalid",
+			policy:          &RetryPolicy{MaxAttempts: -1},
+			class:           NodeLostFailure,
+			retry:           1,
+			expectedInvalid: true,
+		},
+	}
+
+	for _, test := range tests {
+		if actual := test.policy.Retries(test.class); actual != test.expectedRetries {
+			t.Errorf("%s: expected retries %t, got %t", test.name, test.expectedRetries, actual)
+		}
+		if actual := test.policy.BackoffFor(test.retry); actual != test.expectedBackoff {
+			t.Errorf("%s: expected backoff %s, got %s", test.name, test.expectedBackoff, actual)
+		}
+		if test.policy == nil {
+			continue
+		}
+		if err := test.policy.Validate(); (err != nil) != test.expectedInvalid {
+			t.Errorf("%s: expected invalid %t, got error %v", test.name, test.expectedInvalid, err)
+		}
+	}
+}
+
+func TestGCSConfigurationStorageProvider(t *testing.T) {
+	var tests = []struct {
+		name             string
+		bucket           string
+		expectedProvider string
+		expectedName     string
+		expectedURL      string
+		expectedInvalid  bool
+	}{
+		{
+			name:             "bare bucket is gcs",
+			bucket:           "kubernetes-jenkins",
+			expectedProvider: StorageProviderGCS,
+			expectedName:     "kubernetes-jenkins",
+			expectedURL:      "gs://kubernetes-jenkins",
+		},
+		{
+			name:             "gs scheme is gcs",
+			bucket:           "gs://kubernetes-jenkins",
+			expectedProvider: StorageProviderGCS,
+			expectedName:     "kubernetes-jenkins",
+			expectedURL:      "gs://kubernetes-jenkins",
+		},
+		{
+			name:             "s3 scheme is s3",
+			bucket:           "s3://prow-logs",
+			expectedProvider: StorageProviderS3,
+			expectedName:     "prow-logs",
+			expectedURL:      "s3://prow-logs",
+		},
+		{
+			name:             "file scheme keeps the absolute path",
+			bucket:           "file:///srv/prow-logs",
+			expectedProvider: StorageProviderFile,
+			expectedName:     "/srv/prow-logs",
+			expectedURL:      "file:///srv/prow-logs",
+		},
+		{
+			name:             "unknown scheme is invalid",
+			bucket:           "azure://prow-logs",
+			expectedProvider: "azure",
+			expectedName:     "prow-logs",
+			expectedURL:      "azure://prow-logs",
+			expectedInvalid:  true,
+		},
+	}
+
+	for _, test := range tests {
+		g := &GCSConfiguration{Bucket: test.bucket, PathStrategy: PathStrategyExplicit}
+		if actual := g.StorageProvider(); actual != test.expectedProvider {
+			t.Errorf("%s: expected provider %q, got %q", test.name, test.expectedProvider, actual)
+		}
+		if actual := g.BucketName(); actual != test.expectedName {
+			t.Errorf("%s: expected bucket name %q, got %q", test.name, test.expectedName, actual)
+		}
+		if actual := g.BucketURL(); actual != test.expectedURL {
+			t.Errorf("%s: expected bucket URL %q, got %q", test.name, test.expectedURL, actual)
+		}
+		if err := g.Validate(); (err != nil) != test.expectedInvalid {
+			t.Errorf("%s: expected invalid %t, got error %v", test.name, test.expectedInvalid, err)
+		}
+	}
+}
@@ -28,10 +28,12 @@ import (
 	"k8s.io/test-infra/prow/interrupts"
 	"k8s.io/test-infra/prow/pjutil"
 
+	"k8s.io/test-infra/pkg/io"
 	prowjobinformer "k8s.io/test-infra/prow/client/informers/externalversions"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	"k8s.io/test-infra/prow/crier"
+	emailreporter "k8s.io/test-infra/prow/email/reporter"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
 	gerritclient "k8s.io/test-infra/prow/gerrit/client"
 	gerritreporter "k8s.io/test-infra/prow/gerrit/reporter"
@@ -40,6 +42,7 @@ import (
 	"k8s.io/test-infra/prow/logrusutil"
 	pubsubreporter "k8s.io/test-infra/prow/pubsub/reporter"
 	slackreporter "k8s.io/test-infra/prow/slack/reporter"
+	webhookreporter "k8s.io/test-infra/prow/webhook/reporter"
 )
 
 const (
@@ -56,12 +59,22 @@ type options struct {
 	configPath    string
 	jobConfigPath string
 
-	gerritWorkers int
-	pubsubWorkers int
-	githubWorkers int
-	slackWorkers  int
+	gerritWorkers  int
+	pubsubWorkers  int
+	githubWorkers  int
+	slackWorkers   int
+	webhookWorkers int
+	emailWorkers   int
 
-	slackTokenFile string
+	slackTokenFile        string
+	webhookHMACSecretFile string
+
+	emailSMTPServer       string
+	emailSMTPUsername     string
+	emailSMTPPasswordFile string
+	emailDigestPath       string
+	gcsCredentialsFile    string
+	s3CredentialsFile     string
 
 	dryrun      bool
 	reportAgent string
@@ -84,7 +97,7 @@ func (o *options) validate() error {
 		o.githubWorkers = 1
 	}
 
-	if o.gerritWorkers+o.pubsubWorkers+o.githubWorkers+o.slackWorkers <= 0 {
+	if o.gerritWorkers+o.pubsubWorkers+o.githubWorkers+o.slackWorkers+o.webhookWorkers+o.emailWorkers <= 0 {
 		return errors.New("crier need to have at least one report worker to start")
 	}
 
@@ -110,6 +123,15 @@ func (o *options) validate() error {
 		}
 	}
 
+	if o.emailWorkers > 0 {
+		if o.emailSMTPServer == "" {
+			return errors.New("--email-smtp-server must be set")
+		}
+		if o.emailSMTPUsername != "" && o.emailSMTPPasswordFile == "" {
+			return errors.New("--email-smtp-password-file must be set with --email-smtp-username")
+		}
+	}
+
 	if err := o.client.Validate(o.dryrun); err != nil {
 		return err
 	}
@@ -128,13 +150,22 @@ func (o *options) parseArgs(fs *flag.FlagSet, args []string) error {
 	fs.IntVar(&o.githubWorkers, "github-workers", 0, "Number of github report workers (0 means disabled)")
 	fs.IntVar(&o.slackWorkers, "slack-workers", 0, "Number of Slack report workers (0 means disabled)")
 	fs.StringVar(&o.slackTokenFile, "slack-token-file", "", "Path to a Slack token file")
+	fs.IntVar(&o.webhookWorkers, "webhook-workers", 0, "Number of webhook report workers (0 means disabled)")
+	fs.StringVar(&o.webhookHMACSecretFile, "webhook-hmac-secret-file", "", "Path to a file with the secret used to sign webhook payloads, leave empty to not sign them")
+	fs.IntVar(&o.emailWorkers, "email-workers", 0, "Number of email report workers (0 means disabled)")
+	fs.StringVar(&o.emailSMTPServer, "email-smtp-server", "", "Address of the SMTP server to send emails through, as host:port")
+	fs.StringVar(&o.emailSMTPUsername, "email-smtp-username", "", "Username to authenticate to the SMTP server with, leave empty for no authentication")
+	fs.StringVar(&o.emailSMTPPasswordFile, "email-smtp-password-file", "", "Path to a file with the password to authenticate to the SMTP server with")
+	fs.StringVar(&o.emailDigestPath, "email-digest-path", "", "The /local/path, gs://path/to/object or s3://path/to/object to persist pending email digests in. Digests are disabled if unset.")
+	fs.StringVar(&o.gcsCredentialsFile, "gcs-credentials-file", "", "File where Google Cloud authentication credentials are stored. Required for GCS writes.")
+	fs.StringVar(&o.s3CredentialsFile, "s3-credentials-file", "", "File where S3 credentials are stored. Required for writes to s3:// paths on MinIO or other non-AWS endpoints.")
 	fs.StringVar(&o.reportAgent, "report-agent", "", "Only report specified agent - empty means report to all agents (effective for github and Slack only)")
 
 	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
 	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
 
 	// TODO(krzyzacy): implement dryrun for gerrit/pubsub
-	fs.BoolVar(&o.dryrun, "dry-run", false, "Run in dry-run mode, not doing actual report (effective for github and Slack only)")
+	fs.BoolVar(&o.dryrun, "dry-run", false, "Run in dry-run mode, not doing actual report (effective for github, Slack, webhooks and email only)")
 
 	o.github.AddFlags(fs)
 	o.client.AddFlags(fs)
@@ -199,6 +230,62 @@ func main() {
 				o.slackWorkers))
 	}
 
+	if o.webhookWorkers > 0 {
+		if cfg().WebhookReporter == nil {
+			logrus.Fatal("webhookreporter is enabled but has no config")
+		}
+		webhookConfig := func() *config.WebhookReporter {
+			return cfg().WebhookReporter
+		}
+		webhookReporter, err := webhookreporter.New(webhookConfig, o.dryrun, o.webhookHMACSecretFile)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create webhookreporter")
+		}
+		controllers = append(
+			controllers,
+			crier.NewController(
+				prowjobClientset,
+				kube.RateLimiter(webhookReporter.GetName()),
+				prowjobInformerFactory.Prow().V1().ProwJobs(),
+				webhookReporter,
+				o.webhookWorkers))
+	}
+
+	if o.emailWorkers > 0 {
+		if cfg().EmailReporter == nil {
+			logrus.Fatal("emailreporter is enabled but has no config")
+		}
+		emailConfig := func() *config.EmailReporter {
+			return cfg().EmailReporter
+		}
+		var opener io.Opener
+		if o.emailDigestPath != "" {
+			if opener, err = io.NewOpener(context.Background(), o.gcsCredentialsFile, o.s3CredentialsFile); err != nil {
+				logrus.WithError(err).Fatal("failed to create opener")
+			}
+		}
+		emailReporter, err := emailreporter.New(emailConfig, o.dryrun, o.emailSMTPServer, o.emailSMTPUsername, o.emailSMTPPasswordFile, opener, o.emailDigestPath)
+		if err != nil {
+			logrus.WithError(err).Fatal("failed to create emailreporter")
+		}
+		// Without a digest interval, pending digests from before it was
+		// removed from the config are sent within a minute.
+		interrupts.Tick(emailReporter.SendDigests, func() time.Duration {
+			if interval := emailConfig().DigestInterval; interval != nil {
+				return interval.Duration
+			}
+			return time.Minute
+		})
+		controllers = append(
+			controllers,
+			crier.NewController(
+				prowjobClientset,
+				kube.RateLimiter(emailReporter.GetName()),
+				prowjobInformerFactory.Prow().V1().ProwJobs(),
+				emailReporter,
+				o.emailWorkers))
+	}
+
 	if o.gerritWorkers > 0 {
 		informer := prowjobInformerFactory.Prow().V1().ProwJobs()
 		gerritReporter, err := gerritreporter.NewReporter(o.cookiefilePath, o.gerritProjects, informer.Lister())
@@ -125,6 +125,40 @@ func TestOptions(t *testing.T) {
 			name: "Dry run with no --deck-url, rejects",
 			args: []string{"--slack-workers=13", "--slack-token-file=/bar/baz", "--config-path=foo", "--dry-run"},
 		},
+		//Webhook Reporter
+		{
+			name: "webhook workers, sets workers",
+			args: []string{"--webhook-workers=3", "--webhook-hmac-secret-file=/etc/webhook/hmac", "--config-path=foo"},
+			expected: &options{
+				webhookWorkers:        3,
+				webhookHMACSecretFile: "/etc/webhook/hmac",
+				configPath:            "foo",
+				github:                defaultGitHubOptions,
+				gerritProjects:        defaultGerritProjects,
+			},
+		},
+		//Email Reporter
+		{
+			name: "email workers, sets workers",
+			args: []string{"--email-workers=2", "--email-smtp-server=smtp.example.com:587", "--email-smtp-username=prow", "--email-smtp-password-file=/etc/smtp/password", "--config-path=foo"},
+			expected: &options{
+				emailWorkers:          2,
+				emailSMTPServer:       "smtp.example.com:587",
+				emailSMTPUsername:     "prow",
+				emailSMTPPasswordFile: "/etc/smtp/password",
+				configPath:            "foo",
+				github:                defaultGitHubOptions,
+				gerritProjects:        defaultGerritProjects,
+			},
+		},
+		{
+			name: "email missing --email-smtp-server, rejects",
+			args: []string{"--email-workers=1", "--config-path=foo"},
+		},
+		{
+			name: "email username without password file, rejects",
+			args: []string{"--email-workers=1", "--email-smtp-server=smtp.example.com:587", "--email-smtp-username=prow", "--config-path=foo"},
+		},
 	}
 
 	for _, tc := range cases {
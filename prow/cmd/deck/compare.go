@@ -0,0 +1,444 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/pod-utils/clone"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	"k8s.io/test-infra/prow/spyglass/lenses"
+	junitlens "k8s.io/test-infra/prow/spyglass/lenses/junit"
+)
+
+const (
+	// compareLogLines is the number of lines of the tails of the build
+	// logs that are compared.
+	compareLogLines = 100
+	// maxDurationDeltas is the number of tests with the largest changes of
+	// duration that are listed.
+	maxDurationDeltas = 20
+	// minDurationDelta is the change of duration of a test below which it
+	// is not listed.
+	minDurationDelta = time.Second
+)
+
+var (
+	junitArtifactRe = regexp.MustCompile(`(^|/)junit.*\.xml$`)
+	digitsRe        = regexp.MustCompile(`\d`)
+)
+
+// runFetcher fetches the artifacts of job runs.
+type runFetcher interface {
+	ListArtifacts(src string) ([]string, error)
+	FetchArtifacts(src string, podName string, sizeLimit int64, artifactNames []string) ([]lenses.Artifact, error)
+	KeyToJob(src string) (jobName string, buildID string, err error)
+}
+
+// compareRow compares a value of two runs.
+type compareRow struct {
+	Key  string
+	A, B string
+}
+
+// Differs returns whether the value changed between the runs.
+func (r compareRow) Differs() bool {
+	return r.A != r.B
+}
+
+// testDiff compares the result of a test in two runs.
+type testDiff struct {
+	Name           string
+	A, B           string
+	DurationA      time.Duration
+	DurationB      time.Duration
+	DurationChange time.Duration
+}
+
+// logLine is a line of the tail of a build log, marked if it has no
+// counterpart in the other tail.
+type logLine struct {
+	Text   string
+	Unique bool
+}
+
+// runView is the view of one of the compared runs.
+type runView struct {
+	Src          string
+	BuildID      string
+	SpyglassLink string
+	LogTail      []logLine
+}
+
+// compareTemplate is the data of the compare page.
+type compareTemplate struct {
+	JobName        string
+	A, B           runView
+	Metadata       []compareRow
+	Refs           []compareRow
+	NewlyFailing   []testDiff
+	NewlyPassing   []testDiff
+	StillFailing   []testDiff
+	DurationDeltas []testDiff
+	TestsA, TestsB int
+}
+
+// compareLink returns the link to the comparison of two runs, by their
+// spyglass links.
+func compareLink(a, b string) string {
+	return "/compare?" + url.Values{
+		"a": []string{strings.TrimPrefix(a, "/view/")},
+		"b": []string{strings.TrimPrefix(b, "/view/")},
+	}.Encode()
+}
+
+// handleCompare handles requests to compare two runs of a job side by side.
+// The url must look like this:
+//
+// /compare?a=<src>&b=<src>
+//
+// where the sources are the ones of /view/, like gcs/<bucket>/logs/<job>/<build>.
+func handleCompare(o options, cfg config.Getter, rf runFetcher, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		srcA := strings.Trim(r.URL.Query().Get("a"), "/")
+		srcB := strings.Trim(r.URL.Query().Get("b"), "/")
+		if srcA == "" || srcB == "" {
+			http.Error(w, "The a and b query parameters are required.", http.StatusBadRequest)
+			return
+		}
+		tmpl, err := compareRuns(rf, cfg().Deck.Spyglass.SizeLimit, srcA, srcB)
+		if err != nil {
+			msg := fmt.Sprintf("failed to compare runs: %v", err)
+			log.WithField("url", r.URL).Info(msg)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		handleSimpleTemplate(o, cfg, "compare.html", tmpl)(w, r)
+	}
+}
+
+// runArtifacts holds the artifacts of a run that are compared.
+type runArtifacts struct {
+	started      gcs.Started
+	hasStarted   bool
+	finished     gcs.Finished
+	hasFinished  bool
+	cloneRecords []clone.Record
+	tests        map[string]junitlens.JunitResult
+	logTail      []string
+}
+
+// compareRuns compares two runs of the same job.
+func compareRuns(rf runFetcher, sizeLimit int64, srcA, srcB string) (compareTemplate, error) {
+	var tmpl compareTemplate
+	jobA, buildA, err := rf.KeyToJob(srcA)
+	if err != nil {
+		return tmpl, fmt.Errorf("invalid run %q: %v", srcA, err)
+	}
+	jobB, buildB, err := rf.KeyToJob(srcB)
+	if err != nil {
+		return tmpl, fmt.Errorf("invalid run %q: %v", srcB, err)
+	}
+	if jobA != jobB {
+		return tmpl, fmt.Errorf("runs of different jobs cannot be compared: %q and %q", jobA, jobB)
+	}
+	tmpl.JobName = jobA
+
+	type fetched struct {
+		run *runArtifacts
+		err error
+	}
+	chA, chB := make(chan fetched), make(chan fetched)
+	for _, f := range []struct {
+		src string
+		ch  chan fetched
+	}{{srcA, chA}, {srcB, chB}} {
+		go func(src string, ch chan fetched) {
+			run, err := fetchRun(rf, sizeLimit, src)
+			ch <- fetched{run, err}
+		}(f.src, f.ch)
+	}
+	a, b := <-chA, <-chB
+	if a.err != nil {
+		return tmpl, fmt.Errorf("failed to fetch run %q: %v", srcA, a.err)
+	}
+	if b.err != nil {
+		return tmpl, fmt.Errorf("failed to fetch run %q: %v", srcB, b.err)
+	}
+
+	tmpl.A = runView{Src: srcA, BuildID: buildA, SpyglassLink: path.Join("/view", srcA)}
+	tmpl.B = runView{Src: srcB, BuildID: buildB, SpyglassLink: path.Join("/view", srcB)}
+	tmpl.Metadata = compareMaps(a.run.metadata(), b.run.metadata())
+	tmpl.Refs = compareMaps(a.run.refs(), b.run.refs())
+	tmpl.A.LogTail, tmpl.B.LogTail = compareTails(a.run.logTail, b.run.logTail)
+	tmpl.TestsA, tmpl.TestsB = len(a.run.tests), len(b.run.tests)
+	tmpl.NewlyFailing, tmpl.NewlyPassing, tmpl.StillFailing, tmpl.DurationDeltas = compareTests(a.run.tests, b.run.tests)
+	return tmpl, nil
+}
+
+// fetchRun fetches and parses the artifacts of a run that are compared.
+func fetchRun(rf runFetcher, sizeLimit int64, src string) (*runArtifacts, error) {
+	names, err := rf.ListArtifacts(src)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts: %v", err)
+	}
+	var wanted []string
+	for _, name := range names {
+		switch {
+		case name == "started.json", name == "finished.json", name == "clone-records.json", name == "build-log.txt",
+			junitArtifactRe.MatchString(name):
+			wanted = append(wanted, name)
+		}
+	}
+	artifacts, err := rf.FetchArtifacts(src, "", sizeLimit, wanted)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching artifacts: %v", err)
+	}
+
+	run := &runArtifacts{tests: map[string]junitlens.JunitResult{}}
+	for _, artifact := range artifacts {
+		name := artifact.JobPath()
+		log := logrus.WithField("artifact", artifact.CanonicalLink())
+		switch {
+		case name == "started.json":
+			run.hasStarted = readArtifactJSON(artifact, &run.started, log)
+		case name == "finished.json":
+			run.hasFinished = readArtifactJSON(artifact, &run.finished, log)
+		case name == "clone-records.json":
+			readArtifactJSON(artifact, &run.cloneRecords, log)
+		case name == "build-log.txt":
+			if run.logTail, err = lenses.LastNLines(artifact, compareLogLines); err != nil {
+				log.WithError(err).Info("Error reading the build log.")
+			}
+		default:
+			addTestResults(run.tests, artifact)
+		}
+	}
+	return run, nil
+}
+
+// addTestResults adds the results of the tests of a junit artifact to tests.
+func addTestResults(tests map[string]junitlens.JunitResult, artifact lenses.Artifact) {
+	results, err := junitlens.Results(artifact)
+	if err != nil {
+		return
+	}
+	for _, result := range results {
+		// A test that runs more than once failed if any run did.
+		if previous, ok := tests[result.Name]; ok && previous.Failure != nil {
+			continue
+		}
+		tests[result.Name] = junitlens.JunitResult{Result: result}
+	}
+}
+
+func readArtifactJSON(artifact lenses.Artifact, v interface{}, log *logrus.Entry) bool {
+	content, err := artifact.ReadAll()
+	if err != nil {
+		log.WithError(err).Info("Error reading artifact.")
+		return false
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		log.WithError(err).Info("Error parsing artifact.")
+		return false
+	}
+	return true
+}
+
+// metadata flattens the started.json and finished.json of the run.
+func (run *runArtifacts) metadata() map[string]string {
+	m := map[string]string{}
+	if run.hasStarted {
+		flatten("started", run.started, m)
+		m["started/timestamp"] = time.Unix(run.started.Timestamp, 0).UTC().Format(time.RFC3339)
+	}
+	if run.hasFinished {
+		flatten("finished", run.finished, m)
+		if run.finished.Timestamp != nil {
+			m["finished/timestamp"] = time.Unix(*run.finished.Timestamp, 0).UTC().Format(time.RFC3339)
+			if run.hasStarted {
+				m["duration"] = (time.Duration(*run.finished.Timestamp-run.started.Timestamp) * time.Second).String()
+			}
+		}
+	}
+	return m
+}
+
+// refs flattens the refs that the run cloned.
+func (run *runArtifacts) refs() map[string]string {
+	m := map[string]string{}
+	for _, record := range run.cloneRecords {
+		repo := record.Refs.Org + "/" + record.Refs.Repo
+		m[repo+" base"] = record.Refs.BaseRef + "@" + record.Refs.BaseSHA
+		var pulls []string
+		for _, pull := range record.Refs.Pulls {
+			pulls = append(pulls, fmt.Sprintf("#%d@%s", pull.Number, pull.SHA))
+		}
+		if len(pulls) > 0 {
+			m[repo+" pulls"] = strings.Join(pulls, ", ")
+		}
+		if record.FinalSHA != "" {
+			m[repo+" final SHA"] = record.FinalSHA
+		}
+		if record.Failed {
+			m[repo+" clone"] = "failed"
+		}
+	}
+	return m
+}
+
+// flatten adds the fields of v, marshaled to JSON, to m under their paths.
+func flatten(prefix string, v interface{}, m map[string]string) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return
+	}
+	var walk func(key string, value interface{})
+	walk = func(key string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for k, child := range v {
+				walk(key+"/"+k, child)
+			}
+		case string:
+			m[key] = v
+		case nil:
+		default:
+			raw, _ := json.Marshal(v)
+			m[key] = string(raw)
+		}
+	}
+	walk(prefix, generic)
+}
+
+// compareMaps returns the rows of the keys of both maps, sorted by key.
+func compareMaps(a, b map[string]string) []compareRow {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	var rows []compareRow
+	for key := range keys {
+		rows = append(rows, compareRow{Key: key, A: a[key], B: b[key]})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+// compareTails marks the lines of each tail that have no counterpart in
+// the other, ignoring digits, which differ in timestamps and IDs.
+func compareTails(a, b []string) ([]logLine, []logLine) {
+	mark := func(lines, other []string) []logLine {
+		seen := map[string]bool{}
+		for _, line := range other {
+			seen[digitsRe.ReplaceAllString(line, "0")] = true
+		}
+		var res []logLine
+		for _, line := range lines {
+			res = append(res, logLine{Text: line, Unique: !seen[digitsRe.ReplaceAllString(line, "0")]})
+		}
+		return res
+	}
+	return mark(a, b), mark(b, a)
+}
+
+func testStatus(result junitlens.JunitResult, ok bool) string {
+	switch {
+	case !ok:
+		return "absent"
+	case result.Failure != nil:
+		return "failed"
+	case result.Skipped != nil:
+		return "skipped"
+	}
+	return "passed"
+}
+
+// compareTests returns the tests that fail in b but not in a, the ones that
+// failed in a but pass in b, the ones that fail in both, and the passing
+// tests whose duration changed the most.
+func compareTests(a, b map[string]junitlens.JunitResult) (newlyFailing, newlyPassing, stillFailing, durationDeltas []testDiff) {
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	names := map[string]bool{}
+	for name := range a {
+		names[name] = true
+	}
+	for name := range b {
+		names[name] = true
+	}
+	for name := range names {
+		resultA, okA := a[name]
+		resultB, okB := b[name]
+		diff := testDiff{
+			Name:      name,
+			A:         testStatus(resultA, okA),
+			B:         testStatus(resultB, okB),
+			DurationA: resultA.Duration(),
+			DurationB: resultB.Duration(),
+		}
+		diff.DurationChange = diff.DurationB - diff.DurationA
+		switch {
+		case diff.B == "failed" && diff.A == "failed":
+			stillFailing = append(stillFailing, diff)
+		case diff.B == "failed":
+			newlyFailing = append(newlyFailing, diff)
+		case diff.A == "failed" && diff.B == "passed":
+			newlyPassing = append(newlyPassing, diff)
+		case diff.A == "passed" && diff.B == "passed":
+			if abs(diff.DurationChange) >= minDurationDelta {
+				durationDeltas = append(durationDeltas, diff)
+			}
+		}
+	}
+	for _, diffs := range [][]testDiff{newlyFailing, newlyPassing, stillFailing} {
+		sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	}
+	sort.Slice(durationDeltas, func(i, j int) bool {
+		if abs(durationDeltas[i].DurationChange) != abs(durationDeltas[j].DurationChange) {
+			return abs(durationDeltas[i].DurationChange) > abs(durationDeltas[j].DurationChange)
+		}
+		return durationDeltas[i].Name < durationDeltas[j].Name
+	})
+	if len(durationDeltas) > maxDurationDeltas {
+		durationDeltas = durationDeltas[:maxDurationDeltas]
+	}
+	return newlyFailing, newlyPassing, stillFailing, durationDeltas
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+	junitlens "k8s.io/test-infra/prow/spyglass/lenses/junit"
+)
+
+type fakeArtifact struct {
+	path    string
+	content string
+}
+
+func (a *fakeArtifact) JobPath() string {
+	return a.path
+}
+
+func (a *fakeArtifact) CanonicalLink() string {
+	return "https://storage/" + a.path
+}
+
+func (a *fakeArtifact) Size() (int64, error) {
+	return int64(len(a.content)), nil
+}
+
+func (a *fakeArtifact) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(a.content)) {
+		return 0, io.EOF
+	}
+	n := copy(p, a.content[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (a *fakeArtifact) ReadAtMost(n int64) ([]byte, error) {
+	if n > int64(len(a.content)) {
+		n = int64(len(a.content))
+	}
+	return []byte(a.content[:n]), nil
+}
+
+func (a *fakeArtifact) ReadAll() ([]byte, error) {
+	return []byte(a.content), nil
+}
+
+func (a *fakeArtifact) ReadTail(n int64) ([]byte, error) {
+	return []byte(a.content[int64(len(a.content))-n:]), nil
+}
+
+// fakeRunFetcher serves runs keyed by source, whose last two path elements
+// are the job name and build ID.
+type fakeRunFetcher map[string]map[string]string
+
+func (f fakeRunFetcher) ListArtifacts(src string) ([]string, error) {
+	var names []string
+	for name := range f[src] {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f fakeRunFetcher) FetchArtifacts(src string, podName string, sizeLimit int64, artifactNames []string) ([]lenses.Artifact, error) {
+	var artifacts []lenses.Artifact
+	for _, name := range artifactNames {
+		artifacts = append(artifacts, &fakeArtifact{path: name, content: f[src][name]})
+	}
+	return artifacts, nil
+}
+
+func (f fakeRunFetcher) KeyToJob(src string) (string, string, error) {
+	if _, ok := f[src]; !ok {
+		return "", "", fmt.Errorf("unknown run %q", src)
+	}
+	return path.Base(path.Dir(src)), path.Base(src), nil
+}
+
+func TestCompareLink(t *testing.T) {
+	expected := "/compare?a=gcs%2Fbucket%2Flogs%2Fjob%2F1&b=gcs%2Fbucket%2Flogs%2Fjob%2F2"
+	if actual := compareLink("/view/gcs/bucket/logs/job/1", "/view/gcs/bucket/logs/job/2"); actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestCompareTests(t *testing.T) {
+	result := func(name string, seconds float64, status string) junitlens.JunitResult {
+		r := junit.Result{Name: name, Time: seconds}
+		switch status {
+		case "failed":
+			msg := "failed"
+			r.Failure = &msg
+		case "skipped":
+			msg := "skipped"
+			r.Skipped = &msg
+		}
+		return junitlens.JunitResult{Result: r}
+	}
+	results := func(rs ...junitlens.JunitResult) map[string]junitlens.JunitResult {
+		m := map[string]junitlens.JunitResult{}
+		for _, r := range rs {
+			m[r.Name] = r
+		}
+		return m
+	}
+	testCases := []struct {
+		name                 string
+		a, b                 map[string]junitlens.JunitResult
+		expectedNewlyFailing []string
+		expectedNewlyPassing []string
+		expectedStillFailing []string
+		expectedDeltas       []string
+	}{
+		{
+			name: "no change",
+			a:    results(result("a", 1, "passed"), result("b", 1, "skipped")),
+			b:    results(result("a", 1, "passed"), result("b", 1, "skipped")),
+		},
+		{
+			name:                 "changes of result",
+			a:                    results(result("a", 1, "passed"), result("b", 1, "failed"), result("c", 1, "failed"), result("d", 1, "failed")),
+			b:                    results(result("a", 1, "failed"), result("b", 1, "passed"), result("c", 1, "failed"), result("e", 1, "failed")),
+			expectedNewlyFailing: []string{"a", "e"},
+			expectedNewlyPassing: []string{"b"},
+			expectedStillFailing: []string{"c"},
+		},
+		{
+			name:                 "changes of duration",
+			a:                    results(result("a", 10, "passed"), result("b", 10, "passed"), result("c", 10, "passed"), result("d", 10, "failed")),
+			b:                    results(result("a", 20, "passed"), result("b", 10.2, "passed"), result("c", 5, "passed"), result("d", 100, "failed")),
+			expectedStillFailing: []string{"d"},
+			expectedDeltas:       []string{"a", "c"},
+		},
+	}
+	names := func(diffs []testDiff) []string {
+		var res []string
+		for _, diff := range diffs {
+			res = append(res, diff.Name)
+		}
+		return res
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			newlyFailing, newlyPassing, stillFailing, deltas := compareTests(tc.a, tc.b)
+			for _, check := range []struct {
+				kind     string
+				expected []string
+				actual   []testDiff
+			}{
+				{"newly failing", tc.expectedNewlyFailing, newlyFailing},
+				{"newly passing", tc.expectedNewlyPassing, newlyPassing},
+				{"still failing", tc.expectedStillFailing, stillFailing},
+				{"duration deltas", tc.expectedDeltas, deltas},
+			} {
+				if actual := names(check.actual); !reflect.DeepEqual(actual, check.expected) {
+					t.Errorf("Expected %s tests %v, got %v", check.kind, check.expected, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareTails(t *testing.T) {
+	a := []string{"I1203 12:00:01 starting", "ok", "FAIL: TestFoo"}
+	b := []string{"I1203 12:05:09 starting", "ok", "PASS"}
+	expectedA := []logLine{{Text: a[0]}, {Text: a[1]}, {Text: a[2], Unique: true}}
+	expectedB := []logLine{{Text: b[0]}, {Text: b[1]}, {Text: b[2], Unique: true}}
+	actualA, actualB := compareTails(a, b)
+	if !reflect.DeepEqual(actualA, expectedA) {
+		t.Errorf("Expected %+v, got %+v", expectedA, actualA)
+	}
+	if !reflect.DeepEqual(actualB, expectedB) {
+		t.Errorf("Expected %+v, got %+v", expectedB, actualB)
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	junitXML := func(failing bool) string {
+		failure := ""
+		if failing {
+			failure = "<failure>boom</failure>"
+		}
+		return `<testsuite><testcase name="TestFoo" time="1">` + failure + `</testcase><testcase name="TestBar" time="2"></testcase></testsuite>`
+	}
+	rf := fakeRunFetcher{
+		"gcs/bucket/logs/job/1": {
+			"started.json":         `{"timestamp":1575374400,"repos":{"org/repo":"master"}}`,
+			"finished.json":        `{"timestamp":1575374460,"passed":true,"result":"SUCCESS"}`,
+			"clone-records.json":   `[{"refs":{"org":"org","repo":"repo","base_ref":"master","base_sha":"abc"},"final_sha":"abc"}]`,
+			"build-log.txt":        "start\nok\n",
+			"artifacts/junit.xml":  junitXML(false),
+			"artifacts/other.json": "{}",
+		},
+		"gcs/bucket/logs/job/2": {
+			"started.json":        `{"timestamp":1575378000,"repos":{"org/repo":"master"}}`,
+			"finished.json":       `{"timestamp":1575378120,"passed":false,"result":"FAILURE"}`,
+			"clone-records.json":  `[{"refs":{"org":"org","repo":"repo","base_ref":"master","base_sha":"def"},"final_sha":"def"}]`,
+			"build-log.txt":       "start\nfailed\n",
+			"artifacts/junit.xml": junitXML(true),
+		},
+		"gcs/bucket/logs/other-job/3": {},
+	}
+
+	if _, err := compareRuns(rf, 0, "gcs/bucket/logs/job/1", "gcs/bucket/logs/other-job/3"); err == nil {
+		t.Error("Expected an error comparing runs of different jobs, got none")
+	}
+	if _, err := compareRuns(rf, 0, "gcs/bucket/logs/job/1", "gcs/bucket/logs/job/4"); err == nil {
+		t.Error("Expected an error comparing with an unknown run, got none")
+	}
+
+	tmpl, err := compareRuns(rf, 0, "gcs/bucket/logs/job/1", "gcs/bucket/logs/job/2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tmpl.JobName != "job" || tmpl.A.BuildID != "1" || tmpl.B.BuildID != "2" {
+		t.Errorf("Expected job #1 and #2, got %s #%s and #%s", tmpl.JobName, tmpl.A.BuildID, tmpl.B.BuildID)
+	}
+	if tmpl.B.SpyglassLink != "/view/gcs/bucket/logs/job/2" {
+		t.Errorf("Expected the spyglass link of run B, got %q", tmpl.B.SpyglassLink)
+	}
+
+	var differs []string
+	for _, row := range tmpl.Metadata {
+		if row.Differs() {
+			differs = append(differs, row.Key)
+		}
+	}
+	expectedDiffers := []string{"duration", "finished/passed", "finished/result", "finished/timestamp", "started/timestamp"}
+	if !reflect.DeepEqual(differs, expectedDiffers) {
+		t.Errorf("Expected metadata %v to differ, got %v", expectedDiffers, differs)
+	}
+	for _, row := range tmpl.Metadata {
+		if row.Key == "duration" && (row.A != time.Minute.String() || row.B != (2*time.Minute).String()) {
+			t.Errorf("Expected durations of 1m and 2m, got %s and %s", row.A, row.B)
+		}
+	}
+
+	expectedRefs := []compareRow{
+		{Key: "org/repo base", A: "master@abc", B: "master@def"},
+		{Key: "org/repo final SHA", A: "abc", B: "def"},
+	}
+	if !reflect.DeepEqual(tmpl.Refs, expectedRefs) {
+		t.Errorf("Expected refs %+v, got %+v", expectedRefs, tmpl.Refs)
+	}
+
+	if tmpl.TestsA != 2 || tmpl.TestsB != 2 {
+		t.Errorf("Expected 2 tests in both runs, got %d and %d", tmpl.TestsA, tmpl.TestsB)
+	}
+	if len(tmpl.NewlyFailing) != 1 || tmpl.NewlyFailing[0].Name != "TestFoo" {
+		t.Errorf("Expected TestFoo to be newly failing, got %+v", tmpl.NewlyFailing)
+	}
+
+	var unique []string
+	for _, line := range tmpl.B.LogTail {
+		if line.Unique {
+			unique = append(unique, line.Text)
+		}
+	}
+	if expected := []string{"failed"}; !reflect.DeepEqual(unique, expected) {
+		t.Errorf("Expected unique lines %v in the log of run B, got %v", expected, unique)
+	}
+}
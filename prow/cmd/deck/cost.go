@@ -0,0 +1,131 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/jobs"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+// maxCostJobs is the number of most expensive jobs shown on the cost page.
+const maxCostJobs = 50
+
+// costRow is a row of the cost tables. For the repository table, Job and
+// Type are empty.
+type costRow struct {
+	pjutil.JobCost
+}
+
+func hours(seconds float64) string {
+	return fmt.Sprintf("%.1f", seconds/time.Hour.Seconds())
+}
+
+// CPUCoreHours formats the requested CPU core hours for the cost page.
+func (r costRow) CPUCoreHours() string {
+	return hours(r.CPUCoreSeconds)
+}
+
+// MemoryGiBHours formats the requested memory GiB hours for the cost page.
+func (r costRow) MemoryGiBHours() string {
+	return hours(r.MemoryByteSeconds / (1 << 30))
+}
+
+// RunningHours formats the total running time for the cost page.
+func (r costRow) RunningHours() string {
+	return hours(r.RunningSeconds)
+}
+
+// MaxRunningHours formats the running time of the longest run for the cost page.
+func (r costRow) MaxRunningHours() string {
+	return hours(r.MaxRunningSeconds)
+}
+
+// costs is the data shown on the cost page.
+type costs struct {
+	Repos []costRow `json:"repos"`
+	Jobs  []costRow `json:"jobs"`
+}
+
+// getCosts aggregates the recorded reserved resources of the ProwJobs per repo
+// and per job, most expensive first.
+func getCosts(pjs []prowapi.ProwJob) costs {
+	jobCosts := pjutil.AggregateCosts(pjs)
+	byRepo := map[string]*pjutil.JobCost{}
+	for _, cost := range jobCosts {
+		key := cost.Org + "/" + cost.Repo
+		rc, ok := byRepo[key]
+		if !ok {
+			rc = &pjutil.JobCost{Org: cost.Org, Repo: cost.Repo}
+			byRepo[key] = rc
+		}
+		rc.Runs += cost.Runs
+		rc.CPUCoreSeconds += cost.CPUCoreSeconds
+		rc.MemoryByteSeconds += cost.MemoryByteSeconds
+		rc.RunningSeconds += cost.RunningSeconds
+		if cost.MaxRunningSeconds > rc.MaxRunningSeconds {
+			rc.MaxRunningSeconds = cost.MaxRunningSeconds
+		}
+	}
+	c := costs{Repos: []costRow{}, Jobs: []costRow{}}
+	for _, rc := range byRepo {
+		c.Repos = append(c.Repos, costRow{*rc})
+	}
+	sort.Slice(c.Repos, func(i, j int) bool {
+		if c.Repos[i].CPUCoreSeconds != c.Repos[j].CPUCoreSeconds {
+			return c.Repos[i].CPUCoreSeconds > c.Repos[j].CPUCoreSeconds
+		}
+		return c.Repos[i].Org+"/"+c.Repos[i].Repo < c.Repos[j].Org+"/"+c.Repos[j].Repo
+	})
+	for i, cost := range jobCosts {
+		if i == maxCostJobs {
+			break
+		}
+		c.Jobs = append(c.Jobs, costRow{cost})
+	}
+	return c
+}
+
+// handleCost renders the cost page.
+func handleCost(o options, cfg config.Getter, ja *jobs.JobAgent) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		handleSimpleTemplate(o, cfg, "cost.html", getCosts(ja.ProwJobs()))(w, r)
+	}
+}
+
+// handleCostJSON serves the data of the cost page.
+func handleCostJSON(ja *jobs.JobAgent, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		cd, err := json.Marshal(getCosts(ja.ProwJobs()))
+		if err != nil {
+			log.WithError(err).Error("Error marshaling costs.")
+			cd = []byte("{}")
+		}
+		writeJSONResponse(w, r, cd)
+	}
+}
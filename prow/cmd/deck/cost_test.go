@@ -0,0 +1,71 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestGetCosts(t *testing.T) {
+	newJob := func(job, repo string, running time.Duration) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{Job: job, Type: prowapi.PresubmitJob, Refs: &prowapi.Refs{Org: "org", Repo: repo}},
+			Status: prowapi.ProwJobStatus{
+				ReservedResources: &prowapi.ReservedResources{
+					Requests: coreapi.ResourceList{
+						coreapi.ResourceCPU:    resource.MustParse("2"),
+						coreapi.ResourceMemory: resource.MustParse("4Gi"),
+					},
+					RunningDuration: metav1.Duration{Duration: running},
+				},
+			},
+		}
+	}
+	c := getCosts([]prowapi.ProwJob{
+		newJob("unit", "small", 30*time.Minute),
+		newJob("unit", "big", time.Hour),
+		newJob("e2e", "big", 2*time.Hour),
+		newJob("e2e", "big", 3*time.Hour),
+	})
+
+	if len(c.Repos) != 2 {
+		t.Fatalf("expected 2 repos, got %+v", c.Repos)
+	}
+	big := c.Repos[0]
+	if big.Repo != "big" || big.Runs != 3 || big.CPUCoreHours() != "12.0" || big.MemoryGiBHours() != "24.0" || big.RunningHours() != "6.0" || big.MaxRunningHours() != "3.0" {
+		t.Errorf("unexpected cost of the biggest repo: %+v", big)
+	}
+	if small := c.Repos[1]; small.Repo != "small" || small.CPUCoreHours() != "1.0" {
+		t.Errorf("unexpected cost of the smallest repo: %+v", small)
+	}
+
+	var jobs []string
+	for _, job := range c.Jobs {
+		jobs = append(jobs, job.Repo+"/"+job.Job)
+	}
+	if expected := []string{"big/e2e", "big/unit", "small/unit"}; !reflect.DeepEqual(jobs, expected) {
+		t.Errorf("expected jobs %v, got %v", expected, jobs)
+	}
+}
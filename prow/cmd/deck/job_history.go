@@ -58,6 +58,7 @@ type buildData struct {
 	jobName      string
 	prefix       string
 	SpyglassLink string
+	CompareLink  string
 	ID           string
 	Started      time.Time
 	Duration     time.Duration
@@ -80,13 +81,14 @@ type gcsBucket struct {
 }
 
 type jobHistoryTemplate struct {
-	OlderLink    string
-	NewerLink    string
-	LatestLink   string
-	Name         string
-	ResultsShown int
-	ResultsTotal int
-	Builds       []buildData
+	OlderLink       string
+	NewerLink       string
+	LatestLink      string
+	TestHistoryLink string
+	Name            string
+	ResultsShown    int
+	ResultsTotal    int
+	Builds          []buildData
 }
 
 func (bucket gcsBucket) readObject(key string) ([]byte, error) {
@@ -239,22 +241,32 @@ func (bucket gcsBucket) listBuildIDs(root string) ([]int64, error) {
 	return ids, nil
 }
 
-func parseJobHistURL(url *url.URL) (bucketName, root string, buildID int64, err error) {
-	buildID = emptyID
-	p := strings.TrimPrefix(url.Path, "/job-history/")
+// parseGCSJobPath splits the path of a url under handlerPrefix, like
+// /job-history/<gcs-path>, into the GCS bucket and the root of the job.
+func parseGCSJobPath(urlPath, handlerPrefix string) (bucketName, root string, err error) {
+	p := strings.TrimPrefix(urlPath, handlerPrefix)
 	s := strings.SplitN(p, "/", 2)
 	if len(s) < 2 {
-		err = fmt.Errorf("invalid path (expected /job-history/<gcs-path>): %v", url.Path)
+		err = fmt.Errorf("invalid path (expected %s<gcs-path>): %v", handlerPrefix, urlPath)
 		return
 	}
 	bucketName = s[0]
 	root = s[1] // `root` is the root GCS "directory" prefix for this job's results
 	if bucketName == "" {
-		err = fmt.Errorf("missing GCS bucket name: %v", url.Path)
+		err = fmt.Errorf("missing GCS bucket name: %v", urlPath)
 		return
 	}
 	if root == "" {
-		err = fmt.Errorf("invalid GCS path for job: %v", url.Path)
+		err = fmt.Errorf("invalid GCS path for job: %v", urlPath)
+		return
+	}
+	return
+}
+
+func parseJobHistURL(url *url.URL) (bucketName, root string, buildID int64, err error) {
+	buildID = emptyID
+	bucketName, root, err = parseGCSJobPath(url.Path, "/job-history/")
+	if err != nil {
 		return
 	}
 
@@ -357,6 +369,7 @@ func getJobHistory(url *url.URL, config *config.Config, gcsClient *storage.Clien
 		return tmpl, fmt.Errorf("invalid url %s: %v", url.String(), err)
 	}
 	tmpl.Name = root
+	tmpl.TestHistoryLink = path.Join("/test-history", bucketName, root)
 	bucket := gcsBucket{bucketName, gcsClient.Bucket(bucketName)}
 
 	latest, err := readLatestBuild(bucket, root)
@@ -425,6 +438,11 @@ func getJobHistory(url *url.URL, config *config.Config, gcsClient *storage.Clien
 		b := <-bch
 		tmpl.Builds[b.index] = b
 	}
+	for i := 0; i+1 < len(tmpl.Builds); i++ {
+		if newer, older := tmpl.Builds[i].SpyglassLink, tmpl.Builds[i+1].SpyglassLink; newer != "" && older != "" {
+			tmpl.Builds[i].CompareLink = compareLink(older, newer)
+		}
+	}
 
 	elapsed := time.Now().Sub(start)
 	logrus.Infof("loaded %s in %v", url.Path, elapsed)
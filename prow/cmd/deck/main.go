@@ -26,6 +26,7 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -56,6 +57,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/yaml"
 
+	pkgio "k8s.io/test-infra/pkg/io"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	prowv1 "k8s.io/test-infra/prow/client/clientset/versioned/typed/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
@@ -74,12 +76,15 @@ import (
 	"k8s.io/test-infra/prow/plugins/trigger"
 	"k8s.io/test-infra/prow/prstatus"
 	"k8s.io/test-infra/prow/spyglass"
+	"k8s.io/test-infra/prow/tide/history"
 
 	// Import standard spyglass viewers
 
 	"k8s.io/test-infra/prow/spyglass/lenses"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/buildlog"
+	_ "k8s.io/test-infra/prow/spyglass/lenses/clusterdump"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/coverage"
+	_ "k8s.io/test-infra/prow/spyglass/lenses/jsonlog"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/junit"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/metadata"
 	_ "k8s.io/test-infra/prow/spyglass/lenses/restcoverage"
@@ -117,6 +122,8 @@ type options struct {
 	spyglass              bool
 	spyglassFilesLocation string
 	gcsCredentialsFile    string
+	s3CredentialsFile     string
+	localArtifactRoots    prowflagutil.Strings
 	rerunCreatesJob       bool
 	allowInsecure         bool
 	dryRun                bool
@@ -181,6 +188,8 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.staticFilesLocation, "static-files-location", "/static", "Path to the static files")
 	fs.StringVar(&o.templateFilesLocation, "template-files-location", "/template", "Path to the template files")
 	fs.StringVar(&o.gcsCredentialsFile, "gcs-credentials-file", "", "Path to the GCS credentials file")
+	fs.StringVar(&o.s3CredentialsFile, "s3-credentials-file", "", "Path to the S3 credentials file, used by spyglass for artifacts in s3:// buckets")
+	fs.Var(&o.localArtifactRoots, "spyglass-local-artifact-root", "Directory that spyglass may serve artifacts of jobs storing them on the local filesystem from. Can be passed multiple times. Local artifacts are not served without any.")
 	fs.BoolVar(&o.rerunCreatesJob, "rerun-creates-job", false, "Change the re-run option in Deck to actually create the job. **WARNING:** Only use this with non-public deck instances, otherwise strangers can DOS your Prow instance")
 	fs.BoolVar(&o.allowInsecure, "allow-insecure", false, "Allows insecure requests for CSRF and GitHub oauth.")
 	fs.BoolVar(&o.dryRun, "dry-run", false, "Whether or not to make mutating API calls to GitHub.")
@@ -261,6 +270,8 @@ var simplifier = simplifypath.NewSimplifier(l("", // shadow element mimicing the
 	l("badge.svg"),
 	l("command-help"),
 	l("config"),
+	l("cost"),
+	l("cost.js"),
 	l("data.js"),
 	l("favicon.ico"),
 	l("github-login",
@@ -268,6 +279,7 @@ var simplifier = simplifypath.NewSimplifier(l("", // shadow element mimicing the
 	l("job-history",
 		v("job")),
 	l("log"),
+	l("missed-periodics.js"),
 	l("plugin-config"),
 	l("plugin-help"),
 	l("plugins"),
@@ -286,6 +298,10 @@ var simplifier = simplifypath.NewSimplifier(l("", // shadow element mimicing the
 		)),
 	l("static",
 		v("path")),
+	l("test-history",
+		v("job")),
+	l("test-history.js",
+		v("job")),
 	l("tide"),
 	l("tide-history"),
 	l("tide-history.js"),
@@ -573,6 +589,9 @@ func prodOnlyMain(cfg config.Getter, pluginAgent *plugins.ConfigAgent, o options
 	// setup prod only handlers
 	mux.Handle("/data.js", gziphandler.GzipHandler(handleData(ja, logrus.WithField("handler", "/data.js"))))
 	mux.Handle("/prowjobs.js", gziphandler.GzipHandler(handleProwJobs(ja, logrus.WithField("handler", "/prowjobs.js"))))
+	mux.Handle("/cost", gziphandler.GzipHandler(handleCost(o, cfg, ja)))
+	mux.Handle("/cost.js", gziphandler.GzipHandler(handleCostJSON(ja, logrus.WithField("handler", "/cost.js"))))
+	mux.Handle("/missed-periodics.js", gziphandler.GzipHandler(handleMissedPeriodics(ja, cfg, logrus.WithField("handler", "/missed-periodics.js"))))
 	mux.Handle("/badge.svg", gziphandler.GzipHandler(handleBadge(ja)))
 	mux.Handle("/log", gziphandler.GzipHandler(handleLog(ja, logrus.WithField("handler", "/log"))))
 
@@ -722,13 +741,23 @@ func initSpyglass(cfg config.Getter, o options, mux *http.ServeMux, ja *jobs.Job
 	if err != nil {
 		logrus.WithError(err).Fatal("Error getting GCS client")
 	}
-	sg := spyglass.New(ja, cfg, c, o.gcsCredentialsFile, context.Background())
+	opener, err := pkgio.NewStorage(context.Background(), o.gcsCredentialsFile, o.s3CredentialsFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating opener")
+	}
+	sg := spyglass.New(ja, cfg, c, opener, o.localArtifactRoots.Strings(), o.gcsCredentialsFile, context.Background())
 	sg.Start()
 
 	mux.Handle("/spyglass/static/", http.StripPrefix("/spyglass/static", staticHandlerFromDir(o.spyglassFilesLocation)))
 	mux.Handle("/spyglass/lens/", gziphandler.GzipHandler(http.StripPrefix("/spyglass/lens/", handleArtifactView(o, sg, cfg))))
+	mux.Handle(spyglass.LocalArtifactPath, gziphandler.GzipHandler(handleLocalArtifact(sg, logrus.WithField("handler", spyglass.LocalArtifactPath))))
 	mux.Handle("/view/", gziphandler.GzipHandler(handleRequestJobViews(sg, cfg, o, logrus.WithField("handler", "/view"))))
+	mux.Handle("/compare", gziphandler.GzipHandler(handleCompare(o, cfg, sg, logrus.WithField("handler", "/compare"))))
+	mux.Handle("/spyglass/search", gziphandler.GzipHandler(handleSpyglassSearch(sg, logrus.WithField("handler", "/spyglass/search"))))
 	mux.Handle("/job-history/", gziphandler.GzipHandler(handleJobHistory(o, cfg, c, logrus.WithField("handler", "/job-history"))))
+	testHistories := newTestHistoryCache()
+	mux.Handle("/test-history/", gziphandler.GzipHandler(handleTestHistory(o, cfg, c, sg, testHistories, logrus.WithField("handler", "/test-history"))))
+	mux.Handle("/test-history.js/", gziphandler.GzipHandler(handleTestHistoryJSON(cfg, c, sg, testHistories, logrus.WithField("handler", "/test-history.js"))))
 	mux.Handle("/pr-history/", gziphandler.GzipHandler(handlePRHistory(o, cfg, c, gitHubClient, gitClient, logrus.WithField("handler", "/pr-history"))))
 }
 
@@ -1004,9 +1033,10 @@ lensesLoop:
 
 	jobHistLink := ""
 	jobPath, err := sg.JobPath(src)
-	if err == nil {
+	if err == nil && jobPath != "" {
 		jobHistLink = path.Join("/job-history", jobPath)
 	}
+	inGCS := sg.InGCS(src)
 
 	var prowJobLink string
 	prowJobName, err := sg.ProwJobName(src)
@@ -1027,7 +1057,7 @@ lensesLoop:
 
 	artifactsLink := ""
 	gcswebPrefix := cfg().Deck.Spyglass.GCSBrowserPrefix
-	if gcswebPrefix != "" {
+	if gcswebPrefix != "" && inGCS {
 		runPath, err := sg.RunPath(src)
 		if err == nil {
 			artifactsLink = gcswebPrefix + runPath
@@ -1040,7 +1070,7 @@ lensesLoop:
 
 	prHistLink := ""
 	org, repo, number, err := sg.RunToPR(src)
-	if err == nil {
+	if err == nil && inGCS {
 		prHistLink = "/pr-history?org=" + org + "&repo=" + repo + "&pr=" + strconv.Itoa(number)
 	}
 
@@ -1222,6 +1252,36 @@ func handleArtifactView(o options, sg *spyglass.Spyglass, cfg config.Getter) htt
 	}
 }
 
+// handleSpyglassSearch handles requests to search the artifacts of a job for
+// a string. It returns the matching lines of the artifacts as JSON.
+// Query params:
+// - src: required, specifies the job source from which to fetch artifacts
+// - q: required, specifies the string to search for, ignoring case
+func handleSpyglassSearch(sg *spyglass.Spyglass, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		src := strings.TrimSuffix(r.URL.Query().Get("src"), "/")
+		query := r.URL.Query().Get("q")
+		if src == "" || strings.TrimSpace(query) == "" {
+			http.Error(w, "The src and q query parameters are required.", http.StatusBadRequest)
+			return
+		}
+
+		result, err := sg.Search(src, query)
+		if err != nil {
+			log.WithError(err).WithField("source", src).Error("Error searching artifacts.")
+			http.Error(w, fmt.Sprintf("Error searching artifacts: %v", err), http.StatusInternalServerError)
+			return
+		}
+		pd, err := json.Marshal(result)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling payload.")
+			pd = []byte("{}")
+		}
+		writeJSONResponse(w, r, pd)
+	}
+}
+
 func handleTidePools(cfg config.Getter, ta *tideAgent, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
@@ -1238,6 +1298,7 @@ func handleTidePools(cfg config.Getter, ta *tideAgent, log *logrus.Entry) http.H
 		payload := tidePools{
 			Queries:     queries,
 			TideQueries: queryConfigs,
+			Priorities:  cfg().Tide.Priority,
 			Pools:       pools,
 		}
 		pd, err := json.Marshal(payload)
@@ -1253,12 +1314,24 @@ func handleTideHistory(ta *tideAgent, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
 
-		ta.Lock()
-		history := ta.history
-		ta.Unlock()
+		q, err := history.ParseQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var hist map[string][]history.Record
+		if q.IsEmpty() {
+			ta.Lock()
+			hist = ta.history
+			ta.Unlock()
+		} else if hist, err = ta.queryHistory(q); err != nil {
+			log.WithError(err).Error("Error querying Tide history.")
+			http.Error(w, "Error querying Tide history.", http.StatusBadGateway)
+			return
+		}
 
 		payload := tideHistory{
-			History: history,
+			History: hist,
 		}
 		pd, err := json.Marshal(payload)
 		if err != nil {
@@ -1291,6 +1364,34 @@ type logClient interface {
 }
 
 // TODO(spxtr): Cache, rate limit.
+// localArtifactOpener opens artifacts stored on the local filesystem
+type localArtifactOpener interface {
+	LocalArtifact(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// handleLocalArtifact serves artifacts of jobs storing them on the local
+// filesystem, from under the local artifact roots only. They are served as
+// plain text so that no artifact is rendered as a page of deck.
+func handleLocalArtifact(lao localArtifactOpener, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		name := strings.TrimPrefix(r.URL.Path, spyglass.LocalArtifactPath)
+		logger := log.WithField("artifact", name)
+		rc, err := lao.LocalArtifact(r.Context(), name)
+		if err != nil {
+			logger.WithError(err).Info("Failed to open local artifact.")
+			http.Error(w, "Artifact not found.", http.StatusNotFound)
+			return
+		}
+		defer pkgio.LogClose(rc)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := io.Copy(w, rc); err != nil {
+			logger.WithError(err).Warning("Error writing local artifact.")
+		}
+	}
+}
+
 func handleLog(lc logClient, log *logrus.Entry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		setHeadersNoCaching(w)
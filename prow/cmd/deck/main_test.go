@@ -31,6 +31,7 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -215,6 +216,66 @@ func TestHandleLog(t *testing.T) {
 
 // TestProwJob just checks that the result can be unmarshaled properly, has
 // the same status, and has equal spec.
+type fakeLocalArtifactOpener map[string]string
+
+func (o fakeLocalArtifactOpener) LocalArtifact(_ context.Context, name string) (io.ReadCloser, error) {
+	content, ok := o[name]
+	if !ok {
+		return nil, errors.New("not under any of the local artifact roots")
+	}
+	return ioutil.NopCloser(bytes.NewBufferString(content)), nil
+}
+
+func TestHandleLocalArtifact(t *testing.T) {
+	var testcases = []struct {
+		name         string
+		path         string
+		code         int
+		expectedBody string
+	}{
+		{
+			name:         "artifact is served as plain text",
+			path:         "/spyglass/local/srv/logs/logs/job/1/index.html",
+			code:         http.StatusOK,
+			expectedBody: "<script>alert(1)</script>",
+		},
+		{
+			name: "artifact that cannot be opened",
+			path: "/spyglass/local/etc/secret/token",
+			code: http.StatusNotFound,
+		},
+	}
+	opener := fakeLocalArtifactOpener{
+		"srv/logs/logs/job/1/index.html": "<script>alert(1)</script>",
+	}
+	handler := handleLocalArtifact(opener, logrus.WithField("handler", "/spyglass/local/"))
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatalf("Error making request: %v", err)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.code {
+				t.Fatalf("Wrong status code. Got %v, want %v", rr.Code, tc.code)
+			}
+			if rr.Code != http.StatusOK {
+				if strings.Contains(rr.Body.String(), "local artifact roots") {
+					t.Errorf("Expected the error not to be rendered, got %q", rr.Body.String())
+				}
+				return
+			}
+			if rr.Body.String() != tc.expectedBody {
+				t.Errorf("Wrong body. Got %q, want %q", rr.Body.String(), tc.expectedBody)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("Wrong content type. Got %q, want plain text", ct)
+			}
+		})
+	}
+}
+
 func TestProwJob(t *testing.T) {
 	fakeProwJobClient := fake.NewSimpleClientset(&prowapi.ProwJob{
 		ObjectMeta: metav1.ObjectMeta{
@@ -550,7 +611,9 @@ func TestTideHistory(t *testing.T) {
 			{Action: "MERGE"}, {Action: "TRIGGER"},
 		},
 	}
+	var query url.Values
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
 		b, err := json.Marshal(testHist)
 		if err != nil {
 			t.Fatalf("Marshaling: %v", err)
@@ -595,6 +658,21 @@ func TestTideHistory(t *testing.T) {
 	if !reflect.DeepEqual(res.History, testHist) {
 		t.Fatalf("Expected /tide-history.js:\n%#v\n,but got:\n%#v\n", testHist, res.History)
 	}
+
+	// Queries are forwarded to Tide instead of served from the cache.
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tide-history.js?pull=1&from=2019-12-03", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Bad error code for a query: %d", rr.Code)
+	}
+	if expected := (url.Values{"pull": {"1"}, "from": {"2019-12-03T00:00:00Z"}}); !reflect.DeepEqual(query, expected) {
+		t.Errorf("Expected the query %v to be forwarded to Tide, got %v", expected, query)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/tide-history.js?pull=one", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid query to be rejected, got status %d", rr.Code)
+	}
 }
 
 func TestHelp(t *testing.T) {
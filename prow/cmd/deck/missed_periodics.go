@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/deck/jobs"
+	"k8s.io/test-infra/prow/pjutil"
+)
+
+// missedPeriodic is a cron periodic that missed more runs than its
+// missed_runs_threshold allows.
+type missedPeriodic struct {
+	Job        string    `json:"job"`
+	MissedRuns int       `json:"missed_runs"`
+	Since      time.Time `json:"since"`
+}
+
+// findMissedPeriodics returns the periodics that missed too many runs, sorted
+// by job name.
+func findMissedPeriodics(periodics []config.Periodic, pjs []prowapi.ProwJob, now time.Time) []missedPeriodic {
+	latestJobs := pjutil.GetLatestProwJobs(pjs, prowapi.PeriodicJob)
+	missedPeriodics := []missedPeriodic{}
+	for _, p := range periodics {
+		if p.MissedRunsThreshold == nil {
+			continue
+		}
+		latest, previousFound := latestJobs[p.Name]
+		missed, err := pjutil.MissedRuns(p, latest, previousFound, now)
+		if err != nil {
+			logrus.WithError(err).WithField("job", p.Name).Warn("Cannot determine missed runs.")
+			continue
+		}
+		if len(missed) > *p.MissedRunsThreshold {
+			missedPeriodics = append(missedPeriodics, missedPeriodic{
+				Job:        p.Name,
+				MissedRuns: len(missed),
+				Since:      missed[0],
+			})
+		}
+	}
+	sort.Slice(missedPeriodics, func(i, j int) bool { return missedPeriodics[i].Job < missedPeriodics[j].Job })
+	return missedPeriodics
+}
+
+func handleMissedPeriodics(ja *jobs.JobAgent, cfg config.Getter, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		missed := findMissedPeriodics(cfg().Periodics, ja.ProwJobs(), time.Now())
+		md, err := json.Marshal(missed)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling missed periodics.")
+			md = []byte("[]")
+		}
+		writeJSONResponse(w, r, md)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/kube"
+)
+
+func TestFindMissedPeriodics(t *testing.T) {
+	now := time.Date(2019, 11, 6, 12, 0, 0, 0, time.UTC)
+	zero, two := 0, 2
+	periodic := func(name string, threshold *int) config.Periodic {
+		return config.Periodic{JobBase: config.JobBase{Name: name}, Cron: "0 3 * * *", MissedRunsThreshold: threshold}
+	}
+	job := func(name string, started time.Time, annotations map[string]string) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+			Spec:       prowapi.ProwJobSpec{Type: prowapi.PeriodicJob, Job: name},
+			Status:     prowapi.ProwJobStatus{StartTime: metav1.NewTime(started)},
+		}
+	}
+	fourDaysAgo := now.Add(-4 * 24 * time.Hour)
+
+	testcases := []struct {
+		name      string
+		periodics []config.Periodic
+		pjs       []prowapi.ProwJob
+		expected  []missedPeriodic
+	}{
+		{
+			name:      "job without threshold is never reported",
+			periodics: []config.Periodic{periodic("nightly", nil)},
+			pjs:       []prowapi.ProwJob{job("nightly", fourDaysAgo, nil)},
+			expected:  []missedPeriodic{},
+		},
+		{
+			name:      "job that ran recently is not reported",
+			periodics: []config.Periodic{periodic("nightly", &zero)},
+			pjs:       []prowapi.ProwJob{job("nightly", now.Add(-time.Hour), nil)},
+			expected:  []missedPeriodic{},
+		},
+		{
+			name:      "job that never ran is not reported",
+			periodics: []config.Periodic{periodic("nightly", &zero)},
+			expected:  []missedPeriodic{},
+		},
+		{
+			name:      "job that missed more runs than its threshold is reported",
+			periodics: []config.Periodic{periodic("nightly", &zero), periodic("weekly", &two)},
+			pjs:       []prowapi.ProwJob{job("nightly", fourDaysAgo, nil), job("weekly", now.Add(-2*24*time.Hour), nil)},
+			expected: []missedPeriodic{{
+				Job:        "nightly",
+				MissedRuns: 4,
+				Since:      time.Date(2019, 11, 3, 3, 0, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name:      "catch-up run counts from its scheduled time",
+			periodics: []config.Periodic{periodic("nightly", &zero)},
+			pjs: []prowapi.ProwJob{job("nightly", now.Add(-time.Hour), map[string]string{
+				kube.ScheduledTimeAnnotation: "2019-11-04T03:00:00Z",
+			})},
+			expected: []missedPeriodic{{
+				Job:        "nightly",
+				MissedRuns: 2,
+				Since:      time.Date(2019, 11, 5, 3, 0, 0, 0, time.UTC),
+			}},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := findMissedPeriodics(tc.periodics, tc.pjs, now); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, actual)
+			}
+		})
+	}
+}
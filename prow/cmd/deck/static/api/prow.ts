@@ -1,5 +1,5 @@
 export type ProwJobType = "presubmit" | "postsubmit" | "batch" | "periodic";
-export type ProwJobState = "triggered" | "pending" | "success" | "failure" | "aborted" | "error" | "unknown" | "";
+export type ProwJobState = "triggered" | "waiting" | "pending" | "success" | "failure" | "aborted" | "error" | "unknown" | "";
 export type ProwJobAgent = "kubernetes" | "jenkins" | "tekton-pipeline";
 
 // Pull describes a pull request at a particular point in time.
@@ -99,6 +99,8 @@ export interface ProwJobSpec {
   rerun_command?: string;
   max_concurrency?: number;
   error_on_eviction?: boolean;
+  depends_on?: string[];
+  retry_policy?: object;
   pod_spec?: object;
   build_spec?: object;
   jenkins_spec?: object;
@@ -121,4 +123,24 @@ export interface ProwJobStatus {
   build_id?: string;
   jenkins_build_id?: string;
   prev_report_states?: { [key: string]: ProwJobState };
+  attempts?: Attempt[];
+}
+
+// Attempt describes a previous attempt to run a ProwJob.
+// Attempt mirrors the Attempt struct defined in prow/apis/prowjobs/v1/types.go.
+export interface Attempt {
+  pod_name?: string;
+  build_id?: string;
+  startTime?: string;
+  completionTime?: string;
+  failure_class?: string;
+  description?: string;
+}
+
+// MissedPeriodic is a cron periodic that missed more runs than its threshold allows.
+// MissedPeriodic mirrors the missedPeriodic struct defined in prow/cmd/deck/missed_periodics.go.
+export interface MissedPeriodic {
+  job: string;
+  missed_runs: number;
+  since: string;
 }
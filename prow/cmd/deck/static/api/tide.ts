@@ -12,6 +12,10 @@ export interface TideQuery {
   reviewApprovedRequired?: boolean;
 }
 
+export interface TidePriority {
+  labels?: string[];
+}
+
 export interface PullRequest extends BasePullRequest {
   Title: string;
   HeadRefName: string;
@@ -30,7 +34,7 @@ export interface PullRequest extends BasePullRequest {
   };
 }
 
-export type Action = "WAIT" | "TRIGGER" | "TRIGGER_BATCH" | "MERGE" | "MERGE_BATCH" | "BLOCKED";
+export type Action = "WAIT" | "TRIGGER" | "TRIGGER_BATCH" | "TRIGGER_BISECTION" | "TRIGGER_TRAIN_CAR" | "MERGE" | "MERGE_BATCH" | "BLOCKED" | "CLOSED";
 
 export interface Blocker {
   Number: number;
@@ -43,19 +47,27 @@ export interface TidePool {
   Repo: string;
   Branch: string;
 
+  GerritInstance?: string;
+
   SuccessPRs: PullRequest[];
   PendingPRs: PullRequest[];
   MissingPRs: PullRequest[];
 
   BatchPending: PullRequest[];
 
+  Priorities?: {[num: number]: number};
+
   Action: Action;
   Target: PullRequest[];
   Blockers: Blocker[];
+
+  MergeWindowClosed?: boolean;
+  MergeWindowOpens?: string;
 }
 
 export interface TideData {
   Queries: string[];
   TideQueries: TideQuery[];
+  Priorities?: TidePriority[];
   Pools: TidePool[];
 }
@@ -56,6 +56,9 @@ export namespace cell {
       case "triggered":
         displayIcon = "schedule";
         break;
+      case "waiting":
+        displayIcon = "hourglass_empty";
+        break;
       case "pending":
         displayIcon = "watch_later";
         break;
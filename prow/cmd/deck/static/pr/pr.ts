@@ -570,6 +570,8 @@ function createContextList(contexts: UnifiedContext[], itemStyle: string[] = [])
                 return "watch_later";
             case "triggered":
                 return "schedule";
+            case "waiting":
+                return "hourglass_empty";
             case "aborted":
                 return "remove_circle";
             case "error":
@@ -1036,7 +1038,7 @@ function compareJobFn(a: UnifiedContext, b: UnifiedContext): number {
     const stateToPrio: {[key: string]: number} = {};
     stateToPrio.success = stateToPrio.expected = 3;
     stateToPrio.aborted = 2;
-    stateToPrio.pending = stateToPrio.triggered = 1;
+    stateToPrio.pending = stateToPrio.triggered = stateToPrio.waiting = 1;
     stateToPrio.error = stateToPrio.failure = 0;
 
     return stateToPrio[a.state] > stateToPrio[b.state] ? 1
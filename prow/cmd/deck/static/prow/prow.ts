@@ -1,5 +1,5 @@
 import moment from "moment";
-import {ProwJob, ProwJobList, ProwJobState, ProwJobType, Pull} from "../api/prow";
+import {Attempt, MissedPeriodic, ProwJob, ProwJobList, ProwJobState, ProwJobType, Pull} from "../api/prow";
 import {cell, getCookieByName, icon} from "../common/common";
 import {getParameterByName, relativeURL} from "../common/urls";
 import {FuzzySearch} from './fuzzy-search';
@@ -10,6 +10,7 @@ declare const spyglass: boolean;
 declare const rerunCreatesJob: boolean;
 declare const csrfToken: string;
 declare const allowAnyone: boolean;
+declare const missedPeriodics: MissedPeriodic[] | undefined;
 
 function genShortRefKey(baseRef: string, pulls: Pull[] = []) {
     return [baseRef, ...pulls.map((p) => p.number)].filter((n) => n).join(",");
@@ -267,8 +268,29 @@ window.onload = (): void => {
         Object.keys(opts.jobs).sort());
     redrawOptions(fz, opts);
     redraw(fz);
+    drawMissedPeriodics();
 };
 
+function drawMissedPeriodics(): void {
+    // missed-periodics.js is not served when deck runs locally.
+    if (typeof missedPeriodics === "undefined" || missedPeriodics.length === 0) {
+        return;
+    }
+    const list = document.getElementById("missed-periodics-list")!;
+    for (const missed of missedPeriodics) {
+        const item = document.createElement("li");
+        const link = document.createElement("a");
+        link.href = `/?job=${encodeURIComponent(missed.job)}`;
+        link.textContent = missed.job;
+        item.appendChild(link);
+        const runs = missed.missed_runs === 1 ? "run" : "runs";
+        item.appendChild(document.createTextNode(
+            ` missed ${missed.missed_runs} scheduled ${runs}, starting ${moment(missed.since).fromNow()}.`));
+        list.appendChild(item);
+    }
+    document.getElementById("missed-periodics")!.classList.remove("hidden");
+}
+
 function displayFuzzySearchResult(el: HTMLElement, inputContainer: ClientRect | DOMRect): void {
     el.classList.add("active-fuzzy-search");
     el.style.top = inputContainer.height - 1 + "px";
@@ -500,8 +522,9 @@ function redraw(fz: FuzzySearch): void {
                 type = "",
                 job = "",
                 refs: {org = "", repo = "", repo_link = "", base_sha = "", base_link = "", pulls = [], base_ref = ""} = {},
+                depends_on = [],
             },
-            status: {startTime, completionTime = "", state = "", pod_name, build_id = "", url = ""},
+            status: {startTime, completionTime = "", state = "", pod_name, build_id = "", url = "", description = "", attempts = []},
         } = build;
 
         if (!equalSelected(typeSel, type)) {
@@ -627,11 +650,14 @@ function redraw(fz: FuzzySearch): void {
         } else {
             r.appendChild(cell.text(''));
         }
-        if (url === "") {
-            r.appendChild(cell.text(job));
-        } else {
-            r.appendChild(cell.link(job, url));
+        const jobCell = url === "" ? cell.text(job) : cell.link(job, url);
+        if (depends_on.length) {
+            jobCell.appendChild(createDependencyChain(depends_on, description));
         }
+        if (attempts.length) {
+            jobCell.appendChild(createAttemptNote(attempts));
+        }
+        r.appendChild(jobCell);
 
         r.appendChild(cell.time(i.toString(), moment.unix(started)));
         r.appendChild(cell.text(durationStr));
@@ -804,7 +830,7 @@ function batchRevisionCell(build: ProwJob): HTMLTableDataCellElement {
 }
 
 function drawJobBar(total: number, jobCountMap: Map<ProwJobState, number>): void {
-  const states: ProwJobState[] = ["success", "pending", "triggered", "error", "failure", "aborted", ""];
+  const states: ProwJobState[] = ["success", "pending", "triggered", "waiting", "error", "failure", "aborted", ""];
   states.sort((s1, s2) => {
     return jobCountMap.get(s1)! - jobCountMap.get(s2)!;
   });
@@ -833,6 +859,22 @@ function drawJobBar(total: number, jobCountMap: Map<ProwJobState, number>): void
   });
 }
 
+function createDependencyChain(dependsOn: string[], description: string): HTMLElement {
+    const chain = document.createElement("span");
+    chain.classList.add("dependency-chain");
+    chain.textContent = ` \u2190 ${dependsOn.join(", ")}`;
+    chain.title = description || `Depends on ${dependsOn.join(", ")}`;
+    return chain;
+}
+
+function createAttemptNote(attempts: Attempt[]): HTMLElement {
+    const note = document.createElement("span");
+    note.classList.add("attempt-note");
+    note.textContent = ` (attempt ${attempts.length + 1})`;
+    note.title = attempts.map((a, i) => `Attempt ${i + 1}: ${a.description || a.failure_class || "infrastructure failure"}`).join("\n");
+    return note;
+}
+
 function stateToAdj(state: ProwJobState): string {
     switch (state) {
         case "success":
@@ -157,7 +157,69 @@ window.addEventListener('hashchange', (e) => {
   }
 });
 
+interface SearchMatch {
+  artifact: string;
+  line: number;
+  text: string;
+  link: string;
+}
+
+interface SearchResult {
+  query: string;
+  matches: SearchMatch[] | null;
+  truncated?: boolean;
+  skipped?: string[];
+}
+
+// Links to the match in the buildlog lens if it shows the artifact, or to
+// the raw artifact otherwise.
+function linkForMatch(match: SearchMatch): string {
+  for (const frame of Array.from(document.querySelectorAll<HTMLIFrameElement>('iframe[data-lens-name="buildlog"]'))) {
+    const index = frame.dataset.lensIndex!;
+    if ((lensArtifacts[index] || []).indexOf(match.artifact) !== -1) {
+      return '#' + serialiseHashes({[index]: `#${match.artifact}:${match.line}`});
+    }
+  }
+  return match.link;
+}
+
+async function search(query: string): Promise<void> {
+  const status = document.getElementById('search-status')!;
+  const results = document.getElementById('search-results')!;
+  results.innerHTML = '';
+  status.textContent = 'Searching...';
+  const resp = await fetch(`/spyglass/search?src=${encodeURIComponent(src)}&q=${encodeURIComponent(query)}`);
+  if (!resp.ok) {
+    status.textContent = `Search failed: ${await resp.text()}`;
+    return;
+  }
+  const result: SearchResult = await resp.json();
+  const matches = result.matches || [];
+  let summary = `${matches.length}${result.truncated ? '+' : ''} matching lines.`;
+  if (result.skipped && result.skipped.length > 0) {
+    summary += ` Not searched: ${result.skipped.join(', ')}.`;
+  }
+  status.textContent = summary;
+  for (const match of matches) {
+    const li = document.createElement('li');
+    const a = document.createElement('a');
+    a.className = 'search-location';
+    a.href = linkForMatch(match);
+    a.textContent = `${match.artifact}:${match.line}`;
+    li.appendChild(a);
+    li.appendChild(document.createTextNode(match.text));
+    results.appendChild(li);
+  }
+}
+
 // We can't use DOMContentLoaded here or we end up with a bunch of flickering. This appears to be MDL's fault.
 window.addEventListener('load', () => {
     loadLenses();
+    document.getElementById('search-form')!.addEventListener('submit', (e) => {
+      e.preventDefault();
+      const query = document.querySelector<HTMLInputElement>('#search-query')!.value;
+      if (query.trim() !== '') {
+        search(query).then();
+      }
+    });
 });
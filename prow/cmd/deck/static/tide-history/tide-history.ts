@@ -8,6 +8,10 @@ declare const tideHistory: HistoryData;
 
 const recordDisplayLimit = 500;
 
+// historyData holds the recent records that the page is loaded with, until
+// a date range is selected to query the persisted history instead.
+let historyData: HistoryData = typeof tideHistory !== 'undefined' ? tideHistory : {History: {}};
+
 interface FilteredRecord extends Record {
   // The following are not initially present and are instead populated based on the 'History' map key while filtering.
   repo: string;
@@ -33,7 +37,7 @@ function optionsForRepoBranch(repo: string, branch: string): Options {
     states: {},
   };
 
-  const hist: {[key: string]: Record[]} = typeof tideHistory !== 'undefined' ? tideHistory.History : {};
+  const hist: {[key: string]: Record[]} = historyData.History || {};
   const poolKeys = Object.keys(hist);
   for (const poolKey of poolKeys) {
     const match = RegExp('(.*?):(.*)').exec(poolKey);
@@ -113,10 +117,57 @@ window.onload = (): void => {
       };
   });
 
+  // Query the persisted history when the date range changes.
+  const dates = filterBox.querySelectorAll("input[type=date]") as NodeListOf<HTMLInputElement>;
+  dates.forEach((date) => {
+    date.value = getParameterByName(date.id) || "";
+    date.onchange = () => {
+      queryHistory();
+    };
+  });
+
   // set dropdown based on options from query string
+  if (dateRange()) {
+    queryHistory();
+  } else {
+    redrawOptions(optionsForRepoBranch("", ""));
+    redraw();
+  }
+};
+
+// dateRange returns the query of the selected date range, if any. The end
+// date is included in the range.
+function dateRange(): string {
+  const args: string[] = [];
+  const from = (document.getElementById("from") as HTMLInputElement).value;
+  if (from) {
+    args.push(`from=${encodeURIComponent(from)}`);
+  }
+  const to = (document.getElementById("to") as HTMLInputElement).value;
+  if (to) {
+    args.push(`to=${encodeURIComponent(moment.utc(to).add(1, "day").format("YYYY-MM-DD"))}`);
+  }
+  return args.join("&");
+}
+
+async function queryHistory(): Promise<void> {
+  const range = dateRange();
+  const recCount = document.getElementById("record-count")!;
+  recCount.textContent = "Loading records...";
+  try {
+    const resp = await fetch(range ? `tide-history.js?${range}` : "tide-history.js");
+    if (!resp.ok) {
+      recCount.textContent = `Failed to load records: ${await resp.text()}`;
+      return;
+    }
+    historyData = await resp.json();
+  } catch (e) {
+    recCount.textContent = `Failed to load records: ${e}`;
+    return;
+  }
   redrawOptions(optionsForRepoBranch("", ""));
   redraw();
-};
+}
 
 function addOptions(options: string[], selectID: string): string | undefined {
   const sel = document.getElementById(selectID)! as HTMLSelectElement;
@@ -164,6 +215,12 @@ function redraw(): void {
   const authorSel = getSelection("author");
   const actionSel = getSelection("action");
   const stateSel = getSelection("state");
+  for (const name of ["from", "to"]) {
+    const date = (document.getElementById(name) as HTMLInputElement).value;
+    if (date) {
+      args.push(`${name}=${encodeURIComponent(date)}`);
+    }
+  }
 
   if (window.history && window.history.replaceState !== undefined) {
     if (args.length > 0) {
@@ -175,7 +232,7 @@ function redraw(): void {
   redrawOptions(opts);
 
   let filteredRecs: FilteredRecord[] = [];
-  const hist: {[key: string]: Record[]} = typeof tideHistory !== 'undefined' ? tideHistory.History : {};
+  const hist: {[key: string]: Record[]} = historyData.History || {};
   const poolKeys = Object.keys(hist);
   for (const poolKey of poolKeys) {
     const match = RegExp('(.*?):(.*)').exec(poolKey);
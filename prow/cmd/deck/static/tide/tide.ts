@@ -25,6 +25,7 @@ function infoToggle(toToggle: HTMLElement): (event: Event) => void {
 
 function redraw(): void {
     redrawQueries();
+    redrawPriorities();
     redrawPools();
 }
 
@@ -204,6 +205,35 @@ function redrawQueries(): void {
     }
 }
 
+function redrawPriorities(): void {
+    const priorities = document.getElementById("priorities")!;
+    while (priorities.firstChild) {
+        priorities.removeChild(priorities.firstChild);
+    }
+
+    if (!tideData.Priorities || tideData.Priorities.length === 0) {
+        return;
+    }
+    document.getElementById("priorities-info")!.classList.remove("hidden");
+    for (const priority of tideData.Priorities) {
+        const li = document.createElement("li");
+        const labels = priority.labels || [];
+        for (let i = 0; i < labels.length; i++) {
+            const alternatives = labels[i].split("|");
+            for (let j = 0; j < alternatives.length; j++) {
+                li.appendChild(createLabelEl(alternatives[j]));
+                if (j + 1 < alternatives.length) {
+                    li.appendChild(document.createTextNode(" or "));
+                }
+            }
+            if (i + 1 < labels.length) {
+                li.appendChild(document.createTextNode(" and "));
+            }
+        }
+        priorities.appendChild(li);
+    }
+}
+
 function redrawPools(): void {
     const pools = document.getElementById("pools")!.getElementsByTagName("tbody")[0];
     while (pools.firstChild) {
@@ -237,7 +267,10 @@ function createHistoryCell(pool: TidePool): HTMLTableDataCellElement {
 
 function createRepoCell(pool: TidePool): HTMLTableDataCellElement {
     const deckLink = `/?repo=` + encodeURIComponent(`${pool.Org}/${pool.Repo}`);
-    const branchLink = `https://github.com/${pool.Org}/${pool.Repo}/tree/${pool.Branch}`;
+    let branchLink = `https://github.com/${pool.Org}/${pool.Repo}/tree/${pool.Branch}`;
+    if (pool.GerritInstance) {
+        branchLink = `${pool.GerritInstance}/q/project:${pool.Repo}+branch:${pool.Branch}`;
+    }
     const linksTD = document.createElement("td");
     linksTD.appendChild(createLink(deckLink, `${pool.Org}/${pool.Repo}`));
     linksTD.appendChild(document.createTextNode(" "));
@@ -261,9 +294,23 @@ function createActionCell(pool: TidePool): HTMLTableDataCellElement {
     } else if (targeted) {
         addPRsToElem(c, pool, pool.Target);
     }
+    if (pool.MergeWindowClosed) {
+        c.classList.add("blocked");
+        c.appendChild(document.createElement("br"));
+        c.appendChild(document.createTextNode(mergeWindowText(pool)));
+    }
     return c;
 }
 
+// mergeWindowText describes the closed merge window of the pool, during which
+// only exempt PRs are merged.
+function mergeWindowText(pool: TidePool): string {
+    if (!pool.MergeWindowOpens) {
+        return "Merge window closed";
+    }
+    return `Merge window closed until ${new Date(pool.MergeWindowOpens).toLocaleString()}`;
+}
+
 function createPRCell(pool: TidePool, prs: PullRequest[]): HTMLTableDataCellElement {
     const c = document.createElement("td");
     addPRsToElem(c, pool, prs);
@@ -299,16 +346,25 @@ function createBatchCell(pool: TidePool): HTMLTableDataCellElement {
     return td;
 }
 
-// addPRsToElem adds a space separated list of PR numbers that link to the corresponding PR on github.
+// addPRsToElem adds a space separated list of PR numbers that link to the corresponding PR on github,
+// or to the corresponding change on Gerrit.
 function addPRsToElem(elem: HTMLElement, pool: TidePool, prs?: PullRequest[]): void {
     if (prs) {
         for (let i = 0; i < prs.length; i++) {
             const a = document.createElement("a");
             a.href = `https://github.com/${pool.Org}/${pool.Repo}/pull/${prs[i].Number}`;
+            if (pool.GerritInstance) {
+                a.href = `${pool.GerritInstance}/c/${pool.Repo}/+/${prs[i].Number}`;
+            }
             a.appendChild(document.createTextNode("#" + prs[i].Number));
             a.id = `pr-${pool.Org}-${pool.Repo}-${prs[i].Number}-${nextID()}`;
-            if (prs[i].Title) {
-                const tip = tooltip.forElem(a.id, document.createTextNode(prs[i].Title));
+            let title = prs[i].Title;
+            if (pool.Priorities && pool.Priorities.hasOwnProperty(prs[i].Number)) {
+                a.classList.add("priority");
+                title = `${title} (priority tier ${pool.Priorities[prs[i].Number] + 1})`;
+            }
+            if (title) {
+                const tip = tooltip.forElem(a.id, document.createTextNode(title));
                 a.appendChild(tip);
             }
             elem.appendChild(a);
@@ -0,0 +1,408 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/pod-utils/gcs"
+	junitlens "k8s.io/test-infra/prow/spyglass/lenses/junit"
+)
+
+const (
+	buildsParam = "builds"
+	// defaultTestHistoryBuilds is the number of recent builds whose tests
+	// are aggregated by default.
+	defaultTestHistoryBuilds = 10
+	// maxTestHistoryBuilds bounds the number of builds that can be requested.
+	maxTestHistoryBuilds = 50
+	// maxTestHistoryFetches is the number of builds whose artifacts are
+	// fetched concurrently.
+	maxTestHistoryFetches = 5
+	// testHistoryCacheSize is the number of test histories cached at most.
+	testHistoryCacheSize = 100
+	// testHistoryCacheTTL is how long a test history is cached, as the
+	// results of running builds change.
+	testHistoryCacheTTL = 5 * time.Minute
+	// minFlakyFlips is the number of flips between passing and failing from
+	// which a test is considered flaky. A single flip is a regression or a fix.
+	minFlakyFlips = 2
+)
+
+// testHistoryBuild is a build of the job whose tests are aggregated.
+type testHistoryBuild struct {
+	ID           string    `json:"id"`
+	SpyglassLink string    `json:"spyglass_link"`
+	Started      time.Time `json:"started"`
+	Result       string    `json:"result"`
+	src          string
+}
+
+// testHistoryTest is the history of a test across the builds.
+type testHistoryTest struct {
+	Name string `json:"name"`
+	// Runs is the number of builds in which the test passed or failed.
+	Runs     int `json:"runs"`
+	Failures int `json:"failures"`
+	// Flips is the number of times the test went from passing to failing or
+	// back between consecutive runs.
+	Flips       int     `json:"flips"`
+	FailureRate float64 `json:"failure_rate"`
+	FlipRate    float64 `json:"flip_rate"`
+	Flaky       bool    `json:"flaky"`
+	// Results are the results of the test in each build, in the order of the
+	// builds: passed, failed, skipped or absent.
+	Results []string `json:"results"`
+}
+
+// FailurePercent returns the failure rate as a percentage.
+func (t testHistoryTest) FailurePercent() float64 {
+	return 100 * t.FailureRate
+}
+
+// FlipPercent returns the flip rate as a percentage.
+func (t testHistoryTest) FlipPercent() float64 {
+	return 100 * t.FlipRate
+}
+
+// testHistory is the history of the tests of a job that failed at least
+// once in its recent builds, from the flakiest.
+type testHistory struct {
+	Name           string             `json:"name"`
+	JobHistoryLink string             `json:"-"`
+	Builds         []testHistoryBuild `json:"builds"`
+	TestCount      int                `json:"test_count"`
+	FlakyCount     int                `json:"flaky_count"`
+	Tests          []testHistoryTest  `json:"tests"`
+}
+
+// testHistoryKey identifies the test history of the most recent builds of a
+// job.
+type testHistoryKey struct {
+	bucket, root string
+	newestBuild  int64
+	builds       int
+}
+
+type testHistoryCacheEntry struct {
+	history testHistory
+	created time.Time
+}
+
+// testHistoryCache caches the test histories of the most recently viewed
+// jobs. A new build of a job changes the key of its history.
+type testHistoryCache struct {
+	sync.Mutex
+	entries map[testHistoryKey]testHistoryCacheEntry
+	now     func() time.Time
+}
+
+func newTestHistoryCache() *testHistoryCache {
+	return &testHistoryCache{
+		entries: map[testHistoryKey]testHistoryCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns the cached history, if it did not expire.
+func (c *testHistoryCache) get(key testHistoryKey) (testHistory, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || c.now().Sub(entry.created) >= testHistoryCacheTTL {
+		return testHistory{}, false
+	}
+	return entry.history, true
+}
+
+// add caches the history and evicts the oldest histories beyond the size of
+// the cache.
+func (c *testHistoryCache) add(key testHistoryKey, history testHistory) {
+	c.Lock()
+	defer c.Unlock()
+	c.entries[key] = testHistoryCacheEntry{history: history, created: c.now()}
+	for len(c.entries) > testHistoryCacheSize {
+		var oldest testHistoryKey
+		first := true
+		for key, entry := range c.entries {
+			if first || entry.created.Before(c.entries[oldest].created) {
+				oldest = key
+				first = false
+			}
+		}
+		delete(c.entries, oldest)
+	}
+}
+
+// handleTestHistory handles requests to get the history of the tests of a
+// given job. The url must look like the ones of /job-history/:
+//
+// /test-history/<gcs-bucket-name>/logs/<job-name>?builds=<number of builds>
+func handleTestHistory(o options, cfg config.Getter, gcsClient *storage.Client, rf runFetcher, cache *testHistoryCache, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		history, err := getTestHistory(r.URL, "/test-history/", cfg(), gcsClient, rf, cache)
+		if err != nil {
+			msg := fmt.Sprintf("failed to get test history: %v", err)
+			log.WithField("url", r.URL).Error(msg)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		handleSimpleTemplate(o, cfg, "test-history.html", history)(w, r)
+	}
+}
+
+// handleTestHistoryJSON serves the history of /test-history/ as JSON at
+// /test-history.js/<gcs-path>.
+func handleTestHistoryJSON(cfg config.Getter, gcsClient *storage.Client, rf runFetcher, cache *testHistoryCache, log *logrus.Entry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setHeadersNoCaching(w)
+		history, err := getTestHistory(r.URL, "/test-history.js/", cfg(), gcsClient, rf, cache)
+		if err != nil {
+			msg := fmt.Sprintf("failed to get test history: %v", err)
+			log.WithField("url", r.URL).Error(msg)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		hd, err := json.Marshal(history)
+		if err != nil {
+			log.WithError(err).Error("Error marshaling test history.")
+			hd = []byte("{}")
+		}
+		writeJSONResponse(w, r, hd)
+	}
+}
+
+func parseBuildsParam(url *url.URL) (int, error) {
+	val := url.Query().Get(buildsParam)
+	if val == "" {
+		return defaultTestHistoryBuilds, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 || n > maxTestHistoryBuilds {
+		return 0, fmt.Errorf("invalid value %s = %q (expected 1 to %d)", buildsParam, val, maxTestHistoryBuilds)
+	}
+	return n, nil
+}
+
+// getTestHistory lists the recent builds of a job like the job history does
+// and aggregates the results of their tests, unless they are cached.
+func getTestHistory(url *url.URL, handlerPrefix string, config *config.Config, gcsClient *storage.Client, rf runFetcher, cache *testHistoryCache) (testHistory, error) {
+	start := time.Now()
+	bucketName, root, err := parseGCSJobPath(url.Path, handlerPrefix)
+	if err != nil {
+		return testHistory{}, fmt.Errorf("invalid url %s: %v", url.String(), err)
+	}
+	n, err := parseBuildsParam(url)
+	if err != nil {
+		return testHistory{}, fmt.Errorf("invalid url %s: %v", url.String(), err)
+	}
+	bucket := gcsBucket{bucketName, gcsClient.Bucket(bucketName)}
+
+	buildIDs, err := bucket.listBuildIDs(root)
+	if err != nil {
+		return testHistory{}, fmt.Errorf("failed to get build ids: %v", err)
+	}
+	sort.Sort(sort.Reverse(int64slice(buildIDs)))
+	if len(buildIDs) > n {
+		buildIDs = buildIDs[:n]
+	}
+	key := testHistoryKey{bucket: bucketName, root: root, builds: n}
+	if len(buildIDs) > 0 {
+		key.newestBuild = buildIDs[0]
+	}
+	if history, ok := cache.get(key); ok {
+		return history, nil
+	}
+
+	// concurrently resolve the directories of the builds
+	builds := make([]testHistoryBuild, len(buildIDs))
+	done := make(chan struct{})
+	fetches := make(chan struct{}, maxTestHistoryFetches)
+	for i, buildID := range buildIDs {
+		go func(i int, buildID int64) {
+			defer func() { done <- struct{}{} }()
+			fetches <- struct{}{}
+			defer func() { <-fetches }()
+			id := strconv.FormatInt(buildID, 10)
+			builds[i].ID = id
+			dir, err := bucket.getPath(root, id, "")
+			if err != nil {
+				logrus.Errorf("failed to get path: %v", err)
+				return
+			}
+			builds[i].src = path.Join("gcs", bucketName, dir)
+			builds[i].SpyglassLink = path.Join(spyglassPrefix, bucketName, dir)
+		}(i, buildID)
+	}
+	for range buildIDs {
+		<-done
+	}
+
+	history := collectTestHistory(rf, config.Deck.Spyglass.SizeLimit, builds)
+	history.Name = root
+	history.JobHistoryLink = path.Join("/job-history", bucketName, root)
+	cache.add(key, history)
+
+	elapsed := time.Now().Sub(start)
+	logrus.Infof("loaded %s in %v", url.Path, elapsed)
+	return history, nil
+}
+
+// fetchBuildTests fetches the metadata and the results of the tests of a
+// build.
+func fetchBuildTests(rf runFetcher, sizeLimit int64, build *testHistoryBuild) (map[string]junitlens.JunitResult, error) {
+	names, err := rf.ListArtifacts(build.src)
+	if err != nil {
+		return nil, fmt.Errorf("error listing artifacts: %v", err)
+	}
+	var wanted []string
+	for _, name := range names {
+		if name == "started.json" || name == "finished.json" || junitArtifactRe.MatchString(name) {
+			wanted = append(wanted, name)
+		}
+	}
+	artifacts, err := rf.FetchArtifacts(build.src, "", sizeLimit, wanted)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching artifacts: %v", err)
+	}
+
+	tests := map[string]junitlens.JunitResult{}
+	build.Result = "Pending"
+	for _, artifact := range artifacts {
+		log := logrus.WithField("artifact", artifact.CanonicalLink())
+		switch artifact.JobPath() {
+		case "started.json":
+			var started gcs.Started
+			if readArtifactJSON(artifact, &started, log) {
+				build.Started = time.Unix(started.Timestamp, 0)
+			}
+		case "finished.json":
+			var finished gcs.Finished
+			if readArtifactJSON(artifact, &finished, log) && finished.Result != "" {
+				build.Result = finished.Result
+			}
+		default:
+			addTestResults(tests, artifact)
+		}
+	}
+	return tests, nil
+}
+
+// collectTestHistory fetches the results of the tests of the builds, from the
+// newest, and computes the failure and flip rates of the tests. At most
+// maxTestHistoryFetches builds are fetched at a time.
+func collectTestHistory(rf runFetcher, sizeLimit int64, builds []testHistoryBuild) testHistory {
+	history := testHistory{Builds: builds}
+	buildTests := make([]map[string]junitlens.JunitResult, len(builds))
+	done := make(chan struct{})
+	fetches := make(chan struct{}, maxTestHistoryFetches)
+	for i := range builds {
+		go func(i int) {
+			defer func() { done <- struct{}{} }()
+			fetches <- struct{}{}
+			defer func() { <-fetches }()
+			if builds[i].src == "" {
+				builds[i].Result = "Unknown"
+				return
+			}
+			tests, err := fetchBuildTests(rf, sizeLimit, &builds[i])
+			if err != nil {
+				logrus.WithError(err).Warningf("build %s test results unavailable", builds[i].ID)
+				builds[i].Result = "Unknown"
+				return
+			}
+			buildTests[i] = tests
+		}(i)
+	}
+	for range builds {
+		<-done
+	}
+
+	names := map[string]bool{}
+	for _, tests := range buildTests {
+		for name := range tests {
+			names[name] = true
+		}
+	}
+	history.TestCount = len(names)
+	for name := range names {
+		test := testHistoryTest{Name: name}
+		for _, tests := range buildTests {
+			result, ok := tests[name]
+			test.Results = append(test.Results, testStatus(result, ok))
+		}
+		summarizeTest(&test)
+		if test.Failures == 0 {
+			continue
+		}
+		if test.Flaky {
+			history.FlakyCount++
+		}
+		history.Tests = append(history.Tests, test)
+	}
+	sort.Slice(history.Tests, func(i, j int) bool {
+		a, b := history.Tests[i], history.Tests[j]
+		if a.FlipRate != b.FlipRate {
+			return a.FlipRate > b.FlipRate
+		}
+		if a.FailureRate != b.FailureRate {
+			return a.FailureRate > b.FailureRate
+		}
+		return a.Name < b.Name
+	})
+	return history
+}
+
+// summarizeTest computes the runs, failures and flips of a test from its
+// results, which are ordered from the newest build.
+func summarizeTest(test *testHistoryTest) {
+	var previous string
+	for i := len(test.Results) - 1; i >= 0; i-- {
+		result := test.Results[i]
+		if result != "passed" && result != "failed" {
+			continue
+		}
+		test.Runs++
+		if result == "failed" {
+			test.Failures++
+		}
+		if previous != "" && previous != result {
+			test.Flips++
+		}
+		previous = result
+	}
+	if test.Runs > 0 {
+		test.FailureRate = float64(test.Failures) / float64(test.Runs)
+	}
+	if test.Runs > 1 {
+		test.FlipRate = float64(test.Flips) / float64(test.Runs-1)
+	}
+	test.Flaky = test.Failures > 0 && test.Failures < test.Runs && test.Flips >= minFlakyFlips
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBuildsParam(t *testing.T) {
+	testCases := []struct {
+		name     string
+		address  string
+		expected int
+		expErr   bool
+	}{
+		{
+			name:     "default",
+			address:  "http://www.example.com/test-history/foo-bucket/logs/bar-e2e",
+			expected: defaultTestHistoryBuilds,
+		},
+		{
+			name:     "number of builds",
+			address:  "http://www.example.com/test-history/foo-bucket/logs/bar-e2e?builds=5",
+			expected: 5,
+		},
+		{
+			name:    "too many builds",
+			address: "http://www.example.com/test-history/foo-bucket/logs/bar-e2e?builds=1000",
+			expErr:  true,
+		},
+		{
+			name:    "not a number",
+			address: "http://www.example.com/test-history/foo-bucket/logs/bar-e2e?builds=all",
+			expErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, _ := url.Parse(tc.address)
+			n, err := parseBuildsParam(u)
+			if tc.expErr {
+				if err == nil {
+					t.Error("Expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if n != tc.expected {
+				t.Errorf("Expected %d builds, got %d", tc.expected, n)
+			}
+		})
+	}
+}
+
+func TestSummarizeTest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		results  []string
+		expected testHistoryTest
+	}{
+		{
+			name:     "always passing",
+			results:  []string{"passed", "passed", "passed"},
+			expected: testHistoryTest{Runs: 3},
+		},
+		{
+			name:     "regression",
+			results:  []string{"failed", "failed", "passed", "passed", "passed"},
+			expected: testHistoryTest{Runs: 5, Failures: 2, Flips: 1, FailureRate: 0.4, FlipRate: 0.25},
+		},
+		{
+			name:     "flake",
+			results:  []string{"passed", "failed", "passed", "failed", "passed"},
+			expected: testHistoryTest{Runs: 5, Failures: 2, Flips: 4, FailureRate: 0.4, FlipRate: 1, Flaky: true},
+		},
+		{
+			name:     "skipped and absent results are ignored",
+			results:  []string{"passed", "skipped", "failed", "absent", "passed"},
+			expected: testHistoryTest{Runs: 3, Failures: 1, Flips: 2, FailureRate: float64(1) / 3, FlipRate: 1, Flaky: true},
+		},
+		{
+			name:     "always failing",
+			results:  []string{"failed", "failed"},
+			expected: testHistoryTest{Runs: 2, Failures: 2, FailureRate: 1},
+		},
+		{
+			name:     "never run",
+			results:  []string{"absent", "skipped"},
+			expected: testHistoryTest{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			test := testHistoryTest{Results: tc.results}
+			summarizeTest(&test)
+			test.Results = nil
+			if !reflect.DeepEqual(test, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, test)
+			}
+		})
+	}
+}
+
+func TestCollectTestHistory(t *testing.T) {
+	junitXML := func(fooFailing bool) string {
+		failure := ""
+		if fooFailing {
+			failure = "<failure>boom</failure>"
+		}
+		return `<testsuites><testsuite><testcase name="TestFoo">` + failure + `</testcase><testcase name="TestBar"></testcase></testsuite></testsuites>`
+	}
+	run := func(result string, fooFailing bool) map[string]string {
+		return map[string]string{
+			"started.json":              `{"timestamp":1575374400}`,
+			"finished.json":             `{"timestamp":1575374460,"result":"` + result + `"}`,
+			"artifacts/junit_01.xml":    junitXML(fooFailing),
+			"artifacts/build-log.txt":   "ok",
+			"artifacts/junit_runner.xm": "not junit",
+		}
+	}
+	rf := fakeRunFetcher{
+		"gcs/bucket/logs/job/5": run("FAILURE", true),
+		"gcs/bucket/logs/job/4": run("SUCCESS", false),
+		"gcs/bucket/logs/job/3": run("FAILURE", true),
+		"gcs/bucket/logs/job/2": {"started.json": `{"timestamp":1575374400}`},
+	}
+	builds := []testHistoryBuild{
+		{ID: "5", src: "gcs/bucket/logs/job/5"},
+		{ID: "4", src: "gcs/bucket/logs/job/4"},
+		{ID: "3", src: "gcs/bucket/logs/job/3"},
+		{ID: "2", src: "gcs/bucket/logs/job/2"},
+		{ID: "1"},
+	}
+
+	history := collectTestHistory(rf, 0, builds)
+
+	var results []string
+	for _, build := range history.Builds {
+		results = append(results, build.Result)
+	}
+	if expected := []string{"FAILURE", "SUCCESS", "FAILURE", "Pending", "Unknown"}; !reflect.DeepEqual(results, expected) {
+		t.Errorf("Expected build results %v, got %v", expected, results)
+	}
+	if history.TestCount != 2 {
+		t.Errorf("Expected 2 tests, got %d", history.TestCount)
+	}
+	expected := []testHistoryTest{
+		{
+			Name:        "TestFoo",
+			Runs:        3,
+			Failures:    2,
+			Flips:       2,
+			FailureRate: float64(2) / 3,
+			FlipRate:    1,
+			Flaky:       true,
+			Results:     []string{"failed", "passed", "failed", "absent", "absent"},
+		},
+	}
+	if !reflect.DeepEqual(history.Tests, expected) {
+		t.Errorf("Expected tests %+v, got %+v", expected, history.Tests)
+	}
+	if history.FlakyCount != 1 {
+		t.Errorf("Expected 1 flaky test, got %d", history.FlakyCount)
+	}
+}
+
+func TestTestHistoryCache(t *testing.T) {
+	now := time.Now()
+	c := newTestHistoryCache()
+	c.now = func() time.Time { return now }
+	key := testHistoryKey{bucket: "bucket", root: "logs/job", newestBuild: 5, builds: defaultTestHistoryBuilds}
+
+	c.add(key, testHistory{Name: "logs/job"})
+	if history, ok := c.get(key); !ok || history.Name != "logs/job" {
+		t.Errorf("Expected the cached history, got %+v", history)
+	}
+	newer := key
+	newer.newestBuild = 6
+	if _, ok := c.get(newer); ok {
+		t.Error("Expected no history once the job has a new build")
+	}
+	now = now.Add(testHistoryCacheTTL)
+	if _, ok := c.get(key); ok {
+		t.Error("Expected the history to expire")
+	}
+
+	for i := 0; i < 2*testHistoryCacheSize; i++ {
+		now = now.Add(time.Second)
+		key.newestBuild = int64(i)
+		c.add(key, testHistory{})
+	}
+	if len(c.entries) != testHistoryCacheSize {
+		t.Errorf("Expected %d cached histories, got %d", testHistoryCacheSize, len(c.entries))
+	}
+	if _, ok := c.get(key); !ok {
+		t.Error("Expected the most recent history to be cached")
+	}
+}
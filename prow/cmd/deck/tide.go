@@ -20,6 +20,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +37,7 @@ import (
 type tidePools struct {
 	Queries     []string
 	TideQueries []config.TideQuery
+	Priorities  []config.TidePriority
 	Pools       []tide.Pool
 }
 
@@ -155,6 +158,42 @@ func (ta *tideAgent) updateHistory() error {
 	return nil
 }
 
+// historyQueryClient queries the history of Tide on behalf of users, so
+// that slow queries do not pile up.
+var historyQueryClient = &http.Client{Timeout: time.Minute}
+
+// queryHistory queries Tide for the records of its persisted history that
+// match the query, rather than the recent records that are cached.
+func (ta *tideAgent) queryHistory(q history.Query) (map[string][]history.Record, error) {
+	values := url.Values{}
+	if q.Pool != "" {
+		values.Set("pool", q.Pool)
+	}
+	if q.PR != 0 {
+		values.Set("pull", strconv.Itoa(q.PR))
+	}
+	if !q.From.IsZero() {
+		values.Set("from", q.From.Format(time.RFC3339))
+	}
+	if !q.To.IsZero() {
+		values.Set("to", q.To.Format(time.RFC3339))
+	}
+	path := strings.TrimSuffix(ta.path, "/") + "/history?" + values.Encode()
+	resp, err := historyQueryClient.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("response has status code %d", resp.StatusCode)
+	}
+	var hist map[string][]history.Record
+	if err := json.NewDecoder(resp.Body).Decode(&hist); err != nil {
+		return nil, err
+	}
+	return ta.filterHiddenHistory(hist), nil
+}
+
 func (ta *tideAgent) filterHiddenPools(pools []tide.Pool) []tide.Pool {
 	if len(ta.hiddenRepos()) == 0 {
 		return pools
@@ -0,0 +1,89 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/cache"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+var costLabels = []string{"org", "repo", "job_name", "type"}
+
+// costCounters count the resources requested by the completed prowjobs per
+// org, repo, job and type. This is the capacity reserved for the jobs, the
+// actual usage of the pods is not measured.
+type costCounters struct {
+	runs    *prometheus.CounterVec
+	cpu     *prometheus.CounterVec
+	memory  *prometheus.CounterVec
+	running *prometheus.CounterVec
+}
+
+func newCostCounters() *costCounters {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, costLabels)
+	}
+	return &costCounters{
+		runs:    counter("prow_job_reserved_runs_total", "Number of completed runs with recorded reserved resources."),
+		cpu:     counter("prow_job_reserved_cpu_core_seconds_total", "Requested CPU cores multiplied by the running time of the job pods."),
+		memory:  counter("prow_job_reserved_memory_byte_seconds_total", "Requested memory in bytes multiplied by the running time of the job pods."),
+		running: counter("prow_job_reserved_running_seconds_total", "Total running time of the job pods."),
+	}
+}
+
+func (cc *costCounters) collectors() []prometheus.Collector {
+	return []prometheus.Collector{cc.runs, cc.cpu, cc.memory, cc.running}
+}
+
+// NewCostCounters creates the cost counters and hooks them into the prowjob
+// informer. A job is counted once, when it is updated to be complete with a
+// recorded reserved resources. Jobs that are already complete when the exporter
+// starts are not counted again, so the counters never count a job twice
+// across restarts, but jobs that complete while it is down are missed.
+func NewCostCounters(informer cache.SharedIndexInformer) []prometheus.Collector {
+	cc := newCostCounters()
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldJob, newJob interface{}) {
+			cc.update(oldJob.(*prowapi.ProwJob), newJob.(*prowapi.ProwJob))
+		},
+	})
+	return cc.collectors()
+}
+
+// costed returns whether the job is complete with recorded reserved resources.
+func costed(pj *prowapi.ProwJob) bool {
+	return pj.Complete() && pj.Status.ReservedResources != nil
+}
+
+func (cc *costCounters) update(oldJob, newJob *prowapi.ProwJob) {
+	if costed(oldJob) || !costed(newJob) {
+		return
+	}
+	values := []string{"", "", newJob.Spec.Job, string(newJob.Spec.Type)}
+	if newJob.Spec.Refs != nil {
+		values[0], values[1] = newJob.Spec.Refs.Org, newJob.Spec.Refs.Repo
+	} else if len(newJob.Spec.ExtraRefs) > 0 {
+		values[0], values[1] = newJob.Spec.ExtraRefs[0].Org, newJob.Spec.ExtraRefs[0].Repo
+	}
+	reserved := newJob.Status.ReservedResources
+	cc.runs.WithLabelValues(values...).Inc()
+	cc.cpu.WithLabelValues(values...).Add(reserved.CPUCoreSeconds())
+	cc.memory.WithLabelValues(values...).Add(reserved.MemoryByteSeconds())
+	cc.running.WithLabelValues(values...).Add(reserved.RunningDuration.Seconds())
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestCostCounters(t *testing.T) {
+	reserved := &prowapi.ReservedResources{
+		Requests:        corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+		RunningDuration: metav1.Duration{Duration: time.Hour},
+	}
+	completion := metav1.Now()
+	job := func(state prowapi.ProwJobState, reserved *prowapi.ReservedResources) *prowapi.ProwJob {
+		pj := &prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{
+				Job:  "pull-test-infra-bazel",
+				Type: prowapi.PresubmitJob,
+				Refs: &prowapi.Refs{Org: "kubernetes", Repo: "test-infra"},
+			},
+			Status: prowapi.ProwJobStatus{State: state, ReservedResources: reserved},
+		}
+		if state != prowapi.PendingState {
+			pj.Status.CompletionTime = &completion
+		}
+		return pj
+	}
+	testCases := []struct {
+		name           string
+		oldJob, newJob *prowapi.ProwJob
+		expectedRuns   float64
+	}{
+		{
+			name:         "job completing with reserved resources is counted",
+			oldJob:       job(prowapi.PendingState, nil),
+			newJob:       job(prowapi.SuccessState, reserved),
+			expectedRuns: 1,
+		},
+		{
+			name:         "reserved resources recorded after the completion are counted",
+			oldJob:       job(prowapi.AbortedState, nil),
+			newJob:       job(prowapi.AbortedState, reserved),
+			expectedRuns: 1,
+		},
+		{
+			name:   "completed job updated again is not counted twice",
+			oldJob: job(prowapi.SuccessState, reserved),
+			newJob: job(prowapi.SuccessState, reserved),
+		},
+		{
+			name:   "reserved resources of a retried attempt of a pending job are not counted",
+			oldJob: job(prowapi.PendingState, nil),
+			newJob: job(prowapi.PendingState, reserved),
+		},
+		{
+			name:   "job completing without reserved resources is not counted",
+			oldJob: job(prowapi.PendingState, nil),
+			newJob: job(prowapi.FailureState, nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cc := newCostCounters()
+			cc.update(tc.oldJob, tc.newJob)
+
+			values := []string{"kubernetes", "test-infra", "pull-test-infra-bazel", "presubmit"}
+			for _, check := range []struct {
+				name     string
+				counter  interface{ Write(*dto.Metric) error }
+				expected float64
+			}{
+				{"runs", cc.runs.WithLabelValues(values...), tc.expectedRuns},
+				{"cpu", cc.cpu.WithLabelValues(values...), tc.expectedRuns * 2 * 3600},
+				{"memory", cc.memory.WithLabelValues(values...), 0},
+				{"running", cc.running.WithLabelValues(values...), tc.expectedRuns * 3600},
+			} {
+				out := &dto.Metric{}
+				if err := check.counter.Write(out); err != nil {
+					t.Fatalf("failed to write metric: %v", err)
+				}
+				if value := out.GetCounter().GetValue(); value != check.expected {
+					t.Errorf("%s: expected %v, got %v", check.name, check.expected, value)
+				}
+			}
+		})
+	}
+}
@@ -55,11 +55,13 @@ func (o *options) Validate() error {
 	return o.kubernetes.Validate(false)
 }
 
-func mustRegister(component string, lister lister) *prometheus.Registry {
+func mustRegister(component string, lister lister, collectors ...prometheus.Collector) *prometheus.Registry {
 	registry := prometheus.NewRegistry()
-	prometheus.WrapRegistererWith(prometheus.Labels{"collector_name": component}, registry).MustRegister(&prowJobCollector{
-		lister: lister,
-	})
+	prometheus.WrapRegistererWith(prometheus.Labels{"collector_name": component}, registry).MustRegister(
+		append(collectors, &prowJobCollector{
+			lister: lister,
+		})...,
+	)
 	registry.MustRegister(
 		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
 		prometheus.NewGoCollector(),
@@ -93,10 +95,11 @@ func main() {
 	pjLister := informerFactory.Prow().V1().ProwJobs().Lister()
 
 	prometheus.MustRegister(prowjobs.NewProwJobLifecycleHistogramVec(informerFactory.Prow().V1().ProwJobs().Informer()))
+	costCounters := NewCostCounters(informerFactory.Prow().V1().ProwJobs().Informer())
 
 	go informerFactory.Start(interrupts.Context().Done())
 
-	registry := mustRegister("exporter", pjLister)
+	registry := mustRegister("exporter", pjLister, costCounters...)
 
 	// Expose prometheus metrics
 	metrics.ExposeMetricsWithRegistry("exporter", cfg().PushGateway, registry)
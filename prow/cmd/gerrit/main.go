@@ -42,6 +42,7 @@ import (
 
 type options struct {
 	gcsCredentialsFile string
+	s3CredentialsFile  string
 	cookiefilePath     string
 	configPath         string
 	jobConfigPath      string
@@ -81,8 +82,9 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs")
 	fs.StringVar(&o.cookiefilePath, "cookiefile", "", "Path to git http.cookiefile, leave empty for anonymous")
 	fs.Var(&o.projects, "gerrit-projects", "Set of gerrit repos to monitor on a host example: --gerrit-host=https://android.googlesource.com=platform/build,toolchain/llvm, repeat fs for each host")
-	fs.StringVar(&o.lastSyncFallback, "last-sync-fallback", "", "Local or gs:// path to sync the latest timestamp")
+	fs.StringVar(&o.lastSyncFallback, "last-sync-fallback", "", "Local, gs:// or s3:// path to sync the latest timestamp")
 	fs.StringVar(&o.gcsCredentialsFile, "gcs-credentials-file", "", "Path to GCS credentials. Required for a --last-sync-fallback=gs://path")
+	fs.StringVar(&o.s3CredentialsFile, "s3-credentials-file", "", "Path to S3 credentials. Used for a --last-sync-fallback=s3://path")
 	fs.BoolVar(&o.dryRun, "dry-run", false, "Run in dry-run mode, performing no modifying actions.")
 	o.kubernetes.AddFlags(fs)
 	fs.Parse(args)
@@ -236,7 +238,7 @@ func main() {
 	}
 
 	ctx := context.Background() // TODO(fejta): use something better
-	op, err := io.NewOpener(ctx, o.gcsCredentialsFile)
+	op, err := io.NewOpener(ctx, o.gcsCredentialsFile, o.s3CredentialsFile)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error creating opener")
 	}
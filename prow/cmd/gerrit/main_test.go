@@ -135,7 +135,7 @@ func TestSyncTime(t *testing.T) {
 	path := filepath.Join(dir, "value.txt")
 	var noCreds string
 	ctx := context.Background()
-	open, err := io.NewOpener(ctx, noCreds)
+	open, err := io.NewOpener(ctx, noCreds, noCreds)
 	if err != nil {
 		t.Fatalf("Failed to create opener: %v", err)
 	}
@@ -213,7 +213,7 @@ func TestNewProjectAddition(t *testing.T) {
 
 	var noCreds string
 	ctx := context.Background()
-	open, err := io.NewOpener(ctx, noCreds)
+	open, err := io.NewOpener(ctx, noCreds, noCreds)
 	if err != nil {
 		t.Fatalf("Failed to create opener: %v", err)
 	}
@@ -17,12 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
+	stdsync "sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -33,11 +39,35 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/cron"
 	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/logrusutil"
+	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
 )
 
+var (
+	nextTriggerGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "horologium_next_trigger_timestamp_seconds",
+		Help: "Unix time at which each periodic job is next scheduled to be triggered.",
+	}, []string{
+		"job_name",
+	})
+	missedRunsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "horologium_missed_runs",
+		Help: "Number of scheduled runs of each cron periodic job that were missed since its latest run.",
+	}, []string{
+		"job_name",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(nextTriggerGauge)
+	prometheus.MustRegister(missedRunsGauge)
+}
+
 type options struct {
+	port int
+
 	configPath    string
 	jobConfigPath string
 
@@ -47,6 +77,7 @@ type options struct {
 
 func gatherOptions(fs *flag.FlagSet, args ...string) options {
 	var o options
+	fs.IntVar(&o.port, "port", 8888, "Port to serve the /debug/schedule endpoint on.")
 	fs.StringVar(&o.configPath, "config-path", "", "Path to config.yaml.")
 	fs.StringVar(&o.jobConfigPath, "job-config-path", "", "Path to prow job configs.")
 
@@ -98,6 +129,13 @@ func main() {
 		logrus.WithError(err).Fatal("Error getting Kubernetes client.")
 	}
 
+	metrics.ExposeMetrics("horologium", configAgent.Config().PushGateway)
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/schedule", schedule)
+	server := &http.Server{Addr: ":" + strconv.Itoa(o.port), Handler: mux}
+	interrupts.ListenAndServe(server, 5*time.Second)
+
 	// start a cron
 	cr := cron.New()
 	cr.Start()
@@ -137,6 +175,7 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 	}
 
 	var errs []error
+	missedRunsGauge.Reset()
 	for _, p := range cfg.Periodics {
 		j, previousFound := latestJobs[p.Name]
 		logger := logrus.WithFields(logrus.Fields{
@@ -144,7 +183,19 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 			"previous-found": previousFound,
 		})
 
-		if p.Cron == "" {
+		var missed []time.Time
+		if p.Cron != "" {
+			if missed, err = pjutil.MissedRuns(p, j, previousFound, now); err != nil {
+				logger.WithError(err).Warn("Cannot determine missed runs.")
+			}
+			missedRunsGauge.WithLabelValues(p.Name).Set(float64(len(missed)))
+		}
+		catchUp := len(missed) > 0 && (p.CatchUp == config.CatchUpLatest || p.CatchUp == config.CatchUpAll)
+
+		if p.InBlackout(now) {
+			// Triggers during a blackout are dropped rather than delayed.
+			logger.Info("Not triggering periodic during a blackout window.")
+		} else if p.Cron == "" {
 			shouldTrigger := j.Complete() && now.Sub(j.Status.StartTime.Time) > p.GetInterval()
 			logger = logger.WithField("should-trigger", shouldTrigger)
 			if !previousFound || shouldTrigger {
@@ -154,12 +205,21 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 					errs = append(errs, err)
 				}
 			}
-		} else if cronTriggers.Has(p.Name) {
+		} else if catchUp || cronTriggers.Has(p.Name) {
 			shouldTrigger := j.Complete()
-			logger = logger.WithField("should-trigger", shouldTrigger)
+			logger = logger.WithFields(logrus.Fields{"should-trigger": shouldTrigger, "missed-runs": len(missed)})
 			if !previousFound || shouldTrigger {
 				prowJob := pjutil.NewProwJob(pjutil.PeriodicSpec(p), p.Labels, p.Annotations)
-				logger.WithFields(pjutil.ProwJobFields(&prowJob)).Info("Triggering new run of cron periodic.")
+				message := "Triggering new run of cron periodic."
+				if catchUp {
+					message = "Triggering catch-up run of cron periodic."
+					if p.CatchUp == config.CatchUpAll {
+						// Work through the missed runs one at a time, the
+						// next one is picked up once this one completes.
+						prowJob.Annotations[kube.ScheduledTimeAnnotation] = missed[0].Format(time.RFC3339)
+					}
+				}
+				logger.WithFields(pjutil.ProwJobFields(&prowJob)).Info(message)
 				if _, err := prowJobClient.Create(&prowJob); err != nil {
 					errs = append(errs, err)
 				}
@@ -167,9 +227,75 @@ func sync(prowJobClient prowJobClient, cfg *config.Config, cr cronClient, now ti
 		}
 	}
 
+	schedule.update(cfg.Periodics, latestJobs, now)
+
 	if len(errs) > 0 {
 		return fmt.Errorf("failed to create %d prowjobs: %v", len(errs), errs)
 	}
 
 	return nil
 }
+
+// scheduledJob is the next time a periodic job is expected to be triggered.
+type scheduledJob struct {
+	Name        string    `json:"name"`
+	Cron        string    `json:"cron,omitempty"`
+	Interval    string    `json:"interval,omitempty"`
+	NextTrigger time.Time `json:"next_trigger"`
+}
+
+// scheduleRecorder keeps the next trigger time of every periodic job for the
+// debug endpoint and the next trigger metric.
+type scheduleRecorder struct {
+	lock stdsync.RWMutex
+	jobs []scheduledJob
+}
+
+var schedule = &scheduleRecorder{}
+
+// nextTrigger returns when the periodic is expected to be triggered next.
+func nextTrigger(p config.Periodic, latest prowapi.ProwJob, previousFound bool, now time.Time) (time.Time, error) {
+	earliest := now
+	if p.Cron == "" && previousFound {
+		if intervalEnd := latest.Status.StartTime.Add(p.GetInterval()); intervalEnd.After(now) {
+			earliest = intervalEnd
+		}
+	}
+	return p.NextTrigger(earliest)
+}
+
+func (s *scheduleRecorder) update(periodics []config.Periodic, latestJobs map[string]prowapi.ProwJob, now time.Time) {
+	var jobs []scheduledJob
+	for _, p := range periodics {
+		latest, previousFound := latestJobs[p.Name]
+		next, err := nextTrigger(p, latest, previousFound, now)
+		if err != nil {
+			logrus.WithError(err).WithField("job", p.Name).Warn("Cannot determine next trigger time.")
+			continue
+		}
+		job := scheduledJob{Name: p.Name, Interval: p.Interval, NextTrigger: next}
+		if p.Cron != "" {
+			job.Cron = p.CronSpec()
+		}
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs = jobs
+	nextTriggerGauge.Reset()
+	for _, job := range jobs {
+		nextTriggerGauge.WithLabelValues(job.Name).Set(float64(job.NextTrigger.Unix()))
+	}
+}
+
+// ServeHTTP lists the next trigger time of every periodic job.
+func (s *scheduleRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.jobs); err != nil {
+		logrus.WithError(err).Error("Failed to encode schedule.")
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/flagutil"
+	"k8s.io/test-infra/prow/kube"
 )
 
 type fakeCron struct {
@@ -241,6 +242,194 @@ func TestSyncCron(t *testing.T) {
 	}
 }
 
+// idleCron never triggers any cron job.
+type idleCron struct{}
+
+func (ic *idleCron) SyncConfig(cfg *config.Config) error {
+	return nil
+}
+
+func (ic *idleCron) QueuedJobs() []string {
+	return []string{}
+}
+
+func TestSyncCatchUp(t *testing.T) {
+	now := time.Date(2019, 11, 6, 12, 0, 0, 0, time.UTC)
+	testcases := []struct {
+		name        string
+		catchUp     config.CatchUpPolicy
+		jobComplete bool
+
+		expectCreate        bool
+		expectScheduledTime string
+	}{
+		{
+			name:        "missed runs are skipped by default",
+			jobComplete: true,
+		},
+		{
+			name:        "missed runs are skipped with policy none",
+			catchUp:     config.CatchUpNone,
+			jobComplete: true,
+		},
+		{
+			name:         "latest policy triggers a single run",
+			catchUp:      config.CatchUpLatest,
+			jobComplete:  true,
+			expectCreate: true,
+		},
+		{
+			name:                "all policy triggers the oldest missed run",
+			catchUp:             config.CatchUpAll,
+			jobComplete:         true,
+			expectCreate:        true,
+			expectScheduledTime: "2019-11-04T03:00:00Z",
+		},
+		{
+			name:    "catch-up waits for the previous run to complete",
+			catchUp: config.CatchUpAll,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Config{
+				ProwConfig: config.ProwConfig{
+					ProwJobNamespace: "prowjobs",
+				},
+				JobConfig: config.JobConfig{
+					Periodics: []config.Periodic{{JobBase: config.JobBase{Name: "nightly"}, Cron: "0 3 * * *", CatchUp: tc.catchUp}},
+				},
+			}
+			job := &prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "nightly",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					Type: prowapi.PeriodicJob,
+					Job:  "nightly",
+				},
+				Status: prowapi.ProwJobStatus{
+					StartTime: metav1.NewTime(now.Add(-3 * 24 * time.Hour)),
+				},
+			}
+			if tc.jobComplete {
+				complete := metav1.NewTime(now.Add(-2 * 24 * time.Hour))
+				job.Status.CompletionTime = &complete
+			}
+			fakeProwJobClient := fake.NewSimpleClientset(job)
+			if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, &idleCron{}, now); err != nil {
+				t.Fatalf("didn't expect error: %v", err)
+			}
+
+			var created []*prowapi.ProwJob
+			for _, action := range fakeProwJobClient.Fake.Actions() {
+				if create, ok := action.(clienttesting.CreateActionImpl); ok {
+					created = append(created, create.GetObject().(*prowapi.ProwJob))
+				}
+			}
+			if !tc.expectCreate {
+				if len(created) != 0 {
+					t.Fatalf("expected no job to be created, got %d", len(created))
+				}
+				return
+			}
+			if len(created) != 1 {
+				t.Fatalf("expected one job to be created, got %d", len(created))
+			}
+			if scheduled := created[0].Annotations[kube.ScheduledTimeAnnotation]; scheduled != tc.expectScheduledTime {
+				t.Errorf("expected scheduled time %q, got %q", tc.expectScheduledTime, scheduled)
+			}
+		})
+	}
+}
+
+func TestSyncBlackout(t *testing.T) {
+	now := time.Date(2019, 11, 9, 12, 0, 0, 0, time.UTC) // a Saturday
+	weekends := config.Blackout{Cron: "0 0 * * 6", Duration: "48h"}
+	cfg := config.Config{
+		ProwConfig: config.ProwConfig{
+			ProwJobNamespace: "prowjobs",
+		},
+		JobConfig: config.JobConfig{
+			Periodics: []config.Periodic{
+				{JobBase: config.JobBase{Name: "interval"}, Blackouts: []config.Blackout{weekends}},
+				{JobBase: config.JobBase{Name: "cron"}, Cron: "@every 1h", Blackouts: []config.Blackout{weekends}},
+			},
+		},
+	}
+	cfg.Periodics[0].SetInterval(time.Minute)
+
+	fakeProwJobClient := fake.NewSimpleClientset()
+	fc := &fakeCron{}
+	if err := sync(fakeProwJobClient.ProwV1().ProwJobs(cfg.ProwJobNamespace), &cfg, fc, now); err != nil {
+		t.Fatalf("didn't expect error: %v", err)
+	}
+	for _, action := range fakeProwJobClient.Fake.Actions() {
+		if _, ok := action.(clienttesting.CreateActionImpl); ok {
+			t.Errorf("expected no job to be created during a blackout, got %v", action)
+		}
+	}
+}
+
+func TestNextTrigger(t *testing.T) {
+	now := time.Date(2019, 11, 6, 12, 0, 0, 0, time.UTC)
+	interval := config.Periodic{JobBase: config.JobBase{Name: "interval"}}
+	interval.SetInterval(time.Hour)
+
+	testcases := []struct {
+		name          string
+		periodic      config.Periodic
+		latestStarted time.Duration
+		previousFound bool
+		expected      time.Time
+	}{
+		{
+			name:     "interval job without previous run triggers now",
+			periodic: interval,
+			expected: now,
+		},
+		{
+			name:          "interval job triggers an interval after the previous run",
+			periodic:      interval,
+			latestStarted: 20 * time.Minute,
+			previousFound: true,
+			expected:      now.Add(40 * time.Minute),
+		},
+		{
+			name:          "overdue interval job triggers now",
+			periodic:      interval,
+			latestStarted: 2 * time.Hour,
+			previousFound: true,
+			expected:      now,
+		},
+		{
+			name:          "cron job triggers on its schedule",
+			periodic:      config.Periodic{JobBase: config.JobBase{Name: "cron"}, Cron: "0 3 * * *", TimeZone: "America/New_York"},
+			previousFound: true,
+			expected:      time.Date(2019, 11, 7, 8, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			latest := prowapi.ProwJob{
+				Status: prowapi.ProwJobStatus{
+					StartTime: metav1.NewTime(now.Add(-tc.latestStarted)),
+				},
+			}
+			next, err := nextTrigger(tc.periodic, latest, tc.previousFound, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !next.Equal(tc.expected) {
+				t.Errorf("expected next trigger %s, got %s", tc.expected, next)
+			}
+		})
+	}
+}
+
 func TestFlags(t *testing.T) {
 	cases := []struct {
 		name     string
@@ -316,6 +505,7 @@ func TestFlags(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			expected := &options{
 				configPath: "yo",
+				port:       8888,
 				dryRun: flagutil.Bool{
 					Explicit: true,
 				},
@@ -26,6 +26,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	corev1api "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -300,6 +301,10 @@ func (c *controller) clean() {
 	// Only delete pod if its prowjob is marked as finished
 	isExist := sets.NewString()
 	isFinished := sets.NewString()
+	// Finished prowjobs whose reserved resources plank did not record, for
+	// example because they were aborted. It is recorded from their pods
+	// before those are deleted.
+	unaccounted := map[string]prowapi.ProwJob{}
 
 	maxProwJobAge := c.config().Sinker.MaxProwJobAge.Duration
 	for _, prowJob := range prowJobs.Items {
@@ -313,6 +318,9 @@ func (c *controller) clean() {
 		}
 		isFinished.Insert(prowJob.ObjectMeta.Name)
 		if time.Since(prowJob.Status.StartTime.Time) <= maxProwJobAge {
+			if prowJob.Status.ReservedResources == nil {
+				unaccounted[prowJob.ObjectMeta.Name] = prowJob
+			}
 			continue
 		}
 		if err := c.prowJobClient.Delete(c.ctx, &prowJob); err == nil {
@@ -349,6 +357,9 @@ func (c *controller) clean() {
 		}
 		isFinished.Insert(prowJob.ObjectMeta.Name)
 		if time.Since(prowJob.Status.StartTime.Time) <= maxProwJobAge {
+			if prowJob.Status.ReservedResources == nil {
+				unaccounted[prowJob.ObjectMeta.Name] = prowJob
+			}
 			continue
 		}
 		if err := c.prowJobClient.Delete(c.ctx, &prowJob); err == nil {
@@ -388,6 +399,10 @@ func (c *controller) clean() {
 				continue
 			}
 
+			if prowJob, ok := unaccounted[pod.ObjectMeta.Name]; ok {
+				c.recordReservedResources(prowJob, pod)
+			}
+
 			// Delete old finished or orphan pods. Don't quit if we fail to delete one.
 			if err := client.Delete(pod.ObjectMeta.Name, &metav1.DeleteOptions{}); err == nil {
 				c.logger.WithField("pod", pod.ObjectMeta.Name).Info("Deleted old completed pod.")
@@ -417,3 +432,11 @@ func (c *controller) clean() {
 	}
 	c.logger.Info("Sinker reconciliation complete.")
 }
+
+// recordReservedResources records the resources reserved for the pod on its prowjob.
+func (c *controller) recordReservedResources(prowJob prowapi.ProwJob, pod corev1api.Pod) {
+	pjutil.RecordReservedResources(&prowJob, pod, prowJob.Status.CompletionTime.Time)
+	if err := c.prowJobClient.Update(c.ctx, &prowJob); err != nil {
+		c.logger.WithFields(pjutil.ProwJobFields(&prowJob)).WithError(err).Warning("Error recording reserved resources of prowjob.")
+	}
+}
@@ -31,6 +31,7 @@ import (
 	corev1fake "k8s.io/client-go/kubernetes/fake"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	clienttesting "k8s.io/client-go/testing"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	prowv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
@@ -553,3 +554,62 @@ func TestFlags(t *testing.T) {
 		})
 	}
 }
+
+func TestCleanRecordsReservedResources(t *testing.T) {
+	completed := metav1.NewTime(time.Now().Add(-maxPodAge).Truncate(time.Second))
+	prowJobs := []runtime.Object{
+		&prowv1.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "unaccounted", Namespace: "ns"},
+			Status: prowv1.ProwJobStatus{
+				StartTime:      metav1.NewTime(completed.Add(-time.Hour)),
+				CompletionTime: &completed,
+			},
+		},
+		&prowv1.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "accounted", Namespace: "ns"},
+			Status: prowv1.ProwJobStatus{
+				StartTime:         metav1.NewTime(completed.Add(-time.Hour)),
+				CompletionTime:    &completed,
+				ReservedResources: &prowv1.ReservedResources{RunningDuration: metav1.Duration{Duration: time.Minute}},
+			},
+		},
+	}
+	var pods []runtime.Object
+	for _, name := range []string{"unaccounted", "accounted"} {
+		pods = append(pods, &corev1api.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "ns",
+				Labels:    map[string]string{kube.CreatedByProw: "true"},
+			},
+			Status: corev1api.PodStatus{
+				Phase:     corev1api.PodSucceeded,
+				StartTime: startTime(completed.Add(-time.Hour)),
+			},
+		})
+	}
+
+	fpjc := fakectrlruntimeclient.NewFakeClient(prowJobs...)
+	c := controller{
+		ctx:           context.Background(),
+		logger:        logrus.WithField("component", "sinker"),
+		prowJobClient: fpjc,
+		podClients:    []corev1.PodInterface{corev1fake.NewSimpleClientset(pods...).CoreV1().Pods("ns")},
+		config:        newFakeConfigAgent().Config,
+	}
+	c.clean()
+
+	expected := map[string]time.Duration{
+		"unaccounted": time.Hour,
+		"accounted":   time.Minute,
+	}
+	for name, duration := range expected {
+		pj := &prowv1.ProwJob{}
+		if err := fpjc.Get(context.Background(), ctrlruntimeclient.ObjectKey{Namespace: "ns", Name: name}, pj); err != nil {
+			t.Fatalf("failed to get prowjob %s: %v", name, err)
+		}
+		if pj.Status.ReservedResources == nil || pj.Status.ReservedResources.RunningDuration.Duration != duration {
+			t.Errorf("expected prowjob %s to have a running duration of %v, got %+v", name, duration, pj.Status.ReservedResources)
+		}
+	}
+}
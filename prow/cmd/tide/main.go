@@ -18,6 +18,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"net/http"
 	"os"
@@ -33,10 +34,12 @@ import (
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/config/secret"
 	prowflagutil "k8s.io/test-infra/prow/flagutil"
+	gerritclient "k8s.io/test-infra/prow/gerrit/client"
 	"k8s.io/test-infra/prow/logrusutil"
 	"k8s.io/test-infra/prow/metrics"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/tide"
+	"k8s.io/test-infra/prow/tide/history"
 )
 
 type options struct {
@@ -54,8 +57,9 @@ type options struct {
 	github     prowflagutil.GitHubOptions
 
 	maxRecordsPerPool int
-	// The following are used for reading/writing to GCS.
+	// The following are used for reading/writing to GCS or S3.
 	gcsCredentialsFile string
+	s3CredentialsFile  string
 	// historyURI where Tide should store its action history.
 	// Can be a /local/path or gs://path/to/object.
 	// GCS writes will use the bucket's default acl for new objects. Ensure both that
@@ -69,9 +73,45 @@ type options struct {
 	// a) the gcs credentials can write to this bucket
 	// b) the default acls do not expose any private info
 	statusURI string
+
+	// historyStoreURI is the directory where Tide persists its action history
+	// past the records kept per pool, to serve queries of it.
+	// Can be a /local/dir, gs://bucket/dir or s3://bucket/dir.
+	historyStoreURI string
+	// historyRetention is how long the persisted action history is kept.
+	historyRetention time.Duration
+
+	// gerritCookiefilePath is the git http.cookiefile used to authenticate
+	// to the Gerrit instances of the Gerrit queries.
+	gerritCookiefilePath string
+
+	// simulateConfigPath and simulateJobConfigPath are the candidate config
+	// that a simulation compares with the current config.
+	simulateConfigPath    string
+	simulateJobConfigPath string
+	// snapshotPath is a recorded snapshot that a simulation replays instead
+	// of the current PRs and ProwJobs.
+	snapshotPath string
+	// recordSnapshotPath is where a simulation records the current PRs and
+	// ProwJobs, to replay them later.
+	recordSnapshotPath string
 }
 
 func (o *options) Validate() error {
+	if o.simulateConfigPath == "" && (o.snapshotPath != "" || o.recordSnapshotPath != "") {
+		return errors.New("--snapshot-path and --record-snapshot-path require --simulate-config-path")
+	}
+	if o.snapshotPath != "" && o.recordSnapshotPath != "" {
+		return errors.New("--record-snapshot-path cannot be used with --snapshot-path")
+	}
+	if o.historyRetention < 0 {
+		return errors.New("--history-retention cannot be negative")
+	}
+	if o.snapshotPath != "" {
+		// Replaying a snapshot does not use any clients.
+		return nil
+	}
+
 	for _, group := range []flagutil.OptionGroup{&o.kubernetes, &o.github} {
 		if err := group.Validate(o.dryRun); err != nil {
 			return err
@@ -96,8 +136,18 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 
 	fs.IntVar(&o.maxRecordsPerPool, "max-records-per-pool", 1000, "The maximum number of history records stored for an individual Tide pool.")
 	fs.StringVar(&o.gcsCredentialsFile, "gcs-credentials-file", "", "File where Google Cloud authentication credentials are stored. Required for GCS writes.")
-	fs.StringVar(&o.historyURI, "history-uri", "", "The /local/path or gs://path/to/object to store tide action history. GCS writes will use the default object ACL for the bucket")
-	fs.StringVar(&o.statusURI, "status-path", "", "The /local/path or gs://path/to/object to store status controller state. GCS writes will use the default object ACL for the bucket.")
+	fs.StringVar(&o.s3CredentialsFile, "s3-credentials-file", "", "File where S3 credentials are stored. Required for writes to s3:// paths on MinIO or other non-AWS endpoints.")
+	fs.StringVar(&o.historyURI, "history-uri", "", "The /local/path, gs://path/to/object or s3://path/to/object to store tide action history. GCS writes will use the default object ACL for the bucket")
+	fs.StringVar(&o.historyStoreURI, "history-store-uri", "", "The /local/dir, gs://bucket/dir or s3://bucket/dir to persist all of the tide action history in, one object per day, so that it can be queried. GCS writes will use the default object ACL for the bucket.")
+	fs.DurationVar(&o.historyRetention, "history-retention", 90*24*time.Hour, "How long to keep the action history persisted in --history-store-uri, or 0 to keep all of it.")
+	fs.StringVar(&o.statusURI, "status-path", "", "The /local/path, gs://path/to/object or s3://path/to/object to store status controller state. GCS writes will use the default object ACL for the bucket.")
+
+	fs.StringVar(&o.gerritCookiefilePath, "gerrit-cookiefile", "", "Path to git http.cookiefile used for the Gerrit instances of the Gerrit queries, leave empty for anonymous.")
+
+	fs.StringVar(&o.simulateConfigPath, "simulate-config-path", "", "Path to a candidate config.yaml. If set, print how one sync with it differs from one with the current config without side effects, then quit.")
+	fs.StringVar(&o.simulateJobConfigPath, "simulate-job-config-path", "", "Path to the prow job configs of the candidate config, defaults to --job-config-path.")
+	fs.StringVar(&o.snapshotPath, "snapshot-path", "", "Path to a recorded snapshot of PRs and ProwJobs to simulate, instead of reading them from GitHub and Deck.")
+	fs.StringVar(&o.recordSnapshotPath, "record-snapshot-path", "", "Path to record the snapshot of PRs and ProwJobs of a simulation to.")
 
 	fs.Parse(args)
 	o.configPath = config.ConfigPath(o.configPath)
@@ -107,21 +157,31 @@ func gatherOptions(fs *flag.FlagSet, args ...string) options {
 func main() {
 	logrusutil.ComponentInit("tide")
 
-	defer interrupts.WaitForGracefulShutdown()
-
-	pjutil.ServePProf()
-
 	o := gatherOptions(flag.NewFlagSet(os.Args[0], flag.ExitOnError), os.Args[1:]...)
 	if err := o.Validate(); err != nil {
 		logrus.WithError(err).Fatal("Invalid options")
 	}
 
-	opener, err := io.NewOpener(context.Background(), o.gcsCredentialsFile)
+	if o.simulateConfigPath != "" {
+		if err := simulate(o); err != nil {
+			logrus.WithError(err).Fatal("Error simulating candidate config.")
+		}
+		return
+	}
+
+	defer interrupts.WaitForGracefulShutdown()
+
+	pjutil.ServePProf()
+
+	opener, err := io.NewStorage(context.Background(), o.gcsCredentialsFile, o.s3CredentialsFile)
 	if err != nil {
 		entry := logrus.WithError(err)
 		if p := o.gcsCredentialsFile; p != "" {
 			entry = entry.WithField("gcs-credentials-file", p)
 		}
+		if p := o.s3CredentialsFile; p != "" {
+			entry = entry.WithField("s3-credentials-file", p)
+		}
 		entry.Fatal("Cannot create opener")
 	}
 
@@ -170,10 +230,23 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatal("Error constructing mgr.")
 	}
-	c, err := tide.NewController(githubSync, githubStatus, mgr, cfg, gitClient, o.maxRecordsPerPool, opener, o.historyURI, o.statusURI, nil)
+	c, err := tide.NewController(githubSync, githubStatus, mgr, cfg, gitClient, o.dryRun, o.maxRecordsPerPool, opener, o.historyURI, o.statusURI, nil)
 	if err != nil {
 		logrus.WithError(err).Fatal("Error creating Tide controller.")
 	}
+	if o.historyStoreURI != "" {
+		c.History.SetStore(history.NewStorageStore(opener, o.historyStoreURI), o.historyRetention)
+	}
+	// Gerrit instances are only picked up on start, as the client needs to
+	// know all of them up front.
+	if instances := cfg().Tide.GerritInstances(); len(instances) > 0 {
+		gerritClient, err := gerritclient.NewClient(instances)
+		if err != nil {
+			logrus.WithError(err).Fatal("Error creating Gerrit client.")
+		}
+		gerritClient.Start(o.gerritCookiefilePath)
+		c.EnableGerrit(gerritClient)
+	}
 	interrupts.Run(func(ctx context.Context) {
 		if err := mgr.Start(ctx.Done()); err != nil {
 			logrus.WithError(err).Fatal("Mgr failed.")
@@ -20,6 +20,7 @@ import (
 	"flag"
 	"reflect"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/test-infra/prow/config"
@@ -72,6 +73,44 @@ func Test_gatherOptions(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "simulating a snapshot does not require --deck-url",
+			args: map[string]string{
+				"--deck-url":             "",
+				"--simulate-config-path": "candidate.yaml",
+				"--snapshot-path":        "snapshot.json",
+			},
+			expected: func(o *options) {
+				o.kubernetes.DeckURI = ""
+				o.simulateConfigPath = "candidate.yaml"
+				o.snapshotPath = "snapshot.json"
+			},
+		},
+		{
+			name: "set --history-store-uri",
+			args: map[string]string{
+				"--history-store-uri": "gs://bucket/tide-history",
+				"--history-retention": "720h",
+			},
+			expected: func(o *options) {
+				o.historyStoreURI = "gs://bucket/tide-history"
+				o.historyRetention = 30 * 24 * time.Hour
+			},
+		},
+		{
+			name: "--history-retention cannot be negative",
+			args: map[string]string{
+				"--history-retention": "-1h",
+			},
+			err: true,
+		},
+		{
+			name: "--snapshot-path requires --simulate-config-path",
+			args: map[string]string{
+				"--snapshot-path": "snapshot.json",
+			},
+			err: true,
+		},
 	}
 
 	for _, tc := range cases {
@@ -84,6 +123,7 @@ func Test_gatherOptions(t *testing.T) {
 				syncThrottle:      800,
 				statusThrottle:    400,
 				maxRecordsPerPool: 1000,
+				historyRetention:  90 * 24 * time.Hour,
 				github:            flagutil.GitHubOptions{},
 				kubernetes:        flagutil.KubernetesOptions{DeckURI: "http://whatever"},
 			}
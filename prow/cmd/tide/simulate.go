@@ -0,0 +1,113 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/config/secret"
+	"k8s.io/test-infra/prow/tide"
+)
+
+// simulate syncs the pools once with the current and once with the candidate
+// config without side effects, and prints how the actions of the pools and
+// the eligible PRs differ.
+func simulate(o options) error {
+	current, err := config.Load(o.configPath, o.jobConfigPath)
+	if err != nil {
+		return fmt.Errorf("error loading current config: %v", err)
+	}
+	jobConfigPath := o.simulateJobConfigPath
+	if jobConfigPath == "" {
+		jobConfigPath = o.jobConfigPath
+	}
+	candidate, err := config.Load(o.simulateConfigPath, jobConfigPath)
+	if err != nil {
+		return fmt.Errorf("error loading candidate config: %v", err)
+	}
+
+	log := logrus.WithField("controller", "simulation")
+	snapshot, err := loadSnapshot(o, log, current, candidate)
+	if err != nil {
+		return err
+	}
+
+	currentPools, err := tide.Simulate(func() *config.Config { return current }, snapshot, nil, log)
+	if err != nil {
+		return fmt.Errorf("error simulating current config: %v", err)
+	}
+	candidatePools, err := tide.Simulate(func() *config.Config { return candidate }, snapshot, nil, log)
+	if err != nil {
+		return fmt.Errorf("error simulating candidate config: %v", err)
+	}
+	return tide.WriteSimulationReport(os.Stdout, currentPools, candidatePools)
+}
+
+// loadSnapshot reads the recorded snapshot, or takes a snapshot of the PRs
+// and ProwJobs of the configs with read-only clients.
+func loadSnapshot(o options, log *logrus.Entry, cfgs ...*config.Config) (*tide.Snapshot, error) {
+	snapshot := &tide.Snapshot{}
+	if o.snapshotPath != "" {
+		b, err := ioutil.ReadFile(o.snapshotPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading snapshot: %v", err)
+		}
+		if err := json.Unmarshal(b, snapshot); err != nil {
+			return nil, fmt.Errorf("error unmarshaling snapshot: %v", err)
+		}
+		return snapshot, nil
+	}
+
+	secretAgent := &secret.Agent{}
+	if err := secretAgent.Start([]string{o.github.TokenPath}); err != nil {
+		return nil, fmt.Errorf("error starting secrets agent: %v", err)
+	}
+	// Simulations never write, whatever --dry-run says.
+	githubClient, err := o.github.GitHubClientWithLogFields(secretAgent, true, logrus.Fields{"controller": "simulation"})
+	if err != nil {
+		return nil, fmt.Errorf("error getting GitHub client: %v", err)
+	}
+	prowJobClient, err := o.kubernetes.ProwJobClient(cfgs[0].ProwJobNamespace, true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ProwJob client: %v", err)
+	}
+	pjs, err := prowJobClient.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing ProwJobs: %v", err)
+	}
+	if snapshot, err = tide.TakeSnapshot(githubClient, log, pjs.Items, cfgs...); err != nil {
+		return nil, fmt.Errorf("error taking snapshot: %v", err)
+	}
+
+	if o.recordSnapshotPath != "" {
+		b, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling snapshot: %v", err)
+		}
+		if err := ioutil.WriteFile(o.recordSnapshotPath, b, 0644); err != nil {
+			return nil, fmt.Errorf("error writing snapshot: %v", err)
+		}
+	}
+	return snapshot, nil
+}
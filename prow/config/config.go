@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/mail"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -99,6 +100,8 @@ type ProwConfig struct {
 	Gerrit           Gerrit           `json:"gerrit,omitempty"`
 	GitHubReporter   GitHubReporter   `json:"github_reporter,omitempty"`
 	SlackReporter    *SlackReporter   `json:"slack_reporter,omitempty"`
+	WebhookReporter  *WebhookReporter `json:"webhook_reporter,omitempty"`
+	EmailReporter    *EmailReporter   `json:"email_reporter,omitempty"`
 	InRepoConfig     InRepoConfig     `json:"in_repo_config"`
 
 	// TODO: Move this out of the main config.
@@ -388,6 +391,10 @@ type Plank struct {
 	// PodRunningTimeout is after how long the controller will abort a prowjob pod
 	// stuck in running state. Defaults to two days.
 	PodRunningTimeout *metav1.Duration `json:"pod_running_timeout,omitempty"`
+	// WaitingTimeout is after how long the controller will abort a prowjob
+	// waiting for an upstream job that was never triggered for the same refs.
+	// Defaults to one day.
+	WaitingTimeout *metav1.Duration `json:"waiting_timeout,omitempty"`
 	// DefaultDecorationConfig are defaults for shared fields for ProwJobs
 	// that request to have their PodSpecs decorated.
 	// This will be deprecated on April 2020, and it will be replaces with DefaultDecorationConfigs['*'] instead.
@@ -402,6 +409,87 @@ type Plank struct {
 	// JobURLPrefixConfig is the host and path prefix under which job details
 	// will be viewable. Use `org/repo`, `org` or `*`as key and an url as value
 	JobURLPrefixConfig map[string]string `json:"job_url_prefix_config,omitempty"`
+
+	// JobQueues are named queues that triggered ProwJobs are sorted into
+	// before they are started. When at least one queue is configured, plank
+	// starts jobs using fair-share scheduling across the queues instead of
+	// in creation order. Jobs not matching any queue go to the queue named
+	// "default".
+	JobQueues []JobQueue `json:"job_queues,omitempty"`
+}
+
+// DefaultJobQueue is the name of the queue that ProwJobs are sorted into when
+// they do not match any configured queue.
+const DefaultJobQueue = "default"
+
+// JobQueue is a named queue for ProwJobs with a weight and a quota.
+type JobQueue struct {
+	// Name identifies the queue in metrics and logs. A queue named "default"
+	// configures the weight and quota of the default queue and cannot have
+	// any selectors.
+	Name string `json:"name"`
+	// Orgs are the GitHub orgs whose jobs belong to this queue.
+	Orgs []string `json:"orgs,omitempty"`
+	// Repos are the `org/repo` repositories whose jobs belong to this queue.
+	Repos []string `json:"repos,omitempty"`
+	// Labels select jobs by their ProwJob labels. All labels need to match.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Weight is the share of the available capacity this queue gets relative
+	// to the other queues. Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+	// MaxConcurrency is the maximum number of jobs from this queue that can
+	// run at the same time. 0 implies no limit.
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+}
+
+// Matches determines whether the ProwJob belongs to the queue. A queue
+// without any selector matches nothing.
+func (q JobQueue) Matches(pj *prowapi.ProwJob) bool {
+	if len(q.Orgs) == 0 && len(q.Repos) == 0 && len(q.Labels) == 0 {
+		return false
+	}
+	for key, value := range q.Labels {
+		if pj.Labels[key] != value {
+			return false
+		}
+	}
+	if len(q.Orgs) == 0 && len(q.Repos) == 0 {
+		return true
+	}
+	refs := pj.Spec.Refs
+	if refs == nil && len(pj.Spec.ExtraRefs) > 0 {
+		refs = &pj.Spec.ExtraRefs[0]
+	}
+	if refs == nil {
+		return false
+	}
+	for _, org := range q.Orgs {
+		if refs.Org == org {
+			return true
+		}
+	}
+	for _, repo := range q.Repos {
+		if fmt.Sprintf("%s/%s", refs.Org, refs.Repo) == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueFor returns the queue for the ProwJob: the first configured queue
+// matching it or the default queue.
+func (p Plank) QueueFor(pj *prowapi.ProwJob) JobQueue {
+	def := JobQueue{Name: DefaultJobQueue, Weight: 1}
+	for _, queue := range p.JobQueues {
+		if queue.Name == DefaultJobQueue {
+			def = queue
+			continue
+		}
+		if queue.Matches(pj) {
+			return queue
+		}
+	}
+	return def
 }
 
 func (p Plank) GetDefaultDecorationConfigs(repo string) *prowapi.DecorationConfig {
@@ -633,6 +721,163 @@ func (cfg *SlackReporter) DefaultAndValidate() error {
 	return nil
 }
 
+// WebhookReporter represents the config for the webhook reporter. Jobs can opt
+// in, and override the URL and job states, via the .reporter_config.webhook property.
+type WebhookReporter struct {
+	JobTypesToReport  []prowapi.ProwJobType  `json:"job_types_to_report"`
+	JobStatesToReport []prowapi.ProwJobState `json:"job_states_to_report"`
+	// URL is the endpoint the payload is POSTed to.
+	URL string `json:"url,omitempty"`
+	// AllowedURLs are the scheme://host[:port] endpoints that jobs may
+	// override the URL with. Jobs cannot override it without any.
+	AllowedURLs []string `json:"allowed_urls,omitempty"`
+	// PayloadTemplate is executed against the ProwJob and must produce JSON.
+	// The json function renders a value as a quoted and escaped JSON value.
+	PayloadTemplate string `json:"payload_template,omitempty"`
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (cfg *WebhookReporter) DefaultAndValidate() error {
+	// Default PayloadTemplate
+	if cfg.PayloadTemplate == "" {
+		cfg.PayloadTemplate = `{"job": {{json .Spec.Job}}, "type": {{json .Spec.Type}}, "state": {{json .Status.State}}, "url": {{json .Status.URL}}, "build_id": {{json .Status.BuildID}}, "refs": {{json .Spec.Refs}}}`
+	}
+
+	if cfg.URL != "" {
+		if _, err := parseWebhookURL(cfg.URL); err != nil {
+			return fmt.Errorf("invalid url: %v", err)
+		}
+	}
+	for _, allowed := range cfg.AllowedURLs {
+		u, err := parseWebhookURL(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid allowed_urls entry: %v", err)
+		}
+		if strings.Trim(u.Path, "/") != "" || u.RawQuery != "" {
+			return fmt.Errorf("invalid allowed_urls entry %q: must be scheme://host[:port] without a path", allowed)
+		}
+	}
+
+	// Validate PayloadTemplate
+	if _, err := cfg.Payload(&prowapi.ProwJob{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseWebhookURL parses an absolute http or https URL.
+func parseWebhookURL(raw string) (*url.URL, error) {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%q has no host", raw)
+	}
+	return u, nil
+}
+
+// ValidateJobURL checks that a URL set by a job is one of the allowed URLs,
+// so that job authors cannot make the reporter POST to arbitrary endpoints.
+func (cfg *WebhookReporter) ValidateJobURL(raw string) error {
+	u, err := parseWebhookURL(raw)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range cfg.AllowedURLs {
+		a, err := parseWebhookURL(allowed)
+		if err != nil {
+			continue
+		}
+		if strings.EqualFold(u.Scheme, a.Scheme) && strings.EqualFold(u.Host, a.Host) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s://%s is not in the allowed_urls of the webhook_reporter", u.Scheme, u.Host)
+}
+
+// Payload renders the payload template for a ProwJob.
+func (cfg *WebhookReporter) Payload(pj *prowapi.ProwJob) ([]byte, error) {
+	tmpl, err := template.New("").Funcs(webhookTemplateFuncs).Parse(cfg.PayloadTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+	b := &bytes.Buffer{}
+	if err := tmpl.Execute(b, pj); err != nil {
+		return nil, fmt.Errorf("failed to execute payload_template: %v", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("payload_template did not produce valid JSON: %s", b.String())
+	}
+	return b.Bytes(), nil
+}
+
+// EmailReporter represents the config for the email reporter. Jobs can opt in,
+// and override the recipients and job states, via the .reporter_config.email property.
+type EmailReporter struct {
+	JobTypesToReport  []prowapi.ProwJobType  `json:"job_types_to_report"`
+	JobStatesToReport []prowapi.ProwJobState `json:"job_states_to_report"`
+	// From is the address reports are sent from.
+	From string `json:"from"`
+	// Recipients are the addresses reports are sent to.
+	Recipients []string `json:"recipients,omitempty"`
+	// SubjectTemplate is executed against the ProwJob to produce the subject
+	// of its report. In a digest it is used for the line about the job.
+	SubjectTemplate string `json:"subject_template,omitempty"`
+	// DigestInterval enables digest mode when set. Reports of periodic jobs
+	// are then collected and sent as a single message once per interval.
+	DigestInterval *metav1.Duration `json:"digest_interval,omitempty"`
+}
+
+func (cfg *EmailReporter) DefaultAndValidate() error {
+	// Default SubjectTemplate
+	if cfg.SubjectTemplate == "" {
+		cfg.SubjectTemplate = `[prow] Job {{.Spec.Job}} of type {{.Spec.Type}} ended with state {{.Status.State}}`
+	}
+
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return fmt.Errorf("invalid from address %q: %v", cfg.From, err)
+	}
+	for _, recipient := range cfg.Recipients {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %q: %v", recipient, err)
+		}
+	}
+	if cfg.DigestInterval != nil && cfg.DigestInterval.Duration <= 0 {
+		return fmt.Errorf("digest_interval %s must be positive", cfg.DigestInterval.Duration)
+	}
+
+	// Validate SubjectTemplate
+	if _, err := cfg.Subject(&prowapi.ProwJob{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Subject renders the subject template for a ProwJob.
+func (cfg *EmailReporter) Subject(pj *prowapi.ProwJob) (string, error) {
+	tmpl, err := template.New("").Parse(cfg.SubjectTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %v", err)
+	}
+	b := &bytes.Buffer{}
+	if err := tmpl.Execute(b, pj); err != nil {
+		return "", fmt.Errorf("failed to execute subject_template: %v", err)
+	}
+	return b.String(), nil
+}
+
 // Load loads and parses the config at path.
 func Load(prowConfig, jobConfig string) (c *Config, err error) {
 	// we never want config loading to take down the prow components
@@ -1028,6 +1273,18 @@ func (c *Config) validateComponentConfig() error {
 			return fmt.Errorf("failed to validate slackreporter config: %v", err)
 		}
 	}
+
+	if c.WebhookReporter != nil {
+		if err := c.WebhookReporter.DefaultAndValidate(); err != nil {
+			return fmt.Errorf("failed to validate webhookreporter config: %v", err)
+		}
+	}
+
+	if c.EmailReporter != nil {
+		if err := c.EmailReporter.DefaultAndValidate(); err != nil {
+			return fmt.Errorf("failed to validate emailreporter config: %v", err)
+		}
+	}
 	return nil
 }
 
@@ -1053,6 +1310,11 @@ func validateJobBase(v JobBase, jobType prowapi.ProwJobType, podNamespace string
 	if err := validateLabels(v.Labels); err != nil {
 		return err
 	}
+	if v.RetryPolicy != nil {
+		if err := v.RetryPolicy.Validate(); err != nil {
+			return fmt.Errorf("invalid retry_policy: %v", err)
+		}
+	}
 	if v.Spec == nil || len(v.Spec.Containers) == 0 {
 		return nil // jenkins jobs have no spec
 	}
@@ -1082,6 +1344,14 @@ func validatePresubmits(presubmits []Presubmit, podNamespace string) error {
 		validPresubmits[ps.Name] = append(validPresubmits[ps.Name], ps)
 	}
 
+	var jobs []JobBase
+	for _, ps := range presubmits {
+		jobs = append(jobs, ps.JobBase)
+	}
+	if err := validateDependencies(jobs); err != nil {
+		return fmt.Errorf("invalid presubmit dependencies: %v", err)
+	}
+
 	return nil
 }
 
@@ -1102,6 +1372,70 @@ func validatePostsubmits(postsubmits []Postsubmit, podNamespace string) error {
 		validPostsubmits[ps.Name] = append(validPostsubmits[ps.Name], ps)
 	}
 
+	var jobs []JobBase
+	for _, ps := range postsubmits {
+		jobs = append(jobs, ps.JobBase)
+	}
+	if err := validateDependencies(jobs); err != nil {
+		return fmt.Errorf("invalid postsubmit dependencies: %v", err)
+	}
+
+	return nil
+}
+
+// validateDependencies ensures that every job named in depends_on exists
+// among the provided jobs and that the dependencies do not form a cycle.
+func validateDependencies(jobs []JobBase) error {
+	deps := map[string]sets.String{}
+	for _, job := range jobs {
+		if _, ok := deps[job.Name]; !ok {
+			deps[job.Name] = sets.NewString()
+		}
+		deps[job.Name].Insert(job.DependsOn...)
+	}
+	for name, upstream := range deps {
+		for _, dep := range upstream.List() {
+			if _, ok := deps[dep]; !ok {
+				return fmt.Errorf("job %s depends on unknown job %s", name, dep)
+			}
+		}
+	}
+
+	// Walk the graph depth-first, a job that is reached again while it is
+	// still on the stack closes a cycle.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	marks := map[string]int{}
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		path = append(path, name)
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+		marks[name] = visiting
+		for _, dep := range deps[name].List() {
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+		return nil
+	}
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -1116,6 +1450,9 @@ func validatePeriodics(periodics []Periodic, podNamespace string) error {
 			return fmt.Errorf("duplicated periodic job : %s", p.Name)
 		}
 		validPeriodics.Insert(p.Name)
+		if len(p.DependsOn) > 0 {
+			return fmt.Errorf("invalid periodic job %s: depends_on is not supported for periodics", p.Name)
+		}
 		if err := validateJobBase(p.JobBase, prowapi.PeriodicJob, podNamespace); err != nil {
 			return fmt.Errorf("invalid periodic job %s: %v", p.Name, err)
 		}
@@ -1146,15 +1483,25 @@ func (c *Config) validateJobConfig() error {
 		return err
 	}
 
-	// Set the interval on the periodic jobs. It doesn't make sense to do this
-	// for child jobs.
+	if err := c.validateReporterConfigs(); err != nil {
+		return err
+	}
+
+	// Validate the schedules and set the interval and jitter on the periodic
+	// jobs. It doesn't make sense to do this for child jobs.
 	for j, p := range c.Periodics {
 		if p.Cron != "" && p.Interval != "" {
 			return fmt.Errorf("cron and interval cannot be both set in periodic %s", p.Name)
 		} else if p.Cron == "" && p.Interval == "" {
 			return fmt.Errorf("cron and interval cannot be both empty in periodic %s", p.Name)
-		} else if p.Cron != "" {
-			if _, err := cron.Parse(p.Cron); err != nil {
+		}
+		if p.TimeZone != "" {
+			if _, err := time.LoadLocation(p.TimeZone); err != nil {
+				return fmt.Errorf("invalid time zone %s in periodic %s: %v", p.TimeZone, p.Name, err)
+			}
+		}
+		if p.Cron != "" {
+			if _, err := cron.Parse(p.CronSpec()); err != nil {
 				return fmt.Errorf("invalid cron string %s in periodic %s: %v", p.Cron, p.Name, err)
 			}
 		} else {
@@ -1164,11 +1511,87 @@ func (c *Config) validateJobConfig() error {
 			}
 			c.Periodics[j].interval = d
 		}
+		if p.Jitter != "" {
+			if p.Cron == "" {
+				return fmt.Errorf("jitter is only supported for cron periodics, not in periodic %s", p.Name)
+			}
+			d, err := time.ParseDuration(p.Jitter)
+			if err != nil {
+				return fmt.Errorf("cannot parse jitter for %s: %v", p.Name, err)
+			}
+			if d < 0 {
+				return fmt.Errorf("jitter for %s must not be negative", p.Name)
+			}
+			c.Periodics[j].jitter = d
+		}
+		for i, b := range p.Blackouts {
+			if err := b.validate(p.location()); err != nil {
+				return fmt.Errorf("invalid blackout %d in periodic %s: %v", i, p.Name, err)
+			}
+		}
+		switch p.CatchUp {
+		case "", CatchUpNone:
+		case CatchUpLatest, CatchUpAll:
+			if p.Cron == "" {
+				return fmt.Errorf("catch_up is only supported for cron periodics, not in periodic %s", p.Name)
+			}
+		default:
+			return fmt.Errorf("invalid catch_up %q in periodic %s, must be one of %q, %q or %q", p.CatchUp, p.Name, CatchUpNone, CatchUpLatest, CatchUpAll)
+		}
+		if p.MissedRunsThreshold != nil {
+			if p.Cron == "" {
+				return fmt.Errorf("missed_runs_threshold is only supported for cron periodics, not in periodic %s", p.Name)
+			}
+			if *p.MissedRunsThreshold < 0 {
+				return fmt.Errorf("missed_runs_threshold for %s must not be negative", p.Name)
+			}
+		}
 	}
 
 	return nil
 }
 
+// validateReporterConfigs validates the reporter overrides of the jobs
+// against the reporter config.
+func (c *Config) validateReporterConfigs() error {
+	var jobs []JobBase
+	for _, presubmits := range c.PresubmitsStatic {
+		for _, ps := range presubmits {
+			jobs = append(jobs, ps.JobBase)
+		}
+	}
+	for _, postsubmits := range c.Postsubmits {
+		for _, ps := range postsubmits {
+			jobs = append(jobs, ps.JobBase)
+		}
+	}
+	for _, p := range c.Periodics {
+		jobs = append(jobs, p.JobBase)
+	}
+	for _, job := range jobs {
+		if job.ReporterConfig == nil {
+			continue
+		}
+		if job.ReporterConfig.Email != nil {
+			for _, recipient := range job.ReporterConfig.Email.Recipients {
+				if _, err := mail.ParseAddress(recipient); err != nil {
+					return fmt.Errorf("invalid reporter_config.email.recipients in job %s: %q: %v", job.Name, recipient, err)
+				}
+			}
+		}
+		if job.ReporterConfig.Webhook == nil || job.ReporterConfig.Webhook.URL == "" {
+			continue
+		}
+		if c.WebhookReporter == nil {
+			return fmt.Errorf("invalid reporter_config.webhook.url in job %s: no webhook_reporter is configured", job.Name)
+		}
+		if err := c.WebhookReporter.ValidateJobURL(job.ReporterConfig.Webhook.URL); err != nil {
+			return fmt.Errorf("invalid reporter_config.webhook.url in job %s: %v", job.Name, err)
+		}
+	}
+	return nil
+}
+
 // DefaultConfigPath will be used if a --config-path is unset
 const DefaultConfigPath = "/etc/config/config.yaml"
 
@@ -1196,6 +1619,14 @@ func parseProwConfig(c *Config) error {
 		c.Plank.PodRunningTimeout = &metav1.Duration{Duration: 48 * time.Hour}
 	}
 
+	if c.Plank.WaitingTimeout == nil {
+		c.Plank.WaitingTimeout = &metav1.Duration{Duration: 24 * time.Hour}
+	}
+
+	if err := validateJobQueues(c.Plank.JobQueues); err != nil {
+		return fmt.Errorf("validating plank config: %v", err)
+	}
+
 	if !c.Plank.AllowCancellations {
 		logrus.Warning("The `plank.allow_cancellations` setting is deprecated. It will be removed and set to always true in March 2020")
 	}
@@ -1346,6 +1777,10 @@ func parseProwConfig(c *Config) error {
 		c.Tide.StatusUpdatePeriod = c.Tide.SyncPeriod
 	}
 
+	if c.Tide.ConflictLabel == "" && len(c.Tide.ConflictPredictionMap) > 0 {
+		c.Tide.ConflictLabel = DefaultConflictLabel
+	}
+
 	if c.Tide.MaxGoroutines == 0 {
 		c.Tide.MaxGoroutines = 20
 	}
@@ -1391,6 +1826,46 @@ func parseProwConfig(c *Config) error {
 		}
 	}
 
+	for i, gq := range c.Tide.GerritQueries {
+		if err := gq.Validate(); err != nil {
+			return fmt.Errorf("tide gerrit query (index %d) is invalid: %v", i, err)
+		}
+		// The instance is the key of its Gerrit client, so it must be
+		// spelled the same everywhere.
+		c.Tide.GerritQueries[i].Instance = strings.TrimSuffix(gq.Instance, "/")
+	}
+
+	for i, pr := range c.Tide.PostsubmitReverts {
+		if err := pr.Validate(c.Tide.BlockerLabel); err != nil {
+			return fmt.Errorf("tide postsubmit revert (index %d) is invalid: %v", i, err)
+		}
+	}
+
+	for i, w := range c.Tide.MergeWindows {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("tide merge window (index %d) is invalid: %v", i, err)
+		}
+	}
+
+	for name, length := range c.Tide.MergeTrainMap {
+		if length < 0 {
+			return fmt.Errorf("tide merge train length for %q must not be negative, got %d", name, length)
+		}
+	}
+
+	for i, tier := range c.Tide.Priority {
+		if len(tier.Labels) == 0 {
+			return fmt.Errorf("tide priority (index %d) has no labels", i)
+		}
+		for _, label := range tier.Labels {
+			for _, alternative := range strings.Split(label, "|") {
+				if alternative == "" {
+					return fmt.Errorf("tide priority (index %d) has an empty label in %q", i, label)
+				}
+			}
+		}
+	}
+
 	if c.ProwJobNamespace == "" {
 		c.ProwJobNamespace = "default"
 	}
@@ -1498,6 +1973,10 @@ func validateAgent(v JobBase, podNamespace string) error {
 		return fmt.Errorf("decoration requires agent: %s (found %q)", k, agent)
 	case v.ErrorOnEviction && agent != k:
 		return fmt.Errorf("error_on_eviction only applies to agent: %s (found %q)", k, agent)
+	case len(v.DependsOn) > 0 && agent != k:
+		return fmt.Errorf("depends_on only applies to agent: %s (found %q)", k, agent)
+	case v.RetryPolicy != nil && agent != k:
+		return fmt.Errorf("retry_policy only applies to agent: %s (found %q)", k, agent)
 	case v.Namespace == nil || *v.Namespace == "":
 		return fmt.Errorf("failed to default namespace")
 	case *v.Namespace != podNamespace && agent != p:
@@ -1668,6 +2147,39 @@ func ValidateController(c *Controller) error {
 	return nil
 }
 
+// validateJobQueues validates the plank job queues and defaults their weight.
+func validateJobQueues(queues []JobQueue) error {
+	names := sets.NewString()
+	for i := range queues {
+		queue := &queues[i]
+		if queue.Name == "" {
+			return fmt.Errorf("job queue %d has no name", i)
+		}
+		if names.Has(queue.Name) {
+			return fmt.Errorf("duplicated job queue %q", queue.Name)
+		}
+		names.Insert(queue.Name)
+		if queue.Name == DefaultJobQueue && (len(queue.Orgs) > 0 || len(queue.Repos) > 0 || len(queue.Labels) > 0) {
+			return fmt.Errorf("job queue %q cannot have orgs, repos or labels", DefaultJobQueue)
+		}
+		for _, repo := range queue.Repos {
+			if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return fmt.Errorf("job queue %q has invalid repo %q, it needs to be in org/repo format", queue.Name, repo)
+			}
+		}
+		if queue.Weight < 0 {
+			return fmt.Errorf("job queue %q has invalid weight (%d), it needs to be a non-negative number", queue.Name, queue.Weight)
+		}
+		if queue.Weight == 0 {
+			queue.Weight = 1
+		}
+		if queue.MaxConcurrency < 0 {
+			return fmt.Errorf("job queue %q has invalid max_concurrency (%d), it needs to be a non-negative number", queue.Name, queue.MaxConcurrency)
+		}
+	}
+	return nil
+}
+
 // DefaultTriggerFor returns the default regexp string used to match comments
 // that should trigger the job with this name.
 func DefaultTriggerFor(name string) string {
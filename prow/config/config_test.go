@@ -30,6 +30,7 @@ import (
 
 	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/diff"
 	"k8s.io/apimachinery/pkg/util/sets"
 	utilpointer "k8s.io/utils/pointer"
@@ -519,6 +520,22 @@ func TestValidateAgent(t *testing.T) {
 			},
 			pass: true,
 		},
+		{
+			name: "depends_on allowed for kubernetes agent",
+			base: func(j *JobBase) {
+				j.DependsOn = []string{"upstream"}
+			},
+			pass: true,
+		},
+		{
+			name: "depends_on rejected for jenkins agent",
+			base: func(j *JobBase) {
+				j.Agent = jenk
+				j.Spec = nil
+				j.DecorationConfig = nil
+				j.DependsOn = []string{"upstream"}
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -1095,6 +1112,140 @@ periodics:
     - image: alpine`,
 			},
 		},
+		{
+			name:       "cron periodic with time zone, jitter and blackouts",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- cron: "0 3 * * *"
+  time_zone: America/New_York
+  jitter: 10m
+  blackouts:
+  - cron: "0 0 * * 6"
+    duration: 48h
+  - start: "2019-12-20T00:00:00Z"
+    end: "2020-01-06T00:00:00Z"
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			verify: func(c *Config) error {
+				if jitter := c.Periodics[0].GetJitter(); jitter != 10*time.Minute {
+					return fmt.Errorf("expected jitter of 10m, got %v", jitter)
+				}
+				return nil
+			},
+		},
+		{
+			name:       "reject periodic with unknown time zone",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- cron: "0 3 * * *"
+  time_zone: Mars/Olympus_Mons
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
+		{
+			name:       "reject jitter on interval periodic",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- interval: 10m
+  jitter: 1m
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
+		{
+			name:       "reject blackout without duration",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- interval: 10m
+  blackouts:
+  - cron: "0 0 * * 6"
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
+		{
+			name:       "reject blackout ending before it starts",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- interval: 10m
+  blackouts:
+  - start: "2020-01-06T00:00:00Z"
+    end: "2019-12-20T00:00:00Z"
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
+		{
+			name:       "cron periodic with catch up and missed runs threshold",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- cron: "0 3 * * *"
+  catch_up: latest
+  missed_runs_threshold: 1
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+		},
+		{
+			name:       "reject unknown catch up policy",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- cron: "0 3 * * *"
+  catch_up: some
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
+		{
+			name:       "reject catch up on interval periodic",
+			prowConfig: ``,
+			jobConfigs: []string{
+				`
+periodics:
+- interval: 10m
+  catch_up: all
+  name: foo
+  spec:
+    containers:
+    - image: alpine`,
+			},
+			expectError: true,
+		},
 		{
 			name:       "one periodic no agent, should default",
 			prowConfig: ``,
@@ -1628,6 +1779,24 @@ postsubmits:
 				return nil
 			},
 		},
+		{
+			name: "trailing slash of gerrit instances is removed",
+			prowConfig: `
+tide:
+  gerrit_queries:
+  - instance: https://foo-review.googlesource.com/
+    projects:
+    - bar`,
+			verify: func(c *Config) error {
+				if instance := c.Tide.GerritQueries[0].Instance; instance != "https://foo-review.googlesource.com" {
+					return fmt.Errorf("expected the instance without a trailing slash, got %q", instance)
+				}
+				if _, ok := c.Tide.GerritInstances()["https://foo-review.googlesource.com"]; !ok {
+					return fmt.Errorf("expected the normalized instance in %v", c.Tide.GerritInstances())
+				}
+				return nil
+			},
+		},
 		{
 			name: "no jobs doesn't make AllRepos a nilpointer",
 			verify: func(c *Config) error {
@@ -2259,6 +2428,115 @@ func TestPlankJobURLPrefix(t *testing.T) {
 	}
 }
 
+func TestPlankQueueFor(t *testing.T) {
+	plank := Plank{
+		JobQueues: []JobQueue{
+			{Name: "default", Weight: 2},
+			{Name: "release", Labels: map[string]string{"release": "true"}},
+			{Name: "kubernetes", Orgs: []string{"kubernetes"}},
+			{Name: "test-infra", Repos: []string{"org/test-infra"}},
+			{Name: "empty"},
+		},
+	}
+	testCases := []struct {
+		name          string
+		pj            prowapi.ProwJob
+		expectedQueue string
+	}{
+		{
+			name:          "Job without refs goes to the default queue",
+			expectedQueue: "default",
+		},
+		{
+			name:          "Matching org",
+			pj:            prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Refs: &prowapi.Refs{Org: "kubernetes", Repo: "kubernetes"}}},
+			expectedQueue: "kubernetes",
+		},
+		{
+			name:          "Matching repo from extra refs",
+			pj:            prowapi.ProwJob{Spec: prowapi.ProwJobSpec{ExtraRefs: []prowapi.Refs{{Org: "org", Repo: "test-infra"}}}},
+			expectedQueue: "test-infra",
+		},
+		{
+			name:          "Other repo of the same org goes to the default queue",
+			pj:            prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Refs: &prowapi.Refs{Org: "org", Repo: "other"}}},
+			expectedQueue: "default",
+		},
+		{
+			name: "First matching queue wins",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"release": "true"}},
+				Spec:       prowapi.ProwJobSpec{Refs: &prowapi.Refs{Org: "kubernetes", Repo: "kubernetes"}},
+			},
+			expectedQueue: "release",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if queue := plank.QueueFor(&tc.pj); queue.Name != tc.expectedQueue {
+				t.Errorf("expected queue %q, got %q", tc.expectedQueue, queue.Name)
+			}
+		})
+	}
+}
+
+func TestValidateJobQueues(t *testing.T) {
+	testCases := []struct {
+		name            string
+		queues          []JobQueue
+		successExpected bool
+	}{
+		{
+			name: "Valid queues - no error",
+			queues: []JobQueue{
+				{Name: "default", Weight: 1, MaxConcurrency: 10},
+				{Name: "kubernetes", Orgs: []string{"kubernetes"}, Repos: []string{"org/repo"}},
+			},
+			successExpected: true,
+		},
+		{
+			name:   "Missing name - error",
+			queues: []JobQueue{{Orgs: []string{"kubernetes"}}},
+		},
+		{
+			name:   "Duplicated name - error",
+			queues: []JobQueue{{Name: "k8s", Orgs: []string{"kubernetes"}}, {Name: "k8s"}},
+		},
+		{
+			name:   "Default queue with selector - error",
+			queues: []JobQueue{{Name: "default", Orgs: []string{"kubernetes"}}},
+		},
+		{
+			name:   "Invalid repo - error",
+			queues: []JobQueue{{Name: "k8s", Repos: []string{"kubernetes"}}},
+		},
+		{
+			name:   "Negative weight - error",
+			queues: []JobQueue{{Name: "k8s", Weight: -1}},
+		},
+		{
+			name:   "Negative max concurrency - error",
+			queues: []JobQueue{{Name: "k8s", MaxConcurrency: -1}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := validateJobQueues(tc.queues); (err == nil) != tc.successExpected {
+				t.Errorf("Expected success=%t but got err=%v", tc.successExpected, err)
+			}
+			if tc.successExpected {
+				for _, queue := range tc.queues {
+					if queue.Weight < 1 {
+						t.Errorf("expected weight of queue %q to be defaulted, got %d", queue.Name, queue.Weight)
+					}
+				}
+			}
+		})
+	}
+}
+
 func TestValidateComponentConfig(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -2389,6 +2667,207 @@ func TestSlackReporterValidation(t *testing.T) {
 	}
 }
 
+func TestWebhookReporterValidation(t *testing.T) {
+	testCases := []struct {
+		name            string
+		url             string
+		allowedURLs     []string
+		payloadTemplate string
+		successExpected bool
+	}{
+		{
+			name:            "Valid config - no error",
+			url:             "https://dashboard.example.com/prow",
+			allowedURLs:     []string{"https://chat.example.com", "http://dashboard:8080"},
+			successExpected: true,
+		},
+		{
+			name:            "No url, jobs may set their own - no error",
+			successExpected: true,
+		},
+		{
+			name: "Invalid url - error",
+			url:  "dashboard",
+		},
+		{
+			name: "Url that is not http - error",
+			url:  "file:///etc/passwd",
+		},
+		{
+			name:        "Allowed url with a path - error",
+			allowedURLs: []string{"https://chat.example.com/hooks"},
+		},
+		{
+			name:        "Allowed url without a host - error",
+			allowedURLs: []string{"https://"},
+		},
+		{
+			name:            "Invalid template - error",
+			payloadTemplate: "{{ if .Spec.Job}}",
+		},
+		{
+			name:            "Template does not produce JSON - error",
+			payloadTemplate: "Job {{.Spec.Job}} failed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &WebhookReporter{
+				URL:             tc.url,
+				AllowedURLs:     tc.allowedURLs,
+				PayloadTemplate: tc.payloadTemplate,
+			}
+
+			if err := cfg.DefaultAndValidate(); (err == nil) != tc.successExpected {
+				t.Errorf("Expected success=%t but got err=%v", tc.successExpected, err)
+			}
+		})
+	}
+}
+
+func TestValidateReporterConfigs(t *testing.T) {
+	testCases := []struct {
+		name            string
+		webhookReporter *WebhookReporter
+		url             string
+		recipients      []string
+		successExpected bool
+	}{
+		{
+			name:            "Job without a url - no error",
+			successExpected: true,
+		},
+		{
+			name:            "Job url on an allowed host - no error",
+			webhookReporter: &WebhookReporter{AllowedURLs: []string{"https://chat.example.com"}},
+			url:             "https://chat.example.com/hooks/team",
+			successExpected: true,
+		},
+		{
+			name: "Job url without a webhook reporter - error",
+			url:  "https://chat.example.com/hooks/team",
+		},
+		{
+			name:            "Job url without allowed urls - error",
+			webhookReporter: &WebhookReporter{URL: "https://chat.example.com"},
+			url:             "https://chat.example.com/hooks/team",
+		},
+		{
+			name:            "Job url on another host - error",
+			webhookReporter: &WebhookReporter{AllowedURLs: []string{"https://chat.example.com"}},
+			url:             "http://169.254.169.254/computeMetadata/v1",
+		},
+		{
+			name:            "Job url with another scheme - error",
+			webhookReporter: &WebhookReporter{AllowedURLs: []string{"https://chat.example.com"}},
+			url:             "http://chat.example.com/hooks/team",
+		},
+		{
+			name:            "Valid email recipients - no error",
+			recipients:      []string{"team@example.com", "Release Team <release@example.com>"},
+			successExpected: true,
+		},
+		{
+			name:       "Invalid email recipient - error",
+			recipients: []string{"team@example.com\r\nBcc: everyone@example.com"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &Config{
+				JobConfig: JobConfig{
+					Periodics: []Periodic{{JobBase: JobBase{
+						Name: "nightly",
+						ReporterConfig: &prowapi.ReporterConfig{
+							Webhook: &prowapi.WebhookReporterConfig{URL: tc.url},
+							Email:   &prowapi.EmailReporterConfig{Recipients: tc.recipients},
+						},
+					}}},
+				},
+				ProwConfig: ProwConfig{WebhookReporter: tc.webhookReporter},
+			}
+
+			if err := cfg.validateReporterConfigs(); (err == nil) != tc.successExpected {
+				t.Errorf("Expected success=%t but got err=%v", tc.successExpected, err)
+			}
+		})
+	}
+}
+
+func TestWebhookReporterPayload(t *testing.T) {
+	cfg := &WebhookReporter{}
+	if err := cfg.DefaultAndValidate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pj := &prowapi.ProwJob{
+		Spec:   prowapi.ProwJobSpec{Job: `say "hi"`, Type: prowapi.PeriodicJob},
+		Status: prowapi.ProwJobStatus{State: prowapi.FailureState, BuildID: "42"},
+	}
+	payload, err := cfg.Payload(pj)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `{"job": "say \"hi\"", "type": "periodic", "state": "failure", "url": "", "build_id": "42", "refs": null}`
+	if string(payload) != expected {
+		t.Errorf("expected payload %s, got %s", expected, string(payload))
+	}
+}
+
+func TestEmailReporterValidation(t *testing.T) {
+	testCases := []struct {
+		name            string
+		from            string
+		recipients      []string
+		subjectTemplate string
+		digestInterval  *metav1.Duration
+		successExpected bool
+	}{
+		{
+			name:            "Valid config - no error",
+			from:            "prow@example.com",
+			recipients:      []string{"release@example.com"},
+			digestInterval:  &metav1.Duration{Duration: time.Hour},
+			successExpected: true,
+		},
+		{
+			name:       "No from address - error",
+			recipients: []string{"release@example.com"},
+		},
+		{
+			name:       "Invalid recipient - error",
+			from:       "prow@example.com",
+			recipients: []string{"release"},
+		},
+		{
+			name:            "Template accessed invalid property - error",
+			from:            "prow@example.com",
+			subjectTemplate: "{{ .Undef}}",
+		},
+		{
+			name:           "Zero digest interval - error",
+			from:           "prow@example.com",
+			digestInterval: &metav1.Duration{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &EmailReporter{
+				From:            tc.from,
+				Recipients:      tc.recipients,
+				SubjectTemplate: tc.subjectTemplate,
+				DigestInterval:  tc.digestInterval,
+			}
+
+			if err := cfg.DefaultAndValidate(); (err == nil) != tc.successExpected {
+				t.Errorf("Expected success=%t but got err=%v", tc.successExpected, err)
+			}
+		})
+	}
+}
+
 func TestValidateTriggering(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -2438,6 +2917,67 @@ func TestValidateTriggering(t *testing.T) {
 	}
 }
 
+func TestValidateDependencies(t *testing.T) {
+	testCases := []struct {
+		name        string
+		jobs        []JobBase
+		errExpected bool
+	}{
+		{
+			name: "no dependencies, no err",
+			jobs: []JobBase{{Name: "unit"}, {Name: "e2e"}},
+		},
+		{
+			name: "chain of dependencies, no err",
+			jobs: []JobBase{
+				{Name: "build"},
+				{Name: "unit", DependsOn: []string{"build"}},
+				{Name: "e2e", DependsOn: []string{"build", "unit"}},
+			},
+		},
+		{
+			name: "same job on different branches merges dependencies, no err",
+			jobs: []JobBase{
+				{Name: "build"},
+				{Name: "e2e", DependsOn: []string{"build"}},
+				{Name: "e2e"},
+			},
+		},
+		{
+			name: "unknown dependency, err",
+			jobs: []JobBase{
+				{Name: "e2e", DependsOn: []string{"build"}},
+			},
+			errExpected: true,
+		},
+		{
+			name: "self dependency, err",
+			jobs: []JobBase{
+				{Name: "e2e", DependsOn: []string{"e2e"}},
+			},
+			errExpected: true,
+		},
+		{
+			name: "cycle, err",
+			jobs: []JobBase{
+				{Name: "build", DependsOn: []string{"e2e"}},
+				{Name: "unit", DependsOn: []string{"build"}},
+				{Name: "e2e", DependsOn: []string{"unit"}},
+			},
+			errExpected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDependencies(tc.jobs)
+			if err != nil != tc.errExpected {
+				t.Errorf("Expected err: %t but got err %v", tc.errExpected, err)
+			}
+		})
+	}
+}
+
 func TestRefGetterForGitHubPullRequest(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -17,11 +17,13 @@ limitations under the License.
 package config
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"time"
 
 	pipelinev1alpha1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	cron "gopkg.in/robfig/cron.v2"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -98,6 +100,13 @@ type JobBase struct {
 	// If this field is unspecified or false, a new pod will be created to replace
 	// the evicted one.
 	ErrorOnEviction bool `json:"error_on_eviction,omitempty"`
+	// DependsOn lists the names of jobs of the same type and repo that
+	// must succeed against the same refs before this job is started.
+	// If any of them does not succeed, this job is aborted.
+	DependsOn []string `json:"depends_on,omitempty"`
+	// RetryPolicy determines if and how the job is retried when it
+	// fails because of an infrastructure problem, e.g. a lost node.
+	RetryPolicy *prowapi.RetryPolicy `json:"retry_policy,omitempty"`
 	// SourcePath contains the path where this job is defined
 	SourcePath string `json:"-"`
 	// Spec is the Kubernetes pod spec used if Agent is kubernetes.
@@ -174,12 +183,60 @@ type Periodic struct {
 	Interval string `json:"interval,omitempty"`
 	// Cron representation of job trigger time
 	Cron string `json:"cron,omitempty"`
+	// TimeZone is the IANA time zone, such as America/New_York, that the
+	// cron and blackout schedules are evaluated in. Defaults to UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Jitter is the maximum random delay added to each cron trigger, to
+	// avoid starting many jobs at the same time. Only applies to cron jobs.
+	Jitter string `json:"jitter,omitempty"`
+	// Blackouts are windows of time during which the job is not triggered.
+	Blackouts []Blackout `json:"blackouts,omitempty"`
+	// CatchUp is what horologium does about cron runs that were missed, for
+	// example while it was down. Defaults to none.
+	CatchUp CatchUpPolicy `json:"catch_up,omitempty"`
+	// MissedRunsThreshold is the number of missed cron runs above which deck
+	// warns about the job. Unset means deck never warns.
+	MissedRunsThreshold *int `json:"missed_runs_threshold,omitempty"`
 	// Tags for config entries
 	Tags []string `json:"tags,omitempty"`
 
 	interval time.Duration
+	jitter   time.Duration
 }
 
+// CatchUpPolicy determines how missed runs of a cron periodic are made up for.
+type CatchUpPolicy string
+
+const (
+	// CatchUpNone skips missed runs and waits for the next scheduled one.
+	CatchUpNone CatchUpPolicy = "none"
+	// CatchUpLatest starts a single run when any runs were missed.
+	CatchUpLatest CatchUpPolicy = "latest"
+	// CatchUpAll starts one run for every missed run, one after the other.
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+// Blackout is a window of time during which a periodic is not triggered.
+// Tide merge windows use it for the windows during which merging is or is
+// not allowed. A recurring window starts on the Cron schedule and lasts for
+// Duration, for example every weekend. A one-off window, for example a
+// release freeze, lasts from Start until End.
+type Blackout struct {
+	// Cron is when a recurring window starts, in the time zone of the
+	// periodic or merge window.
+	Cron string `json:"cron,omitempty"`
+	// Duration is how long a recurring window lasts.
+	Duration string `json:"duration,omitempty"`
+	// Start is when a one-off window starts, in RFC3339 format.
+	Start string `json:"start,omitempty"`
+	// End is when a one-off window ends, in RFC3339 format.
+	End string `json:"end,omitempty"`
+}
+
+// maxScheduleLookahead bounds the number of runs NextTrigger skips while
+// looking for one outside of blackout windows.
+const maxScheduleLookahead = 10000
+
 // JenkinsSpec holds optional Jenkins job config
 type JenkinsSpec struct {
 	// Job is managed by the GH branch source plugin
@@ -197,6 +254,186 @@ func (p *Periodic) GetInterval() time.Duration {
 	return p.interval
 }
 
+// GetJitter returns jitter, the maximum delay added to each cron trigger.
+func (p *Periodic) GetJitter() time.Duration {
+	return p.jitter
+}
+
+// location returns the time zone schedules are evaluated in.
+func (p *Periodic) location() string {
+	if p.TimeZone == "" {
+		return "UTC"
+	}
+	return p.TimeZone
+}
+
+// CronSpec returns the cron schedule including the time zone it is evaluated in.
+func (p *Periodic) CronSpec() string {
+	return fmt.Sprintf("TZ=%s %s", p.location(), p.Cron)
+}
+
+// InBlackout returns whether t falls within one of the job's blackout windows.
+func (p *Periodic) InBlackout(t time.Time) bool {
+	_, active := p.blackoutEnd(t)
+	return active
+}
+
+// blackoutEnd returns when the last of the blackout windows covering t ends.
+func (p *Periodic) blackoutEnd(t time.Time) (time.Time, bool) {
+	return windowsEnd(p.Blackouts, t, p.location())
+}
+
+// windowsEnd returns whether one of the windows covers t and, if so, when
+// the last of the windows covering t ends.
+func windowsEnd(windows []Blackout, t time.Time, location string) (time.Time, bool) {
+	var end time.Time
+	active := false
+	for _, b := range windows {
+		if windowEnd, covers, err := b.window(t, location); err == nil && covers {
+			active = true
+			if windowEnd.After(end) {
+				end = windowEnd
+			}
+		}
+	}
+	return end, active
+}
+
+// windowsStart returns when the first of the windows that start after t
+// starts, if any.
+func windowsStart(windows []Blackout, t time.Time, location string) (time.Time, bool) {
+	var start time.Time
+	found := false
+	for _, b := range windows {
+		if windowStart, ok, err := b.nextStart(t, location); err == nil && ok {
+			if !found || windowStart.Before(start) {
+				start = windowStart
+			}
+			found = true
+		}
+	}
+	return start, found
+}
+
+// NextTrigger returns the first time from earliest on at which the job
+// may be triggered outside of its blackout windows. Cron jobs trigger on
+// their schedule, while interval jobs trigger as soon as they are allowed.
+func (p *Periodic) NextTrigger(earliest time.Time) (time.Time, error) {
+	next := earliest
+	step := func(t time.Time) time.Time {
+		end, _ := p.blackoutEnd(t)
+		return end
+	}
+	if p.Cron != "" {
+		schedule, err := cron.Parse(p.CronSpec())
+		if err != nil {
+			return time.Time{}, err
+		}
+		next = schedule.Next(earliest)
+		step = schedule.Next
+	}
+	for i := 0; i < maxScheduleLookahead; i++ {
+		if !p.InBlackout(next) {
+			return next, nil
+		}
+		next = step(next)
+	}
+	return time.Time{}, fmt.Errorf("no trigger outside of blackouts in the next %d runs", maxScheduleLookahead)
+}
+
+// ScheduledRuns returns the cron runs after the given time up to and
+// including before, leaving out runs during blackout windows.
+func (p *Periodic) ScheduledRuns(after, before time.Time) ([]time.Time, error) {
+	if p.Cron == "" {
+		return nil, fmt.Errorf("periodic %s does not have a cron schedule", p.Name)
+	}
+	schedule, err := cron.Parse(p.CronSpec())
+	if err != nil {
+		return nil, err
+	}
+	var runs []time.Time
+	next := schedule.Next(after)
+	for i := 0; i < maxScheduleLookahead && !next.After(before); i++ {
+		if !p.InBlackout(next) {
+			runs = append(runs, next)
+		}
+		next = schedule.Next(next)
+	}
+	return runs, nil
+}
+
+// window returns whether the window covers t and, if so, when it ends.
+func (b Blackout) window(t time.Time, location string) (time.Time, bool, error) {
+	if b.Cron == "" {
+		start, err := time.Parse(time.RFC3339, b.Start)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid start: %v", err)
+		}
+		end, err := time.Parse(time.RFC3339, b.End)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid end: %v", err)
+		}
+		return end, !t.Before(start) && t.Before(end), nil
+	}
+	schedule, err := cron.Parse(fmt.Sprintf("TZ=%s %s", location, b.Cron))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid cron: %v", err)
+	}
+	duration, err := time.ParseDuration(b.Duration)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid duration: %v", err)
+	}
+	// The window covering t, if any, started within the last duration.
+	start := schedule.Next(t.Add(-duration))
+	return start.Add(duration), !start.After(t), nil
+}
+
+// nextStart returns when the window next starts after t, if it does.
+func (b Blackout) nextStart(t time.Time, location string) (time.Time, bool, error) {
+	if b.Cron == "" {
+		start, err := time.Parse(time.RFC3339, b.Start)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid start: %v", err)
+		}
+		return start, start.After(t), nil
+	}
+	schedule, err := cron.Parse(fmt.Sprintf("TZ=%s %s", location, b.Cron))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid cron: %v", err)
+	}
+	return schedule.Next(t), true, nil
+}
+
+// validate ensures the window is either recurring or one-off and can be parsed.
+func (b Blackout) validate(location string) error {
+	recurring := b.Cron != "" || b.Duration != ""
+	oneOff := b.Start != "" || b.End != ""
+	switch {
+	case recurring && oneOff:
+		return errors.New("cron and duration cannot be set together with start and end")
+	case recurring && (b.Cron == "" || b.Duration == ""):
+		return errors.New("cron and duration must be set together")
+	case oneOff && (b.Start == "" || b.End == ""):
+		return errors.New("start and end must be set together")
+	case !recurring && !oneOff:
+		return errors.New("either cron and duration or start and end must be set")
+	}
+	if recurring {
+		if d, err := time.ParseDuration(b.Duration); err == nil && d <= 0 {
+			return fmt.Errorf("duration %s must be positive", b.Duration)
+		}
+	}
+	if oneOff {
+		start, startErr := time.Parse(time.RFC3339, b.Start)
+		end, endErr := time.Parse(time.RFC3339, b.End)
+		if startErr == nil && endErr == nil && !end.After(start) {
+			return fmt.Errorf("end %s must be after start %s", b.End, b.Start)
+		}
+	}
+	_, _, err := b.window(time.Now(), location)
+	return err
+}
+
 // Brancher is for shared code between jobs that only run against certain
 // branches. An empty brancher runs against all branches.
 type Brancher struct {
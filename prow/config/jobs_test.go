@@ -23,6 +23,7 @@ import (
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	coreapi "k8s.io/api/core/v1"
 )
@@ -920,3 +921,84 @@ func TestPostsubmitShouldRun(t *testing.T) {
 		})
 	}
 }
+
+func TestPeriodicSchedule(t *testing.T) {
+	mustParse := func(s string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", s, err)
+		}
+		return parsed
+	}
+	weekends := Blackout{Cron: "0 0 * * 6", Duration: "48h"}
+	freeze := Blackout{Start: "2019-12-20T00:00:00Z", End: "2020-01-06T00:00:00Z"}
+
+	testcases := []struct {
+		name            string
+		periodic        Periodic
+		at              string
+		expectBlackout  bool
+		expectedTrigger string
+	}{
+		{
+			name:            "cron job without blackouts",
+			periodic:        Periodic{Cron: "0 3 * * *"},
+			at:              "2019-11-06T12:00:00Z",
+			expectedTrigger: "2019-11-07T03:00:00Z",
+		},
+		{
+			name:            "cron job in another time zone",
+			periodic:        Periodic{Cron: "0 3 * * *", TimeZone: "America/New_York"},
+			at:              "2019-11-06T12:00:00Z",
+			expectedTrigger: "2019-11-07T08:00:00Z",
+		},
+		{
+			name:            "cron job skips a weekend blackout",
+			periodic:        Periodic{Cron: "0 3 * * *", Blackouts: []Blackout{weekends}},
+			at:              "2019-11-08T12:00:00Z",
+			expectedTrigger: "2019-11-11T03:00:00Z",
+		},
+		{
+			name:            "interval job during a weekend blackout",
+			periodic:        Periodic{Interval: "1h", Blackouts: []Blackout{weekends}},
+			at:              "2019-11-09T12:00:00Z",
+			expectBlackout:  true,
+			expectedTrigger: "2019-11-11T00:00:00Z",
+		},
+		{
+			name:            "weekend blackout follows the time zone",
+			periodic:        Periodic{Interval: "1h", TimeZone: "America/New_York", Blackouts: []Blackout{weekends}},
+			at:              "2019-11-09T02:00:00Z",
+			expectedTrigger: "2019-11-09T02:00:00Z",
+		},
+		{
+			name:            "interval job during a one-off blackout",
+			periodic:        Periodic{Interval: "1h", Blackouts: []Blackout{freeze}},
+			at:              "2019-12-25T12:00:00Z",
+			expectBlackout:  true,
+			expectedTrigger: "2020-01-06T00:00:00Z",
+		},
+		{
+			name:            "interval job after a one-off blackout",
+			periodic:        Periodic{Interval: "1h", Blackouts: []Blackout{freeze}},
+			at:              "2020-01-06T00:00:00Z",
+			expectedTrigger: "2020-01-06T00:00:00Z",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			at := mustParse(tc.at)
+			if blackout := tc.periodic.InBlackout(at); blackout != tc.expectBlackout {
+				t.Errorf("expected blackout %t, got %t", tc.expectBlackout, blackout)
+			}
+			trigger, err := tc.periodic.NextTrigger(at)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expected := mustParse(tc.expectedTrigger); !trigger.Equal(expected) {
+				t.Errorf("expected next trigger %s, got %s", expected, trigger.UTC())
+			}
+		})
+	}
+}
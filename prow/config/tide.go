@@ -19,9 +19,11 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
@@ -141,6 +143,323 @@ type Tide struct {
 	//  0 => unlimited batch size
 	// -1 => batch merging disabled :(
 	BatchSizeLimitMap map[string]int `json:"batch_size_limit,omitempty"`
+
+	// Priority is an ordered list of priority tiers. PRs in a tier are merged,
+	// tested and batched before the PRs of later tiers and before PRs that are
+	// in no tier at all. Within a tier PRs are picked by number, lowest first.
+	Priority []TidePriority `json:"priority,omitempty"`
+
+	// BisectFailedBatches makes Tide test both halves of a failed batch as
+	// new batches instead of dropping it, so that the PRs that broke it are
+	// isolated quickly and the others can be merged.
+	BisectFailedBatches bool `json:"bisect_failed_batches,omitempty"`
+
+	// MergeTrainMap is a key/value pair of an org or org/repo as the key and
+	// the maximum number of cars of the merge train as the value. The key "*"
+	// can be used as a global default. In train mode every car is a batch
+	// that is tested on top of the PRs of the car in front of it, while that
+	// car is still being tested. A value of 0 disables train mode.
+	MergeTrainMap map[string]int `json:"merge_train,omitempty"`
+
+	// OwnersApprovalMap is a key/value pair of an org or org/repo as the key
+	// and whether its PRs need an approval from the OWNERS of every directory
+	// they touch as the value. The key "*" can be used as a global default.
+	// Approvals are GitHub reviews by an approver listed in the OWNERS files
+	// of a directory or of any of its parents.
+	OwnersApprovalMap map[string]bool `json:"owners_approval,omitempty"`
+
+	// ConflictPredictionMap is a key/value pair of an org or org/repo as the
+	// key and whether Tide predicts which of its pooled PRs conflict with
+	// each other as the value. The key "*" can be used as a global default.
+	// PRs that are predicted to conflict are not batched together, and PRs
+	// that conflict with a PR ahead of them in the pool are labeled with
+	// ConflictLabel and commented on.
+	ConflictPredictionMap map[string]bool `json:"predict_conflicts,omitempty"`
+	// ConflictLabel is the label of PRs that are predicted to conflict with a
+	// PR ahead of them in the pool. Defaults to DefaultConflictLabel.
+	ConflictLabel string `json:"conflict_label,omitempty"`
+
+	// PostsubmitReverts opt repos in to reverting the PRs that Tide merged,
+	// or blocking their branch, when a blocking postsubmit fails on them.
+	PostsubmitReverts []TidePostsubmitRevert `json:"postsubmit_reverts,omitempty"`
+
+	// MergeWindows restrict when Tide merges into the branches of repos.
+	// The first merge window that applies to a branch is used.
+	MergeWindows []TideMergeWindow `json:"merge_windows,omitempty"`
+
+	// GerritQueries select the Gerrit changes that are in the merge pool in
+	// addition to the GitHub PRs selected by Queries.
+	GerritQueries []TideGerritQuery `json:"gerrit_queries,omitempty"`
+}
+
+// DefaultConflictLabel is the label of PRs that are predicted to conflict
+// with a PR ahead of them in the pool if the config does not set one.
+const DefaultConflictLabel = "tide/conflicts-ahead"
+
+// DefaultGerritLabels are the label votes a Gerrit change needs to be merged
+// if a Gerrit query does not list its own.
+var DefaultGerritLabels = []string{"Code-Review=2", "Verified=1"}
+
+// TideGerritQuery selects the open changes of Gerrit projects whose labels
+// allow them to be merged.
+type TideGerritQuery struct {
+	// Instance is the URL of the Gerrit instance, like
+	// https://android-review.googlesource.com. A trailing slash is removed.
+	Instance string `json:"instance"`
+	// Projects are the projects of the instance to merge changes of.
+	Projects []string `json:"projects"`
+	// Labels are the label votes a change needs, like "Code-Review=2".
+	// Defaults to DefaultGerritLabels.
+	Labels []string `json:"labels,omitempty"`
+}
+
+// RequiredLabels returns the label votes a change needs to be merged.
+func (q *TideGerritQuery) RequiredLabels() []string {
+	if len(q.Labels) == 0 {
+		return DefaultGerritLabels
+	}
+	return q.Labels
+}
+
+// Query returns the Gerrit search query for the changes of the project that
+// can be merged.
+func (q *TideGerritQuery) Query(project string) string {
+	toks := []string{"status:open", fmt.Sprintf("project:%s", project)}
+	for _, label := range q.RequiredLabels() {
+		toks = append(toks, fmt.Sprintf("label:%s", label))
+	}
+	return strings.Join(toks, " ")
+}
+
+// Validate returns an error if the query is not valid.
+func (q *TideGerritQuery) Validate() error {
+	u, err := url.Parse(q.Instance)
+	if err != nil {
+		return fmt.Errorf("instance %q is not a url: %v", q.Instance, err)
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("instance %q must be the url of the host", q.Instance)
+	}
+	if len(q.Projects) == 0 {
+		return errors.New("no projects")
+	}
+	for _, label := range q.Labels {
+		if parts := strings.SplitN(label, "=", 2); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("label %q is not in the form of Label=value", label)
+		}
+	}
+	return nil
+}
+
+// GerritInstances returns the projects of the Gerrit queries by instance.
+func (t *Tide) GerritInstances() map[string][]string {
+	instances := map[string][]string{}
+	for _, q := range t.GerritQueries {
+		instances[q.Instance] = append(instances[q.Instance], q.Projects...)
+	}
+	return instances
+}
+
+// The actions Tide can take when a blocking postsubmit fails on merged PRs.
+const (
+	// RevertActionRevert opens a PR that reverts the merged PRs.
+	RevertActionRevert = "revert"
+	// RevertActionBlock opens a merge blocker issue for the branch.
+	RevertActionBlock = "block"
+)
+
+// TidePostsubmitRevert configures what Tide does when a blocking postsubmit
+// fails on the PRs it merged into a repo.
+type TidePostsubmitRevert struct {
+	// Repos are the org/repos whose merges are watched.
+	Repos []string `json:"repos"`
+	// Jobs are the names of the blocking postsubmits.
+	Jobs []string `json:"jobs"`
+	// Action is either "revert" to open a PR that reverts the merged PRs, or
+	// "block" to open a merge blocker issue for the branch. Defaults to
+	// "revert".
+	Action string `json:"action,omitempty"`
+}
+
+// RevertAction returns the action to take when a blocking postsubmit fails.
+func (r *TidePostsubmitRevert) RevertAction() string {
+	if r.Action == "" {
+		return RevertActionRevert
+	}
+	return r.Action
+}
+
+// Validate returns an error if the config is not valid. Blocking the branch
+// requires the label of merge blocker issues.
+func (r *TidePostsubmitRevert) Validate(blockerLabel string) error {
+	if len(r.Repos) == 0 {
+		return errors.New("no repos")
+	}
+	for _, repo := range r.Repos {
+		if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("repo %q is not in the form of org/repo", repo)
+		}
+	}
+	if len(r.Jobs) == 0 {
+		return errors.New("no jobs")
+	}
+	switch r.RevertAction() {
+	case RevertActionRevert:
+	case RevertActionBlock:
+		if blockerLabel == "" {
+			return errors.New("the block action requires a blocker_label")
+		}
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	return nil
+}
+
+// PostsubmitRevert returns the config for failing postsubmits of a repo, or
+// nil if the repo did not opt in.
+func (t *Tide) PostsubmitRevert(org, repo string) *TidePostsubmitRevert {
+	for i := range t.PostsubmitReverts {
+		for _, r := range t.PostsubmitReverts[i].Repos {
+			if r == org+"/"+repo {
+				return &t.PostsubmitReverts[i]
+			}
+		}
+	}
+	return nil
+}
+
+// TideMergeWindow restricts when Tide merges into the branches of repos.
+// Merging is allowed during the windows, or at any time if there are none,
+// unless a freeze covers that time. PRs with an exempt label are merged even
+// while merging is not allowed.
+type TideMergeWindow struct {
+	// Repos are the orgs and org/repos the merge window applies to.
+	Repos []string `json:"repos"`
+	// Branches are the branches the merge window applies to. Defaults to
+	// all branches.
+	Branches []string `json:"branches,omitempty"`
+	// TimeZone is the IANA time zone, such as America/New_York, that the
+	// cron schedules are evaluated in. Defaults to UTC.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Windows are the windows of time during which merging is allowed.
+	Windows []Blackout `json:"windows,omitempty"`
+	// Freezes are the windows of time during which merging is not allowed.
+	Freezes []Blackout `json:"freezes,omitempty"`
+	// ExemptLabels are labels that allow a PR to merge while merging is not
+	// allowed, like cherry-pick-approved.
+	ExemptLabels []string `json:"exempt_labels,omitempty"`
+}
+
+// location returns the time zone schedules are evaluated in.
+func (w *TideMergeWindow) location() string {
+	if w.TimeZone == "" {
+		return "UTC"
+	}
+	return w.TimeZone
+}
+
+// applies returns whether the merge window applies to the branch.
+func (w *TideMergeWindow) applies(org, repo, branch string) bool {
+	repos := sets.NewString(w.Repos...)
+	if !repos.Has(org) && !repos.Has(org+"/"+repo) {
+		return false
+	}
+	return len(w.Branches) == 0 || sets.NewString(w.Branches...).Has(branch)
+}
+
+// ClosedUntil returns whether merging is not allowed at t and, if so, when
+// it is allowed again. The time is zero if merging is not allowed again
+// within the lookahead.
+func (w *TideMergeWindow) ClosedUntil(t time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(w.location())
+	if err != nil {
+		loc = time.UTC
+	}
+	next := t
+	for i := 0; i < maxScheduleLookahead; i++ {
+		if end, frozen := windowsEnd(w.Freezes, next, w.location()); frozen {
+			next = end
+			continue
+		}
+		if _, open := windowsEnd(w.Windows, next, w.location()); len(w.Windows) > 0 && !open {
+			start, ok := windowsStart(w.Windows, next, w.location())
+			if !ok {
+				return time.Time{}, true
+			}
+			next = start
+			continue
+		}
+		return next.In(loc), !next.Equal(t)
+	}
+	return time.Time{}, true
+}
+
+// Exempt returns whether a PR with the labels is merged while merging is
+// not allowed.
+func (w *TideMergeWindow) Exempt(labels []string) bool {
+	return sets.NewString(w.ExemptLabels...).HasAny(labels...)
+}
+
+// Validate returns an error if the merge window is not valid.
+func (w *TideMergeWindow) Validate() error {
+	if len(w.Repos) == 0 {
+		return errors.New("no repos")
+	}
+	if _, err := time.LoadLocation(w.location()); err != nil {
+		return fmt.Errorf("invalid time zone %q: %v", w.TimeZone, err)
+	}
+	for i, b := range w.Windows {
+		if err := b.validate(w.location()); err != nil {
+			return fmt.Errorf("invalid window %d: %v", i, err)
+		}
+	}
+	for i, b := range w.Freezes {
+		if err := b.validate(w.location()); err != nil {
+			return fmt.Errorf("invalid freeze %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// MergeWindow returns the merge window of a branch, or nil if merging into
+// it is not restricted.
+func (t *Tide) MergeWindow(org, repo, branch string) *TideMergeWindow {
+	for i := range t.MergeWindows {
+		if t.MergeWindows[i].applies(org, repo, branch) {
+			return &t.MergeWindows[i]
+		}
+	}
+	return nil
+}
+
+// TidePriority is a priority tier of the PRs in a merge pool.
+type TidePriority struct {
+	// Labels are the labels a PR needs to have to be in the tier. An entry
+	// may list alternatives separated by '|', e.g.
+	// "priority/critical-urgent|priority/important-soon".
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Matches returns whether a PR with the given labels is in the tier.
+func (tp TidePriority) Matches(labels sets.String) bool {
+	for _, label := range tp.Labels {
+		if !labels.HasAny(strings.Split(label, "|")...) {
+			return false
+		}
+	}
+	return true
+}
+
+// PriorityTier returns the index of the first priority tier a PR with the
+// given labels is in, or the number of tiers if it is in none of them.
+// Lower tiers are more important.
+func (t *Tide) PriorityTier(labels sets.String) int {
+	for i, tier := range t.Priority {
+		if tier.Matches(labels) {
+			return i
+		}
+	}
+	return len(t.Priority)
 }
 
 func (t *Tide) BatchSizeLimit(org, repo string) int {
@@ -153,6 +472,42 @@ func (t *Tide) BatchSizeLimit(org, repo string) int {
 	return t.BatchSizeLimitMap["*"]
 }
 
+// MergeTrainLength returns the maximum number of cars of the merge train of
+// a repo, or 0 if train mode is disabled for it.
+func (t *Tide) MergeTrainLength(org, repo string) int {
+	if length, ok := t.MergeTrainMap[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		return length
+	}
+	if length, ok := t.MergeTrainMap[org]; ok {
+		return length
+	}
+	return t.MergeTrainMap["*"]
+}
+
+// OwnersApprovalRequired returns whether the PRs of a repo need an approval
+// from the OWNERS of every directory they touch to be merged.
+func (t *Tide) OwnersApprovalRequired(org, repo string) bool {
+	if required, ok := t.OwnersApprovalMap[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		return required
+	}
+	if required, ok := t.OwnersApprovalMap[org]; ok {
+		return required
+	}
+	return t.OwnersApprovalMap["*"]
+}
+
+// ConflictPredictionEnabled returns whether Tide predicts which of the
+// pooled PRs of a repo conflict with each other.
+func (t *Tide) ConflictPredictionEnabled(org, repo string) bool {
+	if enabled, ok := t.ConflictPredictionMap[fmt.Sprintf("%s/%s", org, repo)]; ok {
+		return enabled
+	}
+	if enabled, ok := t.ConflictPredictionMap[org]; ok {
+		return enabled
+	}
+	return t.ConflictPredictionMap["*"]
+}
+
 // MergeMethod returns the merge method to use for a repo. The default of merge is
 // returned when not overridden.
 func (t *Tide) MergeMethod(org, repo string) github.PullRequestMergeType {
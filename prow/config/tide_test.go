@@ -20,6 +20,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/diff"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -57,6 +58,47 @@ func TestTideQuery(t *testing.T) {
 	checkTok("review:approved")
 }
 
+func TestTidePriorityTier(t *testing.T) {
+	tide := Tide{
+		Priority: []TidePriority{
+			{Labels: []string{"priority/critical-urgent"}},
+			{Labels: []string{"kind/bug", "priority/important-soon|priority/important-longterm"}},
+		},
+	}
+	testCases := []struct {
+		name     string
+		labels   []string
+		expected int
+	}{
+		{
+			name:     "first matching tier wins",
+			labels:   []string{"priority/critical-urgent", "kind/bug", "priority/important-soon"},
+			expected: 0,
+		},
+		{
+			name:     "all labels of a tier are required",
+			labels:   []string{"kind/bug", "priority/important-longterm"},
+			expected: 1,
+		},
+		{
+			name:     "one of the labels missing",
+			labels:   []string{"priority/important-soon"},
+			expected: 2,
+		},
+		{
+			name:     "no labels",
+			expected: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tier := tide.PriorityTier(sets.NewString(tc.labels...)); tier != tc.expected {
+				t.Errorf("expected tier %d, got %d", tc.expected, tier)
+			}
+		})
+	}
+}
+
 func TestOrgExceptionsAndRepos(t *testing.T) {
 	queries := TideQueries{
 		{
@@ -856,6 +898,56 @@ func TestTideQuery_Validate(t *testing.T) {
 	}
 }
 
+func TestTideGerritQuery(t *testing.T) {
+	testCases := []struct {
+		name        string
+		query       TideGerritQuery
+		expected    string
+		expectError bool
+	}{
+		{
+			name:     "default labels",
+			query:    TideGerritQuery{Instance: "https://foo-review.googlesource.com", Projects: []string{"bar"}},
+			expected: "status:open project:bar label:Code-Review=2 label:Verified=1",
+		},
+		{
+			name:     "custom labels",
+			query:    TideGerritQuery{Instance: "https://foo-review.googlesource.com", Projects: []string{"bar"}, Labels: []string{"Code-Review=2"}},
+			expected: "status:open project:bar label:Code-Review=2",
+		},
+		{
+			name:        "instance with a path",
+			query:       TideGerritQuery{Instance: "https://foo-review.googlesource.com/bar", Projects: []string{"bar"}},
+			expectError: true,
+		},
+		{
+			name:        "no projects",
+			query:       TideGerritQuery{Instance: "https://foo-review.googlesource.com"},
+			expectError: true,
+		},
+		{
+			name:        "label without a value",
+			query:       TideGerritQuery{Instance: "https://foo-review.googlesource.com", Projects: []string{"bar"}, Labels: []string{"Verified"}},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.query.Validate()
+			if err != nil && !tc.expectError {
+				t.Errorf("Unexpected error: %v.", err)
+			} else if err == nil && tc.expectError {
+				t.Error("Expected a validation error, but didn't get one.")
+			}
+			if tc.expected != "" {
+				if q := tc.query.Query("bar"); q != tc.expected {
+					t.Errorf("expected query %q, got %q", tc.expected, q)
+				}
+			}
+		})
+	}
+}
+
 func TestTideContextPolicy_Validate(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -1025,3 +1117,147 @@ func TestTideContextPolicy_MissingRequiredContexts(t *testing.T) {
 		}
 	}
 }
+
+func TestTidePostsubmitRevert(t *testing.T) {
+	testCases := []struct {
+		name         string
+		revert       TidePostsubmitRevert
+		blockerLabel string
+		expectError  bool
+	}{
+		{
+			name:   "revert by default",
+			revert: TidePostsubmitRevert{Repos: []string{"org/repo"}, Jobs: []string{"post-build"}},
+		},
+		{
+			name:         "block with a blocker label",
+			revert:       TidePostsubmitRevert{Repos: []string{"org/repo"}, Jobs: []string{"post-build"}, Action: RevertActionBlock},
+			blockerLabel: "merge-blocker",
+		},
+		{
+			name:        "block without a blocker label",
+			revert:      TidePostsubmitRevert{Repos: []string{"org/repo"}, Jobs: []string{"post-build"}, Action: RevertActionBlock},
+			expectError: true,
+		},
+		{
+			name:        "org instead of a repo",
+			revert:      TidePostsubmitRevert{Repos: []string{"org"}, Jobs: []string{"post-build"}},
+			expectError: true,
+		},
+		{
+			name:        "no jobs",
+			revert:      TidePostsubmitRevert{Repos: []string{"org/repo"}},
+			expectError: true,
+		},
+		{
+			name:        "unknown action",
+			revert:      TidePostsubmitRevert{Repos: []string{"org/repo"}, Jobs: []string{"post-build"}, Action: "rollback"},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.revert.Validate(tc.blockerLabel)
+			if err != nil && !tc.expectError {
+				t.Errorf("Unexpected error: %v.", err)
+			} else if err == nil && tc.expectError {
+				t.Error("Expected a validation error, but didn't get one.")
+			}
+		})
+	}
+
+	tide := Tide{PostsubmitReverts: []TidePostsubmitRevert{{Repos: []string{"org/repo"}, Jobs: []string{"post-build"}}}}
+	if r := tide.PostsubmitRevert("org", "repo"); r == nil || r.RevertAction() != RevertActionRevert {
+		t.Errorf("expected org/repo to revert, got %+v", r)
+	}
+	if r := tide.PostsubmitRevert("org", "other"); r != nil {
+		t.Errorf("expected org/other not to opt in, got %+v", r)
+	}
+}
+
+func TestTideMergeWindow(t *testing.T) {
+	// Merging is allowed from 9:00 to 17:00 on weekdays in Berlin, except
+	// during a freeze over the new year.
+	window := TideMergeWindow{
+		Repos:        []string{"org"},
+		Branches:     []string{"master"},
+		TimeZone:     "Europe/Berlin",
+		Windows:      []Blackout{{Cron: "0 9 * * 1-5", Duration: "8h"}},
+		Freezes:      []Blackout{{Start: "2019-12-31T12:00:00Z", End: "2020-01-02T12:00:00Z"}},
+		ExemptLabels: []string{"cherry-pick-approved"},
+	}
+	if err := window.Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Failed to load time zone: %v.", err)
+	}
+	at := func(month time.Month, day, hour, min int) time.Time {
+		year := 2019
+		if month == time.January {
+			year = 2020
+		}
+		return time.Date(year, month, day, hour, min, 0, 0, berlin)
+	}
+	testCases := []struct {
+		name           string
+		t              time.Time
+		expectedClosed bool
+		expectedOpens  time.Time
+	}{
+		{
+			name: "within the window",
+			t:    at(time.December, 2, 10, 0),
+		},
+		{
+			name:           "before the window opens",
+			t:              at(time.December, 2, 8, 30),
+			expectedClosed: true,
+			expectedOpens:  at(time.December, 2, 9, 0),
+		},
+		{
+			name:           "over the weekend",
+			t:              at(time.December, 7, 12, 0),
+			expectedClosed: true,
+			expectedOpens:  at(time.December, 9, 9, 0),
+		},
+		{
+			name:           "during the freeze",
+			t:              at(time.December, 31, 14, 0),
+			expectedClosed: true,
+			expectedOpens:  at(time.January, 2, 13, 0),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opens, closed := window.ClosedUntil(tc.t)
+			if closed != tc.expectedClosed {
+				t.Fatalf("Expected closed to be %t, got %t.", tc.expectedClosed, closed)
+			}
+			if closed && !opens.Equal(tc.expectedOpens) {
+				t.Errorf("Expected merging to be allowed at %v, got %v.", tc.expectedOpens, opens)
+			}
+		})
+	}
+
+	if !window.Exempt([]string{"lgtm", "cherry-pick-approved"}) || window.Exempt([]string{"lgtm"}) {
+		t.Error("Expected only PRs with the cherry-pick-approved label to be exempt.")
+	}
+	tide := Tide{MergeWindows: []TideMergeWindow{window}}
+	if tide.MergeWindow("org", "repo", "master") == nil {
+		t.Error("Expected org/repo master to have a merge window.")
+	}
+	if tide.MergeWindow("org", "repo", "release-1.0") != nil || tide.MergeWindow("other", "repo", "master") != nil {
+		t.Error("Expected only the master branches of org to have a merge window.")
+	}
+	for _, invalid := range []TideMergeWindow{
+		{Windows: []Blackout{{Cron: "0 9 * * 1-5", Duration: "8h"}}},
+		{Repos: []string{"org"}, TimeZone: "Mars/Olympus_Mons"},
+		{Repos: []string{"org"}, Freezes: []Blackout{{Start: "2020-01-02T00:00:00Z"}}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected a validation error for %+v, but didn't get one.", invalid)
+		}
+	}
+}
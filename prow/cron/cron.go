@@ -19,8 +19,10 @@ package cron
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	cron "gopkg.in/robfig/cron.v2" // using v2 api, doc at https://godoc.org/gopkg.in/robfig/cron.v2
@@ -36,9 +38,13 @@ type jobStatus struct {
 	entryID cron.EntryID
 	// triggered marks if a job has been triggered for the next cron.QueuedJobs() call
 	triggered bool
+	// triggerAt delays a triggered job until the random jitter has passed
+	triggerAt time.Time
 	// cronStr is a cache for job's cron status
 	// cron entry will be regenerated if cron string changes from the periodic job
 	cronStr string
+	// jitter is the maximum random delay for each trigger
+	jitter time.Duration
 }
 
 // Cron is a wrapper for cron.Cron
@@ -47,6 +53,9 @@ type Cron struct {
 	jobs      map[string]*jobStatus
 	logger    *logrus.Entry
 	lock      sync.Mutex
+	now       func() time.Time
+	// randomDelay returns a random duration in [0, max)
+	randomDelay func(max time.Duration) time.Duration
 }
 
 // New makes a new Cron object
@@ -55,6 +64,10 @@ func New() *Cron {
 		cronAgent: cron.New(),
 		jobs:      map[string]*jobStatus{},
 		logger:    logrus.WithField("client", "cron"),
+		now:       time.Now,
+		randomDelay: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(max)))
+		},
 	}
 }
 
@@ -74,11 +87,13 @@ func (c *Cron) QueuedJobs() []string {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	now := c.now()
 	res := []string{}
 	for k, v := range c.jobs {
-		if v.triggered {
-			res = append(res, k)
+		if !v.triggered || now.Before(v.triggerAt) {
+			continue
 		}
+		res = append(res, k)
 		c.jobs[k].triggered = false
 	}
 	return res
@@ -131,7 +146,7 @@ func (c *Cron) addPeriodic(p config.Periodic) error {
 	}
 
 	if job, ok := c.jobs[p.Name]; ok {
-		if job.cronStr == p.Cron {
+		if job.cronStr == p.CronSpec() && job.jitter == p.GetJitter() {
 			return nil
 		}
 		// job updated, remove old entry
@@ -140,7 +155,7 @@ func (c *Cron) addPeriodic(p config.Periodic) error {
 		}
 	}
 
-	if err := c.addJob(p.Name, p.Cron); err != nil {
+	if err := c.addJob(p.Name, p.CronSpec(), p.GetJitter()); err != nil {
 		return err
 	}
 
@@ -148,13 +163,22 @@ func (c *Cron) addPeriodic(p config.Periodic) error {
 }
 
 // addJob adds a cron entry for a job to cronAgent
-func (c *Cron) addJob(name, cron string) error {
-	id, err := c.cronAgent.AddFunc("TZ=UTC "+cron, func() {
+func (c *Cron) addJob(name, cron string, jitter time.Duration) error {
+	id, err := c.cronAgent.AddFunc(cron, func() {
 		c.lock.Lock()
 		defer c.lock.Unlock()
 
-		c.jobs[name].triggered = true
-		c.logger.Infof("Triggering cron job %s.", name)
+		job := c.jobs[name]
+		if job.triggered {
+			// Keep the pending delay, a missed trigger is not queued twice.
+			return
+		}
+		job.triggered = true
+		job.triggerAt = c.now()
+		if job.jitter > 0 {
+			job.triggerAt = job.triggerAt.Add(c.randomDelay(job.jitter))
+		}
+		c.logger.Infof("Triggering cron job %s at %s.", name, job.triggerAt)
 	})
 
 	if err != nil {
@@ -164,8 +188,9 @@ func (c *Cron) addJob(name, cron string) error {
 	c.jobs[name] = &jobStatus{
 		entryID: id,
 		cronStr: cron,
+		jitter:  jitter,
 		// try to kick of a periodic trigger right away
-		triggered: strings.HasPrefix(cron, "@every"),
+		triggered: strings.Contains(cron, "@every"),
 	}
 
 	c.logger.Infof("Added new cron job %s with trigger %s.", name, cron)
@@ -18,6 +18,7 @@ package cron
 
 import (
 	"testing"
+	"time"
 
 	cron "gopkg.in/robfig/cron.v2"
 	"k8s.io/test-infra/prow/config"
@@ -200,3 +201,45 @@ func TestTrigger(t *testing.T) {
 		t.Error("should have triggered job 'periodic'")
 	}
 }
+
+func TestTriggerWithJitter(t *testing.T) {
+	c := New()
+	now := time.Date(2019, 11, 6, 8, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	c.randomDelay = func(max time.Duration) time.Duration {
+		if max != time.Hour {
+			t.Errorf("expected a maximum delay of 1h, got %v", max)
+		}
+		return 10 * time.Minute
+	}
+
+	if err := c.addJob("cron", "TZ=UTC * 8 * * *", time.Hour); err != nil {
+		t.Fatalf("error adding job: %v", err)
+	}
+
+	// force trigger
+	for _, entry := range c.cronAgent.Entries() {
+		entry.Job.Run()
+	}
+
+	if jobs := c.QueuedJobs(); len(jobs) != 0 {
+		t.Errorf("should not have queued jobs before the jitter passed, got %v", jobs)
+	}
+
+	// triggering again must not reset the delay
+	now = now.Add(5 * time.Minute)
+	for _, entry := range c.cronAgent.Entries() {
+		entry.Job.Run()
+	}
+	if jobs := c.QueuedJobs(); len(jobs) != 0 {
+		t.Errorf("should not have queued jobs before the jitter passed, got %v", jobs)
+	}
+
+	now = now.Add(5 * time.Minute)
+	if jobs := c.QueuedJobs(); len(jobs) != 1 || jobs[0] != "cron" {
+		t.Errorf("should have queued job 'cron' after the jitter passed, got %v", jobs)
+	}
+	if jobs := c.QueuedJobs(); len(jobs) != 0 {
+		t.Errorf("should only queue job 'cron' once, got %v", jobs)
+	}
+}
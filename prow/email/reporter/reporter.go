@@ -0,0 +1,348 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reporter contains a crier reporter that sends emails over SMTP,
+// optionally batching the reports of periodic jobs into digests.
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/pkg/io"
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+const reporterName = "emailreporter"
+
+// sender delivers a message over SMTP.
+type sender interface {
+	Send(from string, to []string, msg []byte) error
+}
+
+type smtpSender struct {
+	addr string
+	auth smtp.Auth
+}
+
+func (s *smtpSender) Send(from string, to []string, msg []byte) error {
+	return smtp.SendMail(s.addr, s.auth, from, to, msg)
+}
+
+// digestEntry is a job waiting to be sent in a digest.
+type digestEntry struct {
+	Subject string `json:"subject"`
+	URL     string `json:"url"`
+}
+
+type emailReporter struct {
+	sender sender
+	config func() *config.EmailReporter
+	logger *logrus.Entry
+	dryRun bool
+	now    func() time.Time
+
+	// opener and digestPath persist the pending digests so that they survive
+	// restarts. Without a digestPath, reports are never batched.
+	opener     io.Opener
+	digestPath string
+
+	lock sync.Mutex
+	// digests holds the pending digest entries by comma-separated recipients.
+	digests map[string][]digestEntry
+}
+
+func readDigests(opener io.Opener, path string) (map[string][]digestEntry, error) {
+	reader, err := opener.Reader(context.Background(), path)
+	if io.IsNotExist(err) { // No digest is pending. This is not an error.
+		return map[string][]digestEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: %v", err)
+	}
+	defer io.LogClose(reader)
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+	digests := map[string][]digestEntry{}
+	if err := json.Unmarshal(raw, &digests); err != nil {
+		return nil, fmt.Errorf("unmarshal: %v", err)
+	}
+	return digests, nil
+}
+
+// writeDigests persists the pending digests. The caller must hold the lock.
+func (er *emailReporter) writeDigests() error {
+	writer, err := er.opener.Writer(context.Background(), er.digestPath)
+	if err != nil {
+		return fmt.Errorf("open: %v", err)
+	}
+	b, err := json.Marshal(er.digests)
+	if err != nil {
+		io.LogClose(writer)
+		return fmt.Errorf("marshal: %v", err)
+	}
+	if _, err := writer.Write(b); err != nil {
+		io.LogClose(writer)
+		return fmt.Errorf("write: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close: %v", err)
+	}
+	return nil
+}
+
+// recipients returns the addresses to report the job to.
+func recipients(cfg *config.EmailReporter, pj *v1.ProwJob) []string {
+	if pj.Spec.ReporterConfig != nil && pj.Spec.ReporterConfig.Email != nil && len(pj.Spec.ReporterConfig.Email.Recipients) > 0 {
+		return pj.Spec.ReporterConfig.Email.Recipients
+	}
+	return cfg.Recipients
+}
+
+// validateRecipients checks the addresses before they are written in the
+// headers, as jobs can set their own.
+func validateRecipients(to []string) error {
+	for _, recipient := range to {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("invalid recipient %q: %v", recipient, err)
+		}
+	}
+	return nil
+}
+
+// message formats a plain text email. The subject is encoded as an RFC 2047
+// word if it is not plain ASCII.
+func (er *emailReporter) message(from string, to []string, subject, body string) []byte {
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "From: %s\r\n", from)
+	fmt.Fprintf(b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(b, "Date: %s\r\n", er.now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(body, "\n", "\r\n", -1))
+	return b.Bytes()
+}
+
+func (er *emailReporter) Report(pj *v1.ProwJob) ([]*v1.ProwJob, error) {
+	config := er.config()
+	to := recipients(config, pj)
+	logger := er.logger.WithFields(logrus.Fields{"prowjob": pj.Name, "recipients": to})
+	if err := validateRecipients(to); err != nil {
+		logger.WithError(err).Error("refusing to report to the recipients of the job")
+		return nil, err
+	}
+	subject, err := config.Subject(pj)
+	if err != nil {
+		logger.WithError(err).Error("failed to render subject")
+		return nil, err
+	}
+
+	if config.DigestInterval != nil && pj.Spec.Type == v1.PeriodicJob {
+		if er.digestPath != "" {
+			return er.addToDigest(pj, to, subject, logger)
+		}
+		logger.Warn("Sending the report right away as no --email-digest-path is set to persist digests.")
+	}
+
+	body := fmt.Sprintf("%s.\n\nView logs: %s\n", subject, pj.Status.URL)
+	if err := er.send(config.From, to, subject, body); err != nil {
+		logger.WithError(err).Error("failed to send email")
+		return nil, fmt.Errorf("failed to send email: %v", err)
+	}
+	return []*v1.ProwJob{pj}, nil
+}
+
+// addToDigest adds a job to the next digest of its recipients. The job is
+// only reported once the digest is persisted, so that no report is lost
+// when crier restarts.
+func (er *emailReporter) addToDigest(pj *v1.ProwJob, to []string, subject string, logger *logrus.Entry) ([]*v1.ProwJob, error) {
+	er.lock.Lock()
+	defer er.lock.Unlock()
+	key := strings.Join(to, ",")
+	entries := er.digests[key]
+	er.digests[key] = append(entries, digestEntry{Subject: subject, URL: pj.Status.URL})
+	if err := er.writeDigests(); err != nil {
+		er.digests[key] = entries
+		if len(entries) == 0 {
+			delete(er.digests, key)
+		}
+		logger.WithError(err).Error("failed to persist digest")
+		return nil, fmt.Errorf("failed to persist digest: %v", err)
+	}
+	logger.Debug("Added job to the next digest.")
+	return []*v1.ProwJob{pj}, nil
+}
+
+func (er *emailReporter) send(from string, to []string, subject, body string) error {
+	msg := er.message(from, to, subject, body)
+	if er.dryRun {
+		er.logger.WithField("message", string(msg)).Debug("Skipping sending email because dry-run is enabled")
+		return nil
+	}
+	return er.sender.Send(from, to, msg)
+}
+
+// SendDigests sends one message for every set of recipients with reports
+// waiting. Entries stay pending, and persisted, until their digest is sent,
+// so that reports added meanwhile are persisted along with them and digests
+// that fail to send are kept for the next attempt.
+func (er *emailReporter) SendDigests() {
+	if er.digestPath == "" {
+		return
+	}
+	er.lock.Lock()
+	digests := make(map[string][]digestEntry, len(er.digests))
+	for key, entries := range er.digests {
+		digests[key] = entries
+	}
+	er.lock.Unlock()
+
+	config := er.config()
+	var keys []string
+	for key := range digests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sent []string
+	for _, key := range keys {
+		entries := digests[key]
+		to := strings.Split(key, ",")
+		subject := fmt.Sprintf("[prow] Digest of %d periodic job reports", len(entries))
+		body := &bytes.Buffer{}
+		for _, entry := range entries {
+			fmt.Fprintf(body, "%s.\nView logs: %s\n\n", entry.Subject, entry.URL)
+		}
+		if err := er.send(config.From, to, subject, body.String()); err != nil {
+			er.logger.WithError(err).WithField("recipients", to).Error("failed to send digest, will retry")
+			continue
+		}
+		sent = append(sent, key)
+	}
+	if len(sent) == 0 {
+		return
+	}
+
+	er.lock.Lock()
+	defer er.lock.Unlock()
+	// Entries are only ever appended, so the sent ones are still first.
+	for _, key := range sent {
+		if remaining := er.digests[key][len(digests[key]):]; len(remaining) > 0 {
+			er.digests[key] = remaining
+		} else {
+			delete(er.digests, key)
+		}
+	}
+	if err := er.writeDigests(); err != nil {
+		er.logger.WithError(err).Error("failed to persist digests, sent digests may be sent again")
+	}
+}
+
+func (er *emailReporter) GetName() string {
+	return reporterName
+}
+
+func (er *emailReporter) ShouldReport(pj *v1.ProwJob) bool {
+	config := er.config()
+	to := recipients(config, pj)
+	if len(to) == 0 {
+		return false
+	}
+	if err := validateRecipients(to); err != nil {
+		er.logger.WithField("prowjob", pj.Name).WithError(err).Warn("Not reporting the job.")
+		return false
+	}
+
+	statesToReport := config.JobStatesToReport
+	// Jobs that opt in are reported whatever their type.
+	typeShouldReport := pj.Spec.ReporterConfig != nil && pj.Spec.ReporterConfig.Email != nil
+	if typeShouldReport && len(pj.Spec.ReporterConfig.Email.JobStatesToReport) > 0 {
+		statesToReport = pj.Spec.ReporterConfig.Email.JobStatesToReport
+	}
+	for _, typeToReport := range config.JobTypesToReport {
+		if typeToReport == pj.Spec.Type {
+			typeShouldReport = true
+			break
+		}
+	}
+
+	stateShouldReport := false
+	for _, stateToReport := range statesToReport {
+		if pj.Status.State == stateToReport {
+			stateShouldReport = true
+			break
+		}
+	}
+
+	er.logger.WithField("prowjob", pj.Name).
+		Debugf("reporting=%t", stateShouldReport && typeShouldReport)
+	return stateShouldReport && typeShouldReport
+}
+
+// New returns a reporter that sends emails through the SMTP server at addr,
+// authenticating if a username is given. Pending digests are persisted at
+// digestPath through the opener, digests are disabled without a digestPath.
+func New(cfg func() *config.EmailReporter, dryRun bool, addr, username, passwordFile string, opener io.Opener, digestPath string) (*emailReporter, error) {
+	var auth smtp.Auth
+	if username != "" {
+		password, err := ioutil.ReadFile(passwordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read --email-smtp-password-file: %v", err)
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --email-smtp-server: %v", err)
+		}
+		auth = smtp.PlainAuth("", username, strings.TrimSpace(string(password)), host)
+	}
+
+	digests := map[string][]digestEntry{}
+	if digestPath != "" {
+		var err error
+		if digests, err = readDigests(opener, digestPath); err != nil {
+			return nil, fmt.Errorf("failed to read the digests at %s: %v", digestPath, err)
+		}
+	}
+
+	return &emailReporter{
+		sender:     &smtpSender{addr: addr, auth: auth},
+		config:     cfg,
+		logger:     logrus.WithField("component", reporterName),
+		dryRun:     dryRun,
+		now:        time.Now,
+		opener:     opener,
+		digestPath: digestPath,
+		digests:    digests,
+	}, nil
+}
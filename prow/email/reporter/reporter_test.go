@@ -0,0 +1,404 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/test-infra/pkg/io"
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+// smtpServer is a local SMTP stand-in that records the messages it receives.
+type smtpServer struct {
+	listener net.Listener
+
+	lock       sync.Mutex
+	recipients [][]string
+	messages   []string
+}
+
+func startSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpServer{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 localhost ESMTP")
+	var recipients []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+		case "EHLO", "HELO":
+			text.PrintfLine("250 localhost")
+		case "RCPT":
+			recipients = append(recipients, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 Go ahead")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.recipients = append(s.recipients, recipients)
+			s.messages = append(s.messages, string(data))
+			s.lock.Unlock()
+			recipients = nil
+			text.PrintfLine("250 OK")
+		case "QUIT":
+			text.PrintfLine("221 Bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) Close() {
+	s.listener.Close()
+}
+
+type fakeSender struct {
+	err      error
+	messages []string
+	// onSend is called before every message is sent.
+	onSend func()
+}
+
+func (f *fakeSender) Send(from string, to []string, msg []byte) error {
+	if f.onSend != nil {
+		f.onSend()
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, fmt.Sprintf("%v\n%s", to, string(msg)))
+	return nil
+}
+
+func TestShouldReport(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   config.EmailReporter
+		pj       *v1.ProwJob
+		expected bool
+	}{
+		{
+			name: "Failed periodic job should report",
+			config: config.EmailReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				Recipients:        []string{"release@example.com"},
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: true,
+		},
+		{
+			name: "Successful periodic job should not report",
+			config: config.EmailReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				Recipients:        []string{"release@example.com"},
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.SuccessState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job without recipients should not report",
+			config: config.EmailReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job that opts in should report with its own recipients and states",
+			config: config.EmailReporter{
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+			},
+			pj: &v1.ProwJob{
+				Spec: v1.ProwJobSpec{
+					Type: v1.PostsubmitJob,
+					ReporterConfig: &v1.ReporterConfig{Email: &v1.EmailReporterConfig{
+						Recipients:        []string{"team@example.com"},
+						JobStatesToReport: []v1.ProwJobState{v1.ErrorState},
+					}},
+				},
+				Status: v1.ProwJobStatus{State: v1.ErrorState},
+			},
+			expected: true,
+		},
+		{
+			name: "Job with an invalid recipient should not report",
+			config: config.EmailReporter{
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+			},
+			pj: &v1.ProwJob{
+				Spec: v1.ProwJobSpec{
+					Type: v1.PostsubmitJob,
+					ReporterConfig: &v1.ReporterConfig{Email: &v1.EmailReporterConfig{
+						Recipients: []string{"team@example.com\r\nBcc: everyone@example.com"},
+					}},
+				},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &emailReporter{
+				config: func() *config.EmailReporter { return &tc.config },
+				logger: logrus.WithField("component", reporterName),
+			}
+
+			if result := reporter.ShouldReport(tc.pj); result != tc.expected {
+				t.Errorf("expected result to be %t but was %t", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestReportOverSMTP(t *testing.T) {
+	server := startSMTPServer(t)
+	defer server.Close()
+
+	cfg := &config.EmailReporter{
+		From:       "prow@example.com",
+		Recipients: []string{"release@example.com"},
+	}
+	if err := cfg.DefaultAndValidate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	reporter, err := New(func() *config.EmailReporter { return cfg }, false, server.listener.Addr().String(), "", "", nil, "")
+	if err != nil {
+		t.Fatalf("failed to create reporter: %v", err)
+	}
+
+	pj := &v1.ProwJob{
+		Spec:   v1.ProwJobSpec{Job: "nightly", Type: v1.PeriodicJob},
+		Status: v1.ProwJobStatus{State: v1.FailureState, URL: "https://prow.example.com/view/nightly/1"},
+	}
+	if _, err := reporter.Report(pj); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if len(server.messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(server.messages))
+	}
+	if to := server.recipients[0]; len(to) != 1 || to[0] != "release@example.com" {
+		t.Errorf("expected the message to be sent to release@example.com, got %v", to)
+	}
+	for _, expected := range []string{
+		"From: prow@example.com\n",
+		"Subject: [prow] Job nightly of type periodic ended with state failure\n",
+		"View logs: https://prow.example.com/view/nightly/1\n",
+	} {
+		if !strings.Contains(server.messages[0], expected) {
+			t.Errorf("expected message to contain %q, got:\n%s", expected, server.messages[0])
+		}
+	}
+}
+
+func TestMessageSubject(t *testing.T) {
+	testCases := []struct {
+		name     string
+		subject  string
+		expected string
+	}{
+		{
+			name:     "ASCII subject is written as is",
+			subject:  "[prow] Job nightly failed",
+			expected: "Subject: [prow] Job nightly failed\r\n",
+		},
+		{
+			name:     "line breaks cannot inject headers",
+			subject:  "Job nightly\r\nBcc: everyone@example.com",
+			expected: "Subject: Job nightly  Bcc: everyone@example.com\r\n",
+		},
+		{
+			name:     "non-ASCII subject is encoded",
+			subject:  "Job café failed",
+			expected: "Subject: =?utf-8?q?Job_caf=C3=A9_failed?=\r\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &emailReporter{now: time.Now}
+			msg := string(reporter.message("prow@example.com", []string{"release@example.com"}, tc.subject, "body"))
+			if !strings.Contains(msg, tc.expected) {
+				t.Errorf("expected message to contain %q, got:\n%s", tc.expected, msg)
+			}
+			if strings.Contains(msg, "\r\nBcc:") {
+				t.Errorf("expected no Bcc header, got:\n%s", msg)
+			}
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	cfg := &config.EmailReporter{
+		From:           "prow@example.com",
+		Recipients:     []string{"release@example.com"},
+		DigestInterval: &metav1.Duration{Duration: time.Hour},
+	}
+	if err := cfg.DefaultAndValidate(); err != nil {
+		t.Fatalf("invalid config: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "email-digests")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	opener, err := io.NewOpener(context.Background(), "", "")
+	if err != nil {
+		t.Fatalf("failed to create opener: %v", err)
+	}
+	digestPath := filepath.Join(dir, "digests.json")
+	sender := &fakeSender{}
+	newReporter := func() *emailReporter {
+		reporter, err := New(func() *config.EmailReporter { return cfg }, false, "localhost:25", "", "", opener, digestPath)
+		if err != nil {
+			t.Fatalf("failed to create reporter: %v", err)
+		}
+		reporter.sender = sender
+		return reporter
+	}
+	reporter := newReporter()
+
+	report := func(name string, jobType v1.ProwJobType) {
+		pj := &v1.ProwJob{
+			Spec:   v1.ProwJobSpec{Job: name, Type: jobType},
+			Status: v1.ProwJobStatus{State: v1.FailureState},
+		}
+		if _, err := reporter.Report(pj); err != nil {
+			t.Fatalf("failed to report %s: %v", name, err)
+		}
+	}
+	report("nightly-a", v1.PeriodicJob)
+	report("nightly-b", v1.PeriodicJob)
+	report("post-build", v1.PostsubmitJob)
+
+	if len(sender.messages) != 1 || !strings.Contains(sender.messages[0], "Job post-build") {
+		t.Fatalf("expected only the postsubmit to be sent right away, got %v", sender.messages)
+	}
+
+	// The pending digest survives a restart.
+	reporter = newReporter()
+	sender.err = errors.New("connection refused")
+	reporter.SendDigests()
+	if len(reporter.digests) != 1 {
+		t.Fatalf("expected the digest to be kept after failing to send it")
+	}
+
+	sender.err = nil
+	sender.messages = nil
+	reporter.SendDigests()
+	if len(sender.messages) != 1 {
+		t.Fatalf("expected a single digest, got %d messages", len(sender.messages))
+	}
+	for _, expected := range []string{
+		"Subject: [prow] Digest of 2 periodic job reports",
+		"Job nightly-a of type periodic ended with state failure",
+		"Job nightly-b of type periodic ended with state failure",
+	} {
+		if !strings.Contains(sender.messages[0], expected) {
+			t.Errorf("expected digest to contain %q, got:\n%s", expected, sender.messages[0])
+		}
+	}
+
+	sender.messages = nil
+	reporter = newReporter()
+	reporter.SendDigests()
+	if len(sender.messages) != 0 {
+		t.Errorf("expected no digest without new reports, got %v", sender.messages)
+	}
+
+	// Reports added while a digest is sent are persisted along with the
+	// entries being sent, and are left for the next digest.
+	report("nightly-c", v1.PeriodicJob)
+	sender.onSend = func() {
+		sender.onSend = nil
+		report("nightly-d", v1.PeriodicJob)
+		persisted, err := readDigests(opener, digestPath)
+		if err != nil {
+			t.Fatalf("failed to read digests: %v", err)
+		}
+		if entries := persisted["release@example.com"]; len(entries) != 2 {
+			t.Errorf("expected the entry being sent and the new one to be persisted, got %v", entries)
+		}
+	}
+	reporter.SendDigests()
+	if len(sender.messages) != 1 || !strings.Contains(sender.messages[0], "Job nightly-c") || strings.Contains(sender.messages[0], "Job nightly-d") {
+		t.Fatalf("expected a digest of nightly-c only, got %v", sender.messages)
+	}
+
+	sender.messages = nil
+	reporter = newReporter()
+	reporter.SendDigests()
+	if len(sender.messages) != 1 || !strings.Contains(sender.messages[0], "Subject: [prow] Digest of 1 periodic job reports") || !strings.Contains(sender.messages[0], "Job nightly-d") {
+		t.Errorf("expected a digest of nightly-d after a restart, got %v", sender.messages)
+	}
+}
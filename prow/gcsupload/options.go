@@ -49,7 +49,10 @@ type Options struct {
 	// GcsCredentialsFile is the path to the JSON
 	// credentials for pushing to GCS.
 	GcsCredentialsFile string `json:"gcs_credentials_file,omitempty"`
-	DryRun             bool   `json:"dry_run"`
+	// S3CredentialsFile is the path to the JSON
+	// credentials for pushing to S3.
+	S3CredentialsFile string `json:"s3_credentials_file,omitempty"`
+	DryRun            bool   `json:"dry_run"`
 
 	// mediaTypes holds additional extension media types to add to Go's
 	// builtin's and the local system's defaults.  Values are
@@ -79,8 +82,15 @@ func (o *Options) Validate() error {
 			return errors.New("GCS upload was requested no GCS bucket was provided")
 		}
 
-		if o.GcsCredentialsFile == "" {
-			return errors.New("GCS upload was requested but no GCS credentials file was provided")
+		switch o.StorageProvider() {
+		case prowapi.StorageProviderGCS:
+			if o.GcsCredentialsFile == "" {
+				return errors.New("GCS upload was requested but no GCS credentials file was provided")
+			}
+		case prowapi.StorageProviderS3:
+			if o.S3CredentialsFile == "" {
+				return errors.New("S3 upload was requested but no S3 credentials file was provided")
+			}
 		}
 	}
 
@@ -127,6 +137,7 @@ func (o *Options) AddFlags(fs *flag.FlagSet) {
 
 	fs.Var(&o.gcsPath, "gcs-path", "GCS path to upload into")
 	fs.StringVar(&o.GcsCredentialsFile, "gcs-credentials-file", "", "file where Google Cloud authentication credentials are stored")
+	fs.StringVar(&o.S3CredentialsFile, "s3-credentials-file", "", "file where S3 credentials are stored, for buckets starting with s3://")
 	fs.BoolVar(&o.DryRun, "dry-run", true, "do not interact with GCS")
 
 	fs.Var(&o.mediaTypes, "media-type", "Optional comma-delimited set of extension media types.  Each entry is colon-delimited {extension}:{media-type}, for example, log:text/plain.")
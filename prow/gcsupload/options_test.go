@@ -72,6 +72,52 @@ func TestOptions_Validate(t *testing.T) {
 			},
 			expectedErr: true,
 		},
+		{
+			name: "push to S3, ok",
+			input: Options{
+				DryRun:            false,
+				S3CredentialsFile: "secrets",
+				GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "s3://seal",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "push to S3, missing credentials",
+			input: Options{
+				DryRun:             false,
+				GcsCredentialsFile: "secrets",
+				GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "s3://seal",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				},
+			},
+			expectedErr: true,
+		},
+		{
+			name: "push to a shared filesystem, no credentials needed",
+			input: Options{
+				DryRun: false,
+				GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "file:///srv/seal",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				},
+			},
+			expectedErr: false,
+		},
+		{
+			name: "push to an unknown provider",
+			input: Options{
+				DryRun: false,
+				GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "ftp://seal",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				},
+			},
+			expectedErr: true,
+		},
 	}
 
 	for _, testCase := range testCases {
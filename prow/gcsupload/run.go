@@ -29,6 +29,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/option"
 
+	pkgio "k8s.io/test-infra/pkg/io"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/pod-utils/downwardapi"
 	"k8s.io/test-infra/prow/pod-utils/gcs"
@@ -55,22 +56,35 @@ func (o Options) Run(spec *downwardapi.JobSpec, extra map[string]gcs.UploadFunc)
 		return nil
 	}
 
-	if o.LocalOutputDir == "" {
+	if o.LocalOutputDir != "" {
+		if err := gcs.LocalExport(o.LocalOutputDir, uploadTargets); err != nil {
+			return fmt.Errorf("failed to copy files to %q: %v", o.LocalOutputDir, err)
+		}
+		logrus.Infof("Finished copying files to %q.", o.LocalOutputDir)
+		return nil
+	}
+
+	if o.StorageProvider() == prowapi.StorageProviderGCS {
 		gcsClient, err := storage.NewClient(context.Background(), option.WithCredentialsFile(o.GcsCredentialsFile))
 		if err != nil {
 			return fmt.Errorf("could not connect to GCS: %v", err)
 		}
 
-		if err := gcs.Upload(gcsClient.Bucket(o.Bucket), uploadTargets); err != nil {
+		if err := gcs.Upload(gcsClient.Bucket(o.BucketName()), uploadTargets); err != nil {
 			return fmt.Errorf("failed to upload to GCS: %v", err)
 		}
 		logrus.Info("Finished upload to GCS")
-	} else {
-		if err := gcs.LocalExport(o.LocalOutputDir, uploadTargets); err != nil {
-			return fmt.Errorf("failed to copy files to %q: %v", o.LocalOutputDir, err)
-		}
-		logrus.Infof("Finished copying files to %q.", o.LocalOutputDir)
+		return nil
+	}
+
+	opener, err := pkgio.NewOpener(context.Background(), "", o.S3CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("could not create opener for %s: %v", o.Bucket, err)
+	}
+	if err := gcs.StorageUpload(opener, o.BucketURL(), uploadTargets); err != nil {
+		return fmt.Errorf("failed to upload to %s: %v", o.Bucket, err)
 	}
+	logrus.Infof("Finished upload to %s", o.Bucket)
 	return nil
 }
 
@@ -84,7 +98,7 @@ func (o Options) assembleTargets(spec *downwardapi.JobSpec, extra map[string]gcs
 		// ensure that an alias exists for any
 		// job we're uploading artifacts for
 		if alias := gcs.AliasForSpec(spec); alias != "" {
-			fullBasePath := "gs://" + path.Join(o.Bucket, jobBasePath)
+			fullBasePath := o.BucketURL() + "/" + path.Clean(jobBasePath)
 			uploadTargets[alias] = gcs.DataUploadWithMetadata(strings.NewReader(fullBasePath), map[string]string{
 				"x-goog-meta-link": fullBasePath,
 			})
@@ -89,6 +89,7 @@ type gerritAccount interface {
 type gerritChange interface {
 	QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error)
 	SetReview(changeID, revisionID string, input *gerrit.ReviewInput) (*gerrit.ReviewResult, *gerrit.Response, error)
+	SubmitChange(changeID string, input *gerrit.SubmitInput) (*gerrit.ChangeInfo, *gerrit.Response, error)
 }
 
 type gerritProjects interface {
@@ -239,6 +240,50 @@ func (c *Client) SetReview(instance, id, revision, message string, labels map[st
 	return nil
 }
 
+// SearchChanges returns all changes of the instance that match the query,
+// like "status:open project:platform/build label:Verified=1".
+func (c *Client) SearchChanges(instance, query string, rateLimit int) ([]ChangeInfo, error) {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return nil, fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	opt := &gerrit.QueryChangeOptions{}
+	opt.Query = []string{query}
+	opt.AdditionalFields = []string{"CURRENT_REVISION", "CURRENT_COMMIT", "CURRENT_FILES"}
+
+	result := []ChangeInfo{}
+	for {
+		opt.Limit = rateLimit
+		opt.Start = len(result)
+		changes, _, err := h.changeService.QueryChanges(opt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query gerrit changes: %v", err)
+		}
+		if changes == nil || len(*changes) == 0 {
+			return result, nil
+		}
+		result = append(result, *changes...)
+		if !(*changes)[len(*changes)-1].MoreChanges {
+			return result, nil
+		}
+	}
+}
+
+// SubmitChange submits the change, which merges it into its branch.
+func (c *Client) SubmitChange(instance, id string) error {
+	h, ok := c.handlers[instance]
+	if !ok {
+		return fmt.Errorf("not activated gerrit instance: %s", instance)
+	}
+
+	if _, _, err := h.changeService.SubmitChange(id, &gerrit.SubmitInput{}); err != nil {
+		return fmt.Errorf("cannot submit change %s: %v", id, err)
+	}
+
+	return nil
+}
+
 // GetBranchRevision returns SHA of HEAD of a branch
 func (c *Client) GetBranchRevision(instance, project, branch string) (string, error) {
 	h, ok := c.handlers[instance]
@@ -63,6 +63,10 @@ func (f *fgc) SetReview(changeID, revisionID string, input *gerrit.ReviewInput)
 	return nil, nil, nil
 }
 
+func (f *fgc) SubmitChange(changeID string, input *gerrit.SubmitInput) (*gerrit.ChangeInfo, *gerrit.Response, error) {
+	return nil, nil, nil
+}
+
 func makeStamp(t time.Time) gerrit.Timestamp {
 	return gerrit.Timestamp{Time: t}
 }
@@ -557,3 +561,52 @@ func TestQueryChange(t *testing.T) {
 		}
 	}
 }
+
+type pagingChanges struct {
+	fgc
+	changes []gerrit.ChangeInfo
+	queries []string
+}
+
+func (p *pagingChanges) QueryChanges(opt *gerrit.QueryChangeOptions) (*[]gerrit.ChangeInfo, *gerrit.Response, error) {
+	p.queries = append(p.queries, opt.Query...)
+	changes := []gerrit.ChangeInfo{}
+	for idx := opt.Start; idx < len(p.changes) && len(changes) < opt.Limit; idx++ {
+		changes = append(changes, p.changes[idx])
+	}
+	if len(changes) > 0 && opt.Start+len(changes) < len(p.changes) {
+		changes[len(changes)-1].MoreChanges = true
+	}
+	return &changes, nil, nil
+}
+
+func TestSearchChanges(t *testing.T) {
+	fake := &pagingChanges{}
+	for i := 1; i <= 5; i++ {
+		fake.changes = append(fake.changes, gerrit.ChangeInfo{Number: i})
+	}
+	client := &Client{
+		handlers: map[string]*gerritInstanceHandler{
+			"foo": {instance: "foo", changeService: fake},
+		},
+	}
+
+	changes, err := client.SearchChanges("foo", "status:open project:bar", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var numbers []int
+	for _, change := range changes {
+		numbers = append(numbers, change.Number)
+	}
+	if expected := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(numbers, expected) {
+		t.Errorf("expected changes %v, got %v", expected, numbers)
+	}
+	if len(fake.queries) != 3 || fake.queries[0] != "status:open project:bar" {
+		t.Errorf("expected the query to be sent for each of 3 pages, got %v", fake.queries)
+	}
+
+	if _, err := client.SearchChanges("unknown", "status:open", 2); err == nil {
+		t.Error("expected an error for an unknown instance")
+	}
+}
@@ -44,6 +44,7 @@ var (
 	stateIcon = map[v1.ProwJobState]string{
 		v1.PendingState:   hourglass,
 		v1.TriggeredState: hourglass,
+		v1.WaitingState:   hourglass,
 		v1.SuccessState:   tick,
 		v1.FailureState:   cross,
 		v1.AbortedState:   prohibited,
@@ -95,7 +96,7 @@ func (c *Client) GetName() string {
 // ShouldReport returns if this prowjob should be reported by the gerrit reporter
 func (c *Client) ShouldReport(pj *v1.ProwJob) bool {
 
-	if pj.Status.State == v1.TriggeredState || pj.Status.State == v1.PendingState {
+	if pj.Status.State == v1.TriggeredState || pj.Status.State == v1.WaitingState || pj.Status.State == v1.PendingState {
 		// not done yet
 		logrus.WithField("prowjob", pj.ObjectMeta.Name).Info("PJ not finished")
 		return false
@@ -135,7 +136,7 @@ func (c *Client) ShouldReport(pj *v1.ProwJob) bool {
 	}
 
 	for _, pjob := range pjs {
-		if pjob.Status.State == v1.TriggeredState || pjob.Status.State == v1.PendingState {
+		if pjob.Status.State == v1.TriggeredState || pjob.Status.State == v1.WaitingState || pjob.Status.State == v1.PendingState {
 			// other jobs with same label are still running on this revision, skip report
 			logrus.WithField("prowjob", pjob.ObjectMeta.Name).Info("Other jobs with same label are still running on this revision")
 			return false
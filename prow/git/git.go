@@ -313,6 +313,29 @@ func (r *Repo) MergeAndCheckout(baseSHA string, headSHAs []string, mergeStrategy
 	return nil
 }
 
+// Revert reverts commitlike onto the current branch. Merge commits are
+// reverted relative to their first parent. It returns an error if the revert
+// does not apply cleanly.
+func (r *Repo) Revert(commitlike string) error {
+	r.logger.Infof("Reverting %s.", commitlike)
+	b, err := r.gitCommand("rev-list", "--parents", "-n", "1", commitlike).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error listing parents of %s: %v. output: %s", commitlike, err, string(b))
+	}
+	args := []string{"revert", "--no-edit"}
+	// The commit is listed along with its parents.
+	if len(strings.Fields(string(b))) > 2 {
+		args = append(args, "-m", "1")
+	}
+	if b, err := r.gitCommand(append(args, commitlike)...).CombinedOutput(); err != nil {
+		if b, abortErr := r.gitCommand("revert", "--abort").CombinedOutput(); abortErr != nil {
+			r.logger.WithError(abortErr).Warningf("Aborting revert failed with output: %s", string(b))
+		}
+		return fmt.Errorf("error reverting %s: %v. output: %s", commitlike, err, string(b))
+	}
+	return nil
+}
+
 // Am tries to apply the patch in the given path into the current branch
 // by performing a three-way merge (similar to git cherry-pick). It returns
 // an error if the patch cannot be applied.
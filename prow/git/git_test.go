@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -393,3 +394,77 @@ func TestMergeAndCheckout(t *testing.T) {
 	}
 
 }
+
+func TestRevert(t *testing.T) {
+	const (
+		org  = "my-org"
+		repo = "my-repo"
+	)
+	lg, c, err := localgit.New()
+	if err != nil {
+		t.Fatalf("Making local git repo: %v", err)
+	}
+	defer func() {
+		if err := lg.Clean(); err != nil {
+			t.Errorf("Error cleaning LocalGit: %v", err)
+		}
+		if err := c.Clean(); err != nil {
+			t.Errorf("Error cleaning Client: %v", err)
+		}
+	}()
+	if err := lg.MakeFakeRepo(org, repo); err != nil {
+		t.Fatalf("Making fake repo: %v", err)
+	}
+	if err := lg.AddCommit(org, repo, map[string][]byte{"squashed": []byte("val")}); err != nil {
+		t.Fatalf("failed to add commit: %v", err)
+	}
+	squashed, err := lg.RevParse(org, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("failed to run git rev-parse: %v", err)
+	}
+	if err := lg.CheckoutNewBranch(org, repo, "my-pr-branch"); err != nil {
+		t.Fatalf("failed to checkout new branch: %v", err)
+	}
+	if err := lg.AddCommit(org, repo, map[string][]byte{"merged": []byte("val")}); err != nil {
+		t.Fatalf("failed to add commit: %v", err)
+	}
+	if err := lg.Checkout(org, repo, "master"); err != nil {
+		t.Fatalf("failed to run git checkout master: %v", err)
+	}
+	if _, err := lg.Merge(org, repo, "my-pr-branch"); err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	merged, err := lg.RevParse(org, repo, "HEAD")
+	if err != nil {
+		t.Fatalf("failed to run git rev-parse: %v", err)
+	}
+
+	r, err := c.Clone(org + "/" + repo)
+	if err != nil {
+		t.Fatalf("Cloning failed: %v", err)
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			t.Errorf("Cleaning repo: %v", err)
+		}
+	}()
+	if err := r.Config("user.name", "prow"); err != nil {
+		t.Fatalf("failed to set name for test repo: %v", err)
+	}
+	if err := r.Config("user.email", "prow@localhost"); err != nil {
+		t.Fatalf("failed to set email for test repo: %v", err)
+	}
+	for _, sha := range []string{merged, squashed} {
+		if err := r.Revert(strings.TrimSpace(sha)); err != nil {
+			t.Fatalf("failed to revert %s: %v", sha, err)
+		}
+	}
+	for _, file := range []string{"merged", "squashed"} {
+		if _, err := os.Stat(filepath.Join(r.Dir, file)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be reverted, got %v", file, err)
+		}
+	}
+	if err := r.Revert(strings.TrimSpace(squashed)); err == nil {
+		t.Error("expected reverting a reverted commit again to fail")
+	}
+}
@@ -95,6 +95,7 @@ type IssueClient interface {
 	ListIssueEvents(org, repo string, num int) ([]ListedIssueEvent, error)
 	AssignIssue(org, repo string, number int, logins []string) error
 	UnassignIssue(org, repo string, number int, logins []string) error
+	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
 	CloseIssue(org, repo string, number int) error
 	ReopenIssue(org, repo string, number int) error
 	FindIssues(query, sort string, asc bool) ([]Issue, error)
@@ -2262,6 +2263,40 @@ func (c *client) UnrequestReview(org, repo string, number int, logins []string)
 	return nil
 }
 
+// CreateIssue creates a new issue and returns its number. A milestone of 0
+// leaves the issue without a milestone.
+//
+// See https://developer.github.com/v3/issues/#create-an-issue
+func (c *client) CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error) {
+	c.log("CreateIssue", org, repo, title)
+	data := struct {
+		Title     string   `json:"title"`
+		Body      string   `json:"body,omitempty"`
+		Milestone int      `json:"milestone,omitempty"`
+		Labels    []string `json:"labels,omitempty"`
+		Assignees []string `json:"assignees,omitempty"`
+	}{
+		Title:     title,
+		Body:      body,
+		Milestone: milestone,
+		Labels:    labels,
+		Assignees: assignees,
+	}
+	var resp struct {
+		Num int `json:"number"`
+	}
+	_, err := c.request(&request{
+		method:      http.MethodPost,
+		path:        fmt.Sprintf("/repos/%s/%s/issues", org, repo),
+		requestBody: &data,
+		exitCodes:   []int{201},
+	}, &resp)
+	if err != nil {
+		return 0, err
+	}
+	return resp.Num, nil
+}
+
 // CloseIssue closes the existing, open issue provided
 //
 // See https://developer.github.com/v3/issues/#edit-an-issue
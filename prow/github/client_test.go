@@ -980,6 +980,41 @@ func TestUnrequestReview(t *testing.T) {
 	}
 }
 
+func TestCreateIssue(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Bad method: %s", r.Method)
+		}
+		if r.URL.Path != "/repos/k8s/kuber/issues" {
+			t.Errorf("Bad request path: %s", r.URL.Path)
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("Could not read request body: %v", err)
+		}
+		var issue struct {
+			Title     string   `json:"title"`
+			Body      string   `json:"body"`
+			Milestone *int     `json:"milestone"`
+			Labels    []string `json:"labels"`
+		}
+		if err := json.Unmarshal(b, &issue); err != nil {
+			t.Errorf("Could not unmarshal request: %v", err)
+		} else if issue.Title != "title" || issue.Body != "body" || issue.Milestone != nil || !reflect.DeepEqual(issue.Labels, []string{"bug"}) {
+			t.Errorf("Wrong issue: %+v", issue)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"number": 42}`)
+	}))
+	defer ts.Close()
+	c := getClient(ts.URL)
+	if num, err := c.CreateIssue("k8s", "kuber", "title", "body", 0, []string{"bug"}, nil); err != nil {
+		t.Errorf("Didn't expect error: %v", err)
+	} else if num != 42 {
+		t.Errorf("Expected issue number 42, got %d", num)
+	}
+}
+
 func TestCloseIssue(t *testing.T) {
 	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPatch {
@@ -51,6 +51,8 @@ func prowjobStateToGitHubStatus(pjState prowapi.ProwJobState) (string, error) {
 	switch pjState {
 	case prowapi.TriggeredState:
 		return github.StatusPending, nil
+	case prowapi.WaitingState:
+		return github.StatusPending, nil
 	case prowapi.PendingState:
 		return github.StatusPending, nil
 	case prowapi.SuccessState:
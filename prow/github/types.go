@@ -204,6 +204,8 @@ const (
 	PullRequestActionSynchronize PullRequestEventAction = "synchronize"
 	// PullRequestActionReadyForReview means the PR is no longer a draft PR.
 	PullRequestActionReadyForReview PullRequestEventAction = "ready_for_review"
+	// PullRequestActionConvertedToDraft means the PR is now a draft PR.
+	PullRequestActionConvertedToDraft PullRequestEventAction = "converted_to_draft"
 )
 
 // PullRequestEvent is what GitHub sends us when a PR is changed.
@@ -840,6 +842,7 @@ type Review struct {
 	State       ReviewState `json:"state"`
 	HTMLURL     string      `json:"html_url"`
 	SubmittedAt time.Time   `json:"submitted_at"`
+	CommitID    string      `json:"commit_id"`
 }
 
 // ReviewCommentEventAction enumerates the triggers for this
@@ -41,6 +41,9 @@ const (
 	// job names can be arbitrarily long, this is added as
 	// an annotation instead of a label.
 	ProwJobAnnotation = "prow.k8s.io/job"
+	// ScheduledTimeAnnotation is added to periodic ProwJobs that make up for
+	// a missed cron run and carries the time that run was scheduled for.
+	ScheduledTimeAnnotation = "prow.k8s.io/scheduled-time"
 	// OrgLabel is added in resources created by prow and
 	// carries the org associated with the job, eg kubernetes-sigs.
 	OrgLabel = "prow.k8s.io/refs.org"
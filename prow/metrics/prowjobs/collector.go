@@ -37,7 +37,7 @@ func update(histogramVec *prometheus.HistogramVec, oldJob *prowapi.ProwJob, newJ
 	var newTime *metav1.Time
 
 	switch oldJob.Status.State {
-	case prowapi.TriggeredState:
+	case prowapi.TriggeredState, prowapi.WaitingState:
 		oldTime = &oldJob.CreationTimestamp
 	case prowapi.PendingState:
 		oldTime = oldJob.Status.PendingTime
@@ -27,6 +27,7 @@ import (
 	ktypes "k8s.io/apimachinery/pkg/types"
 
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/errorutil"
 	"k8s.io/test-infra/prow/github/reporter"
 )
 
@@ -105,12 +106,7 @@ func TerminateOlderJobs(pjc prowClient, log *logrus.Entry, pjs []prowapi.ProwJob
 			log.WithError(err).WithFields(ProwJobFields(&toCancel)).Warn("Cannot clean up job resources")
 		}
 
-		toCancel.SetComplete()
-		toCancel.Status.State = prowapi.AbortedState
-		if toCancel.Status.PrevReportStates == nil {
-			toCancel.Status.PrevReportStates = map[string]prowapi.ProwJobState{}
-		}
-		toCancel.Status.PrevReportStates[reporter.GitHubReporterName] = toCancel.Status.State
+		markAborted(&toCancel)
 
 		log.WithFields(ProwJobFields(&toCancel)).
 			WithField("from", prevPJ.Status.State).
@@ -126,6 +122,44 @@ func TerminateOlderJobs(pjc prowClient, log *logrus.Entry, pjs []prowapi.ProwJob
 	return nil
 }
 
+// AbortJobs aborts the given jobs that are not complete yet, explaining why
+// in their description. Unlike TerminateOlderJobs it does not clean up their
+// resources, which is left to the controller running them, and the aborted
+// state is reported to GitHub so that no pending status context is left
+// behind.
+func AbortJobs(pjc prowClient, log *logrus.Entry, pjs []prowapi.ProwJob, description string) error {
+	var errs []error
+	for _, pj := range pjs {
+		if pj.Complete() {
+			continue
+		}
+		prevPJ := *pj.DeepCopy()
+		pj.SetComplete()
+		pj.Status.State = prowapi.AbortedState
+		pj.Status.Description = description
+
+		log.WithFields(ProwJobFields(&pj)).
+			WithField("from", prevPJ.Status.State).
+			WithField("to", pj.Status.State).Info("Transitioning states")
+
+		if _, err := PatchProwjob(pjc, log, prevPJ, pj); err != nil {
+			errs = append(errs, fmt.Errorf("failed to abort prowjob %s: %v", pj.Name, err))
+		}
+	}
+	return errorutil.NewAggregate(errs...)
+}
+
+// markAborted completes the job as aborted. It is recorded as reported to
+// GitHub already so that its status context is not turned into a failure.
+func markAborted(pj *prowapi.ProwJob) {
+	pj.SetComplete()
+	pj.Status.State = prowapi.AbortedState
+	if pj.Status.PrevReportStates == nil {
+		pj.Status.PrevReportStates = map[string]prowapi.ProwJobState{}
+	}
+	pj.Status.PrevReportStates[reporter.GitHubReporterName] = pj.Status.State
+}
+
 func PatchProwjob(pjc prowClient, log *logrus.Entry, srcPJ prowapi.ProwJob, destPJ prowapi.ProwJob) (*prowapi.ProwJob, error) {
 	srcPJData, err := json.Marshal(srcPJ)
 	if err != nil {
@@ -28,6 +28,7 @@ import (
 
 	prowjobv1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	prowfake "k8s.io/test-infra/prow/client/clientset/versioned/fake"
+	"k8s.io/test-infra/prow/github/reporter"
 )
 
 func TestTerminateOlderJobs(t *testing.T) {
@@ -471,3 +472,60 @@ func TestTerminateOlderJobs(t *testing.T) {
 		})
 	}
 }
+
+func TestAbortJobs(t *testing.T) {
+	fakePJNS := "prow-job"
+	completed := metav1.Now()
+	pjs := []prowjobv1.ProwJob{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: fakePJNS},
+			Status:     prowjobv1.ProwJobStatus{State: prowjobv1.PendingState},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "triggered", Namespace: fakePJNS},
+			Status:     prowjobv1.ProwJobStatus{State: prowjobv1.TriggeredState},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "complete", Namespace: fakePJNS},
+			Status:     prowjobv1.ProwJobStatus{State: prowjobv1.SuccessState, CompletionTime: &completed},
+		},
+	}
+	var prowJobs []runtime.Object
+	for i := range pjs {
+		prowJobs = append(prowJobs, pjs[i].DeepCopy())
+	}
+	fakeProwJobClient := prowfake.NewSimpleClientset(prowJobs...)
+	pjc := fakeProwJobClient.ProwV1().ProwJobs(fakePJNS)
+	log := logrus.NewEntry(logrus.StandardLogger())
+
+	if err := AbortJobs(pjc, log, pjs, "Aborted because the pull request was closed."); err != nil {
+		t.Fatalf("error aborting jobs: %v", err)
+	}
+
+	expected := map[string]prowjobv1.ProwJobState{
+		"pending":   prowjobv1.AbortedState,
+		"triggered": prowjobv1.AbortedState,
+		"complete":  prowjobv1.SuccessState,
+	}
+	for name, state := range expected {
+		pj, err := pjc.Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get prowjob %s: %v", name, err)
+		}
+		if pj.Status.State != state {
+			t.Errorf("expected prowjob %s to be %s, got %s", name, state, pj.Status.State)
+		}
+		if state != prowjobv1.AbortedState {
+			continue
+		}
+		if !pj.Complete() {
+			t.Errorf("expected prowjob %s to be complete", name)
+		}
+		if pj.Status.Description != "Aborted because the pull request was closed." {
+			t.Errorf("expected prowjob %s to explain why it was aborted, got %q", name, pj.Status.Description)
+		}
+		if _, reported := pj.Status.PrevReportStates[reporter.GitHubReporterName]; reported {
+			t.Errorf("expected the aborted state of prowjob %s to be reported to GitHub", name)
+		}
+	}
+}
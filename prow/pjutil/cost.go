@@ -0,0 +1,158 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pjutil
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// RecordReservedResources adds the resources requested by the pod and the
+// time it was running to the reserved resources of the ProwJob. It is called
+// for every pod of the job, so retried attempts are accounted for as well.
+func RecordReservedResources(pj *prowapi.ProwJob, pod coreapi.Pod, now time.Time) {
+	if pj.Status.ReservedResources == nil {
+		pj.Status.ReservedResources = &prowapi.ReservedResources{}
+	}
+	reserved := pj.Status.ReservedResources
+	reserved.Requests = podResources(pod.Spec, func(r coreapi.ResourceRequirements) coreapi.ResourceList { return r.Requests })
+	reserved.Limits = podResources(pod.Spec, func(r coreapi.ResourceRequirements) coreapi.ResourceList { return r.Limits })
+	reserved.RunningDuration = metav1.Duration{Duration: reserved.RunningDuration.Duration + podRunningDuration(pod, now)}
+}
+
+// podResources sums up the resources of all containers in the pod. Init
+// containers run before the other containers, so they only count when
+// they need more than all of the other containers together, just like for
+// the scheduler.
+func podResources(spec coreapi.PodSpec, get func(coreapi.ResourceRequirements) coreapi.ResourceList) coreapi.ResourceList {
+	total := coreapi.ResourceList{}
+	for _, container := range spec.Containers {
+		for name, quantity := range get(container.Resources) {
+			sum := total[name]
+			sum.Add(quantity)
+			total[name] = sum
+		}
+	}
+	for _, container := range spec.InitContainers {
+		for name, quantity := range get(container.Resources) {
+			if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	if len(total) == 0 {
+		return nil
+	}
+	return total
+}
+
+// podRunningDuration returns how long the pod was running: from its start
+// until the last of its containers terminated, or until now if a container
+// is still running.
+func podRunningDuration(pod coreapi.Pod, now time.Time) time.Duration {
+	if pod.Status.StartTime == nil {
+		return 0
+	}
+	var end time.Time
+	var running bool
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil {
+			running = true
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(end) {
+			end = terminated.FinishedAt.Time
+		}
+	}
+	if running || end.IsZero() {
+		end = now
+	}
+	if end.Before(pod.Status.StartTime.Time) {
+		return 0
+	}
+	return end.Sub(pod.Status.StartTime.Time)
+}
+
+// JobCost aggregates the reserved resources of the runs of a job.
+type JobCost struct {
+	Org  string `json:"org"`
+	Repo string `json:"repo"`
+	Job  string `json:"job"`
+	Type string `json:"type"`
+	// Runs is the number of completed runs with recorded reserved resources.
+	Runs int `json:"runs"`
+	// CPUCoreSeconds is the requested CPU multiplied by the running time.
+	CPUCoreSeconds float64 `json:"cpu_core_seconds"`
+	// MemoryByteSeconds is the requested memory multiplied by the running time.
+	MemoryByteSeconds float64 `json:"memory_byte_seconds"`
+	// RunningSeconds is the total time the pods of the job were running.
+	RunningSeconds float64 `json:"running_seconds"`
+	// MaxRunningSeconds is the running time of the longest run.
+	MaxRunningSeconds float64 `json:"max_running_seconds"`
+}
+
+// AggregateCosts sums up the recorded reserved resources of the ProwJobs per
+// org, repo, job and type. The result is sorted by requested CPU, most
+// expensive first.
+func AggregateCosts(pjs []prowapi.ProwJob) []JobCost {
+	costs := map[JobCost]*JobCost{}
+	for _, pj := range pjs {
+		reserved := pj.Status.ReservedResources
+		if reserved == nil {
+			continue
+		}
+		key := JobCost{Job: pj.Spec.Job, Type: string(pj.Spec.Type)}
+		if pj.Spec.Refs != nil {
+			key.Org, key.Repo = pj.Spec.Refs.Org, pj.Spec.Refs.Repo
+		} else if len(pj.Spec.ExtraRefs) > 0 {
+			key.Org, key.Repo = pj.Spec.ExtraRefs[0].Org, pj.Spec.ExtraRefs[0].Repo
+		}
+		cost, ok := costs[key]
+		if !ok {
+			cost = &JobCost{Org: key.Org, Repo: key.Repo, Job: key.Job, Type: key.Type}
+			costs[key] = cost
+		}
+		running := reserved.RunningDuration.Seconds()
+		cost.Runs++
+		cost.CPUCoreSeconds += reserved.CPUCoreSeconds()
+		cost.MemoryByteSeconds += reserved.MemoryByteSeconds()
+		cost.RunningSeconds += running
+		if running > cost.MaxRunningSeconds {
+			cost.MaxRunningSeconds = running
+		}
+	}
+
+	result := make([]JobCost, 0, len(costs))
+	for _, cost := range costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CPUCoreSeconds != result[j].CPUCoreSeconds {
+			return result[i].CPUCoreSeconds > result[j].CPUCoreSeconds
+		}
+		if result[i].Job != result[j].Job {
+			return result[i].Job < result[j].Job
+		}
+		return fmt.Sprintf("%s/%s/%s", result[i].Org, result[i].Repo, result[i].Type) < fmt.Sprintf("%s/%s/%s", result[j].Org, result[j].Repo, result[j].Type)
+	})
+	return result
+}
@@ -0,0 +1,155 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pjutil
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+func TestRecordReservedResources(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	resources := func(cpu, memory string) coreapi.ResourceRequirements {
+		return coreapi.ResourceRequirements{
+			Requests: coreapi.ResourceList{
+				coreapi.ResourceCPU:    resource.MustParse(cpu),
+				coreapi.ResourceMemory: resource.MustParse(memory),
+			},
+		}
+	}
+	terminated := func(finished time.Time) coreapi.ContainerStatus {
+		return coreapi.ContainerStatus{State: coreapi.ContainerState{Terminated: &coreapi.ContainerStateTerminated{FinishedAt: metav1.NewTime(finished)}}}
+	}
+	started := metav1.NewTime(now.Add(-time.Hour))
+
+	testCases := []struct {
+		name             string
+		reserved         *prowapi.ReservedResources
+		pod              coreapi.Pod
+		expectedCPU      string
+		expectedMemory   string
+		expectedDuration time.Duration
+	}{
+		{
+			name: "containers are summed up and the pod ran until the last container finished",
+			pod: coreapi.Pod{
+				Spec: coreapi.PodSpec{
+					Containers: []coreapi.Container{
+						{Resources: resources("2", "1Gi")},
+						{Resources: resources("500m", "1Gi")},
+					},
+				},
+				Status: coreapi.PodStatus{
+					StartTime: &started,
+					ContainerStatuses: []coreapi.ContainerStatus{
+						terminated(now.Add(-30 * time.Minute)),
+						terminated(now.Add(-20 * time.Minute)),
+					},
+				},
+			},
+			expectedCPU:      "2500m",
+			expectedMemory:   "2Gi",
+			expectedDuration: 40 * time.Minute,
+		},
+		{
+			name: "init containers only count when they need more",
+			pod: coreapi.Pod{
+				Spec: coreapi.PodSpec{
+					InitContainers: []coreapi.Container{{Resources: resources("4", "100Mi")}},
+					Containers:     []coreapi.Container{{Resources: resources("1", "1Gi")}},
+				},
+				Status: coreapi.PodStatus{
+					StartTime:         &started,
+					ContainerStatuses: []coreapi.ContainerStatus{{State: coreapi.ContainerState{Running: &coreapi.ContainerStateRunning{}}}},
+				},
+			},
+			expectedCPU:      "4",
+			expectedMemory:   "1Gi",
+			expectedDuration: time.Hour,
+		},
+		{
+			name:     "running time of previous attempts is kept",
+			reserved: &prowapi.ReservedResources{RunningDuration: metav1.Duration{Duration: time.Hour}},
+			pod: coreapi.Pod{
+				Spec: coreapi.PodSpec{
+					Containers: []coreapi.Container{{Resources: resources("1", "1Gi")}},
+				},
+				Status: coreapi.PodStatus{
+					StartTime:         &started,
+					ContainerStatuses: []coreapi.ContainerStatus{terminated(now)},
+				},
+			},
+			expectedCPU:      "1",
+			expectedMemory:   "1Gi",
+			expectedDuration: 2 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pj := prowapi.ProwJob{Status: prowapi.ProwJobStatus{ReservedResources: tc.reserved}}
+			RecordReservedResources(&pj, tc.pod, now)
+			reserved := pj.Status.ReservedResources
+			if cpu := reserved.Requests[coreapi.ResourceCPU]; cpu.Cmp(resource.MustParse(tc.expectedCPU)) != 0 {
+				t.Errorf("expected %s CPU, got %s", tc.expectedCPU, cpu.String())
+			}
+			if memory := reserved.Requests[coreapi.ResourceMemory]; memory.Cmp(resource.MustParse(tc.expectedMemory)) != 0 {
+				t.Errorf("expected %s memory, got %s", tc.expectedMemory, memory.String())
+			}
+			if reserved.RunningDuration.Duration != tc.expectedDuration {
+				t.Errorf("expected running duration %v, got %v", tc.expectedDuration, reserved.RunningDuration.Duration)
+			}
+		})
+	}
+}
+
+func TestAggregateCosts(t *testing.T) {
+	newJob := func(job, org, repo string, cpu string, running time.Duration) prowapi.ProwJob {
+		pj := prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{Job: job, Type: prowapi.PresubmitJob, Refs: &prowapi.Refs{Org: org, Repo: repo}},
+		}
+		if cpu != "" {
+			pj.Status.ReservedResources = &prowapi.ReservedResources{
+				Requests:        coreapi.ResourceList{coreapi.ResourceCPU: resource.MustParse(cpu)},
+				RunningDuration: metav1.Duration{Duration: running},
+			}
+		}
+		return pj
+	}
+	pjs := []prowapi.ProwJob{
+		newJob("cheap", "org", "repo", "1", time.Minute),
+		newJob("expensive", "org", "repo", "8", time.Hour),
+		newJob("expensive", "org", "repo", "8", 2*time.Hour),
+		newJob("expensive", "org", "other", "8", time.Minute),
+		newJob("unaccounted", "org", "repo", "", 0),
+	}
+	expected := []JobCost{
+		{Org: "org", Repo: "repo", Job: "expensive", Type: "presubmit", Runs: 2, CPUCoreSeconds: 8 * 3 * 3600, RunningSeconds: 3 * 3600, MaxRunningSeconds: 2 * 3600},
+		{Org: "org", Repo: "other", Job: "expensive", Type: "presubmit", Runs: 1, CPUCoreSeconds: 8 * 60, RunningSeconds: 60, MaxRunningSeconds: 60},
+		{Org: "org", Repo: "repo", Job: "cheap", Type: "presubmit", Runs: 1, CPUCoreSeconds: 60, RunningSeconds: 60, MaxRunningSeconds: 60},
+	}
+	if actual := AggregateCosts(pjs); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected costs %+v, got %+v", expected, actual)
+	}
+}
@@ -22,6 +22,8 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"strings"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/sirupsen/logrus"
@@ -40,6 +42,12 @@ import (
 func NewProwJob(spec prowapi.ProwJobSpec, extraLabels, extraAnnotations map[string]string) prowapi.ProwJob {
 	labels, annotations := decorate.LabelsAndAnnotationsForSpec(spec, extraLabels, extraAnnotations)
 
+	state := prowapi.TriggeredState
+	if len(spec.DependsOn) > 0 {
+		// Jobs with dependencies are held back until their upstream jobs succeed.
+		state = prowapi.WaitingState
+	}
+
 	return prowapi.ProwJob{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "prow.k8s.io/v1",
@@ -53,7 +61,7 @@ func NewProwJob(spec prowapi.ProwJobSpec, extraLabels, extraAnnotations map[stri
 		Spec: spec,
 		Status: prowapi.ProwJobStatus{
 			StartTime: metav1.Now(),
-			State:     prowapi.TriggeredState,
+			State:     state,
 		},
 	}
 }
@@ -167,6 +175,8 @@ func specFromJobBase(jb config.JobBase) prowapi.ProwJobSpec {
 		Namespace:       namespace,
 		MaxConcurrency:  jb.MaxConcurrency,
 		ErrorOnEviction: jb.ErrorOnEviction,
+		DependsOn:       jb.DependsOn,
+		RetryPolicy:     jb.RetryPolicy,
 
 		ExtraRefs:        jb.ExtraRefs,
 		DecorationConfig: jb.DecorationConfig,
@@ -241,6 +251,28 @@ func GetLatestProwJobs(pjs []prowapi.ProwJob, jobType prowapi.ProwJobType) map[s
 	return latestJobs
 }
 
+// missedRunGracePeriod is how long after a cron run is due it may take
+// horologium to trigger it before the run is considered missed.
+const missedRunGracePeriod = 2 * time.Minute
+
+// MissedRuns returns the runs of a cron periodic that were due since its latest
+// ProwJob but were never triggered, oldest first. A catch-up run counts from the
+// time it was scheduled for, any other run from when it started.
+func MissedRuns(p config.Periodic, latest prowapi.ProwJob, previousFound bool, now time.Time) ([]time.Time, error) {
+	if p.Cron == "" || !previousFound {
+		return nil, nil
+	}
+	since := latest.Status.StartTime.Time
+	if scheduled, ok := latest.Annotations[kube.ScheduledTimeAnnotation]; ok {
+		t, err := time.Parse(time.RFC3339, scheduled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on %s: %v", kube.ScheduledTimeAnnotation, latest.Name, err)
+		}
+		since = t
+	}
+	return p.ScheduledRuns(since, now.Add(-p.GetJitter()-missedRunGracePeriod))
+}
+
 // ProwJobFields extracts logrus fields from a prowjob useful for logging.
 func ProwJobFields(pj *prowapi.ProwJob) logrus.Fields {
 	fields := make(logrus.Fields)
@@ -271,8 +303,8 @@ func JobURL(plank config.Plank, pj prowapi.ProwJob, log *logrus.Entry) string {
 		gcsConfig := pj.Spec.DecorationConfig.GCSConfiguration
 		_, gcsPath, _ := gcsupload.PathsForJob(gcsConfig, &spec, "")
 
-		prefix, _ := url.Parse(plank.GetJobURLPrefix(pj.Spec.Refs))
-		prefix.Path = path.Join(prefix.Path, gcsConfig.Bucket, gcsPath)
+		prefix, _ := url.Parse(JobURLPrefix(plank, pj.Spec.Refs, gcsConfig.StorageProvider()))
+		prefix.Path = path.Join(prefix.Path, gcsConfig.BucketName(), gcsPath)
 		return prefix.String()
 	}
 	var b bytes.Buffer
@@ -284,6 +316,27 @@ func JobURL(plank config.Plank, pj prowapi.ProwJob, log *logrus.Entry) string {
 	return ""
 }
 
+// JobURLPrefix returns the job URL prefix for jobs uploading to the given
+// storage provider. Prefixes for spyglass conventionally end in the GCS key
+// type, as in https://prow.k8s.io/view/gcs/, which is swapped for the key
+// type of any other provider, as in https://prow.k8s.io/view/s3/.
+func JobURLPrefix(plank config.Plank, refs *prowapi.Refs, provider string) string {
+	prefix := plank.GetJobURLPrefix(refs)
+	if provider == prowapi.StorageProviderGCS {
+		return prefix
+	}
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return prefix
+	}
+	dir, last := path.Split(strings.TrimSuffix(u.Path, "/"))
+	if last != "gcs" {
+		return prefix
+	}
+	u.Path = dir + provider + "/"
+	return u.String()
+}
+
 // ClusterToCtx converts the prow job's cluster to a cluster context
 func ClusterToCtx(cluster string) string {
 	if cluster == kube.InClusterContext {
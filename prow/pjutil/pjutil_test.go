@@ -688,6 +688,44 @@ func TestJobURL(t *testing.T) {
 			}},
 			expected: "https://gubernator.com/build/bucket/pr-logs/pull/org_repo/1",
 		},
+		{
+			name: "decorated job uploading to s3 uses the s3 spyglass key type",
+			plank: config.Plank{
+				JobURLPrefixConfig: map[string]string{"*": "https://prow.example.com/view/gcs/"},
+			},
+			pj: prowapi.ProwJob{Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PresubmitJob,
+				Refs: &prowapi.Refs{
+					Org:   "org",
+					Repo:  "repo",
+					Pulls: []prowapi.Pull{{Number: 1}},
+				},
+				DecorationConfig: &prowapi.DecorationConfig{GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "s3://bucket",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				}},
+			}},
+			expected: "https://prow.example.com/view/s3/bucket/pr-logs/pull/org_repo/1",
+		},
+		{
+			name: "decorated job uploading to gcs with a scheme keeps the gcs key type",
+			plank: config.Plank{
+				JobURLPrefixConfig: map[string]string{"*": "https://prow.example.com/view/gcs/"},
+			},
+			pj: prowapi.ProwJob{Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PresubmitJob,
+				Refs: &prowapi.Refs{
+					Org:   "org",
+					Repo:  "repo",
+					Pulls: []prowapi.Pull{{Number: 1}},
+				},
+				DecorationConfig: &prowapi.DecorationConfig{GCSConfiguration: &prowapi.GCSConfiguration{
+					Bucket:       "gs://bucket",
+					PathStrategy: prowapi.PathStrategyExplicit,
+				}},
+			}},
+			expected: "https://prow.example.com/view/gcs/bucket/pr-logs/pull/org_repo/1",
+		},
 	}
 
 	logger := logrus.New()
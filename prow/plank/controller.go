@@ -29,6 +29,7 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	ktypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -41,11 +42,13 @@ import (
 	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/pod-utils/decorate"
+	"k8s.io/test-infra/prow/sidecar"
 )
 
 // PodStatus constants
 const (
-	Evicted = "Evicted"
+	Evicted  = "Evicted"
+	NodeLost = "NodeLost"
 )
 
 type prowJobClient interface {
@@ -88,6 +91,10 @@ type Controller struct {
 	// pendingJobs is a short-lived cache that helps in limiting
 	// the maximum concurrency of jobs.
 	pendingJobs map[string]int
+	// admitted holds the names of the triggered ProwJobs the fair-share
+	// scheduler allows to start in the current sync. It is nil when no
+	// job queues are configured.
+	admitted sets.String
 
 	// If `lock` is acquired as well, `lock` must be acquired before locking
 	// pjLock
@@ -126,6 +133,15 @@ func (c *Controller) canExecuteConcurrently(pj *prowapi.ProwJob) bool {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
+	if c.admitted != nil {
+		if !c.admitted.Has(pj.Name) {
+			c.log.WithFields(pjutil.ProwJobFields(pj)).Debug("Not starting job, its queue has no free capacity.")
+			return false
+		}
+		c.pendingJobs[pj.Spec.Job]++
+		return true
+	}
+
 	if max := c.config().Plank.MaxConcurrency; max > 0 {
 		var running int
 		for _, num := range c.pendingJobs {
@@ -242,6 +258,14 @@ func (c *Controller) Sync() error {
 	if err := c.terminateDupes(k8sJobs, pm); err != nil {
 		syncErrs = append(syncErrs, err)
 	}
+	if err := c.deleteAbortedPods(k8sJobs, pm); err != nil {
+		syncErrs = append(syncErrs, err)
+	}
+
+	reportCh := make(chan prowapi.ProwJob, len(k8sJobs))
+	if err := c.syncWaitingJobs(pjs.Items, k8sJobs, reportCh); err != nil {
+		syncErrs = append(syncErrs, err)
+	}
 
 	// Share what we have for gathering metrics.
 	c.pjLock.Lock()
@@ -250,7 +274,6 @@ func (c *Controller) Sync() error {
 
 	pendingCh, triggeredCh := pjutil.PartitionActive(k8sJobs)
 	errCh := make(chan error, len(k8sJobs))
-	reportCh := make(chan prowapi.ProwJob, len(k8sJobs))
 
 	// Reinstantiate on every resync of the controller instead of trying
 	// to keep this in sync with the state of the world.
@@ -260,6 +283,12 @@ func (c *Controller) Sync() error {
 	maxSyncRoutines := c.config().Plank.MaxGoroutines
 	c.log.Debugf("Handling %d pending prowjobs", len(pendingCh))
 	syncProwJobs(c.log, c.syncPendingJob, maxSyncRoutines, pendingCh, reportCh, errCh, pm)
+	// With job queues configured, decide up front which triggered jobs may
+	// start so that free capacity is shared fairly between the queues.
+	c.admitted = nil
+	if plank := c.config().Plank; len(plank.JobQueues) > 0 {
+		c.admitted = schedule(buildQueues(plank, k8sJobs), plank.MaxConcurrency, c.pendingJobs)
+	}
 	c.log.Debugf("Handling %d triggered prowjobs", len(triggeredCh))
 	syncProwJobs(c.log, c.syncTriggeredJob, maxSyncRoutines, triggeredCh, reportCh, errCh, pm)
 
@@ -298,6 +327,7 @@ func (c *Controller) SyncMetrics() {
 	c.pjLock.RLock()
 	defer c.pjLock.RUnlock()
 	kube.GatherProwJobMetrics(c.pjs)
+	gatherQueueMetrics(c.config().Plank, c.pjs, c.clock.Now())
 }
 
 // terminateDupes aborts presubmits that have a newer version. It modifies pjs
@@ -322,6 +352,145 @@ func (c *Controller) terminateDupes(pjs []prowapi.ProwJob, pm map[string]coreapi
 	})
 }
 
+// deleteAbortedPods deletes the pods that are still running for jobs that
+// were aborted elsewhere, e.g. by trigger when their pull request was closed.
+func (c *Controller) deleteAbortedPods(pjs []prowapi.ProwJob, pm map[string]coreapi.Pod) error {
+	if !c.config().Plank.AllowCancellations {
+		return nil
+	}
+	var errs []error
+	for _, pj := range pjs {
+		if pj.Status.State != prowapi.AbortedState {
+			continue
+		}
+		pod, exists := pm[pj.ObjectMeta.Name]
+		if !exists || (pod.Status.Phase != coreapi.PodPending && pod.Status.Phase != coreapi.PodRunning) {
+			continue
+		}
+		if err := c.deletePod(pj, pod); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("%s: %v", pj.ObjectMeta.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors deleting pods of aborted jobs: %v", errs)
+	}
+	return nil
+}
+
+// syncWaitingJobs triggers waiting ProwJobs once every job they depend on
+// has succeeded for the same refs and aborts them as soon as one of those
+// jobs did not succeed. It modifies pjs in-place.
+func (c *Controller) syncWaitingJobs(all, pjs []prowapi.ProwJob, reports chan<- prowapi.ProwJob) error {
+	var errs []error
+	for i := range pjs {
+		if pjs[i].Status.State != prowapi.WaitingState {
+			continue
+		}
+		prevPJ := *pjs[i].DeepCopy()
+		pj := *pjs[i].DeepCopy()
+
+		state, upstream, triggered := upstreamState(pj, all)
+		switch {
+		case state == prowapi.SuccessState:
+			pj.Status.State = prowapi.TriggeredState
+			pj.Status.Description = "Upstream jobs succeeded."
+		case state == prowapi.WaitingState && !triggered && c.waitedTooLong(pj):
+			// Nothing will ever trigger an upstream job that was skipped,
+			// for example because it only runs if some files changed.
+			pj.SetComplete()
+			pj.Status.State = prowapi.AbortedState
+			pj.Status.Description = fmt.Sprintf("Upstream job %s was not triggered within %s.", upstream, c.config().Plank.WaitingTimeout.Duration)
+		case state == prowapi.WaitingState:
+			pj.Status.Description = fmt.Sprintf("Waiting for upstream job %s.", upstream)
+		default:
+			pj.SetComplete()
+			pj.Status.State = prowapi.AbortedState
+			pj.Status.Description = fmt.Sprintf("Upstream job %s did not succeed.", upstream)
+		}
+		if pj.Status.Description == prevPJ.Status.Description {
+			continue
+		}
+
+		if prevPJ.Status.State != pj.Status.State {
+			c.log.WithFields(pjutil.ProwJobFields(&pj)).
+				WithField("from", prevPJ.Status.State).
+				WithField("to", pj.Status.State).Info("Transitioning states.")
+		}
+		newPJ, err := pjutil.PatchProwjob(c.prowJobClient, c.log, prevPJ, pj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pjs[i] = *newPJ
+		if pj.Complete() {
+			reports <- *newPJ
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("errors syncing waiting jobs: %v", errs)
+	}
+	return nil
+}
+
+// waitedTooLong determines if a waiting ProwJob exceeded the waiting timeout.
+func (c *Controller) waitedTooLong(pj prowapi.ProwJob) bool {
+	timeout := c.config().Plank.WaitingTimeout
+	if timeout == nil || pj.Status.StartTime.IsZero() {
+		return false
+	}
+	return c.clock.Since(pj.Status.StartTime.Time) >= timeout.Duration
+}
+
+// upstreamState determines the combined state of the jobs the given
+// ProwJob depends on. It returns SuccessState once all of them succeeded,
+// WaitingState along with the name of the first job that has not yet
+// finished and whether it was triggered at all, or the state and name of
+// the first job that did not succeed.
+func upstreamState(pj prowapi.ProwJob, all []prowapi.ProwJob) (prowapi.ProwJobState, string, bool) {
+	for _, dep := range pj.Spec.DependsOn {
+		var latest *prowapi.ProwJob
+		for i := range all {
+			candidate := &all[i]
+			if candidate.Spec.Job != dep || candidate.Spec.Type != pj.Spec.Type || !sameRefs(candidate.Spec.Refs, pj.Spec.Refs) {
+				continue
+			}
+			if latest == nil || latest.CreationTimestamp.Before(&candidate.CreationTimestamp) {
+				latest = candidate
+			}
+		}
+		if latest == nil {
+			return prowapi.WaitingState, dep, false
+		}
+		if !latest.Complete() {
+			return prowapi.WaitingState, dep, true
+		}
+		if latest.Status.State != prowapi.SuccessState {
+			return latest.Status.State, dep, true
+		}
+	}
+	return prowapi.SuccessState, "", true
+}
+
+// sameRefs determines if two refs point at the same code under test.
+// Pull requests are compared by head SHA, otherwise by base SHA.
+func sameRefs(a, b *prowapi.Refs) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Org != b.Org || a.Repo != b.Repo || a.BaseRef != b.BaseRef || len(a.Pulls) != len(b.Pulls) {
+		return false
+	}
+	if len(a.Pulls) == 0 {
+		return a.BaseSHA == b.BaseSHA
+	}
+	for i := range a.Pulls {
+		if a.Pulls[i].Number != b.Pulls[i].Number || a.Pulls[i].SHA != b.Pulls[i].SHA {
+			return false
+		}
+	}
+	return true
+}
+
 // TODO: Dry this out
 func syncProwJobs(
 	l *logrus.Entry,
@@ -358,8 +527,20 @@ func (c *Controller) syncPendingJob(pj prowapi.ProwJob, pm map[string]coreapi.Po
 	prevPJ := *pj.DeepCopy()
 
 	pod, podExists := pm[pj.ObjectMeta.Name]
+	if podExists && pod.DeletionTimestamp != nil && pj.Spec.RetryPolicy != nil {
+		// The pod of a failed attempt is still being deleted, wait for it
+		// to go away before starting the next attempt.
+		c.incrementNumPendingJobs(pj.Spec.Job)
+		return nil
+	}
 	if !podExists {
 		c.incrementNumPendingJobs(pj.Spec.Job)
+		if n := len(pj.Status.Attempts); n > 0 {
+			if next := pj.Status.Attempts[n-1].CompletionTime.Add(pj.Spec.RetryPolicy.BackoffFor(n)); c.clock.Now().Before(next) {
+				// Wait for the backoff before retrying.
+				return nil
+			}
+		}
 		// Pod is missing. This can happen in case the previous pod was deleted manually or by
 		// a rescheduler. Start a new pod.
 		id, pn, err := c.startPod(pj)
@@ -380,6 +561,26 @@ func (c *Controller) syncPendingJob(pj prowapi.ProwJob, pm map[string]coreapi.Po
 
 		switch pod.Status.Phase {
 		case coreapi.PodUnknown:
+			if pj.Spec.RetryPolicy.Retries(prowapi.NodeLostFailure) {
+				// Pod is in Unknown state. This can happen if the node was lost.
+				// Count this as an attempt so the job does not retry forever.
+				description := "Job pod was lost along with its node."
+				retried, err := c.retry(&pj, pod, prowapi.NodeLostFailure, description)
+				if err != nil {
+					return err
+				}
+				if retried {
+					c.incrementNumPendingJobs(pj.Spec.Job)
+					break
+				}
+				pj.SetComplete()
+				pj.Status.State = prowapi.ErrorState
+				pj.Status.Description = description
+				if err := c.deletePod(pj, pod); err != nil {
+					return fmt.Errorf("failed to delete lost pod %s: %v", pod.Name, err)
+				}
+				break
+			}
 			c.incrementNumPendingJobs(pj.Spec.Job)
 			// Pod is in Unknown state. This can happen if there is a problem with
 			// the node. Delete the old pod, we'll start a new one next loop.
@@ -397,6 +598,9 @@ func (c *Controller) syncPendingJob(pj prowapi.ProwJob, pm map[string]coreapi.Po
 			pj.SetComplete()
 			pj.Status.State = prowapi.SuccessState
 			pj.Status.Description = "Job succeeded."
+			if len(pj.Status.Attempts) > 0 {
+				pj.Status.Description = fmt.Sprintf("Job succeeded on attempt %d.", pj.Attempt())
+			}
 
 		case coreapi.PodFailed:
 			if pod.Status.Reason == Evicted {
@@ -418,18 +622,59 @@ func (c *Controller) syncPendingJob(pj prowapi.ProwJob, pm map[string]coreapi.Po
 				c.log.WithField("name", pj.ObjectMeta.Name).Debug("Delete Pod.")
 				return client.Delete(pj.ObjectMeta.Name, &metav1.DeleteOptions{})
 			}
+			if class, description := infraFailure(pod); class != "" {
+				retried, err := c.retry(&pj, pod, class, description)
+				if err != nil {
+					return err
+				}
+				if retried {
+					c.incrementNumPendingJobs(pj.Spec.Job)
+					break
+				}
+			}
 			// Pod failed. Update ProwJob, talk to GitHub.
 			pj.SetComplete()
 			pj.Status.State = prowapi.FailureState
 			pj.Status.Description = "Job failed."
 
 		case coreapi.PodPending:
+			class, description := infraFailure(pod)
+			if class == prowapi.ImagePullFailure && imagePullBackOff(pod) {
+				// Retry on a fresh pod instead of waiting for the kubelet to
+				// back off pulling the image until the pending timeout. A
+				// first failed pull is often transient, so only pulls the
+				// kubelet backs off from count.
+				retried, err := c.retry(&pj, pod, class, description)
+				if err != nil {
+					return err
+				}
+				if retried {
+					c.incrementNumPendingJobs(pj.Spec.Job)
+					break
+				}
+			}
 			maxPodPending := c.config().Plank.PodPendingTimeout.Duration
-			if pod.Status.StartTime.IsZero() || time.Since(pod.Status.StartTime.Time) < maxPodPending {
+			pendingSince := pod.Status.StartTime
+			if class == prowapi.SchedulingFailure && pj.Spec.RetryPolicy.Retries(class) {
+				// Pods that cannot be scheduled never start, so measure
+				// the pending timeout from when the pod was created.
+				pendingSince = &pod.CreationTimestamp
+			}
+			if pendingSince.IsZero() || time.Since(pendingSince.Time) < maxPodPending {
 				// Pod is running. Do nothing.
 				c.incrementNumPendingJobs(pj.Spec.Job)
 				return nil
 			}
+			if class != "" {
+				retried, err := c.retry(&pj, pod, class, description)
+				if err != nil {
+					return err
+				}
+				if retried {
+					c.incrementNumPendingJobs(pj.Spec.Job)
+					break
+				}
+			}
 
 			// Pod is stuck in pending state longer than maxPodPending
 			// abort the job, and talk to GitHub
@@ -473,6 +718,10 @@ func (c *Controller) syncPendingJob(pj prowapi.ProwJob, pm map[string]coreapi.Po
 		}
 	}
 
+	if podExists && pj.Complete() {
+		pjutil.RecordReservedResources(&pj, pod, c.clock.Now())
+	}
+
 	pj.Status.URL = pjutil.JobURL(c.config().Plank, pj, c.log)
 
 	reports <- pj
@@ -529,6 +778,9 @@ func (c *Controller) syncTriggeredJob(pj prowapi.ProwJob, pm map[string]coreapi.
 		pj.Status.PodName = pn
 		pj.Status.Description = "Job triggered."
 		pj.Status.URL = pjutil.JobURL(c.config().Plank, pj, c.log)
+		if plank := c.config().Plank; len(plank.JobQueues) > 0 {
+			queueWait.WithLabelValues(plank.QueueFor(&pj).Name).Observe(now.Sub(pj.CreationTimestamp.Time).Seconds())
+		}
 	}
 	reports <- pj
 	if prevState != pj.Status.State {
@@ -540,6 +792,80 @@ func (c *Controller) syncTriggeredJob(pj prowapi.ProwJob, pm map[string]coreapi.
 	return err
 }
 
+// infraFailure determines if the pod hit a known infrastructure problem
+// that is not the fault of the test. It returns an empty class otherwise.
+func infraFailure(pod coreapi.Pod) (prowapi.InfraFailureClass, string) {
+	if pod.Status.Reason == NodeLost {
+		return prowapi.NodeLostFailure, "Job pod was lost along with its node."
+	}
+	for _, statuses := range [][]coreapi.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if waiting := status.State.Waiting; waiting != nil && (waiting.Reason == "ErrImagePull" || waiting.Reason == "ImagePullBackOff") {
+				return prowapi.ImagePullFailure, fmt.Sprintf("Could not pull image %s.", status.Image)
+			}
+			if terminated := status.State.Terminated; terminated != nil && terminated.Message == sidecar.InfraFailureMessage {
+				return prowapi.SidecarFailure, "Job results could not be uploaded."
+			}
+		}
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == coreapi.PodScheduled && condition.Status == coreapi.ConditionFalse {
+			return prowapi.SchedulingFailure, "Job pod could not be scheduled."
+		}
+	}
+	return "", ""
+}
+
+// imagePullBackOff returns whether the kubelet backs off pulling an image of
+// the pod after failing to pull it.
+func imagePullBackOff(pod coreapi.Pod) bool {
+	for _, statuses := range [][]coreapi.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if waiting := status.State.Waiting; waiting != nil && waiting.Reason == "ImagePullBackOff" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retry records the current attempt of the job as failed because of an
+// infrastructure problem and deletes its pod so that a new one is started
+// once the backoff passed. It returns false without doing anything if the
+// retry policy of the job does not allow another attempt.
+func (c *Controller) retry(pj *prowapi.ProwJob, pod coreapi.Pod, class prowapi.InfraFailureClass, description string) (bool, error) {
+	policy := pj.Spec.RetryPolicy
+	if !policy.Retries(class) || pj.Attempt() >= policy.MaxAttempts {
+		return false, nil
+	}
+	if err := c.deletePod(*pj, pod); err != nil {
+		return false, fmt.Errorf("failed to delete pod %s to retry it: %v", pod.Name, err)
+	}
+	pjutil.RecordReservedResources(pj, pod, c.clock.Now())
+	pj.Status.Attempts = append(pj.Status.Attempts, prowapi.Attempt{
+		PodName:        pod.ObjectMeta.Name,
+		BuildID:        pj.Status.BuildID,
+		StartTime:      pod.Status.StartTime,
+		CompletionTime: metav1.NewTime(c.clock.Now()),
+		FailureClass:   class,
+		Description:    description,
+	})
+	pj.Status.Description = fmt.Sprintf("%s Retrying, attempt %d of %d.", description, pj.Attempt(), policy.MaxAttempts)
+	c.log.WithFields(pjutil.ProwJobFields(pj)).WithField("failure-class", class).Info("Retrying job after infrastructure failure.")
+	return true, nil
+}
+
+// deletePod deletes the pod of the job right away, without a grace period.
+func (c *Controller) deletePod(pj prowapi.ProwJob, pod coreapi.Pod) error {
+	client, ok := c.buildClients[pj.ClusterAlias()]
+	if !ok {
+		return fmt.Errorf("unknown cluster alias %q", pj.ClusterAlias())
+	}
+	var gracePeriod int64
+	c.log.WithField("name", pod.ObjectMeta.Name).Debug("Delete Pod.")
+	return client.Delete(pod.ObjectMeta.Name, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+}
+
 // TODO: No need to return the pod name since we already have the
 // prowjob in the call site.
 func (c *Controller) startPod(pj prowapi.ProwJob) (string, string, error) {
@@ -53,6 +53,7 @@ type fca struct {
 const (
 	podPendingTimeout = time.Hour
 	podRunningTimeout = time.Hour * 2
+	waitingTimeout    = 24 * time.Hour
 )
 
 func newFakeConfigAgent(t *testing.T, maxConcurrency int) *fca {
@@ -94,6 +95,7 @@ func newFakeConfigAgent(t *testing.T, maxConcurrency int) *fca {
 					},
 					PodPendingTimeout: &metav1.Duration{Duration: podPendingTimeout},
 					PodRunningTimeout: &metav1.Duration{Duration: podRunningTimeout},
+					WaitingTimeout:    &metav1.Duration{Duration: waitingTimeout},
 				},
 			},
 			JobConfig: config.JobConfig{
@@ -351,6 +353,103 @@ func TestTerminateDupes(t *testing.T) {
 	}
 }
 
+func TestDeleteAbortedPods(t *testing.T) {
+	newJob := func(name string, state prowapi.ProwJobState) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "prowjobs"},
+			Spec:       prowapi.ProwJobSpec{Agent: prowapi.KubernetesAgent},
+			Status:     prowapi.ProwJobStatus{State: state},
+		}
+	}
+	newPod := func(name string, phase v1.PodPhase) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "pods"},
+			Status:     v1.PodStatus{Phase: phase},
+		}
+	}
+	testcases := []struct {
+		name               string
+		allowCancellations bool
+		pjs                []prowapi.ProwJob
+		pm                 map[string]v1.Pod
+		deletedPods        sets.String
+	}{
+		{
+			name:               "running and pending pods of aborted jobs are deleted",
+			allowCancellations: true,
+			pjs: []prowapi.ProwJob{
+				newJob("aborted-running", prowapi.AbortedState),
+				newJob("aborted-pending", prowapi.AbortedState),
+				newJob("aborted-done", prowapi.AbortedState),
+				newJob("aborted-no-pod", prowapi.AbortedState),
+				newJob("pending", prowapi.PendingState),
+			},
+			pm: map[string]v1.Pod{
+				"aborted-running": newPod("aborted-running", v1.PodRunning),
+				"aborted-pending": newPod("aborted-pending", v1.PodPending),
+				"aborted-done":    newPod("aborted-done", v1.PodFailed),
+				"pending":         newPod("pending", v1.PodRunning),
+			},
+			deletedPods: sets.NewString("aborted-running", "aborted-pending"),
+		},
+		{
+			name: "pods are kept when cancellations are not allowed",
+			pjs: []prowapi.ProwJob{
+				newJob("aborted-running", prowapi.AbortedState),
+			},
+			pm: map[string]v1.Pod{
+				"aborted-running": newPod("aborted-running", v1.PodRunning),
+			},
+			deletedPods: sets.NewString(),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var pods []runtime.Object
+			for name := range tc.pm {
+				pod := tc.pm[name]
+				pods = append(pods, &pod)
+			}
+			fakePodClient := fake.NewSimpleClientset(pods...)
+			fca := &fca{
+				c: &config.Config{
+					ProwConfig: config.ProwConfig{
+						ProwJobNamespace: "prowjobs",
+						PodNamespace:     "pods",
+						Plank: config.Plank{
+							Controller: config.Controller{
+								AllowCancellations: tc.allowCancellations,
+							},
+						},
+					},
+				},
+			}
+			c := Controller{
+				prowJobClient: prowfake.NewSimpleClientset().ProwV1().ProwJobs("prowjobs"),
+				buildClients:  map[string]corev1.PodInterface{prowapi.DefaultClusterAlias: fakePodClient.CoreV1().Pods("pods")},
+				log:           logrus.NewEntry(logrus.StandardLogger()),
+				config:        fca.Config,
+				clock:         clock.RealClock{},
+			}
+
+			if err := c.deleteAbortedPods(tc.pjs, tc.pm); err != nil {
+				t.Fatalf("Error deleting aborted pods: %v", err)
+			}
+
+			observedDeletedPods := sets.NewString()
+			for _, action := range fakePodClient.Fake.Actions() {
+				if action, ok := action.(clienttesting.DeleteActionImpl); ok {
+					observedDeletedPods.Insert(action.Name)
+				}
+			}
+			if !observedDeletedPods.Equal(tc.deletedPods) {
+				t.Errorf("expected pods %v to be deleted, got %v", tc.deletedPods.List(), observedDeletedPods.List())
+			}
+		})
+	}
+}
+
 func handleTot(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, "42")
 }
@@ -815,12 +914,14 @@ func TestSyncPendingJob(t *testing.T) {
 		pods []v1.Pod
 		err  error
 
-		expectedState      prowapi.ProwJobState
-		expectedNumPods    int
-		expectedComplete   bool
-		expectedCreatedPJs int
-		expectedReport     bool
-		expectedURL        string
+		expectedState       prowapi.ProwJobState
+		expectedNumPods     int
+		expectedComplete    bool
+		expectedCreatedPJs  int
+		expectedReport      bool
+		expectedURL         string
+		expectedAttempts    int
+		expectedDescription string
 	}{
 		{
 			name: "reset when pod goes missing",
@@ -1159,6 +1260,308 @@ func TestSyncPendingJob(t *testing.T) {
 			expectedReport:   true,
 			expectedURL:      "endless/aborted",
 		},
+		{
+			name: "retry pod lost with its node",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lost",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:   prowapi.PendingState,
+					PodName: "lost",
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "lost",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase:  v1.PodFailed,
+						Reason: NodeLost,
+					},
+				},
+			},
+			expectedState:       prowapi.PendingState,
+			expectedNumPods:     0,
+			expectedReport:      true,
+			expectedURL:         "lost/pending",
+			expectedAttempts:    1,
+			expectedDescription: "Job pod was lost along with its node. Retrying, attempt 2 of 3.",
+		},
+		{
+			name: "fail pod lost with its node once attempts are exhausted",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lost",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:    prowapi.PendingState,
+					PodName:  "lost",
+					Attempts: []prowapi.Attempt{{PodName: "lost"}, {PodName: "lost"}},
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "lost",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase:  v1.PodFailed,
+						Reason: NodeLost,
+					},
+				},
+			},
+			expectedState:    prowapi.FailureState,
+			expectedNumPods:  1,
+			expectedComplete: true,
+			expectedReport:   true,
+			expectedURL:      "lost/failure",
+			expectedAttempts: 2,
+		},
+		{
+			name: "do not retry failure classes excluded by the policy",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lost",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3, FailureClasses: []prowapi.InfraFailureClass{prowapi.ImagePullFailure}},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:   prowapi.PendingState,
+					PodName: "lost",
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "lost",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase:  v1.PodFailed,
+						Reason: NodeLost,
+					},
+				},
+			},
+			expectedState:    prowapi.FailureState,
+			expectedNumPods:  1,
+			expectedComplete: true,
+			expectedReport:   true,
+			expectedURL:      "lost/failure",
+		},
+		{
+			name: "retry pod that cannot pull its image",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "image",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 2},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:   prowapi.PendingState,
+					PodName: "image",
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "image",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase:     v1.PodPending,
+						StartTime: startTime(time.Now()),
+						ContainerStatuses: []v1.ContainerStatus{{
+							Image: "gcr.io/k8s-testimages/missing",
+							State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+						}},
+					},
+				},
+			},
+			expectedState:       prowapi.PendingState,
+			expectedNumPods:     0,
+			expectedReport:      true,
+			expectedURL:         "image/pending",
+			expectedAttempts:    1,
+			expectedDescription: "Could not pull image gcr.io/k8s-testimages/missing. Retrying, attempt 2 of 2.",
+		},
+		{
+			name: "error pod in unknown state once attempts are exhausted",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unknown",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3, FailureClasses: []prowapi.InfraFailureClass{prowapi.NodeLostFailure}},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:    prowapi.PendingState,
+					PodName:  "unknown",
+					Attempts: []prowapi.Attempt{{PodName: "unknown"}, {PodName: "unknown"}},
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "unknown",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase: v1.PodUnknown,
+					},
+				},
+			},
+			expectedState:       prowapi.ErrorState,
+			expectedNumPods:     0,
+			expectedComplete:    true,
+			expectedReport:      true,
+			expectedURL:         "unknown/error",
+			expectedAttempts:    2,
+			expectedDescription: "Job pod was lost along with its node.",
+		},
+		{
+			name: "restart pod in unknown state if the policy does not retry lost nodes",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unknown",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3, FailureClasses: []prowapi.InfraFailureClass{prowapi.ImagePullFailure}},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:   prowapi.PendingState,
+					PodName: "unknown",
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "unknown",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase: v1.PodUnknown,
+					},
+				},
+			},
+			expectedState:   prowapi.PendingState,
+			expectedNumPods: 0,
+		},
+		{
+			name: "wait for the kubelet to back off pulling an image before retrying",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "image",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 2},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:   prowapi.PendingState,
+					PodName: "image",
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "image",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase:     v1.PodPending,
+						StartTime: startTime(time.Now()),
+						ContainerStatuses: []v1.ContainerStatus{{
+							Image: "gcr.io/k8s-testimages/missing",
+							State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ErrImagePull"}},
+						}},
+					},
+				},
+			},
+			expectedState:   prowapi.PendingState,
+			expectedNumPods: 1,
+		},
+		{
+			name: "wait for the backoff before retrying",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "backoff",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3, Backoff: &prowapi.Duration{Duration: time.Hour}},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:    prowapi.PendingState,
+					PodName:  "backoff",
+					Attempts: []prowapi.Attempt{{PodName: "backoff", CompletionTime: metav1.Now()}},
+				},
+			},
+			expectedState:    prowapi.PendingState,
+			expectedNumPods:  0,
+			expectedAttempts: 1,
+		},
+		{
+			name: "succeeded pod after a retry",
+			pj: prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "retried",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					PodSpec:     &v1.PodSpec{Containers: []v1.Container{{Name: "test-name", Env: []v1.EnvVar{}}}},
+					RetryPolicy: &prowapi.RetryPolicy{MaxAttempts: 3},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:    prowapi.PendingState,
+					PodName:  "retried",
+					Attempts: []prowapi.Attempt{{PodName: "retried"}},
+				},
+			},
+			pods: []v1.Pod{
+				{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "retried",
+						Namespace: "pods",
+					},
+					Status: v1.PodStatus{
+						Phase: v1.PodSucceeded,
+					},
+				},
+			},
+			expectedState:       prowapi.SuccessState,
+			expectedNumPods:     1,
+			expectedComplete:    true,
+			expectedReport:      true,
+			expectedURL:         "retried/success",
+			expectedAttempts:    1,
+			expectedDescription: "Job succeeded on attempt 2.",
+		},
 	}
 	for _, tc := range testcases {
 		t.Logf("Running test case %q", tc.name)
@@ -1221,6 +1624,12 @@ func TestSyncPendingJob(t *testing.T) {
 		if actual.Complete() != tc.expectedComplete {
 			t.Errorf("for case %q got wrong completion", tc.name)
 		}
+		if got := len(actual.Status.Attempts); got != tc.expectedAttempts {
+			t.Errorf("for case %q got %d attempts, expected %d", tc.name, got, tc.expectedAttempts)
+		}
+		if tc.expectedDescription != "" && actual.Status.Description != tc.expectedDescription {
+			t.Errorf("for case %q got description %q, expected %q", tc.name, actual.Status.Description, tc.expectedDescription)
+		}
 		if tc.expectedReport && len(reports) != 1 {
 			t.Errorf("for case %q wanted one report but got %d", tc.name, len(reports))
 		}
@@ -1682,3 +2091,188 @@ func TestMaxConcurency(t *testing.T) {
 	}
 
 }
+
+func TestSyncWaitingJobs(t *testing.T) {
+	refs := &prowapi.Refs{
+		Org: "kubernetes", Repo: "kubernetes", BaseRef: "master", BaseSHA: "base",
+		Pulls: []prowapi.Pull{{Number: 1, SHA: "head"}},
+	}
+	otherRefs := &prowapi.Refs{
+		Org: "kubernetes", Repo: "kubernetes", BaseRef: "master", BaseSHA: "base",
+		Pulls: []prowapi.Pull{{Number: 1, SHA: "older-head"}},
+	}
+	now := time.Now()
+	upstream := func(name, job string, state prowapi.ProwJobState, refs *prowapi.Refs, created time.Time) prowapi.ProwJob {
+		pj := prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "prowjobs",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: prowapi.ProwJobSpec{
+				Job:   job,
+				Type:  prowapi.PresubmitJob,
+				Agent: prowapi.KubernetesAgent,
+				Refs:  refs,
+			},
+			Status: prowapi.ProwJobStatus{
+				State: state,
+			},
+		}
+		if state != prowapi.TriggeredState && state != prowapi.PendingState {
+			pj.SetComplete()
+		}
+		return pj
+	}
+
+	tests := []struct {
+		name                string
+		upstream            []prowapi.ProwJob
+		waitingFor          time.Duration
+		expectedState       prowapi.ProwJobState
+		expectedDescription string
+		expectedComplete    bool
+		expectedReport      bool
+	}{
+		{
+			name: "all upstream jobs succeeded, job is triggered",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("test", "test-bazel-test", prowapi.SuccessState, refs, now),
+			},
+			expectedState:       prowapi.TriggeredState,
+			expectedDescription: "Upstream jobs succeeded.",
+		},
+		{
+			name: "upstream job failed, job is aborted",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("test", "test-bazel-test", prowapi.FailureState, refs, now),
+			},
+			expectedState:       prowapi.AbortedState,
+			expectedDescription: "Upstream job test-bazel-test did not succeed.",
+			expectedComplete:    true,
+			expectedReport:      true,
+		},
+		{
+			name: "upstream job still running, job keeps waiting",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("test", "test-bazel-test", prowapi.PendingState, refs, now),
+			},
+			expectedState:       prowapi.WaitingState,
+			expectedDescription: "Waiting for upstream job test-bazel-test.",
+		},
+		{
+			name: "upstream job only ran against other refs, job keeps waiting",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("test", "test-bazel-test", prowapi.SuccessState, otherRefs, now),
+			},
+			expectedState:       prowapi.WaitingState,
+			expectedDescription: "Waiting for upstream job test-bazel-test.",
+		},
+		{
+			name: "upstream job not triggered yet, job keeps waiting",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+			},
+			waitingFor:          time.Hour,
+			expectedState:       prowapi.WaitingState,
+			expectedDescription: "Waiting for upstream job test-bazel-test.",
+		},
+		{
+			name: "upstream job never triggered, job is aborted after the timeout",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+			},
+			waitingFor:          waitingTimeout + time.Minute,
+			expectedState:       prowapi.AbortedState,
+			expectedDescription: "Upstream job test-bazel-test was not triggered within 24h0m0s.",
+			expectedComplete:    true,
+			expectedReport:      true,
+		},
+		{
+			name: "upstream job still running after the timeout, job keeps waiting",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("test", "test-bazel-test", prowapi.PendingState, refs, now),
+			},
+			waitingFor:          waitingTimeout + time.Minute,
+			expectedState:       prowapi.WaitingState,
+			expectedDescription: "Waiting for upstream job test-bazel-test.",
+		},
+		{
+			name: "latest upstream run is used",
+			upstream: []prowapi.ProwJob{
+				upstream("build", "test-bazel-build", prowapi.SuccessState, refs, now),
+				upstream("old-test", "test-bazel-test", prowapi.FailureState, refs, now.Add(-time.Hour)),
+				upstream("test", "test-bazel-test", prowapi.SuccessState, refs, now),
+			},
+			expectedState:       prowapi.TriggeredState,
+			expectedDescription: "Upstream jobs succeeded.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waiting := prowapi.ProwJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "waiting",
+					Namespace: "prowjobs",
+				},
+				Spec: prowapi.ProwJobSpec{
+					Job:       "test-e2e",
+					Type:      prowapi.PresubmitJob,
+					Agent:     prowapi.KubernetesAgent,
+					Refs:      refs,
+					DependsOn: []string{"test-bazel-build", "test-bazel-test"},
+				},
+				Status: prowapi.ProwJobStatus{
+					State:     prowapi.WaitingState,
+					StartTime: metav1.NewTime(now.Add(-test.waitingFor)),
+				},
+			}
+			all := append([]prowapi.ProwJob{waiting}, test.upstream...)
+			var prowJobs []runtime.Object
+			for i := range all {
+				prowJobs = append(prowJobs, &all[i])
+			}
+			fakeProwJobClient := prowfake.NewSimpleClientset(prowJobs...)
+			c := Controller{
+				prowJobClient: fakeProwJobClient.ProwV1().ProwJobs("prowjobs"),
+				log:           logrus.NewEntry(logrus.StandardLogger()),
+				config:        newFakeConfigAgent(t, 0).Config,
+				pendingJobs:   make(map[string]int),
+				clock:         clock.RealClock{},
+			}
+
+			pjs := []prowapi.ProwJob{waiting}
+			reports := make(chan prowapi.ProwJob, len(pjs))
+			if err := c.syncWaitingJobs(all, pjs, reports); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			close(reports)
+
+			actual, err := fakeProwJobClient.ProwV1().ProwJobs("prowjobs").Get("waiting", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get prowjob from client: %v", err)
+			}
+			if actual.Status.State != test.expectedState {
+				t.Errorf("expected state %q, got %q", test.expectedState, actual.Status.State)
+			}
+			if actual.Status.Description != test.expectedDescription {
+				t.Errorf("expected description %q, got %q", test.expectedDescription, actual.Status.Description)
+			}
+			if actual.Complete() != test.expectedComplete {
+				t.Errorf("expected complete: %t, got: %t", test.expectedComplete, actual.Complete())
+			}
+			if pjs[0].Status.State != test.expectedState {
+				t.Errorf("expected in-place state %q, got %q", test.expectedState, pjs[0].Status.State)
+			}
+			if reported := len(reports) > 0; reported != test.expectedReport {
+				t.Errorf("expected report: %t, got: %t", test.expectedReport, reported)
+			}
+		})
+	}
+}
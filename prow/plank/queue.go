@@ -0,0 +1,183 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plank
+
+import (
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+var (
+	queueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "plank_queue_depth",
+		Help: "Number of triggered prowjobs waiting in a job queue.",
+	}, []string{"queue"})
+	queueRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "plank_queue_running",
+		Help: "Number of pending prowjobs started from a job queue.",
+	}, []string{"queue"})
+	queueOldestWait = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "plank_queue_oldest_wait_seconds",
+		Help: "Age of the oldest triggered prowjob waiting in a job queue.",
+	}, []string{"queue"})
+	queueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "plank_queue_wait_seconds",
+		Help:    "Time prowjobs spent in a job queue before being started.",
+		Buckets: []float64{1, 10, 30, 60, 120, 300, 600, 1800, 3600, 7200},
+	}, []string{"queue"})
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth)
+	prometheus.MustRegister(queueRunning)
+	prometheus.MustRegister(queueOldestWait)
+	prometheus.MustRegister(queueWait)
+}
+
+// jobQueue tracks the state of one job queue during scheduling.
+type jobQueue struct {
+	config.JobQueue
+	// running is the number of pending jobs of the queue.
+	running int
+	// admitted is the number of triggered jobs of the queue that
+	// are allowed to start in this sync.
+	admitted int
+	// waiting are the triggered jobs of the queue, oldest first.
+	waiting []prowapi.ProwJob
+}
+
+// share is the fraction of the capacity the queue is using, relative to
+// its weight.
+func (q *jobQueue) share() float64 {
+	return float64(q.running+q.admitted) / float64(q.Weight)
+}
+
+func (q *jobQueue) full() bool {
+	return q.MaxConcurrency > 0 && q.running+q.admitted >= q.MaxConcurrency
+}
+
+// buildQueues sorts the active ProwJobs into their queues.
+func buildQueues(plank config.Plank, pjs []prowapi.ProwJob) map[string]*jobQueue {
+	queues := map[string]*jobQueue{}
+	for _, pj := range pjs {
+		if pj.Status.State != prowapi.PendingState && pj.Status.State != prowapi.TriggeredState {
+			continue
+		}
+		qc := plank.QueueFor(&pj)
+		queue, ok := queues[qc.Name]
+		if !ok {
+			if qc.Weight <= 0 {
+				qc.Weight = 1
+			}
+			queue = &jobQueue{JobQueue: qc}
+			queues[qc.Name] = queue
+		}
+		if pj.Status.State == prowapi.PendingState {
+			queue.running++
+		} else {
+			queue.waiting = append(queue.waiting, pj)
+		}
+	}
+	for _, queue := range queues {
+		sort.SliceStable(queue.waiting, func(i, j int) bool {
+			return queue.waiting[i].CreationTimestamp.Before(&queue.waiting[j].CreationTimestamp)
+		})
+	}
+	return queues
+}
+
+// schedule decides which of the triggered ProwJobs may start in this sync.
+// Free capacity is handed out one job at a time to the queue that uses the
+// smallest share of the capacity relative to its weight, so that a busy
+// queue cannot starve the others. Within a queue jobs start oldest first.
+// pending holds the number of running instances per job, as used for the
+// global and per-job concurrency limits.
+func schedule(queues map[string]*jobQueue, maxConcurrency int, pending map[string]int) sets.String {
+	running := map[string]int{}
+	var total int
+	for job, num := range pending {
+		running[job] = num
+		total += num
+	}
+
+	var names []string
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	admitted := sets.NewString()
+	blocked := sets.NewString()
+	for maxConcurrency == 0 || total < maxConcurrency {
+		var next *jobQueue
+		for _, name := range names {
+			queue := queues[name]
+			if len(queue.waiting) == 0 || queue.full() {
+				continue
+			}
+			if next == nil || queue.share() < next.share() ||
+				(queue.share() == next.share() && queue.waiting[0].CreationTimestamp.Before(&next.waiting[0].CreationTimestamp)) {
+				next = queue
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		pj := next.waiting[0]
+		next.waiting = next.waiting[1:]
+		// Once an instance of a job hits its limit, younger instances
+		// must wait as well.
+		if blocked.Has(pj.Spec.Job) {
+			continue
+		}
+		if pj.Spec.MaxConcurrency > 0 && running[pj.Spec.Job] >= pj.Spec.MaxConcurrency {
+			blocked.Insert(pj.Spec.Job)
+			continue
+		}
+		admitted.Insert(pj.Name)
+		next.admitted++
+		running[pj.Spec.Job]++
+		total++
+	}
+	return admitted
+}
+
+// gatherQueueMetrics records the depth and wait time of the job queues.
+func gatherQueueMetrics(plank config.Plank, pjs []prowapi.ProwJob, now time.Time) {
+	queueDepth.Reset()
+	queueRunning.Reset()
+	queueOldestWait.Reset()
+	if len(plank.JobQueues) == 0 {
+		return
+	}
+	for name, queue := range buildQueues(plank, pjs) {
+		queueDepth.WithLabelValues(name).Set(float64(len(queue.waiting)))
+		queueRunning.WithLabelValues(name).Set(float64(queue.running))
+		var oldest float64
+		if len(queue.waiting) > 0 {
+			oldest = now.Sub(queue.waiting[0].CreationTimestamp.Time).Seconds()
+		}
+		queueOldestWait.WithLabelValues(name).Set(oldest)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plank
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+func TestSchedule(t *testing.T) {
+	start := time.Now()
+	// newJob creates a ProwJob for the org, with i ordering creation times.
+	newJob := func(name, job, org string, i int, state prowapi.ProwJobState, max int) prowapi.ProwJob {
+		return prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(start.Add(time.Duration(i) * time.Second)),
+			},
+			Spec: prowapi.ProwJobSpec{
+				Job:            job,
+				MaxConcurrency: max,
+				Refs:           &prowapi.Refs{Org: org, Repo: "repo"},
+			},
+			Status: prowapi.ProwJobStatus{State: state},
+		}
+	}
+	queues := []config.JobQueue{
+		{Name: "busy", Orgs: []string{"busy"}, Weight: 1},
+		{Name: "quiet", Orgs: []string{"quiet"}, Weight: 1},
+	}
+
+	testCases := []struct {
+		name           string
+		queues         []config.JobQueue
+		maxConcurrency int
+		pjs            []prowapi.ProwJob
+		pending        map[string]int
+		expected       sets.String
+	}{
+		{
+			name:           "quiet queue is not starved by older jobs of a busy queue",
+			queues:         queues,
+			maxConcurrency: 2,
+			pjs: []prowapi.ProwJob{
+				newJob("busy-1", "b", "busy", 1, prowapi.TriggeredState, 0),
+				newJob("busy-2", "b", "busy", 2, prowapi.TriggeredState, 0),
+				newJob("busy-3", "b", "busy", 3, prowapi.TriggeredState, 0),
+				newJob("quiet-1", "q", "quiet", 4, prowapi.TriggeredState, 0),
+			},
+			expected: sets.NewString("busy-1", "quiet-1"),
+		},
+		{
+			name:           "running jobs count against the share of their queue",
+			queues:         queues,
+			maxConcurrency: 2,
+			pjs: []prowapi.ProwJob{
+				newJob("busy-running", "b", "busy", 0, prowapi.PendingState, 0),
+				newJob("busy-1", "b", "busy", 1, prowapi.TriggeredState, 0),
+				newJob("quiet-1", "q", "quiet", 2, prowapi.TriggeredState, 0),
+			},
+			pending:  map[string]int{"b": 1},
+			expected: sets.NewString("quiet-1"),
+		},
+		{
+			name: "weights decide the share of each queue",
+			queues: []config.JobQueue{
+				{Name: "busy", Orgs: []string{"busy"}, Weight: 3},
+				{Name: "quiet", Orgs: []string{"quiet"}, Weight: 1},
+			},
+			maxConcurrency: 4,
+			pjs: []prowapi.ProwJob{
+				newJob("quiet-1", "q", "quiet", 1, prowapi.TriggeredState, 0),
+				newJob("quiet-2", "q", "quiet", 2, prowapi.TriggeredState, 0),
+				newJob("busy-1", "b", "busy", 3, prowapi.TriggeredState, 0),
+				newJob("busy-2", "b", "busy", 4, prowapi.TriggeredState, 0),
+				newJob("busy-3", "b", "busy", 5, prowapi.TriggeredState, 0),
+				newJob("busy-4", "b", "busy", 6, prowapi.TriggeredState, 0),
+			},
+			expected: sets.NewString("quiet-1", "busy-1", "busy-2", "busy-3"),
+		},
+		{
+			name: "queue quota is enforced without a global limit",
+			queues: []config.JobQueue{
+				{Name: "busy", Orgs: []string{"busy"}, Weight: 1, MaxConcurrency: 2},
+			},
+			pjs: []prowapi.ProwJob{
+				newJob("busy-running", "b", "busy", 0, prowapi.PendingState, 0),
+				newJob("busy-1", "b", "busy", 1, prowapi.TriggeredState, 0),
+				newJob("busy-2", "b", "busy", 2, prowapi.TriggeredState, 0),
+				newJob("other-1", "o", "other", 3, prowapi.TriggeredState, 0),
+			},
+			pending:  map[string]int{"b": 1},
+			expected: sets.NewString("busy-1", "other-1"),
+		},
+		{
+			name:   "per-job max concurrency blocks younger instances and frees the slot",
+			queues: queues,
+			pjs: []prowapi.ProwJob{
+				newJob("busy-1", "limited", "busy", 1, prowapi.TriggeredState, 1),
+				newJob("busy-2", "limited", "busy", 2, prowapi.TriggeredState, 1),
+				newJob("busy-3", "other", "busy", 3, prowapi.TriggeredState, 0),
+			},
+			expected: sets.NewString("busy-1", "busy-3"),
+		},
+		{
+			name:           "no free capacity",
+			queues:         queues,
+			maxConcurrency: 1,
+			pjs: []prowapi.ProwJob{
+				newJob("busy-running", "b", "busy", 0, prowapi.PendingState, 0),
+				newJob("quiet-1", "q", "quiet", 1, prowapi.TriggeredState, 0),
+			},
+			pending:  map[string]int{"b": 1},
+			expected: sets.NewString(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			plank := config.Plank{JobQueues: tc.queues}
+			pending := tc.pending
+			if pending == nil {
+				pending = map[string]int{}
+			}
+			admitted := schedule(buildQueues(plank, tc.pjs), tc.maxConcurrency, pending)
+			if !admitted.Equal(tc.expected) {
+				t.Errorf("expected %v to be admitted, got %v", tc.expected.List(), admitted.List())
+			}
+		})
+	}
+}
@@ -364,6 +364,12 @@ type Trigger struct {
 	// that could run but do not run. Defaults to true.
 	// THIS FIELD IS DEPRECATED AND WILL BE REMOVED AFTER OCTOBER 2019.
 	ElideSkippedContexts *bool `json:"elide_skipped_contexts,omitempty"`
+	// AbortOnPRStateChange makes trigger abort the presubmits of a PR that
+	// are still running when the PR is closed, converted to a draft or
+	// becomes untrusted because the ok-to-test label was removed. The pods
+	// of aborted jobs are only deleted when plank.allow_cancellations is
+	// set, otherwise they run to completion but are reported as aborted.
+	AbortOnPRStateChange bool `json:"abort_on_pr_state_change,omitempty"`
 }
 
 // Heart contains the configuration for the heart plugin.
@@ -21,9 +21,13 @@ import (
 	"fmt"
 	"net/url"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/errorutil"
 	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/labels"
 	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/plugins"
@@ -34,6 +38,30 @@ func handlePR(c Client, trigger plugins.Trigger, pr github.PullRequestEvent) err
 	author := string(a)
 	num := pr.PullRequest.Number
 
+	// Jobs for PRs that are closed or no longer meant to be tested do not
+	// need to run any longer.
+	switch pr.Action {
+	case github.PullRequestActionClosed:
+		if pr.PullRequest.Merged {
+			return abortAllJobs(c, trigger, &pr.PullRequest, "Aborted because the pull request was merged.")
+		}
+		return abortAllJobs(c, trigger, &pr.PullRequest, "Aborted because the pull request was closed.")
+	case github.PullRequestActionConvertedToDraft:
+		return abortAllJobs(c, trigger, &pr.PullRequest, "Aborted because the pull request was converted to a draft.")
+	case github.PullRequestActionUnlabeled:
+		if pr.Label.Name != labels.OkToTest {
+			return nil
+		}
+		_, trusted, err := TrustedPullRequest(c.GitHubClient, trigger, author, org, repo, num, nil)
+		if err != nil {
+			return fmt.Errorf("could not validate PR: %s", err)
+		}
+		if trusted {
+			return nil
+		}
+		return abortAllJobs(c, trigger, &pr.PullRequest, fmt.Sprintf("Aborted because the %s label was removed.", labels.OkToTest))
+	}
+
 	baseSHA := ""
 	baseSHAGetter := func() (string, error) {
 		var err error
@@ -148,6 +176,34 @@ func handlePR(c Client, trigger plugins.Trigger, pr github.PullRequestEvent) err
 	return nil
 }
 
+// abortAllJobs aborts all presubmits of the PR that are not complete yet if
+// the trigger config of the repo asks for it, and logs the jobs it leaves
+// running otherwise.
+func abortAllJobs(c Client, trigger plugins.Trigger, pr *github.PullRequest, description string) error {
+	org, repo, _ := orgRepoAuthor(*pr)
+	selector := fmt.Sprintf("%s=%s,%s=%s,%s=%d,%s=%s", kube.OrgLabel, org, kube.RepoLabel, repo, kube.PullLabel, pr.Number, kube.ProwJobTypeLabel, prowapi.PresubmitJob)
+	pjs, err := c.ProwJobClient.List(metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return fmt.Errorf("failed to list prowjobs for PR: %v", err)
+	}
+	var toAbort []prowapi.ProwJob
+	for _, pj := range pjs.Items {
+		if pj.Complete() || pj.Spec.Refs == nil || len(pj.Spec.Refs.Pulls) == 0 || pj.Spec.Refs.Pulls[0].Number != pr.Number {
+			continue
+		}
+		toAbort = append(toAbort, pj)
+	}
+	if len(toAbort) == 0 {
+		return nil
+	}
+	if !trigger.AbortOnPRStateChange {
+		c.Logger.WithField("jobs", len(toAbort)).Infof("Not aborting the running presubmits as abort_on_pr_state_change is not set: %s", description)
+		return nil
+	}
+	c.Logger.WithField("jobs", len(toAbort)).Info(description)
+	return pjutil.AbortJobs(c.ProwJobClient, c.Logger, toAbort, description)
+}
+
 type login string
 
 func orgRepoAuthor(pr github.PullRequest) (string, string, login) {
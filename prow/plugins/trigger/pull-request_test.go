@@ -18,15 +18,22 @@ package trigger
 
 import (
 	"encoding/json"
+	"strconv"
 	"testing"
 
 	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clienttesting "k8s.io/client-go/testing"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/client/clientset/versioned/fake"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/git"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/github/fakegithub"
+	"k8s.io/test-infra/prow/github/reporter"
+	"k8s.io/test-infra/prow/kube"
 	"k8s.io/test-infra/prow/labels"
 	"k8s.io/test-infra/prow/plugins"
 )
@@ -376,3 +383,160 @@ func TestHandlePullRequest(t *testing.T) {
 		}
 	}
 }
+
+func TestHandlePullRequestAborts(t *testing.T) {
+	newJob := func(name string, jobType prowapi.ProwJobType, state prowapi.ProwJobState, pull int) *prowapi.ProwJob {
+		pj := &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "namespace",
+				Labels: map[string]string{
+					kube.OrgLabel:         "org",
+					kube.RepoLabel:        "repo",
+					kube.PullLabel:        strconv.Itoa(pull),
+					kube.ProwJobTypeLabel: string(jobType),
+				},
+			},
+			Spec: prowapi.ProwJobSpec{
+				Type: jobType,
+				Refs: &prowapi.Refs{Org: "org", Repo: "repo", Pulls: []prowapi.Pull{{Number: pull}}},
+			},
+			Status: prowapi.ProwJobStatus{State: state},
+		}
+		if state != prowapi.TriggeredState && state != prowapi.PendingState {
+			pj.SetComplete()
+		}
+		return pj
+	}
+
+	var testcases = []struct {
+		name        string
+		author      string
+		action      github.PullRequestEventAction
+		label       string
+		merged      bool
+		noAbort     bool
+		aborted     sets.String
+		description string
+	}{
+		{
+			name:        "closing the PR aborts its running presubmits",
+			author:      "t",
+			action:      github.PullRequestActionClosed,
+			aborted:     sets.NewString("triggered", "pending"),
+			description: "Aborted because the pull request was closed.",
+		},
+		{
+			name:        "merging the PR aborts its running presubmits",
+			author:      "t",
+			action:      github.PullRequestActionClosed,
+			merged:      true,
+			aborted:     sets.NewString("triggered", "pending"),
+			description: "Aborted because the pull request was merged.",
+		},
+		{
+			name:        "converting the PR to a draft aborts its running presubmits",
+			author:      "t",
+			action:      github.PullRequestActionConvertedToDraft,
+			aborted:     sets.NewString("triggered", "pending"),
+			description: "Aborted because the pull request was converted to a draft.",
+		},
+		{
+			name:        "removing ok-to-test from an untrusted PR aborts its running presubmits",
+			author:      "u",
+			action:      github.PullRequestActionUnlabeled,
+			label:       labels.OkToTest,
+			aborted:     sets.NewString("triggered", "pending"),
+			description: "Aborted because the ok-to-test label was removed.",
+		},
+		{
+			name:    "removing ok-to-test from a trusted PR aborts nothing",
+			author:  "t",
+			action:  github.PullRequestActionUnlabeled,
+			label:   labels.OkToTest,
+			aborted: sets.NewString(),
+		},
+		{
+			name:    "closing the PR aborts nothing without abort_on_pr_state_change",
+			author:  "t",
+			action:  github.PullRequestActionClosed,
+			noAbort: true,
+			aborted: sets.NewString(),
+		},
+		{
+			name:    "removing another label aborts nothing",
+			author:  "u",
+			action:  github.PullRequestActionUnlabeled,
+			label:   "lgtm",
+			aborted: sets.NewString(),
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &fakegithub.FakeClient{
+				IssueComments: map[int][]github.IssueComment{},
+				OrgMembers:    map[string][]string{"org": {"t"}},
+			}
+			fakeProwJobClient := fake.NewSimpleClientset(
+				newJob("triggered", prowapi.PresubmitJob, prowapi.TriggeredState, 1),
+				newJob("pending", prowapi.PresubmitJob, prowapi.PendingState, 1),
+				newJob("complete", prowapi.PresubmitJob, prowapi.SuccessState, 1),
+				newJob("other-pr", prowapi.PresubmitJob, prowapi.PendingState, 2),
+				newJob("batch", prowapi.BatchJob, prowapi.PendingState, 1),
+			)
+			c := Client{
+				GitHubClient:  g,
+				ProwJobClient: fakeProwJobClient.ProwV1().ProwJobs("namespace"),
+				Config:        &config.Config{},
+				Logger:        logrus.WithField("plugin", PluginName),
+				GitClient:     &git.Client{},
+			}
+			pr := github.PullRequestEvent{
+				Action: tc.action,
+				Label:  github.Label{Name: tc.label},
+				PullRequest: github.PullRequest{
+					Number: 1,
+					Merged: tc.merged,
+					User:   github.User{Login: tc.author},
+					Base: github.PullRequestBranch{
+						Ref: "master",
+						Repo: github.Repo{
+							Owner:    github.User{Login: "org"},
+							Name:     "repo",
+							FullName: "org/repo",
+						},
+					},
+				},
+			}
+			trigger := plugins.Trigger{TrustedOrg: "org", OnlyOrgMembers: true, AbortOnPRStateChange: !tc.noAbort}
+			trigger.SetDefaults()
+			if err := handlePR(c, trigger, pr); err != nil {
+				t.Fatalf("Didn't expect error: %s", err)
+			}
+
+			pjs, err := fakeProwJobClient.ProwV1().ProwJobs("namespace").List(metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed to list prowjobs: %v", err)
+			}
+			aborted := sets.NewString()
+			for _, pj := range pjs.Items {
+				if pj.Status.State != prowapi.AbortedState {
+					continue
+				}
+				aborted.Insert(pj.Name)
+				if pj.Status.Description != tc.description {
+					t.Errorf("expected description %q for %s, got %q", tc.description, pj.Name, pj.Status.Description)
+				}
+				if pj.Status.CompletionTime == nil {
+					t.Errorf("expected %s to be complete", pj.Name)
+				}
+				if _, reported := pj.Status.PrevReportStates[reporter.GitHubReporterName]; reported {
+					t.Errorf("expected the aborted state of %s to be reported to GitHub", pj.Name)
+				}
+			}
+			if !aborted.Equal(tc.aborted) {
+				t.Errorf("expected %v to be aborted, got %v", tc.aborted.List(), aborted.List())
+			}
+		})
+	}
+}
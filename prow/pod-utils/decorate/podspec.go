@@ -476,7 +476,11 @@ func GCSOptions(dc prowapi.DecorationConfig, localMode bool) (*coreapi.Volume, *
 		Name:      vol.Name,
 		MountPath: gcsCredentialsMountPath,
 	}
-	opt.GcsCredentialsFile = fmt.Sprintf("%s/service-account.json", mount.MountPath)
+	if dc.GCSConfiguration != nil && dc.GCSConfiguration.StorageProvider() == prowapi.StorageProviderS3 {
+		opt.S3CredentialsFile = fmt.Sprintf("%s/s3-credentials.json", mount.MountPath)
+	} else {
+		opt.GcsCredentialsFile = fmt.Sprintf("%s/service-account.json", mount.MountPath)
+	}
 
 	return vol, mount, opt
 }
@@ -1879,3 +1879,39 @@ func TestProwJobToPod(t *testing.T) {
 		})
 	}
 }
+
+func TestGCSOptionsCredentials(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		bucket      string
+		expectedGCS string
+		expectedS3  string
+	}{
+		{
+			name:        "gcs bucket uses the service account",
+			bucket:      "my-bucket",
+			expectedGCS: "/secrets/gcs/service-account.json",
+		},
+		{
+			name:       "s3 bucket uses the s3 credentials",
+			bucket:     "s3://my-bucket",
+			expectedS3: "/secrets/gcs/s3-credentials.json",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := prowapi.DecorationConfig{
+				GCSConfiguration:     &prowapi.GCSConfiguration{Bucket: tc.bucket},
+				GCSCredentialsSecret: "secret-name",
+			}
+			_, _, opt := GCSOptions(dc, false)
+			if opt.GcsCredentialsFile != tc.expectedGCS {
+				t.Errorf("expected gcs credentials file %q, got %q", tc.expectedGCS, opt.GcsCredentialsFile)
+			}
+			if opt.S3CredentialsFile != tc.expectedS3 {
+				t.Errorf("expected s3 credentials file %q, got %q", tc.expectedS3, opt.S3CredentialsFile)
+			}
+		})
+	}
+}
@@ -22,11 +22,13 @@ import (
 	"io"
 	"os"
 	"path"
+	"strings"
 	"sync"
 
 	"cloud.google.com/go/storage"
 	"github.com/sirupsen/logrus"
 
+	pkgio "k8s.io/test-infra/pkg/io"
 	"k8s.io/test-infra/prow/errorutil"
 )
 
@@ -55,6 +57,20 @@ func LocalExport(exportDir string, uploadTargets map[string]UploadFunc) error {
 	return upload(dtw, uploadTargets)
 }
 
+// StorageUpload uploads all of the data in the uploadTargets map in
+// parallel through the opener, so any storage provider the opener
+// supports can be used. The map is keyed on path under bucketURL,
+// for example s3://bucket.
+func StorageUpload(opener pkgio.Opener, bucketURL string, uploadTargets map[string]UploadFunc) error {
+	dtw := func(dest string) dataWriter {
+		return &openerObjectWriter{
+			opener: opener,
+			path:   strings.TrimSuffix(bucketURL, "/") + "/" + dest,
+		}
+	}
+	return upload(dtw, uploadTargets)
+}
+
 func upload(dtw destToWriter, uploadTargets map[string]UploadFunc) error {
 	errCh := make(chan error, len(uploadTargets))
 	group := &sync.WaitGroup{}
@@ -190,3 +206,39 @@ func (w *localFileWriter) Close() error {
 
 // Ignore attributes when copying files locally.
 func (w *localFileWriter) ApplyAttributes(_ *storage.ObjectAttrs) {}
+
+type openerObjectWriter struct {
+	opener pkgio.Opener
+	path   string
+	writer io.WriteCloser
+}
+
+func (w *openerObjectWriter) open() error {
+	if w.writer != nil {
+		return nil
+	}
+	writer, err := w.opener.Writer(context.Background(), w.path)
+	if err != nil {
+		return fmt.Errorf("error opening %q for writing: %v", w.path, err)
+	}
+	w.writer = writer
+	return nil
+}
+
+func (w *openerObjectWriter) Write(b []byte) (int, error) {
+	if err := w.open(); err != nil {
+		return 0, err
+	}
+	return w.writer.Write(b)
+}
+
+func (w *openerObjectWriter) Close() error {
+	// Empty uploads still need to create the object.
+	if err := w.open(); err != nil {
+		return err
+	}
+	return w.writer.Close()
+}
+
+// Attributes are specific to GCS and are not supported by other providers.
+func (w *openerObjectWriter) ApplyAttributes(_ *storage.ObjectAttrs) {}
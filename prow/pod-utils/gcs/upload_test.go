@@ -17,8 +17,12 @@ limitations under the License.
 package gcs
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"testing"
 
@@ -100,3 +104,54 @@ func TestUploadToGcs(t *testing.T) {
 		}
 	}
 }
+
+type fakeOpener struct {
+	lock    sync.Mutex
+	written map[string]*bytes.Buffer
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (o *fakeOpener) Reader(_ context.Context, path string) (io.ReadCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (o *fakeOpener) Writer(_ context.Context, path string) (io.WriteCloser, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	buf := &bytes.Buffer{}
+	o.written[path] = buf
+	return nopWriteCloser{buf}, nil
+}
+
+func TestStorageUpload(t *testing.T) {
+	opener := &fakeOpener{written: map[string]*bytes.Buffer{}}
+	targets := map[string]UploadFunc{
+		"logs/build-log.txt": DataUpload(strings.NewReader("hello")),
+		"logs/empty.txt":     DataUpload(strings.NewReader("")),
+	}
+	if err := StorageUpload(opener, "s3://bucket/", targets); err != nil {
+		t.Fatalf("expected no error but got %v", err)
+	}
+	expected := map[string]string{
+		"s3://bucket/logs/build-log.txt": "hello",
+		"s3://bucket/logs/empty.txt":     "",
+	}
+	if len(opener.written) != len(expected) {
+		t.Errorf("expected %d objects to be written, got %d", len(expected), len(opener.written))
+	}
+	for path, content := range expected {
+		buf, ok := opener.written[path]
+		if !ok {
+			t.Errorf("expected %s to be written", path)
+			continue
+		}
+		if buf.String() != content {
+			t.Errorf("expected %s to contain %q, got %q", path, content, buf.String())
+		}
+	}
+}
@@ -102,11 +102,26 @@ func (o Options) Run(ctx context.Context) (int, error) {
 
 	buildLog := logReader(entries)
 	metadata := combineMetadata(entries)
-	return failures, o.doUpload(spec, passed, aborted, metadata, buildLog)
+	if err := o.doUpload(spec, passed, aborted, metadata, buildLog); err != nil {
+		// The results of the job are lost, so let the prowjob controller
+		// know that this failure is not the fault of the test.
+		if err := ioutil.WriteFile(terminationLog, []byte(InfraFailureMessage), 0644); err != nil {
+			logrus.WithError(err).Warn("Could not write termination message")
+		}
+		return failures, err
+	}
+	return failures, nil
 }
 
 const errorKey = "sidecar-errors"
 
+// InfraFailureMessage is written as the termination message of the
+// sidecar container when the results of the job could not be uploaded.
+const InfraFailureMessage = "sidecar: failed to upload job results"
+
+// terminationLog is where the kubelet reads the termination message from.
+var terminationLog = "/dev/termination-log"
+
 func start(part string) string {
 	return fmt.Sprintf("\n==== start of %s log ====\n", part)
 }
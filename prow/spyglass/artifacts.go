@@ -22,6 +22,9 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/pjutil"
 	"k8s.io/test-infra/prow/spyglass/lenses"
 )
 
@@ -31,19 +34,23 @@ func (s *Spyglass) ListArtifacts(src string) ([]string, error) {
 	if err != nil {
 		return []string{}, fmt.Errorf("error parsing src: %v", err)
 	}
-	gcsKey := ""
-	switch keyType {
-	case gcsKeyType:
-		gcsKey = key
-	case prowKeyType:
-		if gcsKey, err = s.prowToGCS(key); err != nil {
-			logrus.Warningf("Failed to get gcs source for prow job: %v", err)
+	storageKeyType, storageKey := keyType, key
+	switch {
+	case isStorageKeyType(keyType):
+	case keyType == prowKeyType:
+		if storageKeyType, storageKey, err = s.prowToStorage(key); err != nil {
+			logrus.Warningf("Failed to get storage source for prow job: %v", err)
 		}
 	default:
 		return nil, fmt.Errorf("Unrecognized key type for src: %v", src)
 	}
 
-	artifactNames, err := s.GCSArtifactFetcher.artifacts(gcsKey)
+	var artifactNames []string
+	if storageKeyType == gcsKeyType {
+		artifactNames, err = s.GCSArtifactFetcher.artifacts(storageKey)
+	} else {
+		artifactNames, err = s.StorageArtifactFetcher.artifacts(storageKeyType, storageKey)
+	}
 	logFound := false
 	for _, name := range artifactNames {
 		if name == "build-log.txt" {
@@ -69,24 +76,33 @@ func (*Spyglass) KeyToJob(src string) (jobName string, buildID string, err error
 	return jobName, buildID, nil
 }
 
-// prowToGCS returns the GCS key corresponding to the given prow key
-func (s *Spyglass) prowToGCS(prowKey string) (string, error) {
+// prowToStorage returns the storage key type and key corresponding to the given prow key
+func (s *Spyglass) prowToStorage(prowKey string) (string, string, error) {
 	jobName, buildID, err := s.KeyToJob(prowKey)
 	if err != nil {
-		return "", fmt.Errorf("could not get GCS src: %v", err)
+		return "", "", fmt.Errorf("could not get storage src: %v", err)
 	}
 
 	job, err := s.jobAgent.GetProwJob(jobName, buildID)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get prow job from src %q: %v", prowKey, err)
+		return "", "", fmt.Errorf("Failed to get prow job from src %q: %v", prowKey, err)
+	}
+
+	provider := prowapi.StorageProviderGCS
+	if job.Spec.DecorationConfig != nil && job.Spec.DecorationConfig.GCSConfiguration != nil {
+		provider = job.Spec.DecorationConfig.GCSConfiguration.StorageProvider()
+	}
+	keyType, ok := storageKeyTypes[provider]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported storage provider %q for prow job %q", provider, prowKey)
 	}
 
 	url := job.Status.URL
-	prefix := s.config().Plank.GetJobURLPrefix(job.Spec.Refs)
+	prefix := pjutil.JobURLPrefix(s.config().Plank, job.Spec.Refs, provider)
 	if !strings.HasPrefix(url, prefix) {
-		return "", fmt.Errorf("unexpected job URL %q when finding GCS path: expected something starting with %q", url, prefix)
+		return "", "", fmt.Errorf("unexpected job URL %q when finding storage path: expected something starting with %q", url, prefix)
 	}
-	return url[len(prefix):], nil
+	return keyType, url[len(prefix):], nil
 }
 
 // FetchArtifacts constructs and returns Artifact objects for each artifact name in the list.
@@ -102,12 +118,12 @@ func (s *Spyglass) FetchArtifacts(src string, podName string, sizeLimit int64, a
 	if err != nil {
 		return arts, fmt.Errorf("could not derive job: %v", err)
 	}
-	gcsKey := ""
-	switch keyType {
-	case gcsKeyType:
-		gcsKey = strings.TrimSuffix(key, "/")
-	case prowKeyType:
-		if gcsKey, err = s.prowToGCS(key); err != nil {
+	storageKeyType, storageKey := keyType, ""
+	switch {
+	case isStorageKeyType(keyType):
+		storageKey = strings.TrimSuffix(key, "/")
+	case keyType == prowKeyType:
+		if storageKeyType, storageKey, err = s.prowToStorage(key); err != nil {
 			logrus.Warningln(err)
 		}
 	default:
@@ -116,9 +132,14 @@ func (s *Spyglass) FetchArtifacts(src string, podName string, sizeLimit int64, a
 
 	podLogNeeded := false
 	for _, name := range artifactNames {
-		art, err := s.GCSArtifactFetcher.artifact(gcsKey, name, sizeLimit)
+		var art lenses.Artifact
+		if storageKeyType == gcsKeyType {
+			art, err = s.GCSArtifactFetcher.artifact(storageKey, name, sizeLimit)
+		} else {
+			art, err = s.StorageArtifactFetcher.artifact(storageKeyType, storageKey, name, sizeLimit)
+		}
 		if err == nil {
-			// Actually try making a request, because calling GCSArtifactFetcher.artifact does no I/O.
+			// Actually try making a request, because fetching the artifact does no I/O.
 			// (these files are being explicitly requested and so will presumably soon be accessed, so
 			// the extra network I/O should not be too problematic).
 			_, err = art.Size()
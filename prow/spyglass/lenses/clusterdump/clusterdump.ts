@@ -0,0 +1,66 @@
+interface Filter {
+  namespace?: string;
+  from?: string;
+  to?: string;
+}
+
+// The datetime-local inputs are in UTC.
+function inputTime(id: string): string | undefined {
+  const value = (document.getElementById(id) as HTMLInputElement).value;
+  return value ? `${value}:00Z` : undefined;
+}
+
+function currentFilter(): Filter {
+  const namespace = (document.getElementById('namespace') as HTMLSelectElement).value;
+  return {namespace: namespace || undefined, from: inputTime('from'), to: inputTime('to')};
+}
+
+function applyFilter(filter: Filter): void {
+  spyglass.updatePage(JSON.stringify(filter));
+}
+
+async function showLog(link: HTMLAnchorElement): Promise<void> {
+  const pre = link.parentElement!.querySelector<HTMLPreElement>('pre.log-lines')!;
+  if (!pre.classList.contains('hidden')) {
+    pre.classList.add('hidden');
+    spyglass.contentUpdated();
+    return;
+  }
+  const filter = currentFilter();
+  pre.textContent = 'Loading...';
+  pre.classList.remove('hidden');
+  spyglass.contentUpdated();
+  const content = await spyglass.request(JSON.stringify({
+    artifact: link.dataset.artifact,
+    from: filter.from,
+    to: filter.to,
+  }));
+  pre.textContent = content || 'No lines in the selected time window.';
+  spyglass.contentUpdated();
+}
+
+function loaded(): void {
+  const form = document.getElementById('filter') as HTMLFormElement;
+  form.onsubmit = (e) => {
+    e.preventDefault();
+    applyFilter(currentFilter());
+  };
+  document.getElementById('clear')!.onclick = () => applyFilter({});
+
+  for (const link of Array.from(document.querySelectorAll<HTMLAnchorElement>('a.window'))) {
+    link.onclick = (e) => {
+      e.preventDefault();
+      (document.getElementById('from') as HTMLInputElement).value = link.dataset.from!;
+      (document.getElementById('to') as HTMLInputElement).value = link.dataset.to!;
+      applyFilter(currentFilter());
+    };
+  }
+  for (const link of Array.from(document.querySelectorAll<HTMLAnchorElement>('a.show-log'))) {
+    link.onclick = (e) => {
+      e.preventDefault();
+      showLog(link);
+    };
+  }
+}
+
+window.addEventListener('DOMContentLoaded', loaded);
@@ -0,0 +1,639 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clusterdump provides a Spyglass lens for the dumps of Kubernetes
+// clusters that e2e jobs upload: the logs of the nodes and the output of
+// kubectl get for pods and events. It correlates the failures of the junit
+// tests of the job with the events around the time they failed.
+package clusterdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+const (
+	name     = "clusterdump"
+	title    = "Cluster Dump"
+	priority = 3
+
+	// defaultCorrelationWindow is how long before and after the failure of a
+	// test its events are correlated with it if the config does not say.
+	defaultCorrelationWindow = time.Minute
+	// defaultLogLines is the number of lines of a node log shown at most if
+	// the config does not say.
+	defaultLogLines = 1000
+	// maxEvents is the number of events listed at most.
+	maxEvents = 1000
+	// maxCorrelatedEvents is the number of events listed at most for a
+	// failed test.
+	maxCorrelatedEvents = 50
+
+	// inputTimeFormat is the format of the values of datetime-local inputs.
+	inputTimeFormat   = "2006-01-02T15:04"
+	displayTimeFormat = "2006-01-02 15:04:05"
+)
+
+func init() {
+	lenses.RegisterLens(Lens{})
+}
+
+// Lens is the implementation of a cluster dump-rendering Spyglass lens.
+type Lens struct{}
+
+// Config returns the lens's configuration.
+func (lens Lens) Config() lenses.LensConfig {
+	return lenses.LensConfig{
+		Name:     name,
+		Title:    title,
+		Priority: priority,
+	}
+}
+
+// Header renders the content of <head> from template.html.
+func (lens Lens) Header(artifacts []lenses.Artifact, resourceDir string, config json.RawMessage) string {
+	return executeTemplate(resourceDir, "header", nil)
+}
+
+// config is the lens-specific configuration.
+type config struct {
+	// CorrelationWindow is how long before and after the failure of a test
+	// events are correlated with it, like "2m". Defaults to a minute.
+	CorrelationWindow string `json:"correlation_window,omitempty"`
+	// LogLines is the number of lines of a node log shown at most, the last
+	// ones of the selected time window. Defaults to 1000.
+	LogLines int `json:"log_lines,omitempty"`
+}
+
+type parsedConfig struct {
+	correlationWindow time.Duration
+	logLines          int
+}
+
+func parseConfig(raw json.RawMessage) (parsedConfig, error) {
+	conf := parsedConfig{
+		correlationWindow: defaultCorrelationWindow,
+		logLines:          defaultLogLines,
+	}
+	if len(raw) == 0 {
+		return conf, nil
+	}
+	var c config
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return conf, err
+	}
+	if c.CorrelationWindow != "" {
+		window, err := time.ParseDuration(c.CorrelationWindow)
+		if err != nil {
+			return conf, fmt.Errorf("invalid correlation_window: %v", err)
+		}
+		conf.correlationWindow = window
+	}
+	if c.LogLines > 0 {
+		conf.logLines = c.LogLines
+	}
+	return conf, nil
+}
+
+// filter narrows the dump down to the pods and events of a namespace, and to
+// the events and node log lines of a time window.
+type filter struct {
+	Namespace string    `json:"namespace,omitempty"`
+	From      time.Time `json:"from,omitempty"`
+	To        time.Time `json:"to,omitempty"`
+}
+
+// contains returns whether the time is in the window of the filter. Unknown
+// times are only in an unbounded window.
+func (f filter) contains(t time.Time) bool {
+	if t.IsZero() {
+		return f.From.IsZero() && f.To.IsZero()
+	}
+	return (f.From.IsZero() || !t.Before(f.From)) && (f.To.IsZero() || !t.After(f.To))
+}
+
+// Pod is a pod of the dump.
+type Pod struct {
+	Namespace string
+	Name      string
+	Node      string
+	Phase     string
+	Ready     string
+	Restarts  int32
+	Started   time.Time
+}
+
+// Event is an event of the dump.
+type Event struct {
+	Namespace string
+	Time      time.Time
+	Type      string
+	Reason    string
+	Object    string
+	Message   string
+	Count     int32
+}
+
+// NodeLog is a log of a node of the dump.
+type NodeLog struct {
+	Node     string
+	Name     string
+	Artifact string
+	Link     string
+}
+
+// FailedTest is a failed junit test with the events around the time it
+// failed.
+type FailedTest struct {
+	Name string
+	// From and To are the correlation window around the times in the
+	// failure and output of the test, zero if it has none.
+	From, To time.Time
+	Events   []Event
+}
+
+// dump is the index of the artifacts of a cluster dump.
+type dump struct {
+	pods     []Pod
+	events   []Event
+	nodeLogs []NodeLog
+	failures []junit.Result
+}
+
+// index reads the artifacts into a dump. Junit files are read for their
+// failed tests, YAML and JSON files for the pods and events they list, and
+// everything else is a node log named after its directory.
+func index(artifacts []lenses.Artifact) *dump {
+	d := &dump{}
+	for _, artifact := range artifacts {
+		jobPath := artifact.JobPath()
+		base := path.Base(jobPath)
+		switch ext := path.Ext(base); {
+		case strings.HasPrefix(base, "junit") && ext == ".xml":
+			contents, err := artifact.ReadAll()
+			if err != nil {
+				logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Warn("Error reading artifact")
+				continue
+			}
+			suites, err := junit.Parse(contents)
+			if err != nil {
+				logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Info("Error parsing junit file.")
+				continue
+			}
+			for _, suite := range suites.Suites {
+				d.failures = append(d.failures, failures(suite)...)
+			}
+		case ext == ".yaml" || ext == ".yml" || ext == ".json":
+			contents, err := artifact.ReadAll()
+			if err != nil {
+				logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Warn("Error reading artifact")
+				continue
+			}
+			if err := d.addObjects(contents); err != nil {
+				logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Debug("Error parsing objects.")
+			}
+		default:
+			node := path.Base(path.Dir(jobPath))
+			if node == "." || node == "artifacts" {
+				node = ""
+			}
+			d.nodeLogs = append(d.nodeLogs, NodeLog{
+				Node:     node,
+				Name:     base,
+				Artifact: jobPath,
+				Link:     artifact.CanonicalLink(),
+			})
+		}
+	}
+	sort.SliceStable(d.pods, func(i, j int) bool {
+		if d.pods[i].Namespace != d.pods[j].Namespace {
+			return d.pods[i].Namespace < d.pods[j].Namespace
+		}
+		return d.pods[i].Name < d.pods[j].Name
+	})
+	sort.SliceStable(d.events, func(i, j int) bool { return d.events[i].Time.Before(d.events[j].Time) })
+	sort.SliceStable(d.nodeLogs, func(i, j int) bool {
+		if d.nodeLogs[i].Node != d.nodeLogs[j].Node {
+			return d.nodeLogs[i].Node < d.nodeLogs[j].Node
+		}
+		return d.nodeLogs[i].Name < d.nodeLogs[j].Name
+	})
+	return d
+}
+
+func failures(suite junit.Suite) []junit.Result {
+	var res []junit.Result
+	for _, subSuite := range suite.Suites {
+		res = append(res, failures(subSuite)...)
+	}
+	for _, test := range suite.Results {
+		if test.Failure != nil {
+			res = append(res, test)
+		}
+	}
+	return res
+}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+
+// addObjects adds the pods and events of the output of kubectl get, which
+// lists them or is a single object, in YAML or JSON.
+func (d *dump) addObjects(contents []byte) error {
+	for _, doc := range documentSeparator.Split(string(contents), -1) {
+		raw, err := yaml.YAMLToJSON([]byte(doc))
+		if err != nil {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || string(trimmed) == "null" {
+			continue
+		}
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return err
+		}
+		objects := list.Items
+		if objects == nil {
+			objects = []json.RawMessage{raw}
+		}
+		for _, object := range objects {
+			if err := d.addObject(object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *dump) addObject(raw json.RawMessage) error {
+	var meta struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return err
+	}
+	switch meta.Kind {
+	case "Pod":
+		var pod corev1.Pod
+		if err := json.Unmarshal(raw, &pod); err != nil {
+			return err
+		}
+		var ready int
+		var restarts int32
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Ready {
+				ready++
+			}
+			restarts += status.RestartCount
+		}
+		p := Pod{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Node:      pod.Spec.NodeName,
+			Phase:     string(pod.Status.Phase),
+			Ready:     fmt.Sprintf("%d/%d", ready, len(pod.Spec.Containers)),
+			Restarts:  restarts,
+		}
+		if pod.Status.StartTime != nil {
+			p.Started = pod.Status.StartTime.Time.UTC()
+		}
+		d.pods = append(d.pods, p)
+	case "Event":
+		var event corev1.Event
+		if err := json.Unmarshal(raw, &event); err != nil {
+			return err
+		}
+		t := event.LastTimestamp.Time
+		if t.IsZero() {
+			t = event.EventTime.Time
+		}
+		if t.IsZero() {
+			t = event.FirstTimestamp.Time
+		}
+		d.events = append(d.events, Event{
+			Namespace: event.Namespace,
+			Time:      t.UTC(),
+			Type:      event.Type,
+			Reason:    event.Reason,
+			Object:    strings.ToLower(event.InvolvedObject.Kind) + "/" + event.InvolvedObject.Name,
+			Message:   event.Message,
+			Count:     event.Count,
+		})
+	}
+	return nil
+}
+
+// namespaces returns the namespaces of the pods and events of the dump.
+func (d *dump) namespaces() []string {
+	seen := map[string]bool{}
+	for _, pod := range d.pods {
+		seen[pod.Namespace] = true
+	}
+	for _, event := range d.events {
+		seen[event.Namespace] = true
+	}
+	var namespaces []string
+	for namespace := range seen {
+		if namespace != "" {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// referenceTime returns the time of the first event with a time, or now, to
+// complete the timestamps that lack a year.
+func (d *dump) referenceTime() time.Time {
+	for _, event := range d.events {
+		if !event.Time.IsZero() {
+			return event.Time
+		}
+	}
+	return time.Now().UTC()
+}
+
+var (
+	// stampPattern matches timestamps like "Dec  3 12:00:01.234", as in the
+	// output of e2e tests and in journald logs.
+	stampPattern = regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +\d{1,2} \d{2}:\d{2}:\d{2}(?:\.\d+)?`)
+	// rfc3339Pattern matches RFC3339 timestamps.
+	rfc3339Pattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`)
+	// klogPattern matches the header of klog lines, like
+	// "I1203 12:00:01.234567".
+	klogPattern = regexp.MustCompile(`^[IWEF](\d{2})(\d{2}) (\d{2}:\d{2}:\d{2}(?:\.\d+)?)`)
+)
+
+// parseStamp parses a timestamp without a year in the year of the reference.
+func parseStamp(stamp string, reference time.Time) (time.Time, bool) {
+	t, err := time.Parse("Jan _2 15:04:05", strings.Join(strings.Fields(stamp), " "))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.AddDate(reference.Year(), 0, 0), true
+}
+
+// timesIn returns the timestamps in the text.
+func timesIn(text string, reference time.Time) []time.Time {
+	var times []time.Time
+	for _, stamp := range stampPattern.FindAllString(text, -1) {
+		if t, ok := parseStamp(stamp, reference); ok {
+			times = append(times, t)
+		}
+	}
+	for _, stamp := range rfc3339Pattern.FindAllString(text, -1) {
+		if t, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+			times = append(times, t.UTC())
+		}
+	}
+	return times
+}
+
+// lineTime returns the timestamp at the start of a log line, if any.
+func lineTime(line string, reference time.Time) (time.Time, bool) {
+	if m := klogPattern.FindStringSubmatch(line); m != nil {
+		t, err := time.Parse("0102 15:04:05", m[1]+m[2]+" "+m[3])
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t.AddDate(reference.Year(), 0, 0), true
+	}
+	if loc := stampPattern.FindStringIndex(line); loc != nil && loc[0] == 0 {
+		return parseStamp(line[:loc[1]], reference)
+	}
+	if loc := rfc3339Pattern.FindStringIndex(line); loc != nil && loc[0] == 0 {
+		t, err := time.Parse(time.RFC3339Nano, line[:loc[1]])
+		return t.UTC(), err == nil
+	}
+	return time.Time{}, false
+}
+
+// correlate returns the failed tests with the events within the window
+// around the times in their failures and output. If some of those events
+// are in namespaces that the failure mentions, only they are correlated.
+func correlate(failures []junit.Result, events []Event, window time.Duration, reference time.Time) []FailedTest {
+	var tests []FailedTest
+	for _, failure := range failures {
+		text := *failure.Failure
+		for _, extra := range []*string{failure.Output, failure.Error} {
+			if extra != nil {
+				text += "\n" + *extra
+			}
+		}
+		test := FailedTest{Name: failure.Name}
+		for _, t := range timesIn(text, reference) {
+			if test.From.IsZero() || t.Before(test.From) {
+				test.From = t
+			}
+			if test.To.IsZero() || t.After(test.To) {
+				test.To = t
+			}
+		}
+		if test.From.IsZero() {
+			tests = append(tests, test)
+			continue
+		}
+		test.From = test.From.Add(-window)
+		test.To = test.To.Add(window)
+
+		var inWindow, mentioned []Event
+		f := filter{From: test.From, To: test.To}
+		for _, event := range events {
+			if !f.contains(event.Time) {
+				continue
+			}
+			inWindow = append(inWindow, event)
+			if event.Namespace != "" && strings.Contains(text, event.Namespace) {
+				mentioned = append(mentioned, event)
+			}
+		}
+		test.Events = inWindow
+		if len(mentioned) > 0 {
+			test.Events = mentioned
+		}
+		if len(test.Events) > maxCorrelatedEvents {
+			test.Events = test.Events[len(test.Events)-maxCorrelatedEvents:]
+		}
+		tests = append(tests, test)
+	}
+	return tests
+}
+
+// bodyView is the data of the body template.
+type bodyView struct {
+	Namespace   string
+	From, To    string
+	Namespaces  []string
+	FailedTests []FailedTest
+	Events      []Event
+	TotalEvents int
+	Pods        []Pod
+	NodeLogs    []NodeLog
+}
+
+// Body renders the <body> for cluster dumps. The data is a JSON filter.
+func (lens Lens) Body(artifacts []lenses.Artifact, resourceDir string, data string, rawConfig json.RawMessage) string {
+	conf, err := parseConfig(rawConfig)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid config.")
+		return "Invalid config."
+	}
+	var f filter
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			// The error quotes the data of the request, which must not be
+			// rendered.
+			logrus.WithError(err).Info("Failed to parse filter.")
+			return "Failed to parse filter."
+		}
+	}
+
+	d := index(artifacts)
+	view := bodyView{
+		Namespace:   f.Namespace,
+		Namespaces:  d.namespaces(),
+		FailedTests: correlate(d.failures, d.events, conf.correlationWindow, d.referenceTime()),
+		NodeLogs:    d.nodeLogs,
+	}
+	if !f.From.IsZero() {
+		view.From = f.From.UTC().Format(inputTimeFormat)
+	}
+	if !f.To.IsZero() {
+		view.To = f.To.UTC().Format(inputTimeFormat)
+	}
+	for _, pod := range d.pods {
+		if f.Namespace == "" || f.Namespace == pod.Namespace {
+			view.Pods = append(view.Pods, pod)
+		}
+	}
+	for _, event := range d.events {
+		if (f.Namespace == "" || f.Namespace == event.Namespace) && f.contains(event.Time) {
+			view.Events = append(view.Events, event)
+		}
+	}
+	view.TotalEvents = len(view.Events)
+	if len(view.Events) > maxEvents {
+		view.Events = view.Events[len(view.Events)-maxEvents:]
+	}
+	return executeTemplate(resourceDir, "body", view)
+}
+
+// logRequest requests the lines of a node log within a time window.
+type logRequest struct {
+	Artifact string    `json:"artifact"`
+	From     time.Time `json:"from,omitempty"`
+	To       time.Time `json:"to,omitempty"`
+}
+
+// Callback returns the last lines of a node log in the requested window.
+func (lens Lens) Callback(artifacts []lenses.Artifact, resourceDir string, data string, rawConfig json.RawMessage) string {
+	conf, err := parseConfig(rawConfig)
+	if err != nil {
+		logrus.WithError(err).Error("Invalid config.")
+		return "Invalid config."
+	}
+	var request logRequest
+	if err := json.Unmarshal([]byte(data), &request); err != nil {
+		return "failed to unmarshal request"
+	}
+	var artifact lenses.Artifact
+	for _, a := range artifacts {
+		if a.JobPath() == request.Artifact {
+			artifact = a
+		}
+	}
+	if artifact == nil {
+		return "no artifact named " + request.Artifact
+	}
+
+	f := filter{From: request.From, To: request.To}
+	if f.From.IsZero() && f.To.IsZero() {
+		lines, err := lenses.LastNLines(artifact, int64(conf.logLines))
+		if err != nil {
+			return fmt.Sprintf("failed to read log: %v", err)
+		}
+		return strings.Join(lines, "\n")
+	}
+	contents, err := artifact.ReadAll()
+	if err != nil {
+		return fmt.Sprintf("failed to read log: %v", err)
+	}
+	return strings.Join(linesInWindow(strings.Split(string(contents), "\n"), f, conf.logLines), "\n")
+}
+
+// linesInWindow returns the last lines of the log in the window of the
+// filter, up to the limit. Lines without a timestamp belong to the line
+// before them.
+func linesInWindow(lines []string, f filter, limit int) []string {
+	reference := f.From
+	if reference.IsZero() {
+		reference = f.To
+	}
+	var res []string
+	in := false
+	for _, line := range lines {
+		if t, ok := lineTime(line, reference); ok {
+			in = f.contains(t)
+		}
+		if in {
+			res = append(res, line)
+		}
+	}
+	if len(res) > limit {
+		res = res[len(res)-limit:]
+	}
+	return res
+}
+
+var templateFuncs = template.FuncMap{
+	"displayTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(displayTimeFormat)
+	},
+	"inputTime": func(t time.Time) string {
+		return t.UTC().Format(inputTimeFormat)
+	},
+}
+
+func executeTemplate(resourceDir, templateName string, data interface{}) string {
+	t, err := template.New("template.html").Funcs(templateFuncs).ParseFiles(filepath.Join(resourceDir, "template.html"))
+	if err != nil {
+		return fmt.Sprintf("<!-- FAILED LOADING %s: %v -->", templateName, err)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, templateName, data); err != nil {
+		return fmt.Sprintf("<!-- FAILED EXECUTING %s: %v -->", templateName, err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,277 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterdump
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/testgrid/metadata/junit"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+type fakeArtifact struct {
+	path    string
+	content string
+}
+
+func (fa *fakeArtifact) JobPath() string {
+	return fa.path
+}
+
+func (fa *fakeArtifact) Size() (int64, error) {
+	return int64(len(fa.content)), nil
+}
+
+func (fa *fakeArtifact) CanonicalLink() string {
+	return "gs://bucket/" + fa.path
+}
+
+func (fa *fakeArtifact) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader([]byte(fa.content)).ReadAt(b, off)
+}
+
+func (fa *fakeArtifact) ReadAll() ([]byte, error) {
+	return []byte(fa.content), nil
+}
+
+func (fa *fakeArtifact) ReadTail(n int64) ([]byte, error) {
+	if n > int64(len(fa.content)) {
+		n = int64(len(fa.content))
+	}
+	return []byte(fa.content[int64(len(fa.content))-n:]), nil
+}
+
+func (fa *fakeArtifact) ReadAtMost(n int64) ([]byte, error) {
+	if n > int64(len(fa.content)) {
+		n = int64(len(fa.content))
+	}
+	return []byte(fa.content[:n]), nil
+}
+
+const (
+	podsYAML = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    name: web
+    namespace: e2e-1
+  spec:
+    nodeName: node-1
+    containers:
+    - name: nginx
+    - name: sidecar
+  status:
+    phase: Running
+    startTime: "2019-12-03T12:00:00Z"
+    containerStatuses:
+    - name: nginx
+      ready: true
+      restartCount: 2
+    - name: sidecar
+      restartCount: 1
+`
+	eventsJSON = `{"kind": "EventList", "items": [
+  {"kind": "Event", "metadata": {"name": "a", "namespace": "e2e-1"}, "involvedObject": {"kind": "Pod", "name": "web"},
+   "type": "Warning", "reason": "BackOff", "message": "Back-off restarting", "count": 3, "lastTimestamp": "2019-12-03T12:01:00Z"},
+  {"kind": "Event", "metadata": {"name": "b", "namespace": "kube-system"}, "involvedObject": {"kind": "Node", "name": "node-1"},
+   "type": "Normal", "reason": "Starting", "firstTimestamp": "2019-12-03T11:00:00Z"}
+]}`
+	eventYAML = `kind: Event
+metadata:
+  name: c
+  namespace: e2e-1
+involvedObject:
+  kind: Pod
+  name: web
+type: Normal
+reason: Pulled
+eventTime: "2019-12-03T12:00:30.000000Z"
+---
+`
+	junitXML = `<testsuites><testsuite>
+  <testcase name="passes"></testcase>
+  <testcase name="fails"><failure>Dec  3 12:00:45.123: pod web in namespace e2e-1 is not ready</failure></testcase>
+  <testcase name="fails without timestamps"><failure>timed out</failure></testcase>
+</testsuite></testsuites>`
+)
+
+func TestIndex(t *testing.T) {
+	artifacts := []lenses.Artifact{
+		&fakeArtifact{path: "artifacts/pods.yaml", content: podsYAML},
+		&fakeArtifact{path: "artifacts/events.json", content: eventsJSON},
+		&fakeArtifact{path: "artifacts/event.yaml", content: eventYAML},
+		&fakeArtifact{path: "artifacts/nodes.yaml", content: "kind: NodeList\nitems:\n- kind: Node\n"},
+		&fakeArtifact{path: "artifacts/broken.json", content: "{"},
+		&fakeArtifact{path: "artifacts/node-1/kubelet.log", content: ""},
+		&fakeArtifact{path: "artifacts/master/kube-apiserver.log", content: ""},
+		&fakeArtifact{path: "artifacts/junit_01.xml", content: junitXML},
+	}
+	d := index(artifacts)
+
+	expectedPods := []Pod{{
+		Namespace: "e2e-1",
+		Name:      "web",
+		Node:      "node-1",
+		Phase:     "Running",
+		Ready:     "1/2",
+		Restarts:  3,
+		Started:   time.Date(2019, 12, 3, 12, 0, 0, 0, time.UTC),
+	}}
+	if !reflect.DeepEqual(d.pods, expectedPods) {
+		t.Errorf("Expected pods %+v, got %+v", expectedPods, d.pods)
+	}
+
+	expectedEvents := []Event{
+		{Namespace: "kube-system", Time: time.Date(2019, 12, 3, 11, 0, 0, 0, time.UTC), Type: "Normal", Reason: "Starting", Object: "node/node-1"},
+		{Namespace: "e2e-1", Time: time.Date(2019, 12, 3, 12, 0, 30, 0, time.UTC), Type: "Normal", Reason: "Pulled", Object: "pod/web"},
+		{Namespace: "e2e-1", Time: time.Date(2019, 12, 3, 12, 1, 0, 0, time.UTC), Type: "Warning", Reason: "BackOff", Object: "pod/web", Message: "Back-off restarting", Count: 3},
+	}
+	if !reflect.DeepEqual(d.events, expectedEvents) {
+		t.Errorf("Expected events %+v, got %+v", expectedEvents, d.events)
+	}
+
+	expectedLogs := []NodeLog{
+		{Node: "master", Name: "kube-apiserver.log", Artifact: "artifacts/master/kube-apiserver.log", Link: "gs://bucket/artifacts/master/kube-apiserver.log"},
+		{Node: "node-1", Name: "kubelet.log", Artifact: "artifacts/node-1/kubelet.log", Link: "gs://bucket/artifacts/node-1/kubelet.log"},
+	}
+	if !reflect.DeepEqual(d.nodeLogs, expectedLogs) {
+		t.Errorf("Expected node logs %+v, got %+v", expectedLogs, d.nodeLogs)
+	}
+
+	if len(d.failures) != 2 {
+		t.Errorf("Expected 2 failed tests, got %d", len(d.failures))
+	}
+	if expected, namespaces := []string{"e2e-1", "kube-system"}, d.namespaces(); !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("Expected namespaces %v, got %v", expected, namespaces)
+	}
+}
+
+func TestCorrelate(t *testing.T) {
+	events := []Event{
+		{Namespace: "kube-system", Time: time.Date(2019, 12, 3, 12, 0, 0, 0, time.UTC), Reason: "Starting"},
+		{Namespace: "e2e-1", Time: time.Date(2019, 12, 3, 12, 0, 30, 0, time.UTC), Reason: "Pulled"},
+		{Namespace: "e2e-2", Time: time.Date(2019, 12, 3, 12, 1, 0, 0, time.UTC), Reason: "Killing"},
+		{Namespace: "e2e-1", Time: time.Date(2019, 12, 3, 12, 10, 0, 0, time.UTC), Reason: "BackOff"},
+	}
+	reference := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	str := func(s string) *string { return &s }
+	testCases := []struct {
+		name     string
+		failure  junit.Result
+		expected FailedTest
+	}{
+		{
+			name:     "no timestamps",
+			failure:  junit.Result{Name: "t", Failure: str("timed out")},
+			expected: FailedTest{Name: "t"},
+		},
+		{
+			name:    "events in the window",
+			failure: junit.Result{Name: "t", Failure: str("Dec  3 12:00:45.123: something failed")},
+			expected: FailedTest{
+				Name:   "t",
+				From:   time.Date(2019, 12, 3, 11, 59, 45, 123000000, time.UTC),
+				To:     time.Date(2019, 12, 3, 12, 1, 45, 123000000, time.UTC),
+				Events: events[:3],
+			},
+		},
+		{
+			name: "events of the namespaces in the failure",
+			failure: junit.Result{
+				Name:    "t",
+				Failure: str("pod web in namespace e2e-1 is not ready"),
+				Output:  str("2019-12-03T12:00:40Z STEP: waiting\n2019-12-03T12:00:50Z STEP: giving up"),
+			},
+			expected: FailedTest{
+				Name:   "t",
+				From:   time.Date(2019, 12, 3, 11, 59, 40, 0, time.UTC),
+				To:     time.Date(2019, 12, 3, 12, 1, 50, 0, time.UTC),
+				Events: events[1:2],
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tests := correlate([]junit.Result{tc.failure}, events, time.Minute, reference)
+			if len(tests) != 1 || !reflect.DeepEqual(tests[0], tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, tests)
+			}
+		})
+	}
+}
+
+func TestLinesInWindow(t *testing.T) {
+	lines := []string{
+		"I1203 11:59:00.000000    1 kubelet.go:1] before",
+		"I1203 12:00:00.000000    1 kubelet.go:2] in",
+		"  continuation",
+		"Dec 03 12:00:30 node-1 kernel: in",
+		"2019-12-03T12:01:00.5Z in",
+		"E1203 12:02:00.000000    1 kubelet.go:3] after",
+		"  continuation after",
+	}
+	f := filter{
+		From: time.Date(2019, 12, 3, 12, 0, 0, 0, time.UTC),
+		To:   time.Date(2019, 12, 3, 12, 1, 30, 0, time.UTC),
+	}
+	testCases := []struct {
+		name     string
+		filter   filter
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "lines in the window",
+			filter:   f,
+			limit:    10,
+			expected: lines[1:5],
+		},
+		{
+			name:     "last lines in the window",
+			filter:   f,
+			limit:    2,
+			expected: lines[3:5],
+		},
+		{
+			name:     "open-ended window",
+			filter:   filter{From: f.To},
+			limit:    10,
+			expected: lines[5:],
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := linesInWindow(lines, tc.filter, tc.limit); !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Expected lines %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBodyDoesNotRenderInvalidFilters(t *testing.T) {
+	body := Lens{}.Body(nil, "", `{"from": "<script>alert(1)</script>"}`, nil)
+	if strings.Contains(body, "<script>") {
+		t.Errorf("Expected the filter not to be rendered, got %q", body)
+	}
+}
@@ -0,0 +1,62 @@
+interface Filter {
+  fields?: {[key: string]: string};
+  from?: string;
+  to?: string;
+}
+
+function inputValue(id: string): string {
+  return (document.getElementById(id) as HTMLInputElement | HTMLSelectElement).value;
+}
+
+// The datetime-local inputs are in UTC, with or without seconds.
+function inputTime(id: string): string | undefined {
+  const value = inputValue(id);
+  if (!value) {
+    return undefined;
+  }
+  return value.length === 16 ? `${value}:00Z` : `${value}Z`;
+}
+
+function currentFilter(): Filter {
+  const fields: {[key: string]: string} = {};
+  for (const pair of inputValue('fields').split(',')) {
+    const eq = pair.indexOf('=');
+    if (eq > 0) {
+      fields[pair.substring(0, eq).trim()] = pair.substring(eq + 1).trim();
+    }
+  }
+  const level = inputValue('level');
+  if (level) {
+    fields.level = level;
+  }
+  const component = inputValue('component');
+  if (component) {
+    fields.component = component;
+  }
+  return {fields, from: inputTime('from'), to: inputTime('to')};
+}
+
+function applyFilter(filter: Filter): void {
+  spyglass.updatePage(JSON.stringify(filter));
+}
+
+function loaded(): void {
+  const form = document.getElementById('filter') as HTMLFormElement;
+  form.onsubmit = (e) => {
+    e.preventDefault();
+    applyFilter(currentFilter());
+  };
+  document.getElementById('clear')!.onclick = () => applyFilter({});
+
+  // Clicking a field narrows the filter down to its value.
+  for (const link of Array.from(document.querySelectorAll<HTMLAnchorElement>('a.field'))) {
+    link.onclick = (e) => {
+      e.preventDefault();
+      const filter = currentFilter();
+      filter.fields![link.dataset.key!] = link.dataset.value!;
+      applyFilter(filter);
+    };
+  }
+}
+
+window.addEventListener('DOMContentLoaded', loaded);
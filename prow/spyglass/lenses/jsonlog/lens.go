@@ -0,0 +1,398 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package jsonlog provides a Spyglass lens for newline-delimited JSON logs,
+// like the ones logrus writes, that shows their entries as a table.
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+const (
+	name     = "jsonlog"
+	title    = "Structured Log"
+	priority = 11
+
+	// defaultMaxEntries is the number of entries of a log shown at most if
+	// the config does not say.
+	defaultMaxEntries = 5000
+
+	// inputTimeFormat is the format of the values of datetime-local inputs.
+	inputTimeFormat   = "2006-01-02T15:04:05"
+	displayTimeFormat = "2006-01-02 15:04:05.000"
+)
+
+var (
+	// The keys of the well-known fields, as written by logrus, zap and
+	// others, in order of preference.
+	levelKeys     = []string{"level", "severity", "lvl"}
+	messageKeys   = []string{"msg", "message"}
+	timeKeys      = []string{"time", "ts", "timestamp"}
+	componentKeys = []string{"component", "logger"}
+
+	errorLevels   = map[string]bool{"error": true, "fatal": true, "panic": true, "critical": true}
+	warningLevels = map[string]bool{"warning": true, "warn": true}
+)
+
+func init() {
+	lenses.RegisterLens(Lens{})
+}
+
+// Lens is the implementation of a JSON log-rendering Spyglass lens.
+type Lens struct{}
+
+// Config returns the lens's configuration.
+func (lens Lens) Config() lenses.LensConfig {
+	return lenses.LensConfig{
+		Name:     name,
+		Title:    title,
+		Priority: priority,
+	}
+}
+
+// Header renders the content of <head> from template.html.
+func (lens Lens) Header(artifacts []lenses.Artifact, resourceDir string, config json.RawMessage) string {
+	return executeTemplate(resourceDir, "header", nil)
+}
+
+type config struct {
+	// MaxEntries is the number of entries of a log shown at most, the last
+	// ones that match the filter. Defaults to 5000.
+	MaxEntries int `json:"max_entries,omitempty"`
+}
+
+func getMaxEntries(rawConfig json.RawMessage) int {
+	if len(rawConfig) == 0 {
+		return defaultMaxEntries
+	}
+	var c config
+	if err := json.Unmarshal(rawConfig, &c); err != nil {
+		logrus.WithError(err).Error("Failed to decode jsonlog config")
+		return defaultMaxEntries
+	}
+	if c.MaxEntries <= 0 {
+		return defaultMaxEntries
+	}
+	return c.MaxEntries
+}
+
+// Field is a field of a log entry.
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Entry is a line of a log.
+type Entry struct {
+	// Line is the number of the line in the log, starting at 1.
+	Line      int
+	Time      time.Time
+	Level     string
+	Component string
+	Message   string
+	// Fields are the fields other than the well-known ones, sorted by key.
+	Fields []Field
+	// Raw is set if the line is not a JSON object. Its Message is the line.
+	Raw bool
+
+	// values holds all the fields of the entry for filtering.
+	values map[string]string
+}
+
+// Class returns the CSS class of the entry for its level.
+func (e Entry) Class() string {
+	switch {
+	case errorLevels[e.Level]:
+		return "error"
+	case warningLevels[e.Level]:
+		return "warning"
+	case e.Raw:
+		return "raw"
+	}
+	return ""
+}
+
+// parseLine parses a line of a JSON log into an entry.
+func parseLine(number int, line string) Entry {
+	entry := Entry{Line: number}
+	decoder := json.NewDecoder(strings.NewReader(line))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		entry.Message = line
+		entry.Raw = true
+		return entry
+	}
+
+	entry.values = make(map[string]string, len(object))
+	for key, value := range object {
+		entry.values[key] = stringify(value)
+	}
+	wellKnown := map[string]bool{}
+	lookup := func(keys []string) (string, interface{}) {
+		for _, key := range keys {
+			if value, ok := object[key]; ok {
+				wellKnown[key] = true
+				return key, value
+			}
+		}
+		return "", nil
+	}
+	if key, _ := lookup(levelKeys); key != "" {
+		entry.Level = strings.ToLower(entry.values[key])
+	}
+	if key, _ := lookup(messageKeys); key != "" {
+		entry.Message = entry.values[key]
+	}
+	if key, _ := lookup(componentKeys); key != "" {
+		entry.Component = entry.values[key]
+	}
+	if _, value := lookup(timeKeys); value != nil {
+		entry.Time = parseTime(value)
+	}
+	for key := range object {
+		if !wellKnown[key] {
+			entry.Fields = append(entry.Fields, Field{Key: key, Value: entry.values[key]})
+		}
+	}
+	sort.Slice(entry.Fields, func(i, j int) bool { return entry.Fields[i].Key < entry.Fields[j].Key })
+	return entry
+}
+
+func stringify(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case nil:
+		return "null"
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(raw)
+}
+
+// parseTime parses RFC3339 times and seconds since the epoch.
+func parseTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t.UTC()
+		}
+	case json.Number:
+		if seconds, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			whole, fraction := math.Modf(seconds)
+			return time.Unix(int64(whole), int64(fraction*1e9)).UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// filter selects the entries whose fields have the given values and whose
+// time is within a window. The "level" and "component" keys match the
+// well-known fields whatever their keys in the log.
+type filter struct {
+	Fields map[string]string `json:"fields,omitempty"`
+	From   time.Time         `json:"from,omitempty"`
+	To     time.Time         `json:"to,omitempty"`
+}
+
+func (f filter) matches(entry Entry) bool {
+	for key, value := range f.Fields {
+		if entry.Raw {
+			return false
+		}
+		actual := entry.values[key]
+		switch key {
+		case levelKeys[0]:
+			actual, value = entry.Level, strings.ToLower(value)
+		case componentKeys[0]:
+			actual = entry.Component
+		}
+		if actual != value {
+			return false
+		}
+	}
+	if f.From.IsZero() && f.To.IsZero() {
+		return true
+	}
+	if entry.Time.IsZero() {
+		return false
+	}
+	return (f.From.IsZero() || !entry.Time.Before(f.From)) && (f.To.IsZero() || !entry.Time.After(f.To))
+}
+
+// LogView is the view of a log.
+type LogView struct {
+	ArtifactName string
+	ArtifactLink string
+	Entries      []Entry
+	// Matched is the number of entries that match the filter, of which the
+	// last ones are shown.
+	Matched int
+	Errors  int
+	Error   string
+}
+
+// filterView is the filter as shown in the form.
+type filterView struct {
+	Level     string
+	Component string
+	Fields    string
+	From, To  string
+}
+
+// bodyView is the data of the body template.
+type bodyView struct {
+	Logs       []LogView
+	Filter     filterView
+	Levels     []string
+	Components []string
+}
+
+// Body renders the entries of the logs that match the filter in data.
+func (lens Lens) Body(artifacts []lenses.Artifact, resourceDir string, data string, rawConfig json.RawMessage) string {
+	var f filter
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			// The error quotes the data of the request, which must not be
+			// rendered.
+			logrus.WithError(err).Info("Failed to parse filter.")
+			return "Failed to parse filter."
+		}
+	}
+	maxEntries := getMaxEntries(rawConfig)
+
+	view := bodyView{Filter: newFilterView(f)}
+	levels := map[string]bool{}
+	components := map[string]bool{}
+	for _, artifact := range artifacts {
+		logView := LogView{
+			ArtifactName: artifact.JobPath(),
+			ArtifactLink: artifact.CanonicalLink(),
+		}
+		content, err := artifact.ReadAll()
+		if err != nil {
+			logrus.WithError(err).WithField("artifact", artifact.JobPath()).Info("Error reading log.")
+			logView.Error = fmt.Sprintf("Failed to read log: %v", err)
+			view.Logs = append(view.Logs, logView)
+			continue
+		}
+		for i, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+			entry := parseLine(i+1, line)
+			if entry.Level != "" {
+				levels[entry.Level] = true
+			}
+			if entry.Component != "" {
+				components[entry.Component] = true
+			}
+			if !f.matches(entry) {
+				continue
+			}
+			logView.Matched++
+			if errorLevels[entry.Level] {
+				logView.Errors++
+			}
+			logView.Entries = append(logView.Entries, entry)
+			if len(logView.Entries) > maxEntries {
+				logView.Entries = logView.Entries[1:]
+			}
+		}
+		view.Logs = append(view.Logs, logView)
+	}
+	view.Levels = sortedKeys(levels)
+	view.Components = sortedKeys(components)
+	return executeTemplate(resourceDir, "body", view)
+}
+
+// newFilterView splits the level and component out of the fields of the
+// filter for their own inputs.
+func newFilterView(f filter) filterView {
+	var view filterView
+	var fields []string
+	for key, value := range f.Fields {
+		switch key {
+		case levelKeys[0]:
+			view.Level = value
+		case componentKeys[0]:
+			view.Component = value
+		default:
+			fields = append(fields, key+"="+value)
+		}
+	}
+	sort.Strings(fields)
+	view.Fields = strings.Join(fields, ", ")
+	if !f.From.IsZero() {
+		view.From = f.From.UTC().Format(inputTimeFormat)
+	}
+	if !f.To.IsZero() {
+		view.To = f.To.UTC().Format(inputTimeFormat)
+	}
+	return view
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Callback is not used by this lens.
+func (lens Lens) Callback(artifacts []lenses.Artifact, resourceDir string, data string, rawConfig json.RawMessage) string {
+	return ""
+}
+
+var templateFuncs = template.FuncMap{
+	"displayTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(displayTimeFormat)
+	},
+}
+
+func executeTemplate(resourceDir, templateName string, data interface{}) string {
+	t := template.New("template.html").Funcs(templateFuncs)
+	_, err := t.ParseFiles(filepath.Join(resourceDir, "template.html"))
+	if err != nil {
+		return fmt.Sprintf("Failed to load template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, templateName, data); err != nil {
+		logrus.WithError(err).Error("Error executing template.")
+	}
+	return buf.String()
+}
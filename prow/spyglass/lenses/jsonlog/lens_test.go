@@ -0,0 +1,166 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsonlog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	testCases := []struct {
+		name     string
+		line     string
+		expected Entry
+	}{
+		{
+			name: "logrus",
+			line: `{"component":"tide","level":"error","msg":"Error syncing.","org":"kubernetes","pr":42,"time":"2019-12-03T12:00:01Z"}`,
+			expected: Entry{
+				Line:      1,
+				Time:      time.Date(2019, 12, 3, 12, 0, 1, 0, time.UTC),
+				Level:     "error",
+				Component: "tide",
+				Message:   "Error syncing.",
+				Fields:    []Field{{Key: "org", Value: "kubernetes"}, {Key: "pr", Value: "42"}},
+			},
+		},
+		{
+			name: "zap",
+			line: `{"severity":"WARN","logger":"controller","message":"Slow.","ts":1575374401.5,"labels":{"a":"b"},"err":null}`,
+			expected: Entry{
+				Line:      1,
+				Time:      time.Date(2019, 12, 3, 12, 0, 1, 500000000, time.UTC),
+				Level:     "warn",
+				Component: "controller",
+				Message:   "Slow.",
+				Fields:    []Field{{Key: "err", Value: "null"}, {Key: "labels", Value: `{"a":"b"}`}},
+			},
+		},
+		{
+			name:     "not JSON",
+			line:     "+ make test",
+			expected: Entry{Line: 1, Message: "+ make test", Raw: true},
+		},
+		{
+			name:     "JSON but not an object",
+			line:     `["a"]`,
+			expected: Entry{Line: 1, Message: `["a"]`, Raw: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := parseLine(1, tc.line)
+			entry.values = nil
+			if !reflect.DeepEqual(entry, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, entry)
+			}
+		})
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	entry := parseLine(1, `{"severity":"ERROR","logger":"tide","msg":"Failed.","org":"kubernetes","time":"2019-12-03T12:00:00Z"}`)
+	raw := parseLine(2, "+ make test")
+	testCases := []struct {
+		name     string
+		filter   filter
+		entry    Entry
+		expected bool
+	}{
+		{
+			name:     "no filter",
+			entry:    entry,
+			expected: true,
+		},
+		{
+			name:     "well-known fields under other keys",
+			filter:   filter{Fields: map[string]string{"level": "error", "component": "tide"}},
+			entry:    entry,
+			expected: true,
+		},
+		{
+			name:     "field value",
+			filter:   filter{Fields: map[string]string{"org": "kubernetes"}},
+			entry:    entry,
+			expected: true,
+		},
+		{
+			name:   "other field value",
+			filter: filter{Fields: map[string]string{"org": "istio"}},
+			entry:  entry,
+		},
+		{
+			name:     "in the window",
+			filter:   filter{From: time.Date(2019, 12, 3, 11, 0, 0, 0, time.UTC), To: time.Date(2019, 12, 3, 12, 0, 0, 0, time.UTC)},
+			entry:    entry,
+			expected: true,
+		},
+		{
+			name:   "after the window",
+			filter: filter{To: time.Date(2019, 12, 3, 11, 0, 0, 0, time.UTC)},
+			entry:  entry,
+		},
+		{
+			name:     "raw line without filter",
+			entry:    raw,
+			expected: true,
+		},
+		{
+			name:   "raw line with a field filter",
+			filter: filter{Fields: map[string]string{"level": "error"}},
+			entry:  raw,
+		},
+		{
+			name:   "raw line with a window",
+			filter: filter{From: time.Date(2019, 12, 3, 11, 0, 0, 0, time.UTC)},
+			entry:  raw,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := tc.filter.matches(tc.entry); actual != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewFilterView(t *testing.T) {
+	f := filter{
+		Fields: map[string]string{"level": "error", "component": "tide", "org": "kubernetes", "pr": "42"},
+		From:   time.Date(2019, 12, 3, 12, 0, 1, 0, time.UTC),
+	}
+	expected := filterView{
+		Level:     "error",
+		Component: "tide",
+		Fields:    "org=kubernetes, pr=42",
+		From:      "2019-12-03T12:00:01",
+	}
+	if actual := newFilterView(f); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestBodyDoesNotRenderInvalidFilters(t *testing.T) {
+	body := Lens{}.Body(nil, "", `{"from": "<script>alert(1)</script>"}`, nil)
+	if strings.Contains(body, "<script>") {
+		t.Errorf("Expected the filter not to be rendered, got %q", body)
+	}
+}
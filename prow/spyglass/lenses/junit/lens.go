@@ -86,6 +86,33 @@ type TestResult struct {
 	Link  string
 }
 
+// Results reads and parses a junit artifact and returns the results of its
+// tests, including the ones of nested suites.
+func Results(artifact lenses.Artifact) ([]junit.Result, error) {
+	contents, err := artifact.ReadAll()
+	if err != nil {
+		logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Warn("Error reading artifact")
+		return nil, err
+	}
+	suites, err := junit.Parse(contents)
+	if err != nil {
+		logrus.WithError(err).WithField("artifact", artifact.CanonicalLink()).Info("Error parsing junit file.")
+		return nil, err
+	}
+	var results []junit.Result
+	var record func(suite junit.Suite)
+	record = func(suite junit.Suite) {
+		for _, subSuite := range suite.Suites {
+			record(subSuite)
+		}
+		results = append(results, suite.Results...)
+	}
+	for _, suite := range suites.Suites {
+		record(suite)
+	}
+	return results, nil
+}
+
 // Body renders the <body> for JUnit tests
 func (lens Lens) Body(artifacts []lenses.Artifact, resourceDir string, data string, config json.RawMessage) string {
 	type testResults struct {
@@ -101,32 +128,7 @@ func (lens Lens) Body(artifacts []lenses.Artifact, resourceDir string, data stri
 				link: artifact.CanonicalLink(),
 				path: artifact.JobPath(),
 			}
-			var contents []byte
-			contents, result.err = artifact.ReadAll()
-			if result.err != nil {
-				logrus.WithError(result.err).WithField("artifact", artifact.CanonicalLink()).Warn("Error reading artifact")
-				resultChan <- result
-				return
-			}
-			var suites junit.Suites
-			suites, result.err = junit.Parse(contents)
-			if result.err != nil {
-				logrus.WithError(result.err).WithField("artifact", artifact.CanonicalLink()).Info("Error parsing junit file.")
-				resultChan <- result
-				return
-			}
-			var record func(suite junit.Suite)
-			record = func(suite junit.Suite) {
-				for _, subSuite := range suite.Suites {
-					record(subSuite)
-				}
-				for _, test := range suite.Results {
-					result.junit = append(result.junit, test)
-				}
-			}
-			for _, suite := range suites.Suites {
-				record(suite)
-			}
+			result.junit, result.err = Results(artifact)
 			resultChan <- result
 		}(artifact)
 	}
@@ -0,0 +1,376 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+const (
+	// searchChunkSize is the number of bytes of an artifact read at a time
+	// when indexing it.
+	searchChunkSize = 4 << 20
+	// maxSearchBytes is the number of bytes of the artifacts of a job that
+	// are indexed at most.
+	maxSearchBytes = 64 << 20
+	// maxSearchCacheBytes is the number of bytes of artifacts whose indexes
+	// are cached at most, across jobs.
+	maxSearchCacheBytes = 256 << 20
+	// maxSearchMatches is the number of matches returned at most.
+	maxSearchMatches = 500
+	// maxMatchLength is the number of bytes of a matching line returned at
+	// most.
+	maxMatchLength = 1000
+	// searchCacheSize is the number of job indexes cached at most.
+	searchCacheSize = 16
+	// searchCacheTTL is how long an index is cached, as the artifacts of
+	// running jobs change.
+	searchCacheTTL = 5 * time.Minute
+)
+
+var (
+	errBinaryArtifact   = errors.New("artifact is binary")
+	errArtifactTooLarge = errors.New("artifact is too large to index")
+)
+
+// SearchMatch is a line of an artifact that matches a search.
+type SearchMatch struct {
+	Artifact string `json:"artifact"`
+	// Line is the number of the line, starting at 1.
+	Line int    `json:"line"`
+	Text string `json:"text"`
+	Link string `json:"link"`
+}
+
+// SearchResult holds the matches of a search across the artifacts of a job.
+type SearchResult struct {
+	Query   string        `json:"query"`
+	Matches []SearchMatch `json:"matches"`
+	// Truncated is set if there were more matches than returned.
+	Truncated bool `json:"truncated,omitempty"`
+	// Skipped lists the artifacts that were not searched because they are
+	// binary, too large or could not be read.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Search returns the lines of the artifacts of the job in src that contain
+// the query, ignoring case. The artifacts are indexed on the first search
+// of a job and the index is cached for a while.
+func (sg *Spyglass) Search(src, query string) (*SearchResult, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, errors.New("empty query")
+	}
+	idx, err := sg.searchCache.get(src, func() (*searchIndex, error) {
+		artifactNames, err := sg.ListArtifacts(src)
+		if err != nil {
+			return nil, fmt.Errorf("error listing artifacts: %v", err)
+		}
+		artifacts, err := sg.FetchArtifacts(src, "", sg.config().Deck.Spyglass.SizeLimit, artifactNames)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching artifacts: %v", err)
+		}
+		return buildSearchIndex(artifacts, sg.config().Deck.Spyglass.SizeLimit), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	matches, truncated := idx.search(query, maxSearchMatches)
+	return &SearchResult{
+		Query:     query,
+		Matches:   matches,
+		Truncated: truncated,
+		Skipped:   idx.skipped,
+	}, nil
+}
+
+// posting locates a line in the index.
+type posting struct {
+	artifact, line int32
+}
+
+type indexedArtifact struct {
+	name  string
+	link  string
+	lines []string
+}
+
+// searchIndex is an inverted index from the lowercase words of the lines of
+// the artifacts of a job to the lines.
+type searchIndex struct {
+	artifacts []indexedArtifact
+	words     map[string][]posting
+	skipped   []string
+	// size is the number of bytes of the indexed artifacts.
+	size int64
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !isWordRune(r) })
+}
+
+// buildSearchIndex indexes the text artifacts that are at most sizeLimit
+// bytes, up to maxSearchBytes in total.
+func buildSearchIndex(artifacts []lenses.Artifact, sizeLimit int64) *searchIndex {
+	idx := &searchIndex{words: map[string][]posting{}}
+	budget := int64(maxSearchBytes)
+	for _, artifact := range artifacts {
+		limit := sizeLimit
+		if budget < limit {
+			limit = budget
+		}
+		lines, size, err := readLines(artifact, limit)
+		if err != nil {
+			logrus.WithError(err).WithField("artifact", artifact.JobPath()).Debug("Not indexing artifact.")
+			idx.skipped = append(idx.skipped, artifact.JobPath())
+			continue
+		}
+		budget -= size
+		idx.size += size
+		idx.add(artifact, lines)
+	}
+	return idx
+}
+
+func (idx *searchIndex) add(artifact lenses.Artifact, lines []string) {
+	a := int32(len(idx.artifacts))
+	idx.artifacts = append(idx.artifacts, indexedArtifact{
+		name:  artifact.JobPath(),
+		link:  artifact.CanonicalLink(),
+		lines: lines,
+	})
+	for i, line := range lines {
+		p := posting{artifact: a, line: int32(i)}
+		for _, word := range words(line) {
+			postings := idx.words[word]
+			// Words repeated in a line are indexed once.
+			if len(postings) > 0 && postings[len(postings)-1] == p {
+				continue
+			}
+			idx.words[word] = append(postings, p)
+		}
+	}
+}
+
+// readLines streams the lines of the artifact if it is text of at most
+// limit bytes. It also returns the number of bytes read.
+func readLines(artifact lenses.Artifact, limit int64) ([]string, int64, error) {
+	size, err := artifact.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+	if size > limit {
+		return nil, 0, errArtifactTooLarge
+	}
+
+	var lines []string
+	var partial []byte
+	for offset := int64(0); offset < size; {
+		chunk := make([]byte, searchChunkSize)
+		if remaining := size - offset; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		n, err := artifact.ReadAt(chunk, offset)
+		if err == lenses.ErrGzipOffsetRead {
+			// Compressed artifacts cannot be streamed.
+			content, err := artifact.ReadAtMost(limit)
+			if err != nil && err != io.EOF {
+				return nil, 0, err
+			}
+			if bytes.IndexByte(content, 0) >= 0 {
+				return nil, 0, errBinaryArtifact
+			}
+			return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n"), int64(len(content)), nil
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		if n == 0 {
+			break
+		}
+		chunk = chunk[:n]
+		if bytes.IndexByte(chunk, 0) >= 0 {
+			return nil, 0, errBinaryArtifact
+		}
+		offset += int64(n)
+		data := append(partial, chunk...)
+		if last := bytes.LastIndexByte(data, '\n'); last >= 0 {
+			lines = append(lines, strings.Split(string(data[:last]), "\n")...)
+			partial = append([]byte(nil), data[last+1:]...)
+		} else {
+			partial = data
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if len(partial) > 0 {
+		lines = append(lines, string(partial))
+	}
+	return lines, size, nil
+}
+
+// search returns the lines that contain the query, ignoring case, up to the
+// limit, and whether there were more. Every word of the query is part of a
+// word of the matching lines, so the lines of the words that contain the
+// rarest word of the query are the candidates.
+func (idx *searchIndex) search(query string, limit int) ([]SearchMatch, bool) {
+	lowerQuery := strings.ToLower(query)
+	var candidates []posting
+	if queryWords := words(query); len(queryWords) > 0 {
+		first := true
+		for _, queryWord := range queryWords {
+			seen := map[posting]bool{}
+			var lines []posting
+			for word, postings := range idx.words {
+				if !strings.Contains(word, queryWord) {
+					continue
+				}
+				for _, p := range postings {
+					if !seen[p] {
+						seen[p] = true
+						lines = append(lines, p)
+					}
+				}
+			}
+			if first || len(lines) < len(candidates) {
+				candidates = lines
+				first = false
+			}
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			if candidates[i].artifact != candidates[j].artifact {
+				return candidates[i].artifact < candidates[j].artifact
+			}
+			return candidates[i].line < candidates[j].line
+		})
+	} else {
+		// Queries without words cannot use the index.
+		for a, artifact := range idx.artifacts {
+			for l := range artifact.lines {
+				candidates = append(candidates, posting{artifact: int32(a), line: int32(l)})
+			}
+		}
+	}
+
+	var matches []SearchMatch
+	for _, p := range candidates {
+		artifact := idx.artifacts[p.artifact]
+		line := artifact.lines[p.line]
+		if !strings.Contains(strings.ToLower(line), lowerQuery) {
+			continue
+		}
+		if len(matches) == limit {
+			return matches, true
+		}
+		if len(line) > maxMatchLength {
+			line = line[:maxMatchLength]
+		}
+		matches = append(matches, SearchMatch{
+			Artifact: artifact.name,
+			Line:     int(p.line) + 1,
+			Text:     line,
+			Link:     artifact.link,
+		})
+	}
+	return matches, false
+}
+
+type searchCacheEntry struct {
+	index   *searchIndex
+	created time.Time
+}
+
+// searchCache caches the search indexes of the most recently indexed jobs,
+// up to searchCacheSize of them and maxSearchCacheBytes of artifacts.
+type searchCache struct {
+	sync.Mutex
+	entries map[string]searchCacheEntry
+	now     func() time.Time
+	// builds shares the index being built for a job between the searches
+	// that are waiting for it.
+	builds singleflight.Group
+}
+
+func newSearchCache() *searchCache {
+	return &searchCache{
+		entries: map[string]searchCacheEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns the cached index of the job in src, building it if it is not
+// cached or expired.
+func (c *searchCache) get(src string, build func() (*searchIndex, error)) (*searchIndex, error) {
+	c.Lock()
+	entry, ok := c.entries[src]
+	c.Unlock()
+	if ok && c.now().Sub(entry.created) < searchCacheTTL {
+		return entry.index, nil
+	}
+
+	idx, err, _ := c.builds.Do(src, func() (interface{}, error) {
+		idx, err := build()
+		if err != nil {
+			return nil, err
+		}
+		c.add(src, idx)
+		return idx, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx.(*searchIndex), nil
+}
+
+// add caches the index and evicts the oldest indexes that exceed the limits
+// of the cache.
+func (c *searchCache) add(src string, idx *searchIndex) {
+	c.Lock()
+	defer c.Unlock()
+	c.entries[src] = searchCacheEntry{index: idx, created: c.now()}
+	var size int64
+	for _, entry := range c.entries {
+		size += entry.index.size
+	}
+	for len(c.entries) > searchCacheSize || (size > maxSearchCacheBytes && len(c.entries) > 1) {
+		var oldest string
+		for key, entry := range c.entries {
+			if oldest == "" || entry.created.Before(c.entries[oldest].created) {
+				oldest = key
+			}
+		}
+		size -= c.entries[oldest].index.size
+		delete(c.entries, oldest)
+	}
+}
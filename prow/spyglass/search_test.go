@@ -0,0 +1,248 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// searchArtifact reads like a GCS artifact: reads past the end fail, and
+// gzipped artifacts cannot be read at an offset.
+type searchArtifact struct {
+	path    string
+	content string
+	gzipped bool
+}
+
+func (a *searchArtifact) JobPath() string {
+	return a.path
+}
+
+func (a *searchArtifact) CanonicalLink() string {
+	return "https://storage/" + a.path
+}
+
+func (a *searchArtifact) Size() (int64, error) {
+	return int64(len(a.content)), nil
+}
+
+func (a *searchArtifact) ReadAt(p []byte, off int64) (int, error) {
+	if a.gzipped {
+		return 0, lenses.ErrGzipOffsetRead
+	}
+	if off+int64(len(p)) > int64(len(a.content)) {
+		return 0, errors.New("read range exceeds artifact contents")
+	}
+	n := copy(p, a.content[off:])
+	if off+int64(n) == int64(len(a.content)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (a *searchArtifact) ReadAtMost(n int64) ([]byte, error) {
+	if n > int64(len(a.content)) {
+		n = int64(len(a.content))
+	}
+	return []byte(a.content[:n]), nil
+}
+
+func (a *searchArtifact) ReadAll() ([]byte, error) {
+	return []byte(a.content), nil
+}
+
+func (a *searchArtifact) ReadTail(n int64) ([]byte, error) {
+	return []byte(a.content[int64(len(a.content))-n:]), nil
+}
+
+func TestSearch(t *testing.T) {
+	artifacts := []lenses.Artifact{
+		&searchArtifact{path: "build-log.txt", content: "I1203 starting\nE1203 connection refused: dial tcp\nI1203 retrying\nE1203 Connection Refused again\n"},
+		&searchArtifact{path: "artifacts/kubelet.log", content: "no newline at the end: connection refused", gzipped: true},
+		&searchArtifact{path: "artifacts/big.log", content: strings.Repeat("connection refused\n", 100)},
+		&searchArtifact{path: "artifacts/image.png", content: "\x89PNG\x00connection refused"},
+		&searchArtifact{path: "artifacts/punctuation.txt", content: "a\n--- :: ---\nb"},
+	}
+	idx := buildSearchIndex(artifacts, 1000)
+	if expected := []string{"artifacts/big.log", "artifacts/image.png"}; !reflect.DeepEqual(idx.skipped, expected) {
+		t.Errorf("Expected the artifacts %v to be skipped, got %v", expected, idx.skipped)
+	}
+
+	testCases := []struct {
+		name      string
+		query     string
+		limit     int
+		expected  []SearchMatch
+		truncated bool
+	}{
+		{
+			name:  "case-insensitive matches across artifacts",
+			query: "connection REFUSED",
+			limit: 10,
+			expected: []SearchMatch{
+				{Artifact: "build-log.txt", Line: 2, Text: "E1203 connection refused: dial tcp", Link: "https://storage/build-log.txt"},
+				{Artifact: "build-log.txt", Line: 4, Text: "E1203 Connection Refused again", Link: "https://storage/build-log.txt"},
+				{Artifact: "artifacts/kubelet.log", Line: 1, Text: "no newline at the end: connection refused", Link: "https://storage/artifacts/kubelet.log"},
+			},
+		},
+		{
+			name:  "parts of words",
+			query: "onnection ref",
+			limit: 1,
+			expected: []SearchMatch{
+				{Artifact: "build-log.txt", Line: 2, Text: "E1203 connection refused: dial tcp", Link: "https://storage/build-log.txt"},
+			},
+			truncated: true,
+		},
+		{
+			name:  "no words",
+			query: ":: -",
+			limit: 10,
+			expected: []SearchMatch{
+				{Artifact: "artifacts/punctuation.txt", Line: 2, Text: "--- :: ---", Link: "https://storage/artifacts/punctuation.txt"},
+			},
+		},
+		{
+			name:  "no matches",
+			query: "refused connection",
+			limit: 10,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matches, truncated := idx.search(tc.query, tc.limit)
+			if !reflect.DeepEqual(matches, tc.expected) {
+				t.Errorf("Expected matches %+v, got %+v", tc.expected, matches)
+			}
+			if truncated != tc.truncated {
+				t.Errorf("Expected truncated %t, got %t", tc.truncated, truncated)
+			}
+		})
+	}
+}
+
+func TestReadLinesInChunks(t *testing.T) {
+	content := strings.Repeat("x", searchChunkSize-1) + "\nsecond line\nthird"
+	lines, size, err := readLines(&searchArtifact{path: "log", content: content}, int64(len(content)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if size != int64(len(content)) {
+		t.Errorf("Expected to read %d bytes, got %d", len(content), size)
+	}
+	if expected := strings.Split(content, "\n"); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected %d lines, got %d", len(expected), len(lines))
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	now := time.Now()
+	c := newSearchCache()
+	c.now = func() time.Time { return now }
+	builds := 0
+	build := func() (*searchIndex, error) {
+		builds++
+		return &searchIndex{}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.get("gcs/bucket/logs/job/1", build); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("Expected the index to be built once, was built %d times", builds)
+	}
+
+	now = now.Add(searchCacheTTL)
+	if _, err := c.get("gcs/bucket/logs/job/1", build); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if builds != 2 {
+		t.Errorf("Expected the expired index to be rebuilt, was built %d times", builds)
+	}
+
+	for i := 0; i < 2*searchCacheSize; i++ {
+		now = now.Add(time.Second)
+		if _, err := c.get(string(rune('a'+i)), build); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if len(c.entries) != searchCacheSize {
+		t.Errorf("Expected %d cached indexes, got %d", searchCacheSize, len(c.entries))
+	}
+
+	// Large indexes evict the older indexes until they fit.
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Second)
+		large := func() (*searchIndex, error) {
+			return &searchIndex{size: maxSearchCacheBytes/2 + 1}, nil
+		}
+		if _, err := c.get(fmt.Sprintf("large-%d", i), large); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if _, ok := c.entries["large-1"]; !ok || len(c.entries) != 1 {
+		t.Errorf("Expected only the last large index to be cached, got %d cached indexes", len(c.entries))
+	}
+}
+
+func TestSearchCacheSharesBuilds(t *testing.T) {
+	c := newSearchCache()
+	var builds int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	build := func() (*searchIndex, error) {
+		if atomic.AddInt32(&builds, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return &searchIndex{}, nil
+	}
+
+	var wg sync.WaitGroup
+	get := func() {
+		defer wg.Done()
+		if _, err := c.get("gcs/bucket/logs/job/1", build); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	}
+	wg.Add(1)
+	go get()
+	<-started
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go get()
+	}
+	// Give the waiting searches time to join the build.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if builds := atomic.LoadInt32(&builds); builds != 1 {
+		t.Errorf("Expected the index to be built once, was built %d times", builds)
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/GoogleCloudPlatform/testgrid/metadata"
+	pkgio "k8s.io/test-infra/pkg/io"
 	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
 	"k8s.io/test-infra/prow/config"
 	"k8s.io/test-infra/prow/deck/jobs"
@@ -42,6 +43,8 @@ import (
 // Key types specify the way Spyglass will fetch artifact handles
 const (
 	gcsKeyType  = "gcs"
+	s3KeyType   = "s3"
+	fileKeyType = "file"
 	prowKeyType = "prowjob"
 )
 
@@ -55,10 +58,12 @@ type Spyglass struct {
 	// JobAgent contains information about the current jobs in deck
 	JobAgent *jobs.JobAgent
 
-	config   config.Getter
-	testgrid *TestGrid
+	config      config.Getter
+	testgrid    *TestGrid
+	searchCache *searchCache
 
 	*GCSArtifactFetcher
+	*StorageArtifactFetcher
 	*PodLogArtifactFetcher
 }
 
@@ -76,18 +81,22 @@ type ExtraLink struct {
 	URL         string
 }
 
-// New constructs a Spyglass object from a JobAgent, a config.Agent, and a storage Client.
-func New(ja *jobs.JobAgent, cfg config.Getter, c *storage.Client, gcsCredsFile string, ctx context.Context) *Spyglass {
+// New constructs a Spyglass object from a JobAgent, a config.Agent, a storage Client
+// for GCS artifacts and an opener for artifacts in any other storage provider.
+// Artifacts on the local filesystem are only served from under fileRoots.
+func New(ja *jobs.JobAgent, cfg config.Getter, c *storage.Client, opener pkgio.Storage, fileRoots []string, gcsCredsFile string, ctx context.Context) *Spyglass {
 	return &Spyglass{
-		JobAgent:              ja,
-		config:                cfg,
-		PodLogArtifactFetcher: NewPodLogArtifactFetcher(ja),
-		GCSArtifactFetcher:    NewGCSArtifactFetcher(c, gcsCredsFile),
+		JobAgent:               ja,
+		config:                 cfg,
+		PodLogArtifactFetcher:  NewPodLogArtifactFetcher(ja),
+		GCSArtifactFetcher:     NewGCSArtifactFetcher(c, gcsCredsFile),
+		StorageArtifactFetcher: NewStorageArtifactFetcher(opener, fileRoots),
 		testgrid: &TestGrid{
 			conf:   cfg,
 			client: c,
 			ctx:    ctx,
 		},
+		searchCache: newSearchCache(),
 	}
 }
 
@@ -173,12 +182,17 @@ func (sg *Spyglass) ResolveSymlink(src string) (string, error) {
 			return "", fmt.Errorf("expected gs:// symlink, got '%s://'", u.Scheme)
 		}
 		return path.Join(gcsKeyType, u.Host, u.Path), nil
+	case s3KeyType, fileKeyType:
+		// Symlinks are only written to GCS by bootstrap, the pod utilities never write them.
+		return src, nil
 	default:
 		return "", fmt.Errorf("unknown src key type %q", keyType)
 	}
 }
 
-// JobPath returns a link to the GCS directory for the job specified in src
+// JobPath returns a link to the GCS directory for the job specified in src.
+// Job history is only available for GCS, so an empty path and nil error are
+// returned for jobs storing their artifacts anywhere else.
 func (sg *Spyglass) JobPath(src string) (string, error) {
 	src = strings.TrimSuffix(src, "/")
 	keyType, key, err := splitSrc(src)
@@ -201,6 +215,8 @@ func (sg *Spyglass) JobPath(src string) (string, error) {
 			return path.Join(bktName, gcs.PRLogs, "directory", jobName), nil
 		}
 		return "", fmt.Errorf("unrecognized GCS key: %s", key)
+	case s3KeyType, fileKeyType:
+		return "", nil
 	case prowKeyType:
 		if len(split) < 2 {
 			return "", fmt.Errorf("invalid key %s: expected <job-name>/<build-id>", key)
@@ -217,7 +233,10 @@ func (sg *Spyglass) JobPath(src string) (string, error) {
 		if job.Spec.DecorationConfig.GCSConfiguration == nil {
 			return "", fmt.Errorf("failed to locate GCS upload bucket for %s: missing GCS configuration", jobName)
 		}
-		bktName := job.Spec.DecorationConfig.GCSConfiguration.Bucket
+		if job.Spec.DecorationConfig.GCSConfiguration.StorageProvider() != prowapi.StorageProviderGCS {
+			return "", nil
+		}
+		bktName := job.Spec.DecorationConfig.GCSConfiguration.BucketName()
 		if job.Spec.Type == prowapi.PresubmitJob {
 			return path.Join(bktName, gcs.PRLogs, "directory", jobName), nil
 		}
@@ -239,7 +258,7 @@ func (sg *Spyglass) ProwJobName(src string) (string, error) {
 	var jobName string
 	var buildID string
 	switch keyType {
-	case gcsKeyType:
+	case gcsKeyType, s3KeyType, fileKeyType:
 		if len(split) < 4 {
 			return "", fmt.Errorf("invalid key %s: expected <bucket-name>/<log-type>/.../<job-name>/<build-id>", key)
 		}
@@ -264,6 +283,30 @@ func (sg *Spyglass) ProwJobName(src string) (string, error) {
 	return job.Name, nil
 }
 
+// InGCS returns whether the artifacts of the job run specified in src are
+// stored in GCS, which job and PR histories and gcsweb are only available for.
+func (sg *Spyglass) InGCS(src string) bool {
+	keyType, key, err := splitSrc(strings.TrimSuffix(src, "/"))
+	if err != nil {
+		return false
+	}
+	if keyType != prowKeyType {
+		return keyType == gcsKeyType
+	}
+	jobName, buildID, err := sg.KeyToJob(key)
+	if err != nil {
+		return false
+	}
+	job, err := sg.jobAgent.GetProwJob(jobName, buildID)
+	if err != nil {
+		return false
+	}
+	if job.Spec.DecorationConfig == nil || job.Spec.DecorationConfig.GCSConfiguration == nil {
+		return true
+	}
+	return job.Spec.DecorationConfig.GCSConfiguration.StorageProvider() == prowapi.StorageProviderGCS
+}
+
 // RunPath returns the path to the GCS directory for the job run specified in src.
 func (sg *Spyglass) RunPath(src string) (string, error) {
 	src = strings.TrimSuffix(src, "/")
@@ -272,10 +315,11 @@ func (sg *Spyglass) RunPath(src string) (string, error) {
 		return "", fmt.Errorf("error parsing src: %v", src)
 	}
 	switch keyType {
-	case gcsKeyType:
+	case gcsKeyType, s3KeyType, fileKeyType:
 		return key, nil
 	case prowKeyType:
-		return sg.prowToGCS(key)
+		_, key, err := sg.prowToStorage(key)
+		return key, err
 	default:
 		return "", fmt.Errorf("unrecognized key type for src: %v", src)
 	}
@@ -294,7 +338,7 @@ func (sg *Spyglass) RunToPR(src string) (string, string, int, error) {
 		return "", "", 0, fmt.Errorf("expected more URL components in %q", src)
 	}
 	switch keyType {
-	case gcsKeyType:
+	case gcsKeyType, s3KeyType, fileKeyType:
 		// In theory, we could derive this information without trying to parse the URL by instead fetching the
 		// data from uploaded artifacts. In practice, that would not be a great solution: it would require us
 		// to try pulling two different metadata files (one for bootstrap and one for podutils), then parse them
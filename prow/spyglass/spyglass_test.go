@@ -243,7 +243,7 @@ func TestViews(t *testing.T) {
 					},
 				},
 			}
-			sg := New(fakeJa, c.Config, fakeGCSClient, "", context.Background())
+			sg := New(fakeJa, c.Config, fakeGCSClient, nil, nil, "", context.Background())
 			_, ls := sg.Lenses(tc.lenses)
 			for _, l := range ls {
 				var found bool
@@ -370,6 +370,21 @@ func TestJobPath(t *testing.T) {
 				BuildID: "1",
 			},
 		},
+		prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "s3-job",
+				DecorationConfig: &prowapi.DecorationConfig{
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket: "s3://chum-bucket",
+					},
+				},
+			},
+			Status: prowapi.ProwJobStatus{
+				PodName: "flying-whales",
+				BuildID: "3333",
+			},
+		},
 	}
 	fca := config.Agent{}
 	fakeJa = jobs.NewJobAgent(kc, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA"), "trusted": fpkc("clusterB")}, fca.Config)
@@ -435,11 +450,23 @@ func TestJobPath(t *testing.T) {
 			src:      "prowjob/missing-gcs-job/1",
 			expError: true,
 		},
+		{
+			name: "no job history for S3",
+			src:  "s3/chum-bucket/logs/example-job-name/123",
+		},
+		{
+			name: "no job history for the local filesystem",
+			src:  "file/srv/logs/logs/example-job-name/123",
+		},
+		{
+			name: "no job history for a Prow job in S3",
+			src:  "prowjob/s3-job/3333",
+		},
 	}
 	for _, tc := range testCases {
 		fakeGCSClient := fakeGCSServer.Client()
 		fca := config.Agent{}
-		sg := New(fakeJa, fca.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fca.Config, fakeGCSClient, nil, nil, "", context.Background())
 		jobPath, err := sg.JobPath(tc.src)
 		if tc.expError && err == nil {
 			t.Errorf("test %q: JobPath(%q) expected error", tc.name, tc.src)
@@ -455,6 +482,102 @@ func TestJobPath(t *testing.T) {
 	}
 }
 
+func TestInGCS(t *testing.T) {
+	kc := fkc{
+		prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "gcs-job",
+				DecorationConfig: &prowapi.DecorationConfig{
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket: "chum-bucket",
+					},
+				},
+			},
+			Status: prowapi.ProwJobStatus{
+				PodName: "flying-whales",
+				BuildID: "1",
+			},
+		},
+		prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "undecorated-job",
+			},
+			Status: prowapi.ProwJobStatus{
+				PodName: "flying-whales",
+				BuildID: "1",
+			},
+		},
+		prowapi.ProwJob{
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PeriodicJob,
+				Job:  "s3-job",
+				DecorationConfig: &prowapi.DecorationConfig{
+					GCSConfiguration: &prowapi.GCSConfiguration{
+						Bucket: "s3://chum-bucket",
+					},
+				},
+			},
+			Status: prowapi.ProwJobStatus{
+				PodName: "flying-whales",
+				BuildID: "1",
+			},
+		},
+	}
+	fca := config.Agent{}
+	ja := jobs.NewJobAgent(kc, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA")}, fca.Config)
+	ja.Start()
+	sg := New(ja, fca.Config, fakeGCSServer.Client(), nil, nil, "", context.Background())
+
+	testCases := []struct {
+		name     string
+		src      string
+		expected bool
+	}{
+		{
+			name:     "GCS source",
+			src:      "gcs/kubernetes-jenkins/logs/example-job-name/123/",
+			expected: true,
+		},
+		{
+			name: "S3 source",
+			src:  "s3/kubernetes-jenkins/logs/example-job-name/123",
+		},
+		{
+			name: "local source",
+			src:  "file/srv/logs/logs/example-job-name/123",
+		},
+		{
+			name:     "Prow job in GCS",
+			src:      "prowjob/gcs-job/1",
+			expected: true,
+		},
+		{
+			name:     "undecorated Prow job",
+			src:      "prowjob/undecorated-job/1",
+			expected: true,
+		},
+		{
+			name: "Prow job in S3",
+			src:  "prowjob/s3-job/1",
+		},
+		{
+			name: "nonexistent Prow job",
+			src:  "prowjob/gcs-job/2",
+		},
+		{
+			name: "invalid source",
+			src:  "gcs",
+		},
+	}
+	for _, tc := range testCases {
+		if actual := sg.InGCS(tc.src); actual != tc.expected {
+			t.Errorf("test %q: InGCS(%q) expected %t, got %t", tc.name, tc.src, tc.expected, actual)
+		}
+	}
+}
+
 func TestProwJobName(t *testing.T) {
 	kc := fkc{
 		prowapi.ProwJob{
@@ -565,7 +688,7 @@ func TestProwJobName(t *testing.T) {
 	for _, tc := range testCases {
 		fakeGCSClient := fakeGCSServer.Client()
 		fca := config.Agent{}
-		sg := New(fakeJa, fca.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fca.Config, fakeGCSClient, nil, nil, "", context.Background())
 		jobPath, err := sg.ProwJobName(tc.src)
 		if tc.expError && err == nil {
 			t.Errorf("test %q: JobPath(%q) expected error", tc.name, tc.src)
@@ -690,7 +813,7 @@ func TestRunPath(t *testing.T) {
 				},
 			},
 		})
-		sg := New(fakeJa, fca.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fca.Config, fakeGCSClient, nil, nil, "", context.Background())
 		jobPath, err := sg.RunPath(tc.src)
 		if tc.expError && err == nil {
 			t.Errorf("test %q: RunPath(%q) expected error, got  %q", tc.name, tc.src, jobPath)
@@ -850,7 +973,7 @@ func TestRunToPR(t *testing.T) {
 				},
 			},
 		})
-		sg := New(fakeJa, fca.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fca.Config, fakeGCSClient, nil, nil, "", context.Background())
 		org, repo, num, err := sg.RunToPR(tc.src)
 		if tc.expError && err == nil {
 			t.Errorf("test %q: RunToPR(%q) expected error", tc.name, tc.src)
@@ -866,27 +989,38 @@ func TestRunToPR(t *testing.T) {
 	}
 }
 
-func TestProwToGCS(t *testing.T) {
+func TestProwToStorage(t *testing.T) {
 	testCases := []struct {
-		name         string
-		key          string
-		configPrefix string
-		expectedPath string
-		expectError  bool
+		name            string
+		key             string
+		configPrefix    string
+		expectedKeyType string
+		expectedPath    string
+		expectError     bool
 	}{
 		{
-			name:         "extraction from gubernator-like URL",
-			key:          "gubernator-job/1111",
-			configPrefix: "https://gubernator.example.com/build/",
-			expectedPath: "some-bucket/gubernator-job/1111/",
-			expectError:  false,
+			name:            "extraction from gubernator-like URL",
+			key:             "gubernator-job/1111",
+			configPrefix:    "https://gubernator.example.com/build/",
+			expectedKeyType: gcsKeyType,
+			expectedPath:    "some-bucket/gubernator-job/1111/",
+			expectError:     false,
 		},
 		{
-			name:         "extraction from spyglass-like URL",
-			key:          "spyglass-job/2222",
-			configPrefix: "https://prow.example.com/view/gcs/",
-			expectedPath: "some-bucket/spyglass-job/2222/",
-			expectError:  false,
+			name:            "extraction from spyglass-like URL",
+			key:             "spyglass-job/2222",
+			configPrefix:    "https://prow.example.com/view/gcs/",
+			expectedKeyType: gcsKeyType,
+			expectedPath:    "some-bucket/spyglass-job/2222/",
+			expectError:     false,
+		},
+		{
+			name:            "extraction from spyglass-like URL for an s3 job",
+			key:             "s3-job/3333",
+			configPrefix:    "https://prow.example.com/view/gcs/",
+			expectedKeyType: s3KeyType,
+			expectedPath:    "some-bucket/s3-job/3333/",
+			expectError:     false,
 		},
 		{
 			name:         "failed extraction from wrong URL",
@@ -923,6 +1057,18 @@ func TestProwToGCS(t *testing.T) {
 					BuildID: "2222",
 				},
 			},
+			prowapi.ProwJob{
+				Spec: prowapi.ProwJobSpec{
+					Job: "s3-job",
+					DecorationConfig: &prowapi.DecorationConfig{
+						GCSConfiguration: &prowapi.GCSConfiguration{Bucket: "s3://some-bucket"},
+					},
+				},
+				Status: prowapi.ProwJobStatus{
+					URL:     "https://prow.example.com/view/s3/some-bucket/s3-job/3333/",
+					BuildID: "3333",
+				},
+			},
 		}
 
 		fakeGCSClient := fakeGCSServer.Client()
@@ -937,9 +1083,9 @@ func TestProwToGCS(t *testing.T) {
 		}
 		fakeJa = jobs.NewJobAgent(kc, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA"), "trusted": fpkc("clusterB")}, fakeConfigAgent.Config)
 		fakeJa.Start()
-		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, nil, nil, "", context.Background())
 
-		p, err := sg.prowToGCS(tc.key)
+		keyType, p, err := sg.prowToStorage(tc.key)
 		if err != nil && !tc.expectError {
 			t.Errorf("test %q: unexpected error: %v", tc.key, err)
 			continue
@@ -951,6 +1097,9 @@ func TestProwToGCS(t *testing.T) {
 		if p != tc.expectedPath {
 			t.Errorf("test %q: expected '%s' but got '%s'", tc.key, tc.expectedPath, p)
 		}
+		if !tc.expectError && keyType != tc.expectedKeyType {
+			t.Errorf("test %q: expected key type %q but got %q", tc.key, tc.expectedKeyType, keyType)
+		}
 	}
 }
 
@@ -1072,7 +1221,7 @@ func TestGCSPathRoundTrip(t *testing.T) {
 
 		fakeGCSClient := fakeGCSServer.Client()
 
-		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, nil, nil, "", context.Background())
 		gcspath, _, _ := gcsupload.PathsForJob(
 			&prowapi.GCSConfiguration{Bucket: "test-bucket", PathStrategy: tc.pathStrategy},
 			&downwardapi.JobSpec{
@@ -1184,7 +1333,7 @@ func TestTestGridLink(t *testing.T) {
 				},
 			},
 		})
-		sg := New(fakeJa, fca.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fca.Config, fakeGCSClient, nil, nil, "", context.Background())
 		sg.testgrid = &tg
 		link, err := sg.TestGridLink(tc.src)
 		if tc.expError {
@@ -1231,7 +1380,7 @@ func TestFetchArtifactsPodLog(t *testing.T) {
 
 	fakeGCSClient := fakeGCSServer.Client()
 
-	sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, "", context.Background())
+	sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, nil, nil, "", context.Background())
 	testKeys := []string{
 		"prowjob/job/123",
 		"gcs/kubernetes-jenkins/logs/job/123/",
@@ -1389,7 +1538,7 @@ func TestResolveSymlink(t *testing.T) {
 
 		fakeGCSClient := fakeGCSServer.Client()
 
-		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, "", context.Background())
+		sg := New(fakeJa, fakeConfigAgent.Config, fakeGCSClient, nil, nil, "", context.Background())
 
 		result, err := sg.ResolveSymlink(tc.path)
 		if err != nil {
@@ -1481,7 +1630,7 @@ func TestExtraLinks(t *testing.T) {
 			fakeConfigAgent := fca{}
 			fakeJa = jobs.NewJobAgent(fkc{}, map[string]jobs.PodLogClient{kube.DefaultClusterAlias: fpkc("clusterA")}, fakeConfigAgent.Config)
 			fakeJa.Start()
-			sg := New(fakeJa, fakeConfigAgent.Config, gcsClient, "", context.Background())
+			sg := New(fakeJa, fakeConfigAgent.Config, gcsClient, nil, nil, "", context.Background())
 
 			result, err := sg.ExtraLinks("gcs/test-bucket/logs/some-job/42")
 			if err != nil {
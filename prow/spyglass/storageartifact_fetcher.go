@@ -0,0 +1,192 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sirupsen/logrus"
+
+	pkgio "k8s.io/test-infra/pkg/io"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/spyglass/lenses"
+)
+
+// StorageArtifactFetcher contains information used for fetching artifacts
+// from storage providers other than GCS, such as S3 or a shared filesystem
+type StorageArtifactFetcher struct {
+	storage pkgio.Storage
+	// fileRoots are the directories of the local filesystem that artifacts
+	// of the file key type may be read from. No local artifacts are served
+	// without any.
+	fileRoots []string
+}
+
+// NewStorageArtifactFetcher creates a new ArtifactFetcher backed by the given storage
+// that serves local artifacts from under the given roots only.
+func NewStorageArtifactFetcher(s pkgio.Storage, fileRoots []string) *StorageArtifactFetcher {
+	af := &StorageArtifactFetcher{storage: s}
+	for _, root := range fileRoots {
+		af.fileRoots = append(af.fileRoots, filepath.Clean("/"+root))
+	}
+	return af
+}
+
+// LocalArtifactPath is the path deck serves local artifacts under, as a path
+// on the filesystem of deck is of no use as a link to a browser.
+const LocalArtifactPath = "/spyglass/local/"
+
+// storageKeyTypes maps storage providers to the key types spyglass uses for them
+var storageKeyTypes = map[string]string{
+	prowapi.StorageProviderGCS:  gcsKeyType,
+	prowapi.StorageProviderS3:   s3KeyType,
+	prowapi.StorageProviderFile: fileKeyType,
+}
+
+// isStorageKeyType returns whether keys of this type are paths in a storage provider
+func isStorageKeyType(keyType string) bool {
+	for _, t := range storageKeyTypes {
+		if t == keyType {
+			return true
+		}
+	}
+	return false
+}
+
+// storagePath returns the path the opener uses for the key. Keys of the file
+// type are absolute paths with the leading slash removed.
+func storagePath(keyType, key string) string {
+	if keyType == fileKeyType {
+		return "file:///" + key
+	}
+	return keyType + "://" + key
+}
+
+// checkKey rejects keys of the file type outside of the configured roots.
+func (af *StorageArtifactFetcher) checkKey(keyType, key string) error {
+	if keyType != fileKeyType {
+		return nil
+	}
+	if len(af.fileRoots) == 0 {
+		return errors.New("local artifacts are not enabled")
+	}
+	dir := filepath.Clean("/" + key)
+	for _, root := range af.fileRoots {
+		if dir == root || strings.HasPrefix(dir, strings.TrimSuffix(root, "/")+"/") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not under any of the local artifact roots", dir)
+}
+
+// artifactPath joins the key and the name of an artifact, rejecting names
+// that resolve outside of the key.
+func artifactPath(key, artifactName string) (string, error) {
+	dir := filepath.Clean("/" + key)
+	p := filepath.Clean(dir + "/" + artifactName)
+	if !strings.HasPrefix(p, strings.TrimSuffix(dir, "/")+"/") {
+		return "", fmt.Errorf("artifact %q is outside of %s", artifactName, key)
+	}
+	return strings.TrimPrefix(p, "/"), nil
+}
+
+// artifacts lists all artifacts available for the given job source
+func (af *StorageArtifactFetcher) artifacts(keyType, key string) ([]string, error) {
+	if af.storage == nil {
+		return nil, fmt.Errorf("no storage is configured for %s artifacts", keyType)
+	}
+	if err := af.checkKey(keyType, key); err != nil {
+		return nil, err
+	}
+	listStart := time.Now()
+	prefix := storagePath(keyType, strings.TrimSuffix(key, "/")+"/")
+	artifacts, err := af.storage.List(context.Background(), prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", prefix, err)
+	}
+	logrus.WithField("duration", time.Since(listStart).String()).Infof("Listed %d artifacts.", len(artifacts))
+	return artifacts, nil
+}
+
+// artifact constructs an artifact for the given key. As with GCS, no I/O is
+// done until the artifact is read, so a missing artifact only fails then.
+func (af *StorageArtifactFetcher) artifact(keyType, key, artifactName string, sizeLimit int64) (lenses.Artifact, error) {
+	if af.storage == nil {
+		return nil, fmt.Errorf("no storage is configured for %s artifacts", keyType)
+	}
+	if err := af.checkKey(keyType, key); err != nil {
+		return nil, err
+	}
+	name, err := artifactPath(key, artifactName)
+	if err != nil {
+		return nil, err
+	}
+	p := storagePath(keyType, name)
+	var link string
+	if keyType == fileKeyType {
+		link = (&url.URL{Path: LocalArtifactPath + name}).String()
+	} else if link, err = af.storage.SignedURL(context.Background(), p); err != nil {
+		return nil, err
+	}
+	handle := &storageArtifactHandle{storage: af.storage, path: p}
+	return NewGCSArtifact(context.Background(), handle, link, artifactName, sizeLimit), nil
+}
+
+// LocalArtifact opens the artifact at the given path of the local filesystem,
+// which must be under one of the local artifact roots.
+func (af *StorageArtifactFetcher) LocalArtifact(ctx context.Context, name string) (io.ReadCloser, error) {
+	if af.storage == nil {
+		return nil, errors.New("no storage is configured for local artifacts")
+	}
+	p := filepath.Clean("/" + name)
+	if err := af.checkKey(fileKeyType, filepath.Dir(p)); err != nil {
+		return nil, err
+	}
+	return af.storage.Reader(ctx, storagePath(fileKeyType, strings.TrimPrefix(p, "/")))
+}
+
+type storageArtifactHandle struct {
+	storage pkgio.Storage
+	path    string
+}
+
+func (h *storageArtifactHandle) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	attrs, err := h.storage.Attributes(ctx, h.path)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.ObjectAttrs{
+		Size:            attrs.Size,
+		ContentEncoding: attrs.ContentEncoding,
+	}, nil
+}
+
+func (h *storageArtifactHandle) NewReader(ctx context.Context) (io.ReadCloser, error) {
+	return h.storage.Reader(ctx, h.path)
+}
+
+func (h *storageArtifactHandle) NewRangeReader(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	return h.storage.RangeReader(ctx, h.path, offset, length)
+}
@@ -0,0 +1,335 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spyglass
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	pkgio "k8s.io/test-infra/pkg/io"
+)
+
+// fakeStorage is an in-memory pkgio.Storage keyed on full paths
+type fakeStorage map[string]string
+
+func (s fakeStorage) Reader(ctx context.Context, path string) (io.ReadCloser, error) {
+	return s.RangeReader(ctx, path, 0, -1)
+}
+
+func (s fakeStorage) Writer(ctx context.Context, path string) (io.WriteCloser, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s fakeStorage) RangeReader(_ context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	content, ok := s[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	content = content[offset:]
+	if length >= 0 && int(length) < len(content) {
+		content = content[:length]
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s fakeStorage) Attributes(_ context.Context, path string) (pkgio.Attributes, error) {
+	content, ok := s[path]
+	if !ok {
+		return pkgio.Attributes{}, os.ErrNotExist
+	}
+	return pkgio.Attributes{Size: int64(len(content))}, nil
+}
+
+func (s fakeStorage) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	for path := range s {
+		if strings.HasPrefix(path, prefix) {
+			names = append(names, strings.TrimPrefix(path, prefix))
+		}
+	}
+	return names, nil
+}
+
+func (s fakeStorage) SignedURL(_ context.Context, path string) (string, error) {
+	return "https://signed.example.com/" + path, nil
+}
+
+func (s fakeStorage) Delete(_ context.Context, path string) error {
+	if _, ok := s[path]; !ok {
+		return os.ErrNotExist
+	}
+	delete(s, path)
+	return nil
+}
+
+func TestStorageArtifactFetcher(t *testing.T) {
+	storage := fakeStorage{
+		"s3://bucket/logs/job/1/build-log.txt":             "hello world",
+		"s3://bucket/logs/job/1/artifacts/junit.xml":       "<testsuite/>",
+		"s3://bucket/logs/job/2/build-log.txt":             "other build",
+		"file:///srv/logs/logs/job/1/build-log.txt":        "local build",
+		"file:///srv/logs/logs/job/1/artifacts/junit.xml":  "<testsuite/>",
+		"file:///srv/logs/logs/job/10/artifacts/junit.xml": "<testsuite/>",
+	}
+	af := NewStorageArtifactFetcher(storage, []string{"/srv/logs/"})
+
+	testCases := []struct {
+		name              string
+		keyType           string
+		key               string
+		expectedArtifacts []string
+		expectedLog       string
+		expectedLink      string
+	}{
+		{
+			name:              "s3 artifacts",
+			keyType:           s3KeyType,
+			key:               "bucket/logs/job/1",
+			expectedArtifacts: []string{"artifacts/junit.xml", "build-log.txt"},
+			expectedLog:       "hello world",
+			expectedLink:      "https://signed.example.com/s3://bucket/logs/job/1/build-log.txt",
+		},
+		{
+			name:              "file artifacts are rooted at an absolute path",
+			keyType:           fileKeyType,
+			key:               "srv/logs/logs/job/1/",
+			expectedArtifacts: []string{"artifacts/junit.xml", "build-log.txt"},
+			expectedLog:       "local build",
+			expectedLink:      "/spyglass/local/srv/logs/logs/job/1/build-log.txt",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			artifacts, err := af.artifacts(tc.keyType, tc.key)
+			if err != nil {
+				t.Fatalf("failed to list artifacts: %v", err)
+			}
+			sort.Strings(artifacts)
+			if !reflect.DeepEqual(artifacts, tc.expectedArtifacts) {
+				t.Errorf("expected artifacts %v, got %v", tc.expectedArtifacts, artifacts)
+			}
+
+			art, err := af.artifact(tc.keyType, tc.key, "build-log.txt", 500e6)
+			if err != nil {
+				t.Fatalf("failed to get artifact: %v", err)
+			}
+			content, err := art.ReadAll()
+			if err != nil {
+				t.Fatalf("failed to read artifact: %v", err)
+			}
+			if string(content) != tc.expectedLog {
+				t.Errorf("expected content %q, got %q", tc.expectedLog, string(content))
+			}
+			if art.CanonicalLink() != tc.expectedLink {
+				t.Errorf("expected link %q, got %q", tc.expectedLink, art.CanonicalLink())
+			}
+
+			missing, err := af.artifact(tc.keyType, tc.key, "missing.txt", 500e6)
+			if err != nil {
+				t.Fatalf("failed to get artifact handle: %v", err)
+			}
+			if _, err := missing.Size(); err == nil {
+				t.Error("expected an error reading the size of a missing artifact")
+			}
+		})
+	}
+}
+
+func TestStorageArtifactFetcherRejectsEscapes(t *testing.T) {
+	storage := fakeStorage{
+		"file:///srv/logs/logs/job/1/build-log.txt": "local build",
+		"file:///etc/secret/token":                  "secret",
+		"file:///srv/logs-other/token":              "secret",
+		"s3://bucket/logs/job/1/build-log.txt":      "hello world",
+		"s3://bucket/token":                         "secret",
+	}
+
+	testCases := []struct {
+		name         string
+		roots        []string
+		keyType      string
+		key          string
+		artifactName string
+		listErr      bool
+		fetchErr     bool
+	}{
+		{
+			name:         "local artifacts under a root",
+			roots:        []string{"/srv/logs"},
+			keyType:      fileKeyType,
+			key:          "srv/logs/logs/job/1",
+			artifactName: "build-log.txt",
+		},
+		{
+			name:         "local artifacts are disabled without roots",
+			keyType:      fileKeyType,
+			key:          "srv/logs/logs/job/1",
+			artifactName: "build-log.txt",
+			listErr:      true,
+			fetchErr:     true,
+		},
+		{
+			name:         "key outside of the roots",
+			roots:        []string{"/srv/logs"},
+			keyType:      fileKeyType,
+			key:          "etc/secret",
+			artifactName: "token",
+			listErr:      true,
+			fetchErr:     true,
+		},
+		{
+			name:         "key sharing a prefix with a root",
+			roots:        []string{"/srv/logs"},
+			keyType:      fileKeyType,
+			key:          "srv/logs-other",
+			artifactName: "token",
+			listErr:      true,
+			fetchErr:     true,
+		},
+		{
+			name:         "key escaping the root",
+			roots:        []string{"/srv/logs"},
+			keyType:      fileKeyType,
+			key:          "srv/logs/../../etc/secret",
+			artifactName: "token",
+			listErr:      true,
+			fetchErr:     true,
+		},
+		{
+			name:         "local artifact name escaping the key",
+			roots:        []string{"/srv/logs"},
+			keyType:      fileKeyType,
+			key:          "srv/logs/logs/job/1",
+			artifactName: "../../../../../etc/secret/token",
+			fetchErr:     true,
+		},
+		{
+			name:         "s3 artifact name escaping the key",
+			keyType:      s3KeyType,
+			key:          "bucket/logs/job/1",
+			artifactName: "../../../token",
+			fetchErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			af := NewStorageArtifactFetcher(storage, tc.roots)
+			if _, err := af.artifacts(tc.keyType, tc.key); (err != nil) != tc.listErr {
+				t.Errorf("expected listing error: %t, got: %v", tc.listErr, err)
+			}
+			art, err := af.artifact(tc.keyType, tc.key, tc.artifactName, 500e6)
+			if (err != nil) != tc.fetchErr {
+				t.Fatalf("expected fetching error: %t, got: %v", tc.fetchErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if content, err := art.ReadAll(); err != nil || string(content) == "secret" {
+				t.Errorf("expected to read the artifact, got %q: %v", string(content), err)
+			}
+		})
+	}
+}
+
+func TestLocalArtifact(t *testing.T) {
+	storage := fakeStorage{
+		"file:///srv/logs/logs/job/1/build-log.txt": "local build",
+		"file:///srv/logs/token":                    "root file",
+		"file:///etc/secret/token":                  "secret",
+		"file:///srv/logs-other/token":              "secret",
+	}
+
+	testCases := []struct {
+		name            string
+		roots           []string
+		artifact        string
+		expectedContent string
+		expectedErr     bool
+	}{
+		{
+			name:            "artifact under a root",
+			roots:           []string{"/srv/logs"},
+			artifact:        "srv/logs/logs/job/1/build-log.txt",
+			expectedContent: "local build",
+		},
+		{
+			name:            "artifact directly in a root",
+			roots:           []string{"/srv/logs"},
+			artifact:        "srv/logs/token",
+			expectedContent: "root file",
+		},
+		{
+			name:        "local artifacts are disabled without roots",
+			artifact:    "srv/logs/logs/job/1/build-log.txt",
+			expectedErr: true,
+		},
+		{
+			name:        "artifact outside of the roots",
+			roots:       []string{"/srv/logs"},
+			artifact:    "etc/secret/token",
+			expectedErr: true,
+		},
+		{
+			name:        "artifact sharing a prefix with a root",
+			roots:       []string{"/srv/logs"},
+			artifact:    "srv/logs-other/token",
+			expectedErr: true,
+		},
+		{
+			name:        "artifact escaping the root",
+			roots:       []string{"/srv/logs"},
+			artifact:    "srv/logs/../../etc/secret/token",
+			expectedErr: true,
+		},
+		{
+			name:        "missing artifact",
+			roots:       []string{"/srv/logs"},
+			artifact:    "srv/logs/logs/job/1/missing.txt",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			af := NewStorageArtifactFetcher(storage, tc.roots)
+			rc, err := af.LocalArtifact(context.Background(), tc.artifact)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error: %t, got: %v", tc.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+			content, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("failed to read artifact: %v", err)
+			}
+			if string(content) != tc.expectedContent {
+				t.Errorf("expected content %q, got %q", tc.expectedContent, string(content))
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/git"
+)
+
+const (
+	// conflictMarker starts the comments on PRs that are predicted to
+	// conflict with a PR ahead of them in the pool.
+	conflictMarker = "<!-- tide: conflicts ahead -->"
+	// maxConflictChecks is the number of pairs of PRs of a pool that are
+	// merged per sync. The other pairs are merged in later syncs, from the
+	// pairs of the PRs with the highest priority.
+	maxConflictChecks = 100
+)
+
+type conflictClient interface {
+	AddLabel(org, repo string, number int, label string) error
+	RemoveLabel(org, repo string, number int, label string) error
+	CreateComment(org, repo string, number int, comment string) error
+	Query(ctx context.Context, q interface{}, vars map[string]interface{}) error
+}
+
+// conflictKey identifies a pair of PRs by their head SHAs, in order.
+type conflictKey struct {
+	a, b string
+}
+
+func newConflictKey(a, b *PullRequest) conflictKey {
+	shaA, shaB := string(a.HeadRefOID), string(b.HeadRefOID)
+	if shaB < shaA {
+		shaA, shaB = shaB, shaA
+	}
+	return conflictKey{a: shaA, b: shaB}
+}
+
+// conflictAgent predicts which PRs of a pool conflict with each other by
+// merging them pairwise, and caches whether they conflict by the head SHAs
+// of the pairs. Cache entries expire if they are not used during a sync loop.
+type conflictAgent struct {
+	ghc conflictClient
+	// maxChecks is the number of pairs of a pool that are merged per sync.
+	maxChecks int
+	cache     map[conflictKey]bool
+	// nextCache caches the pairs that are relevant this sync for use next
+	// sync. This becomes the new cache when prune() is called at the end of
+	// each sync.
+	nextCache map[conflictKey]bool
+	sync.Mutex
+}
+
+func newConflictAgent(ghc conflictClient) *conflictAgent {
+	return &conflictAgent{
+		ghc:       ghc,
+		maxChecks: maxConflictChecks,
+		cache:     make(map[conflictKey]bool),
+		nextCache: make(map[conflictKey]bool),
+	}
+}
+
+// lookup returns whether the PRs conflict, if known.
+func (a *conflictAgent) lookup(key conflictKey) (conflict, ok bool) {
+	a.Lock()
+	defer a.Unlock()
+	if conflict, ok = a.nextCache[key]; ok {
+		return conflict, true
+	}
+	if conflict, ok = a.cache[key]; ok {
+		a.nextCache[key] = conflict
+	}
+	return conflict, ok
+}
+
+func (a *conflictAgent) add(key conflictKey, conflict bool) {
+	a.Lock()
+	defer a.Unlock()
+	a.nextCache[key] = conflict
+}
+
+// prune removes any cached pairs that were not used since the last prune.
+func (a *conflictAgent) prune() {
+	a.Lock()
+	defer a.Unlock()
+	a.cache = a.nextCache
+	a.nextCache = make(map[conflictKey]bool)
+}
+
+// predictConflicts returns the PRs of the subpool that are predicted to
+// conflict with each other, by PR number, if the repo opted in. Pairs of
+// PRs that were not merged together before are merged on top of the base,
+// up to the maxChecks of the agent. The PRs must be sorted by priority.
+func (c *Controller) predictConflicts(sp *subpool) (map[int]sets.Int, error) {
+	if c.conflicts == nil || !c.config().Tide.ConflictPredictionEnabled(sp.org, sp.repo) {
+		return nil, nil
+	}
+
+	var unknown [][2]PullRequest
+	conflicts := map[int]sets.Int{}
+	addConflict := func(a, b *PullRequest) {
+		for _, pair := range [][2]*PullRequest{{a, b}, {b, a}} {
+			number := int(pair[0].Number)
+			if conflicts[number] == nil {
+				conflicts[number] = sets.NewInt()
+			}
+			conflicts[number].Insert(int(pair[1].Number))
+		}
+	}
+	var deferred int
+	for i := range sp.prs {
+		for j := i + 1; j < len(sp.prs); j++ {
+			conflict, ok := c.conflicts.lookup(newConflictKey(&sp.prs[i], &sp.prs[j]))
+			if !ok {
+				if len(unknown) >= c.conflicts.maxChecks {
+					deferred++
+					continue
+				}
+				unknown = append(unknown, [2]PullRequest{sp.prs[i], sp.prs[j]})
+			} else if conflict {
+				addConflict(&sp.prs[i], &sp.prs[j])
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return conflicts, nil
+	}
+	if deferred > 0 {
+		sp.log.WithField("deferred-pairs", deferred).Info("Deferring conflict checks of PRs to the next syncs.")
+	}
+
+	r, err := c.providerOf(sp.org).clone(sp.org, sp.repo)
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		// The provider cannot check merges.
+		return nil, nil
+	}
+	defer r.Clean()
+	if err := prepareMerges(sp, r); err != nil {
+		return nil, err
+	}
+	// PRs that do not merge cleanly on top of the base by themselves need a
+	// rebase anyway and are not predicted to conflict with other PRs.
+	clean := map[int]bool{}
+	for _, pair := range unknown {
+		for _, pr := range pair {
+			if _, checked := clean[int(pr.Number)]; checked {
+				continue
+			}
+			if err := r.Checkout(sp.sha); err != nil {
+				return nil, err
+			}
+			ok, err := r.Merge(string(pr.HeadRefOID))
+			if err != nil {
+				return nil, err
+			}
+			clean[int(pr.Number)] = ok
+		}
+	}
+	for _, pair := range unknown {
+		if !clean[int(pair[0].Number)] || !clean[int(pair[1].Number)] {
+			c.conflicts.add(newConflictKey(&pair[0], &pair[1]), false)
+			continue
+		}
+		conflict, err := mergeConflict(sp, r, pair[0], pair[1])
+		if err != nil {
+			return nil, err
+		}
+		c.conflicts.add(newConflictKey(&pair[0], &pair[1]), conflict)
+		if conflict {
+			addConflict(&pair[0], &pair[1])
+		}
+	}
+	sp.log.WithField("conflicting-prs", len(conflicts)).Debug("Predicted conflicts between PRs.")
+	return conflicts, nil
+}
+
+// mergeConflict returns whether the second PR does not merge cleanly on top
+// of the base and the first PR.
+func mergeConflict(sp *subpool, r *git.Repo, first, second PullRequest) (bool, error) {
+	if err := r.Checkout(sp.sha); err != nil {
+		return false, err
+	}
+	if ok, err := r.Merge(string(first.HeadRefOID)); err != nil {
+		return false, err
+	} else if !ok {
+		return false, fmt.Errorf("PR #%d does not merge cleanly anymore", first.Number)
+	}
+	ok, err := r.Merge(string(second.HeadRefOID))
+	return !ok, err
+}
+
+// labelConflicts labels and comments on the PRs of the subpool that conflict
+// with a PR ahead of them in the pool, and unlabels the PRs that do not
+// anymore. The PRs must be sorted by priority.
+func (c *Controller) labelConflicts(sp *subpool, conflicts map[int]sets.Int) {
+	if c.conflicts == nil || c.conflicts.ghc == nil || conflicts == nil {
+		return
+	}
+	label := c.config().Tide.ConflictLabel
+	ahead := sets.NewInt()
+	for _, pr := range sp.prs {
+		number := int(pr.Number)
+		var conflictsAhead []string
+		for _, other := range conflicts[number].Intersection(ahead).List() {
+			conflictsAhead = append(conflictsAhead, fmt.Sprintf("#%d", other))
+		}
+		ahead.Insert(number)
+
+		labeled := false
+		for _, l := range pr.Labels.Nodes {
+			if string(l.Name) == label {
+				labeled = true
+			}
+		}
+		log := sp.log.WithFields(pr.logFields())
+		if len(conflictsAhead) > 0 && !labeled {
+			if err := c.conflicts.ghc.AddLabel(sp.org, sp.repo, number, label); err != nil {
+				log.WithError(err).Warn("Failed to label a PR that conflicts with a PR ahead of it.")
+				continue
+			}
+			comment := fmt.Sprintf("%s\nThis PR is predicted to conflict with %s, which is ahead of it in the merge pool. "+
+				"Tide does not test it together with the conflicting PRs, and it will need a rebase once they merge.",
+				conflictMarker, strings.Join(conflictsAhead, ", "))
+			if err := c.conflicts.ghc.CreateComment(sp.org, sp.repo, number, comment); err != nil {
+				log.WithError(err).Warn("Failed to comment on a PR that conflicts with a PR ahead of it.")
+			}
+		} else if len(conflictsAhead) == 0 && labeled {
+			if err := c.conflicts.ghc.RemoveLabel(sp.org, sp.repo, number, label); err != nil {
+				log.WithError(err).Warn("Failed to unlabel a PR that does not conflict with a PR ahead of it anymore.")
+			}
+		}
+	}
+	c.unlabelUnpooledConflicts(sp, ahead, label)
+}
+
+// unlabelUnpooledConflicts unlabels the PRs of the branch that left the pool,
+// since they are not predicted to conflict with the pool anymore.
+func (c *Controller) unlabelUnpooledConflicts(sp *subpool, pooled sets.Int, label string) {
+	q := fmt.Sprintf("is:pr state:open repo:\"%s/%s\" base:\"%s\" label:\"%s\"", sp.org, sp.repo, sp.branch, label)
+	labeled, err := search(c.conflicts.ghc.Query, sp.log, q, time.Time{}, time.Now())
+	if err != nil {
+		sp.log.WithError(err).Warn("Failed to search for the PRs labeled as conflicting.")
+		return
+	}
+	for _, pr := range labeled {
+		if pooled.Has(int(pr.Number)) {
+			continue
+		}
+		if err := c.conflicts.ghc.RemoveLabel(sp.org, sp.repo, int(pr.Number), label); err != nil {
+			sp.log.WithError(err).WithFields(pr.logFields()).Warn("Failed to unlabel a PR that left the pool.")
+		}
+	}
+}
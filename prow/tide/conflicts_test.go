@@ -0,0 +1,288 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/localgit"
+)
+
+type fakeConflictClient struct {
+	added    []int
+	removed  []int
+	comments map[int]string
+	// labeled are the PRs found by searching for the label.
+	labeled []PullRequest
+}
+
+func (f *fakeConflictClient) Query(ctx context.Context, q interface{}, vars map[string]interface{}) error {
+	sq, ok := q.(*searchQuery)
+	if !ok {
+		return errors.New("unexpected query type")
+	}
+	for _, pr := range f.labeled {
+		sq.Search.Nodes = append(sq.Search.Nodes, struct {
+			PullRequest PullRequest `graphql:"... on PullRequest"`
+		}{PullRequest: pr})
+	}
+	return nil
+}
+
+func (f *fakeConflictClient) AddLabel(org, repo string, number int, label string) error {
+	f.added = append(f.added, number)
+	return nil
+}
+
+func (f *fakeConflictClient) RemoveLabel(org, repo string, number int, label string) error {
+	f.removed = append(f.removed, number)
+	return nil
+}
+
+func (f *fakeConflictClient) CreateComment(org, repo string, number int, comment string) error {
+	f.comments[number] = comment
+	return nil
+}
+
+func TestPredictConflicts(t *testing.T) {
+	lg, gc, err := localgit.New()
+	if err != nil {
+		t.Fatalf("Error making local git: %v", err)
+	}
+	defer gc.Clean()
+	defer lg.Clean()
+	if err := lg.MakeFakeRepo("o", "r"); err != nil {
+		t.Fatalf("Error making fake repo: %v", err)
+	}
+	if err := lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("foo")}); err != nil {
+		t.Fatalf("Adding initial commit: %v", err)
+	}
+	// Check out the base by SHA, like Tide does, rather than by branch.
+	baseSHA, err := lg.RevParse("o", "r", "HEAD")
+	if err != nil {
+		t.Fatalf("Error getting the base SHA: %v", err)
+	}
+	testprs := []struct {
+		number int
+		files  map[string][]byte
+		labels []string
+	}{
+		{number: 1, files: map[string][]byte{"bar": []byte("ok")}},
+		{number: 2, files: map[string][]byte{"bar": []byte("conflicts with 1")}},
+		{number: 3, files: map[string][]byte{"baz": []byte("ok")}, labels: []string{config.DefaultConflictLabel}},
+		{number: 4, files: map[string][]byte{"bar": []byte("conflicts with 1 and 2")}, labels: []string{config.DefaultConflictLabel}},
+	}
+	sp := &subpool{
+		log:    logrus.WithField("component", "tide"),
+		org:    "o",
+		repo:   "r",
+		branch: "master",
+		sha:    strings.TrimSpace(baseSHA),
+	}
+	for _, testpr := range testprs {
+		if err := lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", testpr.number)); err != nil {
+			t.Fatalf("Error checking out new branch: %v", err)
+		}
+		if err := lg.AddCommit("o", "r", testpr.files); err != nil {
+			t.Fatalf("Error adding commit: %v", err)
+		}
+		if err := lg.Checkout("o", "r", "master"); err != nil {
+			t.Fatalf("Error checking out master: %v", err)
+		}
+		var pr PullRequest
+		pr.Number = githubql.Int(testpr.number)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("origin/pr-%d", testpr.number))
+		for _, label := range testpr.labels {
+			pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+		}
+		sp.prs = append(sp.prs, pr)
+	}
+	cfg := &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{
+		ConflictPredictionMap: map[string]bool{"o": true},
+		ConflictLabel:         config.DefaultConflictLabel,
+	}}}
+	// PR 5 left the pool but is still labeled.
+	var unpooled PullRequest
+	unpooled.Number = 5
+	ghc := &fakeConflictClient{comments: map[int]string{}, labeled: []PullRequest{sp.prs[2], sp.prs[3], unpooled}}
+	c := &Controller{
+		logger:    logrus.WithField("component", "tide"),
+		gc:        gc,
+		config:    func() *config.Config { return cfg },
+		conflicts: newConflictAgent(ghc),
+	}
+
+	expected := map[int]sets.Int{
+		1: sets.NewInt(2, 4),
+		2: sets.NewInt(1, 4),
+		4: sets.NewInt(1, 2),
+	}
+	conflicts, err := c.predictConflicts(sp)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected conflicts %v, got %v", expected, conflicts)
+	}
+
+	// The second prediction is served from the cache, without a clone.
+	c.conflicts.prune()
+	c.gc = nil
+	if conflicts, err = c.predictConflicts(sp); err != nil {
+		t.Fatalf("Unexpected error predicting cached conflicts: %v", err)
+	}
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("Expected cached conflicts %v, got %v", expected, conflicts)
+	}
+
+	c.labelConflicts(sp, conflicts)
+	if expected := []int{2}; !reflect.DeepEqual(ghc.added, expected) {
+		t.Errorf("Expected the PRs %v to be labeled, got %v", expected, ghc.added)
+	}
+	if expected := []int{3, 5}; !reflect.DeepEqual(ghc.removed, expected) {
+		t.Errorf("Expected the PRs %v to be unlabeled, got %v", expected, ghc.removed)
+	}
+	if comment := ghc.comments[2]; !strings.HasPrefix(comment, conflictMarker) || !strings.Contains(comment, "#1") {
+		t.Errorf("Expected a comment on #2 about #1, got %q", comment)
+	}
+	if len(ghc.comments) != 1 {
+		t.Errorf("Expected only #2 to be commented on, got %v", ghc.comments)
+	}
+}
+
+func TestPredictConflictsDefersChecks(t *testing.T) {
+	lg, gc, err := localgit.New()
+	if err != nil {
+		t.Fatalf("Error making local git: %v", err)
+	}
+	defer gc.Clean()
+	defer lg.Clean()
+	if err := lg.MakeFakeRepo("o", "r"); err != nil {
+		t.Fatalf("Error making fake repo: %v", err)
+	}
+	if err := lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("foo")}); err != nil {
+		t.Fatalf("Adding initial commit: %v", err)
+	}
+	baseSHA, err := lg.RevParse("o", "r", "HEAD")
+	if err != nil {
+		t.Fatalf("Error getting the base SHA: %v", err)
+	}
+	sp := &subpool{
+		log:    logrus.WithField("component", "tide"),
+		org:    "o",
+		repo:   "r",
+		branch: "master",
+		sha:    strings.TrimSpace(baseSHA),
+	}
+	// All PRs conflict with each other.
+	for number := 1; number <= 3; number++ {
+		if err := lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", number)); err != nil {
+			t.Fatalf("Error checking out new branch: %v", err)
+		}
+		if err := lg.AddCommit("o", "r", map[string][]byte{"bar": []byte(fmt.Sprintf("pr %d", number))}); err != nil {
+			t.Fatalf("Error adding commit: %v", err)
+		}
+		if err := lg.Checkout("o", "r", "master"); err != nil {
+			t.Fatalf("Error checking out master: %v", err)
+		}
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("origin/pr-%d", number))
+		sp.prs = append(sp.prs, pr)
+	}
+	cfg := &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{
+		ConflictPredictionMap: map[string]bool{"o": true},
+	}}}
+	c := &Controller{
+		logger:    logrus.WithField("component", "tide"),
+		gc:        gc,
+		config:    func() *config.Config { return cfg },
+		conflicts: newConflictAgent(&fakeConflictClient{}),
+	}
+	c.conflicts.maxChecks = 2
+
+	for _, expected := range []map[int]sets.Int{
+		// The pairs of the first PR are checked first.
+		{1: sets.NewInt(2, 3), 2: sets.NewInt(1), 3: sets.NewInt(1)},
+		{1: sets.NewInt(2, 3), 2: sets.NewInt(1, 3), 3: sets.NewInt(1, 2)},
+	} {
+		conflicts, err := c.predictConflicts(sp)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(conflicts, expected) {
+			t.Errorf("Expected conflicts %v, got %v", expected, conflicts)
+		}
+		c.conflicts.prune()
+	}
+}
+
+func TestMergeCleanlySkipsPredictedConflicts(t *testing.T) {
+	lg, gc, err := localgit.New()
+	if err != nil {
+		t.Fatalf("Error making local git: %v", err)
+	}
+	defer gc.Clean()
+	defer lg.Clean()
+	if err := lg.MakeFakeRepo("o", "r"); err != nil {
+		t.Fatalf("Error making fake repo: %v", err)
+	}
+	var prs []PullRequest
+	for number := 1; number <= 3; number++ {
+		if err := lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", number)); err != nil {
+			t.Fatalf("Error checking out new branch: %v", err)
+		}
+		if err := lg.AddCommit("o", "r", map[string][]byte{fmt.Sprintf("file-%d", number): []byte("ok")}); err != nil {
+			t.Fatalf("Error adding commit: %v", err)
+		}
+		if err := lg.Checkout("o", "r", "master"); err != nil {
+			t.Fatalf("Error checking out master: %v", err)
+		}
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("origin/pr-%d", number))
+		prs = append(prs, pr)
+	}
+	r, err := gc.Clone("o/r")
+	if err != nil {
+		t.Fatalf("Error cloning: %v", err)
+	}
+	sp := subpool{
+		log:       logrus.WithField("component", "tide"),
+		org:       "o",
+		repo:      "r",
+		sha:       "master",
+		conflicts: map[int]sets.Int{1: sets.NewInt(2), 2: sets.NewInt(1)},
+	}
+	res, err := mergeCleanly(sp, r, nil, prs, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if numbers := prNumbers(res); !reflect.DeepEqual(numbers, []int{1, 3}) {
+		t.Errorf("Expected PRs [1 3] to be batched, got %v", numbers)
+	}
+}
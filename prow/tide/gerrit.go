@@ -0,0 +1,266 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	gerritclient "k8s.io/test-infra/prow/gerrit/client"
+	"k8s.io/test-infra/prow/git"
+	"k8s.io/test-infra/prow/github"
+)
+
+// gerritClient is the subset of the Gerrit client that tide uses to pool and
+// submit changes.
+type gerritClient interface {
+	SearchChanges(instance, query string, rateLimit int) ([]gerritclient.ChangeInfo, error)
+	GetBranchRevision(instance, project, branch string) (string, error)
+	SubmitChange(instance, id string) error
+}
+
+// gerritProvider sources PRs from the changes of Gerrit instances and
+// submits them through the Gerrit REST API. The org of these PRs is the
+// host of the instance and the repo is the project, just like in the refs
+// of the jobs that the Gerrit adapter triggers.
+type gerritProvider struct {
+	gc     gerritClient
+	config config.Getter
+
+	// newGitClient creates the git client of an instance.
+	newGitClient func(instance string) (*git.Client, error)
+	lock         sync.Mutex
+	// gitClients clone the projects of the instances, by instance.
+	gitClients map[string]*git.Client
+}
+
+func newGerritProvider(gc gerritClient, cfg config.Getter) *gerritProvider {
+	return &gerritProvider{
+		gc:     gc,
+		config: cfg,
+		newGitClient: func(instance string) (*git.Client, error) {
+			client, err := git.NewClient()
+			if err != nil {
+				return nil, err
+			}
+			client.SetRemote(instance)
+			return client, nil
+		},
+		gitClients: map[string]*git.Client{},
+	}
+}
+
+// gerritHost returns the host of the Gerrit instance, which is the org of
+// its changes in tide.
+func gerritHost(instance string) string {
+	u, err := url.Parse(instance)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// query returns the Gerrit query of the instance the org is the host of.
+func (p *gerritProvider) query(org string) *config.TideGerritQuery {
+	queries := p.config().Tide.GerritQueries
+	for i := range queries {
+		if gerritHost(queries[i].Instance) == org {
+			return &queries[i]
+		}
+	}
+	return nil
+}
+
+// owns returns whether the org is the host of a Gerrit instance.
+func (p *gerritProvider) owns(org string) bool {
+	return p.query(org) != nil
+}
+
+// instance returns the URL of the Gerrit instance the org is the host of.
+func (p *gerritProvider) instance(org string) string {
+	if q := p.query(org); q != nil {
+		return q.Instance
+	}
+	return ""
+}
+
+// gerritInstance returns the URL of the Gerrit instance the org is the host
+// of, or the empty string for GitHub orgs.
+func (c *Controller) gerritInstance(org string) string {
+	if c.gerrit == nil {
+		return ""
+	}
+	return c.gerrit.instance(org)
+}
+
+// search returns the changes selected by the Gerrit queries as PRs. The
+// files changed by the changes are added to the cache of changed files, as
+// they are listed along with the changes.
+func (p *gerritProvider) search(log *logrus.Entry, changedFiles *changedFilesAgent) []PullRequest {
+	cfg := p.config()
+	var prs []PullRequest
+	for _, q := range cfg.Tide.GerritQueries {
+		host := gerritHost(q.Instance)
+		for _, project := range q.Projects {
+			query := q.Query(project)
+			changes, err := p.gc.SearchChanges(q.Instance, query, cfg.Gerrit.RateLimit)
+			if err != nil {
+				// Do not block the other pools on one failing instance.
+				log.WithError(err).WithField("query", query).Warning("Failed to search Gerrit changes.")
+				continue
+			}
+			for _, change := range changes {
+				pr, files, err := gerritPullRequest(host, q.RequiredLabels(), change)
+				if err != nil {
+					log.WithError(err).WithField("change", change.Number).Warning("Ignoring Gerrit change.")
+					continue
+				}
+				changedFiles.add(&pr, files)
+				prs = append(prs, pr)
+			}
+		}
+	}
+	return prs
+}
+
+// gerritPullRequest converts the change into a PR and returns the files it
+// changes. Changes are only selected if they have the required label votes,
+// so these labels are the passing status contexts of the PR.
+func gerritPullRequest(host string, labels []string, change gerritclient.ChangeInfo) (PullRequest, []string, error) {
+	var pr PullRequest
+	rev, ok := change.Revisions[change.CurrentRevision]
+	if !ok {
+		return pr, nil, fmt.Errorf("cannot find current revision for change %v", change.ID)
+	}
+
+	pr.Number = githubql.Int(change.Number)
+	pr.Author.Login = githubql.String(change.Owner.Username)
+	if change.Owner.Username == "" {
+		pr.Author.Login = githubql.String(change.Owner.Name)
+	}
+	pr.BaseRef.Name = githubql.String(change.Branch)
+	pr.BaseRef.Prefix = "refs/heads/"
+	pr.HeadRefName = githubql.String(rev.Ref)
+	pr.HeadRefOID = githubql.String(change.CurrentRevision)
+	pr.Mergeable = githubql.MergeableStateUnknown
+	if change.Mergeable {
+		pr.Mergeable = githubql.MergeableStateMergeable
+	}
+	pr.Repository.Name = githubql.String(change.Project)
+	pr.Repository.NameWithOwner = githubql.String(host + "/" + change.Project)
+	pr.Repository.Owner.Login = githubql.String(host)
+	pr.Title = githubql.String(change.Subject)
+	pr.Body = githubql.String(rev.Commit.Message)
+	pr.UpdatedAt = githubql.DateTime{Time: change.Updated.Time}
+
+	commit := Commit{OID: pr.HeadRefOID}
+	for _, label := range labels {
+		commit.Status.Contexts = append(commit.Status.Contexts, Context{
+			Context:     githubql.String(gerritLabelName(label)),
+			Description: githubql.String(label),
+			State:       githubql.StatusStateSuccess,
+		})
+	}
+	pr.Commits.Nodes = []struct {
+		Commit Commit
+	}{{Commit: commit}}
+
+	files := make([]string, 0, len(rev.Files))
+	for file := range rev.Files {
+		// The commit message is listed as a file as well.
+		if file == "/COMMIT_MSG" {
+			continue
+		}
+		files = append(files, file)
+	}
+	return pr, files, nil
+}
+
+// gerritLabelName returns the name of the label of a label vote like
+// "Code-Review=2".
+func gerritLabelName(label string) string {
+	return strings.SplitN(label, "=", 2)[0]
+}
+
+func (p *gerritProvider) getRef(org, repo, ref string) (string, error) {
+	return p.gc.GetBranchRevision(p.instance(org), repo, strings.TrimPrefix(ref, "heads/"))
+}
+
+// merge submits the change. Gerrit merges it with the submit type of the
+// project, so the merge method of the details is not used.
+func (p *gerritProvider) merge(org, repo string, pr PullRequest, details github.MergeDetails) error {
+	return p.gc.SubmitChange(p.instance(org), strconv.Itoa(int(pr.Number)))
+}
+
+func (p *gerritProvider) clone(org, repo string) (*git.Repo, error) {
+	instance := p.instance(org)
+	p.lock.Lock()
+	client, ok := p.gitClients[instance]
+	if !ok {
+		var err error
+		if client, err = p.newGitClient(instance); err != nil {
+			p.lock.Unlock()
+			return nil, fmt.Errorf("failed to create git client for %s: %v", instance, err)
+		}
+		p.gitClients[instance] = client
+	}
+	p.lock.Unlock()
+	return client.Clone(repo)
+}
+
+// decorateRefs makes the jobs fetch the changes from Gerrit.
+func (p *gerritProvider) decorateRefs(refs *prowapi.Refs, prs []PullRequest) {
+	instance := p.instance(refs.Org)
+	refs.CloneURI = fmt.Sprintf("%s/%s", instance, refs.Repo)
+	for i, pr := range prs {
+		refs.Pulls[i].Ref = string(pr.HeadRefName)
+		refs.Pulls[i].Link = fmt.Sprintf("%s/c/%s/+/%d", instance, refs.Repo, refs.Pulls[i].Number)
+	}
+}
+
+// contextChecker requires the labels of the Gerrit query, which are the only
+// status contexts of the changes.
+func (p *gerritProvider) contextChecker(cfg *config.Config, sp *subpool, pr PullRequest) (contextChecker, error) {
+	q := p.query(sp.org)
+	if q == nil {
+		return nil, fmt.Errorf("no gerrit query for %s", sp.org)
+	}
+	var required []string
+	for _, label := range q.RequiredLabels() {
+		required = append(required, gerritLabelName(label))
+	}
+	return &config.TideContextPolicy{RequiredContexts: required}, nil
+}
+
+// clean removes the caches of the git clients.
+func (p *gerritProvider) clean() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for instance, client := range p.gitClients {
+		if err := client.Clean(); err != nil {
+			logrus.WithError(err).WithField("instance", instance).Error("Could not clean up git client cache.")
+		}
+	}
+}
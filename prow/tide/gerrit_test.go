@@ -0,0 +1,179 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	gerritclient "k8s.io/test-infra/prow/gerrit/client"
+	"k8s.io/test-infra/prow/github"
+)
+
+type fakeGerritClient struct {
+	changes   map[string][]gerritclient.ChangeInfo
+	revisions map[string]string
+	submitted []string
+	queries   []string
+}
+
+func (f *fakeGerritClient) SearchChanges(instance, query string, rateLimit int) ([]gerritclient.ChangeInfo, error) {
+	f.queries = append(f.queries, query)
+	changes, ok := f.changes[instance]
+	if !ok {
+		return nil, errors.New("unknown instance")
+	}
+	return changes, nil
+}
+
+func (f *fakeGerritClient) GetBranchRevision(instance, project, branch string) (string, error) {
+	return f.revisions[instance+" "+project+" "+branch], nil
+}
+
+func (f *fakeGerritClient) SubmitChange(instance, id string) error {
+	f.submitted = append(f.submitted, instance+" "+id)
+	return nil
+}
+
+func newGerritChange(number int, project, revision string) gerritclient.ChangeInfo {
+	change := gerritclient.ChangeInfo{
+		Number:          number,
+		Project:         project,
+		Branch:          "master",
+		Subject:         "Fix the bug",
+		CurrentRevision: revision,
+		Mergeable:       true,
+		Revisions: map[string]gerritclient.RevisionInfo{
+			revision: {
+				Ref: "refs/changes/00/1/1",
+				Files: map[string]gerritclient.FileInfo{
+					"/COMMIT_MSG": {},
+					"main.go":     {},
+				},
+			},
+		},
+	}
+	change.Owner.Username = "alice"
+	return change
+}
+
+func TestGerritPullRequest(t *testing.T) {
+	pr, files, err := gerritPullRequest("foo-review.googlesource.com", config.DefaultGerritLabels, newGerritChange(1, "bar", "abc"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pr.Number != 1 || pr.HeadRefOID != "abc" || pr.HeadRefName != "refs/changes/00/1/1" || pr.Author.Login != "alice" {
+		t.Errorf("unexpected PR %+v", pr)
+	}
+	if pr.Repository.Owner.Login != "foo-review.googlesource.com" || pr.Repository.Name != "bar" || pr.BaseRef.Name != "master" {
+		t.Errorf("unexpected repository %+v and base %+v", pr.Repository, pr.BaseRef)
+	}
+	if pr.Mergeable != githubql.MergeableStateMergeable {
+		t.Errorf("expected the PR to be mergeable, got %s", pr.Mergeable)
+	}
+	if expected := []string{"main.go"}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected changed files %v, got %v", expected, files)
+	}
+
+	contexts, err := headContexts(logrus.WithField("component", "tide"), &fgc{}, &pr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if names := contextsToStrings(contexts); !reflect.DeepEqual(names, []string{"Code-Review", "Verified"}) {
+		t.Errorf("expected the labels to be the contexts, got %v", names)
+	}
+
+	if _, _, err := gerritPullRequest("foo-review.googlesource.com", nil, gerritclient.ChangeInfo{CurrentRevision: "missing"}); err == nil {
+		t.Error("expected an error for a change without its current revision")
+	}
+}
+
+func TestGerritProvider(t *testing.T) {
+	const instance = "https://foo-review.googlesource.com"
+	const host = "foo-review.googlesource.com"
+	ca := &config.Agent{}
+	ca.Set(&config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{
+		GerritQueries: []config.TideGerritQuery{{Instance: instance, Projects: []string{"bar"}}},
+	}}})
+	gc := &fakeGerritClient{
+		changes:   map[string][]gerritclient.ChangeInfo{instance: {newGerritChange(1, "bar", "abc"), newGerritChange(2, "bar", "def")}},
+		revisions: map[string]string{instance + " bar master": "base"},
+	}
+	changedFiles := &changedFilesAgent{nextChangeCache: map[changeCacheKey][]string{}}
+	c := &Controller{config: ca.Config, ghc: &fgc{}, changedFiles: changedFiles}
+	c.EnableGerrit(gc)
+
+	if _, ok := c.providerOf("kubernetes").(*githubProvider); !ok {
+		t.Error("expected GitHub orgs to use the GitHub provider")
+	}
+	p := c.providerOf(host)
+	if p != c.gerrit {
+		t.Fatal("expected the host of the instance to use the Gerrit provider")
+	}
+
+	prs := c.gerrit.search(logrus.WithField("component", "tide"), changedFiles)
+	if len(prs) != 2 {
+		t.Fatalf("expected 2 PRs, got %d", len(prs))
+	}
+	if expected := []string{"status:open project:bar label:Code-Review=2 label:Verified=1"}; !reflect.DeepEqual(gc.queries, expected) {
+		t.Errorf("expected queries %v, got %v", expected, gc.queries)
+	}
+	if files, err := changedFiles.prChanges(&prs[0])(); err != nil || !reflect.DeepEqual(files, []string{"main.go"}) {
+		t.Errorf("expected the changed files to be cached, got %v, %v", files, err)
+	}
+
+	if sha, err := p.getRef(host, "bar", "heads/master"); err != nil || sha != "base" {
+		t.Errorf("expected the branch to point to base, got %q, %v", sha, err)
+	}
+	if err := p.merge(host, "bar", prs[1], github.MergeDetails{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{instance + " 2"}; !reflect.DeepEqual(gc.submitted, expected) {
+		t.Errorf("expected submitted changes %v, got %v", expected, gc.submitted)
+	}
+
+	refs := prowapi.Refs{Org: host, Repo: "bar", Pulls: []prowapi.Pull{{Number: 1}, {Number: 2}}}
+	p.decorateRefs(&refs, prs)
+	if refs.CloneURI != instance+"/bar" {
+		t.Errorf("expected clone URI %s/bar, got %s", instance, refs.CloneURI)
+	}
+	if refs.Pulls[0].Ref != "refs/changes/00/1/1" || refs.Pulls[1].Link != instance+"/c/bar/+/2" {
+		t.Errorf("unexpected pulls %+v", refs.Pulls)
+	}
+
+	sp := &subpool{org: host, repo: "bar", branch: "master", sha: "base"}
+	cc, err := p.contextChecker(ca.Config(), sp, prs[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	missing := cc.MissingRequiredContexts([]string{"Code-Review"})
+	sort.Strings(missing)
+	if !reflect.DeepEqual(missing, []string{"Verified"}) {
+		t.Errorf("expected Verified to be missing, got %v", missing)
+	}
+
+	if got := c.gerritInstance(host); got != instance {
+		t.Errorf("expected pool instance %s, got %s", instance, got)
+	}
+}
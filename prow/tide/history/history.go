@@ -15,7 +15,8 @@ limitations under the License.
 */
 
 // Package history provides an append only, size limited log of recent actions
-// that Tide has taken for each subpool.
+// that Tide has taken for each subpool. All actions can additionally be kept
+// in a store that can be queried after they were evicted from the log.
 package history
 
 import (
@@ -47,8 +48,19 @@ type History struct {
 
 	opener io.Opener
 	path   string
+
+	// store persists all records, if set. pending are the records that
+	// were not stored yet.
+	store     Store
+	pending   map[string][]*Record
+	retention time.Duration
+	lastPrune time.Time
 }
 
+// pruneInterval is how often records older than the retention are pruned
+// from the store.
+const pruneInterval = time.Hour
+
 func readHistory(maxRecordsPerKey int, opener io.Opener, path string) (map[string]*recordLog, error) {
 	reader, err := opener.Reader(context.Background(), path)
 	if io.IsNotExist(err) { // No history exists yet. This is not an error.
@@ -159,11 +171,39 @@ func (h *History) addRecord(poolKey string, rec *Record) {
 		h.logs[poolKey] = newRecordLog(h.logSizeLimit)
 	}
 	h.logs[poolKey].add(rec)
+	if h.store != nil {
+		h.pending[poolKey] = append(h.pending[poolKey], rec)
+	}
+}
+
+// SetStore makes the history persist all records in the store in addition
+// to the logs of the pools, and serve queries from it. Records older than
+// the retention are pruned from the store, unless it is zero.
+func (h *History) SetStore(store Store, retention time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+	h.store = store
+	h.pending = map[string][]*Record{}
+	h.retention = retention
 }
 
 // ServeHTTP serves a JSON mapping from pool key -> sorted records for the pool.
+// The records can be selected with the pool, pull, from and to parameters.
 func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	b, err := json.Marshal(h.AllRecords())
+	q, err := ParseQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records := h.AllRecords()
+	if !q.IsEmpty() {
+		if records, err = h.Query(q); err != nil {
+			logrus.WithError(err).Error("Querying history.")
+			http.Error(w, "failed to query history", http.StatusInternalServerError)
+			return
+		}
+	}
+	b, err := json.Marshal(records)
 	if err != nil {
 		logrus.WithError(err).Error("Encoding JSON history.")
 		b = []byte("{}")
@@ -175,6 +215,7 @@ func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 // Flush writes the action history to persistent storage if configured to do so.
 func (h *History) Flush() {
+	h.flushStore()
 	if h.path == "" {
 		return
 	}
@@ -192,6 +233,76 @@ func (h *History) Flush() {
 	}
 }
 
+// flushStore adds the pending records to the store and prunes it if it is
+// time to. Records that failed to be stored are retried on the next flush.
+func (h *History) flushStore() {
+	h.Lock()
+	store, pending, retention := h.store, h.pending, h.retention
+	h.pending = map[string][]*Record{}
+	prune := retention > 0 && now().Sub(h.lastPrune) >= pruneInterval
+	if prune {
+		h.lastPrune = now()
+	}
+	h.Unlock()
+	if store == nil {
+		return
+	}
+
+	if len(pending) > 0 {
+		if err := store.Add(pending); err != nil {
+			logrus.WithError(err).Error("Error storing action history.")
+			h.Lock()
+			for poolKey, recs := range pending {
+				h.pending[poolKey] = append(recs, h.pending[poolKey]...)
+			}
+			h.Unlock()
+		}
+	}
+	if prune {
+		if err := store.Prune(now().Add(-retention)); err != nil {
+			logrus.WithError(err).Error("Error pruning action history.")
+		}
+	}
+}
+
+// Query returns a map from pool key -> sorted records for the pool that match
+// the query. Records are read from the store if there is one, so that they can
+// be found after they were evicted from the logs of the pools.
+func (h *History) Query(q Query) (map[string][]*Record, error) {
+	res := map[string][]*Record{}
+	seen := map[string]bool{}
+	add := func(poolKey string, rec *Record) {
+		key := fmt.Sprintf("%s %s %s %s", poolKey, rec.Time.Format(time.RFC3339Nano), rec.Action, rec.BaseSHA)
+		if !seen[key] && q.Matches(poolKey, rec) {
+			seen[key] = true
+			res[poolKey] = append(res[poolKey], rec)
+		}
+	}
+	for poolKey, recs := range h.AllRecords() {
+		for _, rec := range recs {
+			add(poolKey, rec)
+		}
+	}
+	h.Lock()
+	store := h.store
+	h.Unlock()
+	if store != nil {
+		stored, err := store.Query(q)
+		if err != nil {
+			return nil, err
+		}
+		for poolKey, recs := range stored {
+			for _, rec := range recs {
+				add(poolKey, rec)
+			}
+		}
+	}
+	for _, recs := range res {
+		sortRecent(recs)
+	}
+	return res, nil
+}
+
 // AllRecords generates a map from pool key -> sorted records for the pool.
 func (h *History) AllRecords() map[string][]*Record {
 	h.Lock()
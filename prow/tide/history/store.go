@@ -0,0 +1,279 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/test-infra/pkg/io"
+)
+
+const (
+	// dayFormat is the format of the day in the names of the objects that
+	// store the records of a day.
+	dayFormat = "2006-01-02"
+	// MaxQueryDays bounds the number of days of records a query of a store
+	// covers, and so the number of objects it reads.
+	MaxQueryDays = 31
+)
+
+// Query selects records of the actions that Tide has taken.
+type Query struct {
+	// Pool is the key of the pool of the records, or empty for all pools.
+	Pool string
+	// PR is the number of a PR that is a target of the records, or zero for
+	// all records.
+	PR int
+	// From and To bound the time of the records, if set. To is exclusive.
+	// Stores only look up the MaxQueryDays days before To, or before their
+	// most recent records if To is not set.
+	From, To time.Time
+}
+
+// ParseQuery reads a query from the pool, pull, from and to parameters of a
+// URL. Times are either RFC3339 or dates, like 2019-12-03.
+func ParseQuery(values url.Values) (Query, error) {
+	q := Query{Pool: values.Get("pool")}
+	if pull := values.Get("pull"); pull != "" {
+		number, err := strconv.Atoi(pull)
+		if err != nil || number <= 0 {
+			return Query{}, fmt.Errorf("invalid pull %q", pull)
+		}
+		q.PR = number
+	}
+	for _, param := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		value := values.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.Parse(dayFormat, value); err != nil {
+				return Query{}, fmt.Errorf("invalid %s %q, must be RFC3339 or %s", param.name, value, dayFormat)
+			}
+		}
+		*param.t = t
+	}
+	if !q.From.IsZero() && !q.To.IsZero() {
+		if !q.To.After(q.From) {
+			return Query{}, fmt.Errorf("to %s must be after from %s", values.Get("to"), values.Get("from"))
+		}
+		if q.To.Sub(q.From) > MaxQueryDays*24*time.Hour {
+			return Query{}, fmt.Errorf("from %s and to %s are more than %d days apart", values.Get("from"), values.Get("to"), MaxQueryDays)
+		}
+	}
+	return q, nil
+}
+
+// IsEmpty returns whether the query selects all records.
+func (q Query) IsEmpty() bool {
+	return q == Query{}
+}
+
+// Matches returns whether the query selects the record of the pool.
+func (q Query) Matches(poolKey string, rec *Record) bool {
+	if q.Pool != "" && q.Pool != poolKey {
+		return false
+	}
+	if !q.From.IsZero() && rec.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !rec.Time.Before(q.To) {
+		return false
+	}
+	if q.PR == 0 {
+		return true
+	}
+	for _, pull := range rec.Target {
+		if pull.Number == q.PR {
+			return true
+		}
+	}
+	return false
+}
+
+// Store persists the records of the actions that Tide has taken, so that
+// they can be queried after they were evicted from the log of their pool.
+type Store interface {
+	// Add stores records by pool key.
+	Add(records map[string][]*Record) error
+	// Query returns the stored records that match the query by pool key,
+	// most recent first.
+	Query(q Query) (map[string][]*Record, error)
+	// Prune deletes the records from before the time.
+	Prune(before time.Time) error
+}
+
+// storageStore stores the records of each day in a JSON object mapping pool
+// keys to records, under a directory of a GCS, S3 or local path.
+type storageStore struct {
+	storage io.Storage
+	dir     string
+}
+
+// NewStorageStore returns a store that keeps one object per day under the
+// /local/dir, gs://bucket/dir or s3://bucket/dir.
+func NewStorageStore(storage io.Storage, dir string) Store {
+	return &storageStore{storage: storage, dir: strings.TrimSuffix(dir, "/") + "/"}
+}
+
+func (s *storageStore) path(day string) string {
+	return s.dir + day + ".json"
+}
+
+func (s *storageStore) read(day string) (map[string][]*Record, error) {
+	reader, err := s.storage.Reader(context.Background(), s.path(day))
+	if io.IsNotExist(err) {
+		return map[string][]*Record{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: %v", err)
+	}
+	defer io.LogClose(reader)
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read: %v", err)
+	}
+	var records map[string][]*Record
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %v", day, err)
+	}
+	return records, nil
+}
+
+// days returns the days with stored records, oldest first.
+func (s *storageStore) days() ([]string, error) {
+	names, err := s.storage.List(context.Background(), s.dir)
+	if err != nil && !io.IsNotExist(err) {
+		return nil, fmt.Errorf("list: %v", err)
+	}
+	var days []string
+	for _, name := range names {
+		day := strings.TrimSuffix(name, ".json")
+		if _, err := time.Parse(dayFormat, day); err == nil && day != name {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+	return days, nil
+}
+
+// Add appends the records to the objects of their days.
+func (s *storageStore) Add(records map[string][]*Record) error {
+	byDay := map[string]map[string][]*Record{}
+	for poolKey, recs := range records {
+		for _, rec := range recs {
+			day := rec.Time.UTC().Format(dayFormat)
+			if byDay[day] == nil {
+				byDay[day] = map[string][]*Record{}
+			}
+			byDay[day][poolKey] = append(byDay[day][poolKey], rec)
+		}
+	}
+	for day, recs := range byDay {
+		stored, err := s.read(day)
+		if err != nil {
+			return err
+		}
+		for poolKey, rec := range recs {
+			stored[poolKey] = append(stored[poolKey], rec...)
+		}
+		if err := writeHistory(s.storage, s.path(day), stored); err != nil {
+			return fmt.Errorf("write %s: %v", day, err)
+		}
+	}
+	return nil
+}
+
+// Query reads the objects of the days the query covers, up to MaxQueryDays
+// of them.
+func (s *storageStore) Query(q Query) (map[string][]*Record, error) {
+	days, err := s.days()
+	if err != nil {
+		return nil, err
+	}
+	res := map[string][]*Record{}
+	var from time.Time
+	for i := len(days) - 1; i >= 0; i-- {
+		day := days[i]
+		start, _ := time.Parse(dayFormat, day)
+		if !q.To.IsZero() && !start.Before(q.To) {
+			continue
+		}
+		if from.IsZero() {
+			end := q.To
+			if end.IsZero() {
+				end = start.AddDate(0, 0, 1)
+			}
+			if from = end.AddDate(0, 0, -MaxQueryDays); q.From.After(from) {
+				from = q.From
+			}
+		}
+		if !start.AddDate(0, 0, 1).After(from) {
+			break
+		}
+		stored, err := s.read(day)
+		if err != nil {
+			return nil, err
+		}
+		for poolKey, recs := range stored {
+			for _, rec := range recs {
+				if q.Matches(poolKey, rec) {
+					res[poolKey] = append(res[poolKey], rec)
+				}
+			}
+		}
+	}
+	for _, recs := range res {
+		sortRecent(recs)
+	}
+	return res, nil
+}
+
+// Prune deletes the objects of the days that ended before the time.
+func (s *storageStore) Prune(before time.Time) error {
+	days, err := s.days()
+	if err != nil {
+		return err
+	}
+	for _, day := range days {
+		start, _ := time.Parse(dayFormat, day)
+		if start.AddDate(0, 0, 1).After(before) {
+			break
+		}
+		if err := s.storage.Delete(context.Background(), s.path(day)); err != nil && !io.IsNotExist(err) {
+			return fmt.Errorf("delete %s: %v", day, err)
+		}
+	}
+	return nil
+}
+
+// sortRecent sorts records by descending time, like the logs of pools.
+func sortRecent(recs []*Record) {
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Time.After(recs[j].Time) })
+}
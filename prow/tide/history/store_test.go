@@ -0,0 +1,282 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	pkgio "k8s.io/test-infra/pkg/io"
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+)
+
+// fakeStorage is an in-memory pkgio.Storage keyed on full paths.
+type fakeStorage map[string]string
+
+type fakeStorageWriter struct {
+	bytes.Buffer
+	storage fakeStorage
+	path    string
+}
+
+func (w *fakeStorageWriter) Close() error {
+	w.storage[w.path] = w.String()
+	return nil
+}
+
+func (s fakeStorage) Reader(ctx context.Context, path string) (io.ReadCloser, error) {
+	return s.RangeReader(ctx, path, 0, -1)
+}
+
+func (s fakeStorage) Writer(_ context.Context, path string) (io.WriteCloser, error) {
+	return &fakeStorageWriter{storage: s, path: path}, nil
+}
+
+func (s fakeStorage) RangeReader(_ context.Context, path string, offset, length int64) (io.ReadCloser, error) {
+	content, ok := s[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s fakeStorage) Attributes(_ context.Context, path string) (pkgio.Attributes, error) {
+	return pkgio.Attributes{}, errors.New("not implemented")
+}
+
+func (s fakeStorage) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	for path := range s {
+		if strings.HasPrefix(path, prefix) {
+			names = append(names, strings.TrimPrefix(path, prefix))
+		}
+	}
+	return names, nil
+}
+
+func (s fakeStorage) SignedURL(_ context.Context, path string) (string, error) {
+	return path, nil
+}
+
+func (s fakeStorage) Delete(_ context.Context, path string) error {
+	if _, ok := s[path]; !ok {
+		return os.ErrNotExist
+	}
+	delete(s, path)
+	return nil
+}
+
+func TestParseQuery(t *testing.T) {
+	testCases := []struct {
+		name        string
+		values      url.Values
+		expected    Query
+		expectError bool
+	}{
+		{
+			name: "no parameters",
+		},
+		{
+			name:   "all parameters",
+			values: url.Values{"pool": {"org/repo:master"}, "pull": {"1234"}, "from": {"2019-12-03"}, "to": {"2019-12-04T12:00:00Z"}},
+			expected: Query{
+				Pool: "org/repo:master",
+				PR:   1234,
+				From: time.Date(2019, time.December, 3, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2019, time.December, 4, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:        "invalid pull",
+			values:      url.Values{"pull": {"#1234"}},
+			expectError: true,
+		},
+		{
+			name:        "invalid time",
+			values:      url.Values{"from": {"last tuesday"}},
+			expectError: true,
+		},
+		{
+			name:        "range too long",
+			values:      url.Values{"from": {"2019-11-01"}, "to": {"2019-12-03"}},
+			expectError: true,
+		},
+		{
+			name:        "to before from",
+			values:      url.Values{"from": {"2019-12-04"}, "to": {"2019-12-03"}},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := ParseQuery(tc.values)
+			if err != nil && !tc.expectError {
+				t.Fatalf("Unexpected error: %v.", err)
+			} else if err == nil && tc.expectError {
+				t.Fatal("Expected an error, but didn't get one.")
+			}
+			if !reflect.DeepEqual(q, tc.expected) {
+				t.Errorf("Expected query %+v, got %+v.", tc.expected, q)
+			}
+		})
+	}
+}
+
+func TestStorageStore(t *testing.T) {
+	day := time.Date(2019, time.December, 3, 0, 0, 0, 0, time.UTC)
+	record := func(t time.Time, numbers ...int) *Record {
+		rec := &Record{Time: t, Action: "MERGE"}
+		for _, number := range numbers {
+			rec.Target = append(rec.Target, prowapi.Pull{Number: number})
+		}
+		return rec
+	}
+	storage := fakeStorage{}
+	store := NewStorageStore(storage, "gs://bucket/tide-history")
+	if err := store.Add(map[string][]*Record{
+		"org/repo:master": {record(day.Add(time.Hour), 1), record(day.Add(25*time.Hour), 2, 3)},
+		"org/repo:dev":    {record(day.Add(2*time.Hour), 2)},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	if err := store.Add(map[string][]*Record{"org/repo:master": {record(day.Add(26*time.Hour), 4)}}); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	var paths []string
+	for path := range storage {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if expected := []string{"gs://bucket/tide-history/2019-12-03.json", "gs://bucket/tide-history/2019-12-04.json"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected objects %v, got %v.", expected, paths)
+	}
+
+	testCases := []struct {
+		name     string
+		query    Query
+		expected map[string][]int
+	}{
+		{
+			name:     "all records",
+			expected: map[string][]int{"org/repo:master": {4, 2, 1}, "org/repo:dev": {2}},
+		},
+		{
+			name:     "records of a PR",
+			query:    Query{PR: 2},
+			expected: map[string][]int{"org/repo:master": {2}, "org/repo:dev": {2}},
+		},
+		{
+			name:     "records of a pool and time range",
+			query:    Query{Pool: "org/repo:master", From: day.Add(time.Hour), To: day.Add(26 * time.Hour)},
+			expected: map[string][]int{"org/repo:master": {2, 1}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := store.Query(tc.query)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v.", err)
+			}
+			numbers := map[string][]int{}
+			for poolKey, recs := range res {
+				for _, rec := range recs {
+					numbers[poolKey] = append(numbers[poolKey], rec.Target[0].Number)
+				}
+			}
+			if !reflect.DeepEqual(numbers, tc.expected) {
+				t.Errorf("Expected the first targets %v, got %v.", tc.expected, numbers)
+			}
+		})
+	}
+
+	// Queries only read the days before the most recent records.
+	later := day.AddDate(0, 0, MaxQueryDays)
+	if err := store.Add(map[string][]*Record{"org/repo:master": {record(later, 5)}}); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	res, err := store.Query(Query{Pool: "org/repo:master"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	var numbers []int
+	for _, rec := range res["org/repo:master"] {
+		numbers = append(numbers, rec.Target[0].Number)
+	}
+	if expected := []int{5, 4, 2}; !reflect.DeepEqual(numbers, expected) {
+		t.Errorf("Expected the first targets %v, got %v.", expected, numbers)
+	}
+	delete(storage, store.(*storageStore).path(later.Format(dayFormat)))
+
+	if err := store.Prune(day.Add(36 * time.Hour)); err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	if _, ok := storage["gs://bucket/tide-history/2019-12-03.json"]; ok {
+		t.Error("Expected the records of 2019-12-03 to be pruned.")
+	}
+	if _, ok := storage["gs://bucket/tide-history/2019-12-04.json"]; !ok {
+		t.Error("Expected the records of 2019-12-04 to be kept.")
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	nowTime := time.Date(2019, time.December, 3, 12, 0, 0, 0, time.UTC)
+	oldNow := now
+	now = func() time.Time { return nowTime }
+	defer func() { now = oldNow }()
+
+	hist, err := New(1, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create history client: %v", err)
+	}
+	hist.SetStore(NewStorageStore(fakeStorage{}, "/tide-history"), 30*24*time.Hour)
+	hist.Record("org/repo:master", "MERGE", "sha1", "", []prowapi.Pull{{Number: 1}})
+	hist.Flush()
+	nowTime = nowTime.Add(time.Minute)
+	// The record of PR 1 is evicted from the log of the pool, while the
+	// record of PR 2 is not stored yet.
+	hist.Record("org/repo:master", "MERGE", "sha2", "", []prowapi.Pull{{Number: 2}})
+
+	res, err := hist.Query(Query{Pool: "org/repo:master"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v.", err)
+	}
+	if recs := res["org/repo:master"]; len(recs) != 2 || recs[0].BaseSHA != "sha2" || recs[1].BaseSHA != "sha1" {
+		t.Errorf("Expected the records of sha2 and sha1, got %v.", recs)
+	}
+
+	w := httptest.NewRecorder()
+	hist.ServeHTTP(w, httptest.NewRequest("GET", "/history?pull=1", nil))
+	if body := w.Body.String(); !strings.Contains(body, `"baseSHA":"sha1"`) || strings.Contains(body, "sha2") {
+		t.Errorf("Expected only the record of PR 1, got %s.", body)
+	}
+	w = httptest.NewRecorder()
+	hist.ServeHTTP(w, httptest.NewRequest("GET", "/history?pull=one", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected an invalid query to be rejected, got status %d.", w.Code)
+	}
+}
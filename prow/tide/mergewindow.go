@@ -0,0 +1,97 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"fmt"
+	"time"
+)
+
+// mergeWindowTimeFormat is the format of the time a closed merge window
+// opens in the status description of a PR.
+const mergeWindowTimeFormat = "Mon Jan 2 15:04 MST"
+
+// For mocking out the time merge windows are evaluated at during unit tests.
+var now = time.Now
+
+// initMergeWindow finds out whether the merge window of the subpool is closed.
+func (c *Controller) initMergeWindow(sp *subpool) {
+	window := c.config().Tide.MergeWindow(sp.org, sp.repo, sp.branch)
+	if window == nil {
+		return
+	}
+	if opens, closed := window.ClosedUntil(now()); closed {
+		sp.mergeWindow = window
+		sp.mergeWindowOpens = opens
+	}
+}
+
+// exemptPRs returns the PRs of the subpool that are tested and merged while
+// its merge window is closed.
+func exemptPRs(sp *subpool) []PullRequest {
+	var exempt []PullRequest
+	for _, pr := range sp.prs {
+		if exemptFromMergeWindow(sp, &pr) {
+			exempt = append(exempt, pr)
+		}
+	}
+	return exempt
+}
+
+// exemptFromMergeWindow returns whether the PR is tested and merged even
+// though the merge window of the subpool may be closed.
+func exemptFromMergeWindow(sp *subpool, pr *PullRequest) bool {
+	if sp.mergeWindow == nil {
+		return true
+	}
+	var labels []string
+	for _, label := range pr.Labels.Nodes {
+		labels = append(labels, string(label.Name))
+	}
+	return sp.mergeWindow.Exempt(labels)
+}
+
+// mergeWindowOpens returns when the closed merge window of the subpool
+// opens, if it is closed and known to open.
+func mergeWindowOpens(sp *subpool) *time.Time {
+	if sp.mergeWindow == nil || sp.mergeWindowOpens.IsZero() {
+		return nil
+	}
+	opens := sp.mergeWindowOpens
+	return &opens
+}
+
+// closedMergeWindowMap describes the closed merge windows of the PRs of the
+// subpools that are not exempt from them, by PR key.
+func closedMergeWindowMap(subpoolMap map[string]*subpool) map[string]string {
+	closed := make(map[string]string)
+	for _, sp := range subpoolMap {
+		if sp.mergeWindow == nil {
+			continue
+		}
+		desc := " Merge window closed."
+		if opens := mergeWindowOpens(sp); opens != nil {
+			desc = fmt.Sprintf(" Merge window closed until %s.", opens.Format(mergeWindowTimeFormat))
+		}
+		for _, pr := range sp.prs {
+			if !exemptFromMergeWindow(sp, &pr) {
+				closed[prKey(&pr)] = desc
+			}
+		}
+	}
+	return closed
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+func TestSyncSubpoolMergeWindow(t *testing.T) {
+	freezeEnd := time.Date(2020, time.January, 2, 12, 0, 0, 0, time.UTC)
+	pr := func(number int, labels ...string) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.Repository.Owner.Login = "o"
+		pr.Repository.Name = "r"
+		pr.Repository.NameWithOwner = "o/r"
+		pr.BaseRef.Name = "master"
+		pr.HeadRefOID = "head"
+		pr.Mergeable = githubql.MergeableStateMergeable
+		pr.Commits.Nodes = []struct{ Commit Commit }{{Commit: Commit{OID: "head"}}}
+		for _, label := range labels {
+			pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+		}
+		return pr
+	}
+	testcases := []struct {
+		name string
+		now  time.Time
+		prs  []PullRequest
+
+		expectedAction  Action
+		expectedTargets []int
+		expectedClosed  map[string]string
+	}{
+		{
+			name:            "merge window is open",
+			now:             freezeEnd,
+			prs:             []PullRequest{pr(1), pr(2, "cherry-pick-approved")},
+			expectedAction:  Merge,
+			expectedTargets: []int{1},
+			expectedClosed:  map[string]string{},
+		},
+		{
+			name:            "only exempt PRs are merged during a freeze",
+			now:             freezeEnd.Add(-time.Hour),
+			prs:             []PullRequest{pr(1), pr(2, "cherry-pick-approved")},
+			expectedAction:  Merge,
+			expectedTargets: []int{2},
+			expectedClosed:  map[string]string{"o/r#1": " Merge window closed until Thu Jan 2 12:00 UTC."},
+		},
+		{
+			name:           "pool waits for the freeze to end without exempt PRs",
+			now:            freezeEnd.Add(-time.Hour),
+			prs:            []PullRequest{pr(1)},
+			expectedAction: PoolClosed,
+			expectedClosed: map[string]string{"o/r#1": " Merge window closed until Thu Jan 2 12:00 UTC."},
+		},
+	}
+
+	defer func(orig func() time.Time) { now = orig }(now)
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = func() time.Time { return tc.now }
+			cfg := &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{
+				MergeWindows: []config.TideMergeWindow{{
+					Repos:        []string{"o/r"},
+					Freezes:      []config.Blackout{{Start: "2019-12-20T00:00:00Z", End: "2020-01-02T12:00:00Z"}},
+					ExemptLabels: []string{"cherry-pick-approved"},
+				}},
+			}}}
+			hist, err := history.New(10, nil, "")
+			if err != nil {
+				t.Fatalf("failed to create history: %v", err)
+			}
+			ghc := &fgc{}
+			c := &Controller{
+				logger:  logrus.WithField("component", "tide"),
+				config:  func() *config.Config { return cfg },
+				ghc:     ghc,
+				History: hist,
+			}
+			cc := map[int]contextChecker{}
+			for _, pr := range tc.prs {
+				cc[int(pr.Number)] = &config.TideContextPolicy{}
+			}
+			sp := &subpool{
+				log:    logrus.WithField("component", "tide"),
+				org:    "o",
+				repo:   "r",
+				branch: "master",
+				sha:    "base",
+				prs:    tc.prs,
+				cc:     cc,
+			}
+			c.initMergeWindow(sp)
+			if closed := closedMergeWindowMap(map[string]*subpool{"o/r:master": sp}); !reflect.DeepEqual(closed, tc.expectedClosed) {
+				t.Errorf("expected closed merge windows %v, got %v", tc.expectedClosed, closed)
+			}
+
+			pool, err := c.syncSubpool(*sp, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if pool.Action != tc.expectedAction {
+				t.Errorf("expected action %s, got %s", tc.expectedAction, pool.Action)
+			}
+			if targets := prNumbers(pool.Target); !reflect.DeepEqual(targets, tc.expectedTargets) {
+				t.Errorf("expected targets %v, got %v", tc.expectedTargets, targets)
+			}
+			if len(pool.SuccessPRs) != len(tc.prs) {
+				t.Errorf("expected the pool to list all %d PRs, got %d", len(tc.prs), len(pool.SuccessPRs))
+			}
+			closed := len(tc.expectedClosed) > 0
+			if pool.MergeWindowClosed != closed {
+				t.Errorf("expected merge window closed to be %t, got %t", closed, pool.MergeWindowClosed)
+			}
+			if closed && (pool.MergeWindowOpens == nil || !pool.MergeWindowOpens.Equal(freezeEnd)) {
+				t.Errorf("expected merge window to open at %v, got %v", freezeEnd, pool.MergeWindowOpens)
+			}
+		})
+	}
+}
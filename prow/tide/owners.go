@@ -0,0 +1,207 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/repoowners"
+)
+
+// maxNamesInDescription is the number of OWNERS files or approvers that are
+// listed in the status description of a PR that lacks approvals.
+const maxNamesInDescription = 3
+
+type reviewClient interface {
+	ListReviews(org, repo string, number int) ([]github.Review, error)
+}
+
+// approvalsAgent lists and caches the users that approved PRs. A new review
+// bumps the update time of a PR, so entries are keyed by it along with the
+// head SHA. Cache entries expire if they are not used during a sync loop.
+type approvalsAgent struct {
+	ghc   reviewClient
+	cache map[approvalsKey]sets.String
+	// nextCache caches the approvers that are relevant this sync for use
+	// next sync. This becomes the new cache when prune() is called at the
+	// end of each sync.
+	nextCache map[approvalsKey]sets.String
+	sync.Mutex
+}
+
+type approvalsKey struct {
+	org, repo string
+	number    int
+	sha       string
+	updatedAt time.Time
+}
+
+func newApprovalsAgent(ghc reviewClient) *approvalsAgent {
+	return &approvalsAgent{
+		ghc:       ghc,
+		cache:     make(map[approvalsKey]sets.String),
+		nextCache: make(map[approvalsKey]sets.String),
+	}
+}
+
+// approvers returns the users whose latest review approves the head of the
+// PR, either from the cache or by querying GitHub. Approvals of earlier head
+// SHAs are stale and do not count, so pushing to a PR withdraws them.
+func (a *approvalsAgent) approvers(pr *PullRequest) (sets.String, error) {
+	key := approvalsKey{
+		org:       string(pr.Repository.Owner.Login),
+		repo:      string(pr.Repository.Name),
+		number:    int(pr.Number),
+		sha:       string(pr.HeadRefOID),
+		updatedAt: pr.UpdatedAt.Time,
+	}
+	a.Lock()
+	approvers, ok := a.nextCache[key]
+	if !ok {
+		approvers, ok = a.cache[key]
+	}
+	a.Unlock()
+	if !ok {
+		reviews, err := a.ghc.ListReviews(key.org, key.repo, key.number)
+		if err != nil {
+			return nil, fmt.Errorf("error listing reviews of pr %d: %v", key.number, err)
+		}
+		approvers = reviewApprovers(reviews, key.sha)
+	}
+	a.Lock()
+	a.nextCache[key] = approvers
+	a.Unlock()
+	return approvers, nil
+}
+
+// prune removes any cached approvers that were not used since the last
+// prune.
+func (a *approvalsAgent) prune() {
+	a.Lock()
+	defer a.Unlock()
+	a.cache = a.nextCache
+	a.nextCache = make(map[approvalsKey]sets.String)
+}
+
+// initMissingApprovals finds the PRs of the subpool that lack the approval of
+// the OWNERS of a directory they touch, if the repo requires these approvals.
+func (c *Controller) initMissingApprovals(sp *subpool) error {
+	if c.owners == nil || !c.config().Tide.OwnersApprovalRequired(sp.org, sp.repo) {
+		return nil
+	}
+	owners, err := c.owners.LoadRepoOwners(sp.org, sp.repo, sp.branch)
+	if err != nil {
+		return fmt.Errorf("error loading OWNERS: %v", err)
+	}
+	sp.missingApprovals = make(map[int]string)
+	for _, pr := range sp.prs {
+		files, err := c.changedFiles.prChanges(&pr)()
+		if err != nil {
+			return fmt.Errorf("error getting changes of pr %d: %v", int(pr.Number), err)
+		}
+		approvers, err := c.approvals.approvers(&pr)
+		if err != nil {
+			return err
+		}
+		if missing := missingApprovals(owners, files, approvers); len(missing) > 0 {
+			sp.missingApprovals[int(pr.Number)] = approvalDescription(missing)
+		}
+	}
+	return nil
+}
+
+// reviewApprovers returns the users whose latest review of the PR approves
+// the head SHA. Comments do not change the verdict of an earlier review.
+func reviewApprovers(reviews []github.Review, sha string) sets.String {
+	sorted := make([]github.Review, len(reviews))
+	copy(sorted, reviews)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].SubmittedAt.Before(sorted[j].SubmittedAt) })
+
+	latest := map[string]github.Review{}
+	for _, review := range sorted {
+		if review.State == github.ReviewStateCommented || review.State == github.ReviewStatePending {
+			continue
+		}
+		latest[github.NormLogin(review.User.Login)] = review
+	}
+	approvers := sets.NewString()
+	for login, review := range latest {
+		if review.State == github.ReviewStateApproved && review.CommitID == sha {
+			approvers.Insert(login)
+		}
+	}
+	return approvers
+}
+
+// missingApprovals returns the leaf approvers of the OWNERS files that cover
+// a changed file and none of whose approvers, including the approvers of
+// parent OWNERS files, approved the PR. They are keyed by the directory of
+// the OWNERS file. Files without any approvers need no approval.
+func missingApprovals(owners repoowners.RepoOwner, files []string, approvers sets.String) map[string]sets.String {
+	missing := map[string]sets.String{}
+	for _, file := range files {
+		fileApprovers := normLogins(owners.Approvers(file))
+		if fileApprovers.Len() == 0 || fileApprovers.HasAny(approvers.UnsortedList()...) {
+			continue
+		}
+		missing[owners.FindApproverOwnersForFile(file)] = normLogins(owners.LeafApprovers(file))
+	}
+	return missing
+}
+
+func normLogins(logins sets.String) sets.String {
+	normed := sets.NewString()
+	for login := range logins {
+		normed.Insert(github.NormLogin(login))
+	}
+	return normed
+}
+
+// approvalDescription describes the OWNERS files whose approvers have to
+// approve a PR, for its tide status context.
+func approvalDescription(missing map[string]sets.String) string {
+	var dirs []string
+	for dir := range missing {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	if len(dirs) > 1 {
+		var files []string
+		for _, dir := range dirs {
+			files = append(files, filepath.Join(dir, "OWNERS"))
+		}
+		return fmt.Sprintf(" Needs approval from the approvers in %s.", strings.Join(truncateNames(files), ", "))
+	}
+	approvers := missing[dirs[0]].List()
+	return fmt.Sprintf(" Needs approval from %s (%s).", filepath.Join(dirs[0], "OWNERS"), strings.Join(truncateNames(approvers), ", "))
+}
+
+// truncateNames keeps the status description short enough for GitHub.
+func truncateNames(names []string) []string {
+	if len(names) <= maxNamesInDescription {
+		return names
+	}
+	return append(names[:maxNamesInDescription:maxNamesInDescription], "...")
+}
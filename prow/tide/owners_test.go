@@ -0,0 +1,278 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/repoowners"
+)
+
+// fakeRepoOwners has the approvers of the OWNERS files of a repo by
+// directory, with "" being the root of the repo.
+type fakeRepoOwners struct {
+	repoowners.RepoOwner
+	approvers map[string]sets.String
+}
+
+// ownersDirs returns the directories with OWNERS files that cover the file,
+// deepest first.
+func (f *fakeRepoOwners) ownersDirs(path string) []string {
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if dir == "." {
+			dir = ""
+		}
+		if _, ok := f.approvers[dir]; ok {
+			dirs = append(dirs, dir)
+		}
+		if dir == "" {
+			return dirs
+		}
+	}
+}
+
+func (f *fakeRepoOwners) FindApproverOwnersForFile(path string) string {
+	if dirs := f.ownersDirs(path); len(dirs) > 0 {
+		return dirs[0]
+	}
+	return ""
+}
+
+func (f *fakeRepoOwners) LeafApprovers(path string) sets.String {
+	if dirs := f.ownersDirs(path); len(dirs) > 0 {
+		return f.approvers[dirs[0]]
+	}
+	return sets.NewString()
+}
+
+func (f *fakeRepoOwners) Approvers(path string) sets.String {
+	approvers := sets.NewString()
+	for _, dir := range f.ownersDirs(path) {
+		approvers = approvers.Union(f.approvers[dir])
+	}
+	return approvers
+}
+
+type fakeOwnersClient struct {
+	owners *fakeRepoOwners
+}
+
+func (f *fakeOwnersClient) LoadRepoAliases(org, repo, base string) (repoowners.RepoAliases, error) {
+	return nil, nil
+}
+
+func (f *fakeOwnersClient) LoadRepoOwners(org, repo, base string) (repoowners.RepoOwner, error) {
+	return f.owners, nil
+}
+
+func TestReviewApprovers(t *testing.T) {
+	now := time.Now()
+	review := func(login string, state github.ReviewState, ago time.Duration, sha string) github.Review {
+		return github.Review{User: github.User{Login: login}, State: state, SubmittedAt: now.Add(-ago), CommitID: sha}
+	}
+	reviews := []github.Review{
+		review("Alice", github.ReviewStateApproved, time.Hour, "head"),
+		review("alice", github.ReviewStateCommented, time.Minute, "head"),
+		review("bob", github.ReviewStateApproved, time.Minute, "head"),
+		review("bob", github.ReviewStateChangesRequested, time.Hour, "old"),
+		review("carol", github.ReviewStateApproved, time.Hour, "head"),
+		review("carol", github.ReviewStateDismissed, time.Minute, "head"),
+		review("dave", github.ReviewStateApproved, time.Minute, "old"),
+		review("erin", github.ReviewStateApproved, time.Hour, "head"),
+		review("erin", github.ReviewStateApproved, time.Minute, "old"),
+	}
+	if approvers := reviewApprovers(reviews, "head"); !reflect.DeepEqual(approvers.List(), []string{"alice", "bob"}) {
+		t.Errorf("expected alice and bob to approve, got %v", approvers.List())
+	}
+}
+
+func TestMissingApprovals(t *testing.T) {
+	owners := &fakeRepoOwners{approvers: map[string]sets.String{
+		"":        sets.NewString("root"),
+		"pkg":     sets.NewString("Alice", "bob"),
+		"pkg/sub": sets.NewString("carol"),
+		"docs":    sets.NewString("dave", "erin", "frank", "grace"),
+	}}
+	testcases := []struct {
+		name      string
+		files     []string
+		approvers []string
+
+		expected string
+	}{
+		{
+			name:      "approved by the leaf approvers",
+			files:     []string{"pkg/sub/file.go"},
+			approvers: []string{"carol"},
+		},
+		{
+			name:      "approved by the approvers of a parent",
+			files:     []string{"pkg/sub/file.go", "pkg/file.go"},
+			approvers: []string{"alice"},
+		},
+		{
+			name:      "approved by the root approvers",
+			files:     []string{"pkg/sub/file.go", "docs/README.md", "main.go"},
+			approvers: []string{"root"},
+		},
+		{
+			name:      "one OWNERS file is missing its approval",
+			files:     []string{"pkg/file.go", "docs/README.md"},
+			approvers: []string{"erin"},
+
+			expected: " Needs approval from pkg/OWNERS (alice, bob).",
+		},
+		{
+			name:      "many approvers are truncated",
+			files:     []string{"docs/README.md"},
+			approvers: []string{"alice"},
+
+			expected: " Needs approval from docs/OWNERS (dave, erin, frank, ...).",
+		},
+		{
+			name:  "several OWNERS files are missing their approval",
+			files: []string{"docs/README.md", "pkg/sub/file.go", "main.go"},
+
+			expected: " Needs approval from the approvers in OWNERS, docs/OWNERS, pkg/sub/OWNERS.",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			missing := missingApprovals(owners, tc.files, sets.NewString(tc.approvers...))
+			if tc.expected == "" {
+				if len(missing) > 0 {
+					t.Errorf("expected no missing approvals, got %v", missing)
+				}
+				return
+			}
+			if desc := approvalDescription(missing); desc != tc.expected {
+				t.Errorf("expected description %q, got %q", tc.expected, desc)
+			}
+		})
+	}
+}
+
+func TestFilterMissingApprovals(t *testing.T) {
+	pr := func(number int) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.Repository.Owner.Login = "o"
+		pr.Repository.Name = "r"
+		pr.Repository.NameWithOwner = "o/r"
+		pr.HeadRefOID = "head"
+		pr.Commits.Nodes = []struct{ Commit Commit }{{Commit: Commit{OID: "head"}}}
+		return pr
+	}
+	ghc := &fgc{reviews: map[int][]github.Review{
+		1: {{User: github.User{Login: "alice"}, State: github.ReviewStateApproved, CommitID: "head"}},
+	}}
+	cfg := &config.Config{ProwConfig: config.ProwConfig{Tide: config.Tide{
+		OwnersApprovalMap: map[string]bool{"o/r": true},
+	}}}
+	c := &Controller{
+		config:    func() *config.Config { return cfg },
+		ghc:       ghc,
+		owners:    &fakeOwnersClient{owners: &fakeRepoOwners{approvers: map[string]sets.String{"": sets.NewString("alice")}}},
+		approvals: newApprovalsAgent(ghc),
+		changedFiles: &changedFilesAgent{
+			ghc:             ghc,
+			nextChangeCache: make(map[changeCacheKey][]string),
+		},
+	}
+	// fgc reports that only PR 100 changes a file.
+	sp := &subpool{
+		log:  logrus.WithField("component", "tide"),
+		org:  "o",
+		repo: "r",
+		prs:  []PullRequest{pr(1), pr(2), pr(100)},
+		cc:   map[int]contextChecker{1: &config.TideContextPolicy{}, 2: &config.TideContextPolicy{}, 100: &config.TideContextPolicy{}},
+	}
+	if err := c.initMissingApprovals(sp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[int]string{100: " Needs approval from OWNERS (alice)."}
+	if !reflect.DeepEqual(sp.missingApprovals, expected) {
+		t.Errorf("expected missing approvals %v, got %v", expected, sp.missingApprovals)
+	}
+	if filtered := filterSubpool(ghc, sp); !reflect.DeepEqual(prNumbers(filtered.prs), []int{1, 2}) {
+		t.Errorf("expected PR 100 to be filtered out, got %v", prNumbers(filtered.prs))
+	}
+	if missing := missingApprovalMap(map[string]*subpool{"o/r:": sp}); !reflect.DeepEqual(missing, map[string]string{"o/r#100": expected[100]}) {
+		t.Errorf("unexpected missing approvals by PR key %v", missing)
+	}
+}
+
+type countingReviewClient struct {
+	reviews []github.Review
+	calls   int
+}
+
+func (c *countingReviewClient) ListReviews(org, repo string, number int) ([]github.Review, error) {
+	c.calls++
+	return c.reviews, nil
+}
+
+func TestApprovalsAgent(t *testing.T) {
+	ghc := &countingReviewClient{reviews: []github.Review{{User: github.User{Login: "Alice"}, State: github.ReviewStateApproved, CommitID: "head"}}}
+	a := newApprovalsAgent(ghc)
+	var pr PullRequest
+	pr.Number = 1
+	pr.Repository.Owner.Login = "o"
+	pr.Repository.Name = "r"
+	pr.HeadRefOID = "head"
+	pr.UpdatedAt = githubql.DateTime{Time: time.Unix(1, 0)}
+
+	expectApprovers := func(expectedCalls int, expected ...string) {
+		t.Helper()
+		approvers, err := a.approvers(&pr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !approvers.Equal(sets.NewString(expected...)) {
+			t.Errorf("expected %v to approve, got %v", expected, approvers.List())
+		}
+		if ghc.calls != expectedCalls {
+			t.Errorf("expected %d calls to list the reviews, got %d", expectedCalls, ghc.calls)
+		}
+	}
+	expectApprovers(1, "alice")
+	a.prune()
+	expectApprovers(1, "alice")
+	// A new review updates the PR.
+	pr.UpdatedAt = githubql.DateTime{Time: time.Unix(2, 0)}
+	expectApprovers(2, "alice")
+	// Approvals of an earlier head are stale.
+	pr.HeadRefOID = "new-head"
+	expectApprovers(3)
+	// Entries that were not used during a sync expire.
+	a.prune()
+	a.prune()
+	pr.HeadRefOID = "head"
+	pr.UpdatedAt = githubql.DateTime{Time: time.Unix(2, 0)}
+	expectApprovers(4, "alice")
+}
@@ -0,0 +1,349 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+const (
+	// revertWindow is how long after a merge a failing postsubmit causes
+	// the merged PRs to be reverted.
+	revertWindow = 24 * time.Hour
+	// revertMarker marks the comments on PRs that were reverted or blocked,
+	// so that they are not handled again after a restart.
+	revertMarker = "<!-- tide: failed blocking postsubmit -->"
+)
+
+// revertClient is the subset of the GitHub client that reverts merged PRs
+// or blocks their branch.
+type revertClient interface {
+	BotName() (string, error)
+	CreateComment(org, repo string, number int, comment string) error
+	CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error)
+	CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error)
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+}
+
+// revertController watches the blocking postsubmits of the merges in the
+// history of Tide. When one of them fails on a merge commit, it reverts the
+// PRs merged up to that commit, or blocks their branch, and explains why on
+// the PRs.
+type revertController struct {
+	ctx           context.Context
+	logger        *logrus.Entry
+	config        config.Getter
+	ghc           revertClient
+	gc            *git.Client
+	prowJobClient ctrlruntimeclient.Client
+	history       *history.History
+	// dryRun skips pushing reverts, which the GitHub client does not cover.
+	dryRun bool
+
+	// mergeSHAs caches the merge commits of PRs by PR key.
+	mergeSHAs map[string]string
+	// handled are the merges that were already reverted or blocked.
+	handled sets.String
+}
+
+func newRevertController(logger *logrus.Entry, cfg config.Getter, ghc revertClient, gc *git.Client, dryRun bool, prowJobClient ctrlruntimeclient.Client, hist *history.History) *revertController {
+	return &revertController{
+		ctx:           context.Background(),
+		logger:        logger.WithField("controller", "revert"),
+		config:        cfg,
+		ghc:           ghc,
+		gc:            gc,
+		prowJobClient: prowJobClient,
+		history:       hist,
+		dryRun:        dryRun,
+		mergeSHAs:     map[string]string{},
+		handled:       sets.NewString(),
+	}
+}
+
+// mergedPull is a PR that Tide merged, with its merge commit.
+type mergedPull struct {
+	prowapi.Pull
+	sha string
+}
+
+// sync acts on the blocking postsubmits that failed on recent merges.
+func (rc *revertController) sync() {
+	cfg := rc.config()
+	if len(cfg.Tide.PostsubmitReverts) == 0 {
+		return
+	}
+	pjs := &prowapi.ProwJobList{}
+	if err := rc.prowJobClient.List(rc.ctx, pjs, ctrlruntimeclient.InNamespace(cfg.ProwJobNamespace)); err != nil {
+		rc.logger.WithError(err).Error("Failed to list ProwJobs.")
+		return
+	}
+	failed := failedPostsubmits(&cfg.Tide, pjs.Items)
+	if len(failed) == 0 {
+		return
+	}
+
+	for key, records := range rc.history.AllRecords() {
+		jobs := failed[key]
+		if len(jobs) == 0 {
+			continue
+		}
+		org, repo, branch, err := splitPoolKey(key)
+		if err != nil {
+			rc.logger.WithError(err).Warn("Ignoring history of unknown pool.")
+			continue
+		}
+		log := rc.logger.WithField("pool", key)
+		for _, rec := range records {
+			if (rec.Action != string(Merge) && rec.Action != MergeBatch) || time.Since(rec.Time) > revertWindow {
+				continue
+			}
+			handledKey := fmt.Sprintf("%s %s %v", key, rec.BaseSHA, rec.Target)
+			if rc.handled.Has(handledKey) || failedOn(jobs, rec.BaseSHA) != nil {
+				// Skip merges into a branch whose postsubmits already failed.
+				continue
+			}
+			merged, err := rc.mergedPulls(org, repo, rec.Target)
+			if err != nil {
+				log.WithError(err).Warn("Failed to get merge commits.")
+				continue
+			}
+			culprits, pj := culprits(merged, jobs)
+			if pj == nil {
+				continue
+			}
+			log := log.WithFields(logrus.Fields{"job": pj.Spec.Job, "merged": culprits})
+			if done, err := rc.alreadyHandled(org, repo, culprits); err != nil {
+				log.WithError(err).Warn("Failed to check if the merge was already handled.")
+				continue
+			} else if done {
+				rc.handled.Insert(handledKey)
+				continue
+			}
+			if err := rc.handle(org, repo, branch, culprits, pj); err != nil {
+				log.WithError(err).Error("Failed to handle failed blocking postsubmit.")
+				continue
+			}
+			log.Info("Handled failed blocking postsubmit.")
+			rc.handled.Insert(handledKey)
+		}
+	}
+}
+
+// failedPostsubmits returns the failed blocking postsubmits of the repos
+// that opted in, by pool key.
+func failedPostsubmits(tide *config.Tide, pjs []prowapi.ProwJob) map[string][]prowapi.ProwJob {
+	failed := map[string][]prowapi.ProwJob{}
+	for _, pj := range pjs {
+		if pj.Spec.Type != prowapi.PostsubmitJob || pj.Spec.Refs == nil {
+			continue
+		}
+		// Errors are failures to run the job, not to build the branch.
+		if pj.Status.State != prowapi.FailureState {
+			continue
+		}
+		refs := pj.Spec.Refs
+		revert := tide.PostsubmitRevert(refs.Org, refs.Repo)
+		if revert == nil || !sets.NewString(revert.Jobs...).Has(pj.Spec.Job) {
+			continue
+		}
+		key := poolKey(refs.Org, refs.Repo, refs.BaseRef)
+		failed[key] = append(failed[key], pj)
+	}
+	return failed
+}
+
+// failedOn returns a job that failed on the SHA, if any.
+func failedOn(jobs []prowapi.ProwJob, sha string) *prowapi.ProwJob {
+	for i := range jobs {
+		if jobs[i].Spec.Refs.BaseSHA == sha {
+			return &jobs[i]
+		}
+	}
+	return nil
+}
+
+// culprits returns the PRs merged up to the first merge commit that a job
+// failed on, and that job.
+func culprits(merged []mergedPull, jobs []prowapi.ProwJob) ([]mergedPull, *prowapi.ProwJob) {
+	for i := range merged {
+		if pj := failedOn(jobs, merged[i].sha); pj != nil {
+			return merged[:i+1], pj
+		}
+	}
+	return nil, nil
+}
+
+// mergedPulls returns the merge commits of the pulls in the order they were
+// merged in. Pulls that were not merged are left out.
+func (rc *revertController) mergedPulls(org, repo string, pulls []prowapi.Pull) ([]mergedPull, error) {
+	var merged []mergedPull
+	for _, pull := range pulls {
+		key := fmt.Sprintf("%s/%s#%d", org, repo, pull.Number)
+		sha, ok := rc.mergeSHAs[key]
+		if !ok {
+			pr, err := rc.ghc.GetPullRequest(org, repo, pull.Number)
+			if err != nil {
+				return nil, err
+			}
+			if !pr.Merged || pr.MergeSHA == nil {
+				continue
+			}
+			sha = *pr.MergeSHA
+			rc.mergeSHAs[key] = sha
+		}
+		merged = append(merged, mergedPull{Pull: pull, sha: sha})
+	}
+	return merged, nil
+}
+
+// alreadyHandled returns whether the last culprit was commented on already.
+func (rc *revertController) alreadyHandled(org, repo string, culprits []mergedPull) (bool, error) {
+	comments, err := rc.ghc.ListIssueComments(org, repo, culprits[len(culprits)-1].Number)
+	if err != nil {
+		return false, err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.Body, revertMarker) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handle reverts the culprits or blocks their branch, and comments on them.
+func (rc *revertController) handle(org, repo, branch string, culprits []mergedPull, pj *prowapi.ProwJob) error {
+	revert := rc.config().Tide.PostsubmitRevert(org, repo)
+	if revert == nil {
+		return fmt.Errorf("%s/%s did not opt in", org, repo)
+	}
+	var numbers []string
+	for _, pull := range culprits {
+		numbers = append(numbers, fmt.Sprintf("#%d", pull.Number))
+	}
+	prs := strings.Join(numbers, ", ")
+	failure := fmt.Sprintf("The blocking postsubmit [%s](%s) failed on %s after Tide merged %s into `%s`.", pj.Spec.Job, pj.Status.URL, culprits[len(culprits)-1].sha, prs, branch)
+
+	var outcome string
+	switch revert.RevertAction() {
+	case config.RevertActionBlock:
+		title := fmt.Sprintf("Postsubmit %s failed after merging %s branch:%s", pj.Spec.Job, prs, branch)
+		body := failure + "\n\nTide does not merge into the branch until this issue is closed."
+		number, err := rc.ghc.CreateIssue(org, repo, title, body, 0, []string{rc.config().Tide.BlockerLabel}, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create blocker issue: %v", err)
+		}
+		outcome = fmt.Sprintf("Tide blocked merges into `%s` with #%d until the branch is fixed.", branch, number)
+	default:
+		if rc.dryRun {
+			rc.logger.WithFields(logrus.Fields{"org": org, "repo": repo, "merged": prs}).Info("Not reverting in dry-run mode.")
+			return nil
+		}
+		number, err := rc.revert(org, repo, branch, culprits, prs, failure)
+		if err != nil {
+			return err
+		}
+		outcome = fmt.Sprintf("Tide opened #%d to revert %s.", number, prs)
+	}
+
+	comment := fmt.Sprintf("%s\n%s %s", revertMarker, failure, outcome)
+	for _, pull := range culprits {
+		if err := rc.ghc.CreateComment(org, repo, pull.Number, comment); err != nil {
+			return fmt.Errorf("failed to comment on #%d: %v", pull.Number, err)
+		}
+	}
+	return nil
+}
+
+// revert opens a PR from the fork of the bot that reverts the merge commits
+// of the culprits, most recent first, and returns its number.
+func (rc *revertController) revert(org, repo, branch string, culprits []mergedPull, prs, failure string) (int, error) {
+	botName, err := rc.ghc.BotName()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get bot name: %v", err)
+	}
+	r, err := rc.gc.Clone(org + "/" + repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clone: %v", err)
+	}
+	defer func() {
+		if err := r.Clean(); err != nil {
+			rc.logger.WithError(err).Error("Failed to clean up repo.")
+		}
+	}()
+	if err := r.Config("user.name", "prow"); err != nil {
+		return 0, err
+	}
+	if err := r.Config("user.email", "prow@localhost"); err != nil {
+		return 0, err
+	}
+	if err := r.Checkout(branch); err != nil {
+		return 0, err
+	}
+	// The branch of an earlier attempt may be left in the fork, so the name
+	// is unique to this attempt.
+	last := culprits[len(culprits)-1].sha
+	revertBranch := fmt.Sprintf("tide-revert-%s-%d", last[:minInt(len(last), 8)], time.Now().Unix())
+	if err := r.CheckoutNewBranch(revertBranch); err != nil {
+		return 0, err
+	}
+	for i := len(culprits) - 1; i >= 0; i-- {
+		if err := r.Revert(culprits[i].sha); err != nil {
+			return 0, err
+		}
+	}
+	if err := r.Push(repo, revertBranch); err != nil {
+		return 0, err
+	}
+	title := fmt.Sprintf("Revert %s", prs)
+	number, err := rc.ghc.CreatePullRequest(org, repo, title, failure, botName+":"+revertBranch, branch, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create revert PR: %v", err)
+	}
+	return number, nil
+}
+
+// splitPoolKey returns the org, repo and branch of a pool key.
+func splitPoolKey(key string) (string, string, string, error) {
+	slash := strings.Index(key, "/")
+	colon := strings.Index(key, ":")
+	if slash < 1 || colon < slash+2 || colon == len(key)-1 {
+		return "", "", "", fmt.Errorf("invalid pool key %q", key)
+	}
+	return key[:slash], key[slash+1 : colon], key[colon+1:], nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
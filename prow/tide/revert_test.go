@@ -0,0 +1,243 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+type fakeRevertClient struct {
+	mergeSHAs map[int]string
+	comments  map[int][]string
+	issues    []string
+	labels    []string
+}
+
+func (f *fakeRevertClient) BotName() (string, error) {
+	return "bot", nil
+}
+
+func (f *fakeRevertClient) CreateComment(org, repo string, number int, comment string) error {
+	f.comments[number] = append(f.comments[number], comment)
+	return nil
+}
+
+func (f *fakeRevertClient) CreateIssue(org, repo, title, body string, milestone int, labels, assignees []string) (int, error) {
+	f.issues = append(f.issues, title)
+	f.labels = append(f.labels, labels...)
+	return 1000 + len(f.issues), nil
+}
+
+func (f *fakeRevertClient) CreatePullRequest(org, repo, title, body, head, base string, canModify bool) (int, error) {
+	return 0, fmt.Errorf("unexpected revert PR %q", title)
+}
+
+func (f *fakeRevertClient) GetPullRequest(org, repo string, number int) (*github.PullRequest, error) {
+	pr := &github.PullRequest{Number: number}
+	if sha, ok := f.mergeSHAs[number]; ok {
+		pr.Merged = true
+		pr.MergeSHA = &sha
+	}
+	return pr, nil
+}
+
+func (f *fakeRevertClient) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	var comments []github.IssueComment
+	for _, body := range f.comments[number] {
+		comments = append(comments, github.IssueComment{Body: body})
+	}
+	return comments, nil
+}
+
+func TestRevertControllerSync(t *testing.T) {
+	postsubmit := func(name, sha string, state prowapi.ProwJobState) runtime.Object {
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("%s-%s", name, sha), Namespace: "default"},
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.PostsubmitJob,
+				Job:  name,
+				Refs: &prowapi.Refs{Org: "o", Repo: "r", BaseRef: "master", BaseSHA: sha},
+			},
+			Status: prowapi.ProwJobStatus{State: state, URL: "https://prow/" + name},
+		}
+	}
+	testcases := []struct {
+		name       string
+		prowJobs   []runtime.Object
+		comments   map[int][]string
+		notOptedIn bool
+		revert     bool
+		dryRun     bool
+
+		expectedCommented []int
+		expectedIssues    int
+	}{
+		{
+			name:     "blocking postsubmit passed",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m2", prowapi.SuccessState)},
+		},
+		{
+			name:     "non-blocking postsubmit failed",
+			prowJobs: []runtime.Object{postsubmit("other", "m2", prowapi.FailureState)},
+		},
+		{
+			name:       "repo did not opt in",
+			prowJobs:   []runtime.Object{postsubmit("blocking", "m2", prowapi.FailureState)},
+			notOptedIn: true,
+		},
+		{
+			name:     "blocking postsubmit failed on the second merge of the batch",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m2", prowapi.FailureState)},
+
+			expectedCommented: []int{1, 2},
+			expectedIssues:    1,
+		},
+		{
+			name:     "blocking postsubmit failed on the first merge of the batch",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m1", prowapi.FailureState)},
+
+			expectedCommented: []int{1},
+			expectedIssues:    1,
+		},
+		{
+			name:     "blocking postsubmit errored",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m1", prowapi.ErrorState)},
+		},
+		{
+			name:     "revert is skipped in dry-run mode",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m2", prowapi.FailureState)},
+			revert:   true,
+			dryRun:   true,
+		},
+		{
+			name: "branch was already red before the batch",
+			prowJobs: []runtime.Object{
+				postsubmit("blocking", "base", prowapi.FailureState),
+				postsubmit("blocking", "m2", prowapi.FailureState),
+			},
+		},
+		{
+			name:     "merge was handled before a restart",
+			prowJobs: []runtime.Object{postsubmit("blocking", "m2", prowapi.FailureState)},
+			comments: map[int][]string{2: {revertMarker + "\nReverted."}},
+
+			expectedCommented: []int{2},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{ProwConfig: config.ProwConfig{
+				ProwJobNamespace: "default",
+				Tide: config.Tide{
+					BlockerLabel: "tide/merge-blocker",
+					PostsubmitReverts: []config.TidePostsubmitRevert{{
+						Repos:  []string{"o/r"},
+						Jobs:   []string{"blocking"},
+						Action: config.RevertActionBlock,
+					}},
+				},
+			}}
+			if tc.revert {
+				cfg.Tide.PostsubmitReverts[0].Action = config.RevertActionRevert
+			}
+			if tc.notOptedIn {
+				cfg.Tide.PostsubmitReverts[0].Repos = []string{"o/other"}
+			}
+			hist, err := history.New(10, nil, "")
+			if err != nil {
+				t.Fatalf("failed to create history: %v", err)
+			}
+			hist.Record("o/r:master", MergeBatch, "base", "", []prowapi.Pull{{Number: 1}, {Number: 2}, {Number: 3}})
+			comments := tc.comments
+			if comments == nil {
+				comments = map[int][]string{}
+			}
+			ghc := &fakeRevertClient{
+				// PR 3 failed to merge.
+				mergeSHAs: map[int]string{1: "m1", 2: "m2"},
+				comments:  comments,
+			}
+			rc := newRevertController(
+				logrus.WithField("component", "tide"),
+				func() *config.Config { return cfg },
+				ghc, nil, tc.dryRun,
+				fakectrlruntimeclient.NewFakeClient(tc.prowJobs...),
+				hist,
+			)
+
+			// A second sync must not handle the merge again.
+			rc.sync()
+			rc.sync()
+
+			var commented []int
+			for number, comments := range ghc.comments {
+				commented = append(commented, number)
+				if len(comments) != 1 {
+					t.Errorf("expected one comment on #%d, got %d", number, len(comments))
+				}
+				if !strings.HasPrefix(comments[0], revertMarker) {
+					t.Errorf("expected comment on #%d to start with the marker, got %q", number, comments[0])
+				}
+			}
+			sort.Ints(commented)
+			if !reflect.DeepEqual(commented, tc.expectedCommented) {
+				t.Errorf("expected comments on %v, got %v", tc.expectedCommented, commented)
+			}
+			if len(ghc.issues) != tc.expectedIssues {
+				t.Fatalf("expected %d blocker issues, got %v", tc.expectedIssues, ghc.issues)
+			}
+			if tc.expectedIssues > 0 {
+				if !strings.HasSuffix(ghc.issues[0], " branch:master") {
+					t.Errorf("expected blocker issue to block master, got title %q", ghc.issues[0])
+				}
+				if !reflect.DeepEqual(ghc.labels, []string{"tide/merge-blocker"}) {
+					t.Errorf("expected blocker label, got %v", ghc.labels)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPoolKey(t *testing.T) {
+	org, repo, branch, err := splitPoolKey(poolKey("o", "r", "release/1.0"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if org != "o" || repo != "r" || branch != "release/1.0" {
+		t.Errorf("expected o, r and release/1.0, got %s, %s and %s", org, repo, branch)
+	}
+	for _, key := range []string{"o/r", "o:master", "/r:master", "o/:master", "o/r:"} {
+		if _, _, _, err := splitPoolKey(key); err == nil {
+			t.Errorf("expected an error for %q", key)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git"
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/tide/history"
+)
+
+// Snapshot is the state of the open PRs, their branches and their ProwJobs
+// that a simulation runs against. It is recorded from GitHub and the cluster
+// with TakeSnapshot and can be stored as JSON to replay it later.
+type Snapshot struct {
+	// PRs are the open PRs that match the queries of the configs the
+	// snapshot was taken for. The contexts of their head commit are included.
+	PRs []PullRequest `json:"prs"`
+	// ChangedFiles are the files changed by the PRs, by PR key like
+	// "org/repo#1". PRs without an entry change no files.
+	ChangedFiles map[string][]string `json:"changed_files,omitempty"`
+	// Branches are the SHAs of the base branches, by pool like
+	// "org/repo:branch".
+	Branches map[string]string `json:"branches"`
+	// ProwJobs are the presubmit and batch jobs of the repos of the PRs.
+	ProwJobs []prowapi.ProwJob `json:"prowjobs,omitempty"`
+}
+
+// TakeSnapshot searches GitHub for the PRs that match the queries of any of
+// the configs and records them together with their base branches, changed
+// files and ProwJobs. It only reads from GitHub.
+func TakeSnapshot(ghc github.Client, log *logrus.Entry, pjs []prowapi.ProwJob, cfgs ...*config.Config) (*Snapshot, error) {
+	prs := map[string]PullRequest{}
+	for _, cfg := range cfgs {
+		for _, query := range cfg.Tide.Queries {
+			q := query.Query()
+			results, err := search(ghc.Query, log, q, time.Time{}, time.Now())
+			if err != nil && len(results) == 0 {
+				return nil, fmt.Errorf("query %q, err: %v", q, err)
+			}
+			if err != nil {
+				log.WithError(err).WithField("query", q).Warning("found partial results")
+			}
+			for _, pr := range results {
+				prs[prKey(&pr)] = pr
+			}
+		}
+	}
+
+	snapshot := &Snapshot{ChangedFiles: map[string][]string{}, Branches: map[string]string{}}
+	repos := sets.NewString()
+	for key, pr := range prs {
+		org := string(pr.Repository.Owner.Login)
+		repo := string(pr.Repository.Name)
+		// Only keep the head commit, with the contexts it has on GitHub.
+		contexts, err := headContexts(log, ghc, &pr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the contexts of %s: %v", key, err)
+		}
+		pr.Commits.Nodes = []struct{ Commit Commit }{{Commit: Commit{OID: pr.HeadRefOID}}}
+		pr.Commits.Nodes[0].Commit.Status.Contexts = contexts
+
+		changes, err := ghc.GetPullRequestChanges(org, repo, int(pr.Number))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the changes of %s: %v", key, err)
+		}
+		for _, change := range changes {
+			snapshot.ChangedFiles[key] = append(snapshot.ChangedFiles[key], change.Filename)
+		}
+
+		pool := poolKey(org, repo, string(pr.BaseRef.Name))
+		if _, ok := snapshot.Branches[pool]; !ok {
+			branchRef := string(pr.BaseRef.Prefix) + string(pr.BaseRef.Name)
+			sha, err := ghc.GetRef(org, repo, strings.TrimPrefix(branchRef, "refs/"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the branch of %s: %v", pool, err)
+			}
+			snapshot.Branches[pool] = sha
+		}
+		repos.Insert(org + "/" + repo)
+		snapshot.PRs = append(snapshot.PRs, pr)
+	}
+	sort.Slice(snapshot.PRs, func(i, j int) bool { return prKey(&snapshot.PRs[i]) < prKey(&snapshot.PRs[j]) })
+
+	for _, pj := range pjs {
+		if pj.Spec.Type != prowapi.PresubmitJob && pj.Spec.Type != prowapi.BatchJob {
+			continue
+		}
+		if pj.Spec.Refs == nil || !repos.Has(pj.Spec.Refs.Org+"/"+pj.Spec.Refs.Repo) {
+			continue
+		}
+		snapshot.ProwJobs = append(snapshot.ProwJobs, pj)
+	}
+	return snapshot, nil
+}
+
+// snapshotClient serves the reads of a simulation from a snapshot and only
+// records the merges and ProwJobs that the simulation would create. It acts
+// as the GitHub client, the provider of all PRs and the ProwJob client.
+type snapshotClient struct {
+	// Client is nil, only List and Create are used by the sync.
+	ctrlruntimeclient.Client
+
+	snapshot *Snapshot
+	gc       *git.Client
+
+	lock    sync.Mutex
+	created []prowapi.ProwJob
+}
+
+func (s *snapshotClient) CreateStatus(string, string, string, github.Status) error {
+	return errors.New("statuses are not set in simulations")
+}
+
+func (s *snapshotClient) GetCombinedStatus(org, repo, ref string) (*github.CombinedStatus, error) {
+	return nil, fmt.Errorf("the snapshot has no status for %s/%s@%s", org, repo, ref)
+}
+
+func (s *snapshotClient) GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error) {
+	var changes []github.PullRequestChange
+	for _, file := range s.snapshot.ChangedFiles[fmt.Sprintf("%s/%s#%d", org, repo, number)] {
+		changes = append(changes, github.PullRequestChange{Filename: file})
+	}
+	return changes, nil
+}
+
+func (s *snapshotClient) GetRef(org, repo, ref string) (string, error) {
+	return s.getRef(org, repo, ref)
+}
+
+func (s *snapshotClient) ListReviews(org, repo string, number int) ([]github.Review, error) {
+	return nil, fmt.Errorf("the snapshot has no reviews for %s/%s#%d", org, repo, number)
+}
+
+func (s *snapshotClient) Merge(string, string, int, github.MergeDetails) error {
+	return nil
+}
+
+func (s *snapshotClient) Query(context.Context, interface{}, map[string]interface{}) error {
+	return errors.New("the snapshot cannot be searched")
+}
+
+func (s *snapshotClient) getRef(org, repo, ref string) (string, error) {
+	pool := poolKey(org, repo, strings.TrimPrefix(ref, "heads/"))
+	sha, ok := s.snapshot.Branches[pool]
+	if !ok {
+		return "", fmt.Errorf("the snapshot has no SHA for %s", pool)
+	}
+	return sha, nil
+}
+
+// merge does nothing, the merges are reported by the action of the pool.
+func (s *snapshotClient) merge(org, repo string, pr PullRequest, details github.MergeDetails) error {
+	return nil
+}
+
+// clone only clones the repo if the simulation has a git client. Without one
+// all PRs are assumed to merge cleanly.
+func (s *snapshotClient) clone(org, repo string) (*git.Repo, error) {
+	if s.gc == nil {
+		return nil, nil
+	}
+	return s.gc.Clone(org + "/" + repo)
+}
+
+func (s *snapshotClient) decorateRefs(refs *prowapi.Refs, prs []PullRequest) {}
+
+func (s *snapshotClient) contextChecker(cfg *config.Config, sp *subpool, pr PullRequest) (contextChecker, error) {
+	return cfg.GetTideContextPolicy(s.gc, sp.org, sp.repo, sp.branch, refGetterFactory(string(sp.sha)), string(pr.HeadRefOID))
+}
+
+// List lists the ProwJobs of the snapshot and the ones created by the
+// simulation that match the index of tide.
+func (s *snapshotClient) List(ctx context.Context, obj runtime.Object, opts ...ctrlruntimeclient.ListOption) error {
+	pjs, ok := obj.(*prowapi.ProwJobList)
+	if !ok {
+		return fmt.Errorf("cannot list %T in simulations", obj)
+	}
+	listOpts := &ctrlruntimeclient.ListOptions{}
+	for _, opt := range opts {
+		opt.ApplyToList(listOpts)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, pj := range append(append([]prowapi.ProwJob{}, s.snapshot.ProwJobs...), s.created...) {
+		if listOpts.FieldSelector != nil && !matchesIndex(&pj, listOpts.FieldSelector.Requirements()) {
+			continue
+		}
+		pjs.Items = append(pjs.Items, pj)
+	}
+	return nil
+}
+
+// matchesIndex returns whether the ProwJob has the value of every
+// requirement on the index of tide.
+func matchesIndex(pj *prowapi.ProwJob, requirements fields.Requirements) bool {
+	for _, req := range requirements {
+		if req.Field != cacheIndexName {
+			continue
+		}
+		if !sets.NewString(cacheIndexFunc(pj)...).Has(req.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create records the ProwJob, so that the pools see it as if it was running.
+func (s *snapshotClient) Create(ctx context.Context, obj runtime.Object, opts ...ctrlruntimeclient.CreateOption) error {
+	pj, ok := obj.(*prowapi.ProwJob)
+	if !ok {
+		return fmt.Errorf("cannot create %T in simulations", obj)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.created = append(s.created, *pj)
+	return nil
+}
+
+// SimulatedPool is the outcome of the simulated sync of a pool.
+type SimulatedPool struct {
+	Org    string `json:"org"`
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+
+	// Matched are the PRs that match a query of the config.
+	Matched []int `json:"matched"`
+	// Eligible are the matched PRs that are mergeable and pass their
+	// required contexts, so tide could test or merge them.
+	Eligible []int `json:"eligible"`
+
+	Action  Action `json:"action"`
+	Targets []int  `json:"targets,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Key returns the key of the pool, like "org/repo:branch".
+func (p SimulatedPool) Key() string {
+	return poolKey(p.Org, p.Repo, p.Branch)
+}
+
+// Simulate syncs the pools of the snapshot with the config once without side
+// effects and returns what tide would do with every pool. Merges and
+// triggered ProwJobs are not carried out, only reported by the pools. If gc is
+// nil, the PRs of a batch are assumed to merge cleanly.
+//
+// Whether PRs match the queries is evaluated locally from the labels,
+// milestone and branch of the PRs. Review approvals and blocking issues are
+// not part of the snapshot, so they are not taken into account.
+func Simulate(cfg config.Getter, snapshot *Snapshot, gc *git.Client, logger *logrus.Entry) ([]SimulatedPool, error) {
+	hist, err := history.New(1, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	sim := &snapshotClient{snapshot: snapshot, gc: gc}
+	c := &Controller{
+		ctx:           context.Background(),
+		logger:        logger.WithField("controller", "simulation"),
+		config:        cfg,
+		ghc:           sim,
+		prowJobClient: sim,
+		gc:            gc,
+		provider:      sim,
+		changedFiles: &changedFilesAgent{
+			ghc:             sim,
+			nextChangeCache: make(map[changeCacheKey][]string),
+		},
+		History: hist,
+	}
+	// Nothing is merged, so there is no need to wait between merges.
+	defer func(s func(time.Duration)) { sleep = s }(sleep)
+	sleep = func(time.Duration) {}
+
+	queries := cfg().Tide.Queries.QueryMap()
+	prs := map[string]PullRequest{}
+	for _, pr := range snapshot.PRs {
+		if matchesQuery(queries, &pr) {
+			prs[prKey(&pr)] = pr
+		}
+	}
+	raw, err := c.dividePool(prs)
+	if err != nil {
+		return nil, err
+	}
+	// Filtering the subpools drops PRs from them.
+	matched := make(map[string][]int, len(raw))
+	for key, sp := range raw {
+		matched[key] = sortedNumbers(sp.prs)
+	}
+	filtered := c.filterSubpools(cfg().Tide.MaxGoroutines, raw)
+
+	var pools []SimulatedPool
+	for key, sp := range raw {
+		pool := SimulatedPool{
+			Org:     sp.org,
+			Repo:    sp.repo,
+			Branch:  sp.branch,
+			Matched: matched[key],
+			Action:  Wait,
+		}
+		if sp, ok := filtered[key]; ok {
+			pool.Eligible = sortedNumbers(sp.prs)
+			result, err := c.syncSubpool(*sp, nil)
+			pool.Action = result.Action
+			for _, pr := range result.Target {
+				pool.Targets = append(pool.Targets, int(pr.Number))
+			}
+			if err != nil {
+				pool.Error = err.Error()
+			}
+		}
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Key() < pools[j].Key() })
+	return pools, nil
+}
+
+// allOptional treats all contexts as optional, as they are not part of the
+// queries but of the eligibility of PRs.
+type allOptional struct{}
+
+func (allOptional) IsOptional(string) bool                    { return true }
+func (allOptional) MissingRequiredContexts([]string) []string { return nil }
+
+// matchesQuery returns whether the PR meets all requirements of one of the
+// queries of its repo that can be checked without GitHub.
+func matchesQuery(queries *config.QueryMap, pr *PullRequest) bool {
+	for _, q := range queries.ForRepo(string(pr.Repository.Owner.Login), string(pr.Repository.Name)) {
+		if _, diff := requirementDiff(pr, &q, allOptional{}); diff == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNumbers(prs []PullRequest) []int {
+	numbers := prNumbers(prs)
+	sort.Ints(numbers)
+	return numbers
+}
+
+// WriteSimulationReport compares the simulations of the current and the
+// candidate config pool by pool. It writes the action of every pool under
+// both configs and the PRs that become eligible or ineligible for merging.
+func WriteSimulationReport(w io.Writer, current, candidate []SimulatedPool) error {
+	byKey := func(pools []SimulatedPool) map[string]SimulatedPool {
+		m := make(map[string]SimulatedPool, len(pools))
+		for _, pool := range pools {
+			m[pool.Key()] = pool
+		}
+		return m
+	}
+	cur, cand := byKey(current), byKey(candidate)
+	keys := sets.NewString()
+	for key := range cur {
+		keys.Insert(key)
+	}
+	for key := range cand {
+		keys.Insert(key)
+	}
+
+	for _, key := range keys.List() {
+		before, after := cur[key], cand[key]
+		changed := " "
+		if before.Action != after.Action || !equalNumbers(before.Targets, after.Targets) || !equalNumbers(before.Eligible, after.Eligible) {
+			changed = "*"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s\n", changed, key); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "    current:   %s\n    candidate: %s\n", describePool(before), describePool(after)); err != nil {
+			return err
+		}
+		gained := sets.NewInt(after.Eligible...).Difference(sets.NewInt(before.Eligible...))
+		lost := sets.NewInt(before.Eligible...).Difference(sets.NewInt(after.Eligible...))
+		if gained.Len() > 0 {
+			if _, err := fmt.Fprintf(w, "    newly eligible:   %v\n", gained.List()); err != nil {
+				return err
+			}
+		}
+		if lost.Len() > 0 {
+			if _, err := fmt.Fprintf(w, "    newly ineligible: %v\n", lost.List()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// describePool describes the action of a simulated pool in one line.
+func describePool(pool SimulatedPool) string {
+	if pool.Action == "" {
+		return "not in the pool"
+	}
+	desc := string(pool.Action)
+	if len(pool.Targets) > 0 {
+		desc += fmt.Sprintf(" %v", pool.Targets)
+	}
+	desc += fmt.Sprintf(" (%d of %d matched PRs eligible)", len(pool.Eligible), len(pool.Matched))
+	if pool.Error != "" {
+		desc += ": " + pool.Error
+	}
+	return desc
+}
+
+func equalNumbers(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
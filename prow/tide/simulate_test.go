@@ -0,0 +1,153 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+func simulatedPR(number int, labels ...string) PullRequest {
+	pr := PullRequest{Number: githubql.Int(number)}
+	pr.Repository.Owner.Login = "o"
+	pr.Repository.Name = "r"
+	pr.Repository.NameWithOwner = "o/r"
+	pr.BaseRef.Name = "master"
+	pr.BaseRef.Prefix = "refs/heads/"
+	pr.HeadRefOID = githubql.String(strings.Repeat(string(rune('a'+number)), 4))
+	pr.Mergeable = githubql.MergeableStateMergeable
+	for _, label := range labels {
+		pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+	}
+	commit := Commit{OID: pr.HeadRefOID}
+	commit.Status.Contexts = []Context{{Context: "job", State: githubql.StatusStateSuccess}}
+	pr.Commits.Nodes = []struct{ Commit Commit }{{Commit: commit}}
+	return pr
+}
+
+func TestSimulate(t *testing.T) {
+	newConfig := func(labels ...string) config.Getter {
+		cfg := &config.Config{
+			ProwConfig: config.ProwConfig{
+				ProwJobNamespace: "default",
+				Tide: config.Tide{
+					Queries:       []config.TideQuery{{Repos: []string{"o/r"}, Labels: labels}},
+					MaxGoroutines: 2,
+				},
+			},
+		}
+		if err := cfg.SetPresubmits(map[string][]config.Presubmit{
+			"o/r": {{AlwaysRun: true, JobBase: config.JobBase{Name: "job"}, Reporter: config.Reporter{Context: "job"}}},
+		}); err != nil {
+			t.Fatalf("failed to set presubmits: %v", err)
+		}
+		return func() *config.Config { return cfg }
+	}
+	passed := prowapi.ProwJob{
+		Spec: prowapi.ProwJobSpec{
+			Type:    prowapi.PresubmitJob,
+			Job:     "job",
+			Context: "job",
+			Refs: &prowapi.Refs{
+				Org:     "o",
+				Repo:    "r",
+				BaseRef: "master",
+				BaseSHA: "base",
+				Pulls:   []prowapi.Pull{{Number: 2, SHA: "cccc"}},
+			},
+		},
+		Status: prowapi.ProwJobStatus{State: prowapi.SuccessState},
+	}
+	snapshot := &Snapshot{
+		PRs: []PullRequest{
+			simulatedPR(1, "lgtm"),
+			simulatedPR(2, "lgtm", "approved"),
+			simulatedPR(3),
+		},
+		Branches: map[string]string{"o/r:master": "base"},
+		ProwJobs: []prowapi.ProwJob{passed},
+	}
+	log := logrus.WithField("component", "tide")
+
+	current, err := Simulate(newConfig("lgtm"), snapshot, nil, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	candidate, err := Simulate(newConfig("lgtm", "approved"), snapshot, nil, log)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(current) != 1 || !reflect.DeepEqual(current[0].Eligible, []int{1, 2}) {
+		t.Fatalf("expected PRs 1 and 2 to be eligible with the current config, got %+v", current)
+	}
+	expected := []SimulatedPool{{
+		Org:      "o",
+		Repo:     "r",
+		Branch:   "master",
+		Matched:  []int{2},
+		Eligible: []int{2},
+		Action:   Merge,
+		Targets:  []int{2},
+	}}
+	if !reflect.DeepEqual(candidate, expected) {
+		t.Errorf("expected candidate pools %+v, got %+v", expected, candidate)
+	}
+
+	var report bytes.Buffer
+	if err := WriteSimulationReport(&report, current, candidate); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{"* o/r:master", "candidate: MERGE [2] (1 of 1 matched PRs eligible)", "newly ineligible: [1]"} {
+		if !strings.Contains(report.String(), line) {
+			t.Errorf("expected the report to contain %q, got:\n%s", line, report.String())
+		}
+	}
+}
+
+func TestSnapshotClientList(t *testing.T) {
+	pj := func(sha string) prowapi.ProwJob {
+		return prowapi.ProwJob{Spec: prowapi.ProwJobSpec{
+			Type: prowapi.BatchJob,
+			Refs: &prowapi.Refs{Org: "o", Repo: "r", BaseRef: "master", BaseSHA: sha},
+		}}
+	}
+	s := &snapshotClient{snapshot: &Snapshot{ProwJobs: []prowapi.ProwJob{pj("old"), pj("base")}}}
+	created := pj("base")
+	if err := s.Create(context.Background(), &created); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := &Controller{prowJobClient: s}
+	pjs := &prowapi.ProwJobList{}
+	if err := c.prowJobClient.List(context.Background(), pjs, ctrlruntimeclient.MatchingField(cacheIndexName, cacheIndexKey("o", "r", "master", "base"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pjs.Items) != 2 {
+		t.Errorf("expected the snapshot and the created job of the base, got %d jobs", len(pjs.Items))
+	}
+}
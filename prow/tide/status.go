@@ -76,6 +76,11 @@ type statusController struct {
 	sync.Mutex
 	poolPRs map[string]PullRequest
 	blocks  blockers.Blockers
+	// missingApprovals describes the OWNERS approvals that PRs lack by PR key.
+	missingApprovals map[string]string
+	// closedMergeWindows describes the closed merge windows that PRs wait
+	// for by PR key.
+	closedMergeWindows map[string]string
 
 	storedState
 	opener io.Opener
@@ -225,7 +230,7 @@ func requirementDiff(pr *PullRequest, q *config.TideQuery, cc contextChecker) (s
 // in order to generate a diff for the status description. We choose the query
 // for the repo that the PR is closest to meeting (as determined by the number
 // of unmet/violated requirements).
-func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers) (string, string) {
+func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]PullRequest, cc contextChecker, blocks blockers.Blockers, missingApprovals, closedMergeWindows map[string]string) (string, string) {
 	if _, ok := pool[prKey(pr)]; !ok {
 		// if the branch is blocked forget checking for a diff
 		blockingIssues := blocks.GetApplicable(string(pr.Repository.Owner.Login), string(pr.Repository.Name), string(pr.BaseRef.Name))
@@ -249,8 +254,15 @@ func expectedStatus(queryMap *config.QueryMap, pr *PullRequest, pool map[string]
 				minDiff = diff
 			}
 		}
+		// Fixing the query requirements takes precedence over approvals.
+		if desc, ok := missingApprovals[prKey(pr)]; ok && minDiffCount <= 0 {
+			minDiff = desc
+		}
 		return github.StatusPending, fmt.Sprintf(statusNotInPool, minDiff)
 	}
+	if desc, ok := closedMergeWindows[prKey(pr)]; ok {
+		return github.StatusPending, statusInPool + desc
+	}
 	return github.StatusSuccess, statusInPool
 }
 
@@ -276,7 +288,7 @@ func targetURL(c config.Getter, pr *PullRequest, log *logrus.Entry) string {
 	return link
 }
 
-func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullRequest, blocks blockers.Blockers) {
+func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullRequest, blocks blockers.Blockers, missingApprovals, closedMergeWindows map[string]string) {
 	// queryMap caches which queries match a repo.
 	// Make a new one each sync loop as queries will change.
 	queryMap := sc.config().Tide.Queries.QueryMap()
@@ -305,7 +317,7 @@ func (sc *statusController) setStatuses(all []PullRequest, pool map[string]PullR
 			return
 		}
 
-		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cr, blocks)
+		wantState, wantDesc := expectedStatus(queryMap, pr, pool, cr, blocks, missingApprovals, closedMergeWindows)
 		var actualState githubql.StatusState
 		var actualDesc string
 		for _, ctx := range contexts {
@@ -439,8 +451,10 @@ func (sc *statusController) waitSync() {
 			sc.Lock()
 			pool := sc.poolPRs
 			blocks := sc.blocks
+			missingApprovals := sc.missingApprovals
+			closedMergeWindows := sc.closedMergeWindows
 			sc.Unlock()
-			sc.sync(pool, blocks)
+			sc.sync(pool, blocks, missingApprovals, closedMergeWindows)
 			return
 		case more := <-sc.newPoolPending:
 			if !more {
@@ -450,7 +464,7 @@ func (sc *statusController) waitSync() {
 	}
 }
 
-func (sc *statusController) sync(pool map[string]PullRequest, blocks blockers.Blockers) {
+func (sc *statusController) sync(pool map[string]PullRequest, blocks blockers.Blockers, missingApprovals, closedMergeWindows map[string]string) {
 	sc.lastSyncStart = time.Now()
 	defer func() {
 		duration := time.Since(sc.lastSyncStart)
@@ -458,7 +472,7 @@ func (sc *statusController) sync(pool map[string]PullRequest, blocks blockers.Bl
 		tideMetrics.statusUpdateDuration.Set(duration.Seconds())
 	}()
 
-	sc.setStatuses(sc.search(), pool, blocks)
+	sc.setStatuses(sc.search(), pool, blocks, missingApprovals, closedMergeWindows)
 }
 
 func (sc *statusController) search() []PullRequest {
@@ -46,6 +46,8 @@ func TestExpectedStatus(t *testing.T) {
 		contexts        []Context
 		inPool          bool
 		blocks          []int
+		approvals       string
+		closedWindow    string
 
 		state string
 		desc  string
@@ -74,6 +76,14 @@ func TestExpectedStatus(t *testing.T) {
 			state: github.StatusPending,
 			desc:  fmt.Sprintf(statusNotInPool, " Needs need-a-very-super-duper-extra-not-short-at-all-label-name label."),
 		},
+		{
+			name:         "in pool while the merge window is closed",
+			inPool:       true,
+			closedWindow: " Merge window closed until Mon Dec 9 09:00 CET.",
+
+			state: github.StatusPending,
+			desc:  "In merge pool. Merge window closed until Mon Dec 9 09:00 CET.",
+		},
 		{
 			name:      "has forbidden labels",
 			labels:    append(append([]string{}, neededLabels...), forbiddenLabels...),
@@ -206,6 +216,26 @@ func TestExpectedStatus(t *testing.T) {
 			state: github.StatusError,
 			desc:  fmt.Sprintf(statusNotInPool, " Merging is blocked by issues 1, 2."),
 		},
+		{
+			name:      "missing OWNERS approvals are surfaced",
+			labels:    neededLabels,
+			milestone: "v1.0",
+			inPool:    false,
+			approvals: " Needs approval from pkg/OWNERS (alice).",
+
+			state: github.StatusPending,
+			desc:  fmt.Sprintf(statusNotInPool, " Needs approval from pkg/OWNERS (alice)."),
+		},
+		{
+			name:      "check that query requirements take precedence over OWNERS approvals",
+			labels:    neededLabels[:2],
+			milestone: "v1.0",
+			inPool:    false,
+			approvals: " Needs approval from pkg/OWNERS (alice).",
+
+			state: github.StatusPending,
+			desc:  fmt.Sprintf(statusNotInPool, " Needs need-a-very-super-duper-extra-not-short-at-all-label-name label."),
+		},
 	}
 
 	for _, tc := range testcases {
@@ -275,7 +305,17 @@ func TestExpectedStatus(t *testing.T) {
 		}
 		blocks.Repo[blockers.OrgRepo{Org: "", Repo: ""}] = items
 
-		state, desc := expectedStatus(queriesByRepo, &pr, pool, &config.TideContextPolicy{}, blocks)
+		var approvals map[string]string
+		if tc.approvals != "" {
+			approvals = map[string]string{"#0": tc.approvals}
+		}
+
+		var closedWindows map[string]string
+		if tc.closedWindow != "" {
+			closedWindows = map[string]string{"#0": tc.closedWindow}
+		}
+
+		state, desc := expectedStatus(queriesByRepo, &pr, pool, &config.TideContextPolicy{}, blocks, approvals, closedWindows)
 		if state != tc.state {
 			t.Errorf("Expected status state %q, but got %q.", string(tc.state), string(state))
 		}
@@ -394,7 +434,7 @@ func TestSetStatuses(t *testing.T) {
 		}
 
 		sc := &statusController{ghc: fc, gc: &git.Client{}, config: ca.Config, logger: log}
-		sc.setStatuses([]PullRequest{pr}, pool, blockers.Blockers{})
+		sc.setStatuses([]PullRequest{pr}, pool, blockers.Blockers{}, nil, nil)
 		if str, err := log.String(); err != nil {
 			t.Fatalf("For case %s: failed to get log output: %v", tc.name, err)
 		} else if str != initialLog {
@@ -44,6 +44,7 @@ import (
 	"k8s.io/test-infra/prow/git"
 	"k8s.io/test-infra/prow/github"
 	"k8s.io/test-infra/prow/pjutil"
+	"k8s.io/test-infra/prow/repoowners"
 	"k8s.io/test-infra/prow/tide/blockers"
 	"k8s.io/test-infra/prow/tide/history"
 )
@@ -56,10 +57,53 @@ type githubClient interface {
 	GetCombinedStatus(org, repo, ref string) (*github.CombinedStatus, error)
 	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
 	GetRef(string, string, string) (string, error)
+	ListReviews(org, repo string, number int) ([]github.Review, error)
 	Merge(string, string, int, github.MergeDetails) error
 	Query(context.Context, interface{}, map[string]interface{}) error
 }
 
+// provider is the code-review system that the PRs of a subpool are sourced
+// from and merged through. GitHub is the default provider.
+type provider interface {
+	// getRef returns the SHA of a ref of the repo, like "heads/master".
+	getRef(org, repo, ref string) (string, error)
+	// merge merges the PR into its base branch.
+	merge(org, repo string, pr PullRequest, details github.MergeDetails) error
+	// clone clones the repo to check which PRs merge cleanly together. If
+	// the provider cannot clone the repo, it returns a nil repo and all PRs
+	// are assumed to merge cleanly.
+	clone(org, repo string) (*git.Repo, error)
+	// decorateRefs adds what is needed to check out the PRs to the refs of
+	// the jobs that test them.
+	decorateRefs(refs *prowapi.Refs, prs []PullRequest)
+	// contextChecker returns the checker for the status contexts of the PR.
+	contextChecker(cfg *config.Config, sp *subpool, pr PullRequest) (contextChecker, error)
+}
+
+// githubProvider sources PRs from GitHub.
+type githubProvider struct {
+	ghc githubClient
+	gc  *git.Client
+}
+
+func (p *githubProvider) getRef(org, repo, ref string) (string, error) {
+	return p.ghc.GetRef(org, repo, ref)
+}
+
+func (p *githubProvider) merge(org, repo string, pr PullRequest, details github.MergeDetails) error {
+	return p.ghc.Merge(org, repo, int(pr.Number), details)
+}
+
+func (p *githubProvider) clone(org, repo string) (*git.Repo, error) {
+	return p.gc.Clone(org + "/" + repo)
+}
+
+func (p *githubProvider) decorateRefs(refs *prowapi.Refs, prs []PullRequest) {}
+
+func (p *githubProvider) contextChecker(cfg *config.Config, sp *subpool, pr PullRequest) (contextChecker, error) {
+	return cfg.GetTideContextPolicy(p.gc, sp.org, sp.repo, sp.branch, refGetterFactory(string(sp.sha)), string(pr.HeadRefOID))
+}
+
 type contextChecker interface {
 	// IsOptional tells whether a context is optional.
 	IsOptional(string) bool
@@ -85,6 +129,28 @@ type Controller struct {
 	// Cache entries expire if they are not used during a sync loop.
 	changedFiles *changedFilesAgent
 
+	// trains remembers the recent merges of train cars per pool.
+	trains trainSteps
+	// bisections remembers the failed batches per pool across base changes.
+	bisections failedBatchMemory
+
+	// gerrit sources changes from Gerrit, if enabled.
+	gerrit *gerritProvider
+	// provider replaces GitHub as the provider of all other PRs, if set.
+	provider provider
+
+	// owners loads the OWNERS files of repos whose PRs need their approval.
+	owners repoowners.Interface
+	// approvals caches the approvers of the PRs whose OWNERS must approve
+	// them.
+	approvals *approvalsAgent
+
+	// reverts reverts merges whose blocking postsubmits fail.
+	reverts *revertController
+
+	// conflicts predicts which PRs of a pool conflict with each other.
+	conflicts *conflictAgent
+
 	History *history.History
 }
 
@@ -100,15 +166,25 @@ const (
 	Merge               = "MERGE"
 	MergeBatch          = "MERGE_BATCH"
 	PoolBlocked         = "BLOCKED"
+	// PoolClosed waits for the merge window to open, because none of the
+	// PRs are exempt from it.
+	PoolClosed = "CLOSED"
+	// TriggerBisection tests the halves of a failed batch as new batches.
+	TriggerBisection = "TRIGGER_BISECTION"
+	// TriggerTrainCar tests a batch on top of the last car of the merge train.
+	TriggerTrainCar = "TRIGGER_TRAIN_CAR"
 )
 
 // recordableActions is the subset of actions that we keep historical record of.
 // Ignore idle actions to avoid flooding the records with useless data.
+// TriggerBisection is recorded once for every half of the bisected batch
+// when it is triggered, so that the history shows which subsets were tested.
 var recordableActions = map[Action]bool{
-	Trigger:      true,
-	TriggerBatch: true,
-	Merge:        true,
-	MergeBatch:   true,
+	Trigger:         true,
+	TriggerBatch:    true,
+	TriggerTrainCar: true,
+	Merge:           true,
+	MergeBatch:      true,
 }
 
 // Pool represents information about a tide pool. There is one for every
@@ -118,6 +194,10 @@ type Pool struct {
 	Repo   string
 	Branch string
 
+	// GerritInstance is the URL of the Gerrit instance the PRs of the pool
+	// are changes of. Empty for GitHub pools.
+	GerritInstance string `json:",omitempty"`
+
 	// PRs with passing tests, pending tests, and missing or failed tests.
 	// Note that these results are rolled up. If all tests for a PR are passing
 	// except for one pending, it will be in PendingPRs.
@@ -128,11 +208,21 @@ type Pool struct {
 	// Empty if there is no pending batch.
 	BatchPending []PullRequest
 
+	// Priorities maps the numbers of the PRs that are in a priority tier to
+	// the index of that tier in the tide config. Other PRs are omitted.
+	Priorities map[int]int
+
 	// Which action did we last take, and to what target(s), if any.
 	Action   Action
 	Target   []PullRequest
 	Blockers []blockers.Blocker
 	Error    string
+
+	// MergeWindowClosed is set while the merge window of the branch is
+	// closed, so that only PRs with an exempt label are tested and merged.
+	MergeWindowClosed bool `json:",omitempty"`
+	// MergeWindowOpens is when the closed merge window opens, if known.
+	MergeWindowOpens *time.Time `json:",omitempty"`
 }
 
 // Prometheus Metrics
@@ -210,7 +300,7 @@ type manager interface {
 }
 
 // NewController makes a Controller out of the given clients.
-func NewController(ghcSync, ghcStatus github.Client, mgr manager, cfg config.Getter, gc *git.Client, maxRecordsPerPool int, opener io.Opener, historyURI, statusURI string, logger *logrus.Entry) (*Controller, error) {
+func NewController(ghcSync, ghcStatus github.Client, mgr manager, cfg config.Getter, gc *git.Client, dryRun bool, maxRecordsPerPool int, opener io.Opener, historyURI, statusURI string, logger *logrus.Entry) (*Controller, error) {
 	if logger == nil {
 		logger = logrus.NewEntry(logrus.StandardLogger())
 	}
@@ -230,7 +320,20 @@ func NewController(ghcSync, ghcStatus github.Client, mgr manager, cfg config.Get
 		path:           statusURI,
 	}
 	go sc.run()
-	return newSyncController(logger, ghcSync, mgr, cfg, gc, sc, hist)
+	c, err := newSyncController(logger, ghcSync, mgr, cfg, gc, sc, hist)
+	if err != nil {
+		return nil, err
+	}
+	c.owners = repoowners.NewClient(
+		gc, ghcSync,
+		func(org, repo string) bool { return false },
+		func(org, repo string) bool { return false },
+		func() config.OwnersDirBlacklist { return cfg().OwnersDirBlacklist },
+	)
+	c.approvals = newApprovalsAgent(ghcSync)
+	c.reverts = newRevertController(logger, cfg, ghcSync, gc, dryRun, mgr.GetClient(), hist)
+	c.conflicts = newConflictAgent(ghcSync)
+	return c, nil
 }
 
 func newSyncController(
@@ -271,6 +374,27 @@ func newSyncController(
 func (c *Controller) Shutdown() {
 	c.History.Flush()
 	c.sc.shutdown()
+	if c.gerrit != nil {
+		c.gerrit.clean()
+	}
+}
+
+// EnableGerrit makes the controller pool the changes selected by the Gerrit
+// queries of the Tide config in addition to the GitHub PRs, and submit them
+// through the Gerrit client.
+func (c *Controller) EnableGerrit(gc gerritClient) {
+	c.gerrit = newGerritProvider(gc, c.config)
+}
+
+// providerOf returns the provider of the PRs of the org.
+func (c *Controller) providerOf(org string) provider {
+	if c.gerrit != nil && c.gerrit.owns(org) {
+		return c.gerrit
+	}
+	if c.provider != nil {
+		return c.provider
+	}
+	return &githubProvider{ghc: c.ghc, gc: c.gc}
 }
 
 func prKey(pr *PullRequest) string {
@@ -314,6 +438,12 @@ func (c *Controller) Sync() error {
 		tideMetrics.syncDuration.Set(duration.Seconds())
 	}()
 	defer c.changedFiles.prune()
+	if c.conflicts != nil {
+		defer c.conflicts.prune()
+	}
+	if c.approvals != nil {
+		defer c.approvals.prune()
+	}
 
 	c.logger.Debug("Building tide pool.")
 	prs := make(map[string]PullRequest)
@@ -330,6 +460,11 @@ func (c *Controller) Sync() error {
 			prs[prKey(&pr)] = pr
 		}
 	}
+	if c.gerrit != nil {
+		for _, pr := range c.gerrit.search(c.logger, c.changedFiles) {
+			prs[prKey(&pr)] = pr
+		}
+	}
 	c.logger.WithField(
 		"duration", time.Since(start).String(),
 	).Debugf("Found %d (unfiltered) pool PRs.", len(prs))
@@ -362,6 +497,8 @@ func (c *Controller) Sync() error {
 	c.sc.Lock()
 	c.sc.blocks = blocks
 	c.sc.poolPRs = poolPRMap(filteredPools)
+	c.sc.missingApprovals = missingApprovalMap(rawPools)
+	c.sc.closedMergeWindows = closedMergeWindowMap(filteredPools)
 	select {
 	case c.sc.newPoolPending <- true:
 	default:
@@ -389,10 +526,14 @@ func (c *Controller) Sync() error {
 		pools = append(pools, pool)
 	}
 	sortPools(pools)
+	c.pruneFailedBatches(sets.StringKeySet(filteredPools))
 	c.m.Lock()
 	c.pools = pools
 	c.m.Unlock()
 
+	if c.reverts != nil {
+		c.reverts.sync()
+	}
 	c.History.Flush()
 	return nil
 }
@@ -472,12 +613,13 @@ func (c *Controller) initSubpoolData(sp *subpool) error {
 	}
 	sp.cc = make(map[int]contextChecker, len(sp.prs))
 	for _, pr := range sp.prs {
-		sp.cc[int(pr.Number)], err = c.config().GetTideContextPolicy(c.gc, sp.org, sp.repo, sp.branch, refGetterFactory(string(sp.sha)), string(pr.HeadRefOID))
+		sp.cc[int(pr.Number)], err = c.providerOf(sp.org).contextChecker(c.config(), sp, pr)
 		if err != nil {
 			return fmt.Errorf("error setting up context checker for pr %d: %v", int(pr.Number), err)
 		}
 	}
-	return nil
+	c.initMergeWindow(sp)
+	return c.initMissingApprovals(sp)
 }
 
 // filterSubpool filters PRs from an initially identified subpool, returning the
@@ -502,6 +644,7 @@ func filterSubpool(ghc githubClient, sp *subpool) *subpool {
 // Specifically we filter out PRs that:
 // - Have known merge conflicts.
 // - Have failing or missing status contexts.
+// - Lack the approval of the OWNERS of a directory they touch, if required.
 // - Have pending required status contexts that are not associated with a
 //   ProwJob. (This ensures that the 'tide' context indicates that the pending
 //   status is preventing merge. Required ProwJob statuses are allowed to be
@@ -514,6 +657,10 @@ func filterPR(ghc githubClient, sp *subpool, pr *PullRequest) bool {
 		log.Debug("filtering out PR as it is unmergeable")
 		return true
 	}
+	if desc, missing := sp.missingApprovals[int(pr.Number)]; missing {
+		log.WithField("approvals", desc).Debug("filtering out PR as it lacks OWNERS approvals")
+		return true
+	}
 	// Filter out PRs with unsuccessful contexts unless the only unsuccessful
 	// contexts are pending required prowjobs.
 	contexts, err := headContexts(log, ghc, pr)
@@ -554,6 +701,18 @@ func poolPRMap(subpoolMap map[string]*subpool) map[string]PullRequest {
 	return prs
 }
 
+// missingApprovalMap collects the descriptions of the OWNERS approvals that
+// subpool PRs lack by PR key.
+func missingApprovalMap(subpoolMap map[string]*subpool) map[string]string {
+	missing := make(map[string]string)
+	for _, sp := range subpoolMap {
+		for number, desc := range sp.missingApprovals {
+			missing[fmt.Sprintf("%s/%s#%d", sp.org, sp.repo, number)] = desc
+		}
+	}
+	return missing
+}
+
 type simpleState string
 
 const (
@@ -563,7 +722,7 @@ const (
 )
 
 func toSimpleState(s prowapi.ProwJobState) simpleState {
-	if s == prowapi.TriggeredState || s == prowapi.PendingState {
+	if s == prowapi.TriggeredState || s == prowapi.WaitingState || s == prowapi.PendingState {
 		return pendingState
 	} else if s == prowapi.SuccessState {
 		return successState
@@ -611,29 +770,100 @@ func unsuccessfulContexts(contexts []Context, cc contextChecker, log *logrus.Ent
 	return failed
 }
 
-func pickSmallestPassingNumber(log *logrus.Entry, ghc githubClient, prs []PullRequest, cc map[int]contextChecker) (bool, PullRequest) {
-	smallestNumber := -1
-	var smallestPR PullRequest
+// priorityTier returns the index of the priority tier of the PR, see
+// config.Tide.PriorityTier.
+func priorityTier(tide *config.Tide, pr PullRequest) int {
+	labels := sets.NewString()
+	for _, label := range pr.Labels.Nodes {
+		labels.Insert(string(label.Name))
+	}
+	return tide.PriorityTier(labels)
+}
+
+// sortByPriority sorts the PRs in the order tide picks them in: PRs of
+// more important priority tiers first and by number within a tier.
+func sortByPriority(tide *config.Tide, prs []PullRequest) {
+	tiers := make(map[githubql.Int]int, len(prs))
 	for _, pr := range prs {
-		if smallestNumber != -1 && int(pr.Number) >= smallestNumber {
-			continue
+		tiers[pr.Number] = priorityTier(tide, pr)
+	}
+	sort.SliceStable(prs, func(i, j int) bool {
+		if tiers[prs[i].Number] != tiers[prs[j].Number] {
+			return tiers[prs[i].Number] < tiers[prs[j].Number]
 		}
+		return prs[i].Number < prs[j].Number
+	})
+}
+
+// prioritiesOf maps the numbers of the PRs that are in a priority tier to
+// the index of that tier.
+func prioritiesOf(tide *config.Tide, prs ...[]PullRequest) map[int]int {
+	if len(tide.Priority) == 0 {
+		return nil
+	}
+	priorities := map[int]int{}
+	for _, list := range prs {
+		for _, pr := range list {
+			if tier := priorityTier(tide, pr); tier < len(tide.Priority) {
+				priorities[int(pr.Number)] = tier
+			}
+		}
+	}
+	return priorities
+}
+
+// pickHighestPriorityPR returns the passing PR of the most important
+// priority tier, with the smallest number within that tier.
+func pickHighestPriorityPR(log *logrus.Entry, ghc githubClient, prs []PullRequest, cc map[int]contextChecker, tide *config.Tide) (bool, PullRequest) {
+	sorted := make([]PullRequest, len(prs))
+	copy(sorted, prs)
+	sortByPriority(tide, sorted)
+	for _, pr := range sorted {
 		if len(pr.Commits.Nodes) < 1 {
 			continue
 		}
 		if !isPassingTests(log, ghc, pr, cc[int(pr.Number)]) {
 			continue
 		}
-		smallestNumber = int(pr.Number)
-		smallestPR = pr
+		return true, pr
 	}
-	return smallestNumber > -1, smallestPR
+	return false, PullRequest{}
 }
 
 // accumulateBatch looks at existing batch ProwJobs and, if applicable, returns:
 // * A list of PRs that are part of a batch test that finished successfully
 // * A list of PRs that are part of a batch test that hasn't finished yet but didn't have any failures so far
-func (c *Controller) accumulateBatch(sp subpool) (successBatch []PullRequest, pendingBatch []PullRequest) {
+// * The lists of PRs that are part of batch tests in which a required job failed
+func (c *Controller) accumulateBatch(sp subpool) (successBatch []PullRequest, pendingBatch []PullRequest, failedBatches [][]PullRequest) {
+	for _, result := range c.accumulateBatches(sp) {
+		switch result.state {
+		// Currently we only consider 1 pending batch and 1 success batch at a time.
+		// If more are somehow present the largest ones are used.
+		case pendingState:
+			if len(result.prs) > len(pendingBatch) {
+				pendingBatch = result.prs
+			}
+		case successState:
+			if len(result.prs) > len(successBatch) {
+				successBatch = result.prs
+			}
+		case failureState:
+			failedBatches = append(failedBatches, result.prs)
+		}
+	}
+	return successBatch, pendingBatch, failedBatches
+}
+
+// batchResult is the overall state of the required jobs of a batch.
+type batchResult struct {
+	prs   []PullRequest
+	state simpleState
+}
+
+// accumulateBatches returns the results of the batches tested against the
+// base of the subpool whose PRs are all still in the pool. Batches that are
+// missing a required job are left out. The results are sorted by the PRs.
+func (c *Controller) accumulateBatches(sp subpool) []batchResult {
 	sp.log.Debug("accumulating PRs for batch testing")
 	prNums := make(map[int]PullRequest)
 	for _, pr := range sp.prs {
@@ -686,6 +916,7 @@ func (c *Controller) accumulateBatch(sp subpool) (successBatch []PullRequest, pe
 			states[ref].jobStates[context] = jobState
 		}
 	}
+	var results []batchResult
 	for ref, state := range states {
 		if !state.validPulls {
 			continue
@@ -698,25 +929,201 @@ func (c *Controller) accumulateBatch(sp subpool) (successBatch []PullRequest, pe
 		}
 
 		overallState := successState
+		var missing bool
 		for _, p := range requiredPresubmits {
 			if s, ok := state.jobStates[p.Context]; !ok || s == failureState {
 				overallState = failureState
+				missing = !ok
 				sp.log.WithField("batch", ref).Debugf("batch invalid, required presubmit %s is not passing", p.Context)
 				break
 			} else if s == pendingState && overallState == successState {
 				overallState = pendingState
 			}
 		}
-		switch overallState {
-		// Currently we only consider 1 pending batch and 1 success batch at a time.
-		// If more are somehow present they will be ignored.
-		case pendingState:
-			pendingBatch = state.prs
-		case successState:
-			successBatch = state.prs
+		if !missing {
+			results = append(results, batchResult{prs: state.prs, state: overallState})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return batchKey(prMeta(results[i].prs...)) < batchKey(prMeta(results[j].prs...))
+	})
+	return results
+}
+
+// batchKey identifies the set of PR heads that a batch tests.
+func batchKey(pulls []prowapi.Pull) string {
+	keys := make([]string, 0, len(pulls))
+	for _, pull := range pulls {
+		keys = append(keys, fmt.Sprintf("%d:%s", pull.Number, pull.SHA))
+	}
+	return strings.Join(keys, ",")
+}
+
+// failedBatchMemory remembers the failed batches of every pool, so that
+// their bisection carries on after a passing half or anything else is merged
+// into the base.
+type failedBatchMemory struct {
+	sync.Mutex
+	batches map[string]map[string][]prowapi.Pull
+}
+
+// rememberFailedBatches adds the batches that failed against the current base
+// to the failed batches of the pool and returns all of them that consist of
+// PRs of the subpool. The PRs of a failed batch that left the pooled PRs,
+// usually by being merged with a passing half, are dropped from it, while a
+// batch with a PR whose head changed is forgotten.
+func (c *Controller) rememberFailedBatches(sp subpool, pooled []PullRequest, failedBatches [][]PullRequest) [][]PullRequest {
+	prs := make(map[int]PullRequest, len(pooled))
+	for _, pr := range pooled {
+		prs[int(pr.Number)] = pr
+	}
+	key := poolKey(sp.org, sp.repo, sp.branch)
+
+	c.bisections.Lock()
+	defer c.bisections.Unlock()
+	if c.bisections.batches == nil {
+		c.bisections.batches = map[string]map[string][]prowapi.Pull{}
+	}
+	remembered := map[string][]prowapi.Pull{}
+	for k, pulls := range c.bisections.batches[key] {
+		remembered[k] = pulls
+	}
+	for _, batch := range failedBatches {
+		pulls := prMeta(batch...)
+		remembered[batchKey(pulls)] = pulls
+	}
+	next := map[string][]prowapi.Pull{}
+	for _, pulls := range remembered {
+		var batch []PullRequest
+		valid := true
+		for _, pull := range pulls {
+			pr, ok := prs[pull.Number]
+			if !ok {
+				continue
+			}
+			if string(pr.HeadRefOID) != pull.SHA {
+				valid = false
+				break
+			}
+			batch = append(batch, pr)
+		}
+		if valid && len(batch) > 0 {
+			pulls := prMeta(batch...)
+			next[batchKey(pulls)] = pulls
+		}
+	}
+	if len(next) == 0 {
+		delete(c.bisections.batches, key)
+		return nil
+	}
+	c.bisections.batches[key] = next
+
+	testable := sets.NewString()
+	for _, pr := range sp.prs {
+		testable.Insert(batchKey(prMeta(pr)))
+	}
+	keys := make([]string, 0, len(next))
+	for k := range next {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var batches [][]PullRequest
+	for _, k := range keys {
+		var batch []PullRequest
+		for _, pull := range next[k] {
+			if !testable.Has(batchKey([]prowapi.Pull{pull})) {
+				batch = nil
+				break
+			}
+			batch = append(batch, prs[pull.Number])
+		}
+		if len(batch) > 0 {
+			batches = append(batches, batch)
+		}
+	}
+	return batches
+}
+
+// pruneFailedBatches forgets the failed batches of the pools that are not
+// in the pool keys.
+func (c *Controller) pruneFailedBatches(keys sets.String) {
+	c.bisections.Lock()
+	defer c.bisections.Unlock()
+	for key := range c.bisections.batches {
+		if !keys.Has(key) {
+			delete(c.bisections.batches, key)
+		}
+	}
+}
+
+// isolatedPRs returns the numbers of the PRs that failed as batches of their
+// own. They are left out of new batches until their heads change.
+func isolatedPRs(failedBatches [][]PullRequest) sets.Int {
+	isolated := sets.NewInt()
+	for _, batch := range failedBatches {
+		if len(batch) == 1 {
+			isolated.Insert(int(batch[0].Number))
+		}
+	}
+	return isolated
+}
+
+// pickBisection returns the halves of the first failed batch that were not
+// tested as batches against the current base yet. Testing both halves at
+// once isolates the PRs that broke a batch in log2(n) rounds, while the
+// halves without them can be merged right away. Batches that contain a
+// smaller failed batch are not split, as the PRs that broke them are already
+// narrowed down further. The failed batches are remembered across base
+// changes, so the halves of a batch that failed against an earlier base are
+// tested again against the current one.
+func pickBisection(sp subpool, failedBatches [][]PullRequest) [][]PullRequest {
+	tested := sets.NewString()
+	for _, pj := range sp.pjs {
+		if pj.Spec.Type == prowapi.BatchJob {
+			tested.Insert(batchKey(pj.Spec.Refs.Pulls))
+		}
+	}
+	for _, batch := range failedBatches {
+		if len(batch) < 2 || containsFailedBatch(batch, failedBatches) {
+			continue
+		}
+		middle := len(batch) / 2
+		var untested [][]PullRequest
+		for _, half := range [][]PullRequest{batch[:middle], batch[middle:]} {
+			if !tested.Has(batchKey(prMeta(half...))) {
+				untested = append(untested, half)
+			}
+		}
+		if len(untested) > 0 {
+			return untested
 		}
 	}
-	return successBatch, pendingBatch
+	return nil
+}
+
+// containsFailedBatch returns whether the batch contains all PRs of another,
+// smaller failed batch.
+func containsFailedBatch(batch []PullRequest, failedBatches [][]PullRequest) bool {
+	heads := sets.NewString()
+	for _, pr := range batch {
+		heads.Insert(batchKey(prMeta(pr)))
+	}
+	for _, other := range failedBatches {
+		if len(other) >= len(batch) {
+			continue
+		}
+		contained := true
+		for _, pr := range other {
+			if !heads.Has(batchKey(prMeta(pr))) {
+				contained = false
+				break
+			}
+		}
+		if contained {
+			return true
+		}
+	}
+	return false
 }
 
 // accumulate returns the supplied PRs sorted into three buckets based on their
@@ -789,17 +1196,30 @@ func prNumbers(prs []PullRequest) []int {
 }
 
 func (c *Controller) pickBatch(sp subpool, cc map[int]contextChecker) ([]PullRequest, []config.Presubmit, error) {
-	batchLimit := c.config().Tide.BatchSizeLimit(sp.org, sp.repo)
+	return c.pickBatchOnTopOf(sp, cc, nil, nil)
+}
+
+// pickBatchOnTopOf picks a batch of passing PRs that merge cleanly on top of
+// the base and the PRs of the train, which are not picked again and lead
+// the returned batch. PRs in excluded are not picked either. If no PR can
+// be added to the train, no batch is returned.
+func (c *Controller) pickBatchOnTopOf(sp subpool, cc map[int]contextChecker, train []PullRequest, excluded sets.Int) ([]PullRequest, []config.Presubmit, error) {
+	tide := c.config().Tide
+	batchLimit := tide.BatchSizeLimit(sp.org, sp.repo)
 	if batchLimit < 0 {
 		sp.log.Debug("Batch merges disabled by configuration in this repo.")
 		return nil, nil, nil
 	}
 
-	// we must choose the oldest PRs for the batch
-	sort.Slice(sp.prs, func(i, j int) bool { return sp.prs[i].Number < sp.prs[j].Number })
+	// we must choose the most important and then the oldest PRs for the batch
+	sortByPriority(&tide, sp.prs)
 
+	inTrain := sets.NewInt(prNumbers(train)...)
 	var candidates []PullRequest
 	for _, pr := range sp.prs {
+		if inTrain.Has(int(pr.Number)) || excluded.Has(int(pr.Number)) {
+			continue
+		}
 		if isPassingTests(sp.log, c.ghc, pr, cc[int(pr.Number)]) {
 			candidates = append(candidates, pr)
 		}
@@ -811,45 +1231,90 @@ func (c *Controller) pickBatch(sp subpool, cc map[int]contextChecker) ([]PullReq
 	}
 	sp.log.Debugf("of %d possible PRs, %d are passing tests", len(sp.prs), len(candidates))
 
-	r, err := c.gc.Clone(sp.org + "/" + sp.repo)
+	r, err := c.providerOf(sp.org).clone(sp.org, sp.repo)
 	if err != nil {
 		return nil, nil, err
 	}
-	defer r.Clean()
-	if err := r.Config("user.name", "prow"); err != nil {
+	var res []PullRequest
+	if r == nil {
+		// The provider cannot check merges, so assume that all candidates
+		// merge cleanly.
+		res = candidates
+		if batchLimit > 0 && len(res) > batchLimit {
+			res = res[:batchLimit]
+		}
+	} else if res, err = mergeCleanly(sp, r, train, candidates, batchLimit); err != nil {
 		return nil, nil, err
 	}
-	if err := r.Config("user.email", "prow@localhost"); err != nil {
+
+	if len(res) == 0 {
+		return nil, nil, nil
+	}
+	res = append(append([]PullRequest{}, train...), res...)
+
+	presubmits, err := c.presubmitsForBatch(res, sp.org, sp.repo, sp.sha, sp.branch)
+	if err != nil {
 		return nil, nil, err
 	}
-	if err := r.Config("commit.gpgsign", "false"); err != nil {
-		sp.log.Warningf("Cannot set gpgsign=false in gitconfig: %v", err)
+
+	return res, presubmits, nil
+}
+
+// mergeCleanly merges the train and then the candidates on top of the base
+// of the subpool and returns the candidates that merge cleanly, up to the
+// batch limit.
+func mergeCleanly(sp subpool, r *git.Repo, train, candidates []PullRequest, batchLimit int) ([]PullRequest, error) {
+	defer r.Clean()
+	if err := prepareMerges(&sp, r); err != nil {
+		return nil, err
 	}
 	if err := r.Checkout(sp.sha); err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	for _, pr := range train {
+		if ok, err := r.Merge(string(pr.HeadRefOID)); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, fmt.Errorf("PR #%d of the merge train does not merge cleanly anymore", pr.Number)
+		}
 	}
 
 	var res []PullRequest
+	picked := sets.NewInt(prNumbers(train)...)
 	for _, pr := range candidates {
+		if conflicts := sp.conflicts[int(pr.Number)]; conflicts.HasAny(picked.UnsortedList()...) {
+			sp.log.WithFields(pr.logFields()).Debug("Not batching a PR that is predicted to conflict with the batch.")
+			continue
+		}
 		if ok, err := r.Merge(string(pr.HeadRefOID)); err != nil {
 			// we failed to abort the merge and our git client is
 			// in a bad state; it must be cleaned before we try again
-			return nil, nil, err
+			return nil, err
 		} else if ok {
 			res = append(res, pr)
+			picked.Insert(int(pr.Number))
 			// TODO: Make this configurable per subpool.
 			if batchLimit > 0 && len(res) >= batchLimit {
 				break
 			}
 		}
 	}
+	return res, nil
+}
 
-	presubmits, err := c.presubmitsForBatch(res, sp.org, sp.repo, sp.sha, sp.branch)
-	if err != nil {
-		return nil, nil, err
+// prepareMerges configures the clone of the repo of the subpool to commit
+// merges.
+func prepareMerges(sp *subpool, r *git.Repo) error {
+	if err := r.Config("user.name", "prow"); err != nil {
+		return err
 	}
-
-	return res, presubmits, nil
+	if err := r.Config("user.email", "prow@localhost"); err != nil {
+		return err
+	}
+	if err := r.Config("commit.gpgsign", "false"); err != nil {
+		sp.log.Warningf("Cannot set gpgsign=false in gitconfig: %v", err)
+	}
+	return nil
 }
 
 func checkMergeLabels(pr PullRequest, squash, rebase, merge string, method github.PullRequestMergeType) (github.PullRequestMergeType, error) {
@@ -933,7 +1398,7 @@ func (c *Controller) mergePRs(sp subpool, prs []PullRequest) error {
 
 		keepTrying, err := tryMerge(func() error {
 			ghMergeDetails := c.prepareMergeDetails(commitTemplates, pr, mergeMethod)
-			return c.ghc.Merge(sp.org, sp.repo, int(pr.Number), ghMergeDetails)
+			return c.providerOf(sp.org).merge(sp.org, sp.repo, pr, ghMergeDetails)
 		})
 		if err != nil {
 			log.WithError(err).Error("Merge failed.")
@@ -1031,6 +1496,35 @@ func tryMerge(mergeFunc func() error) (bool, error) {
 }
 
 func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []PullRequest) error {
+	return c.triggerJobs(sp, presubmits, prs, len(prs) > 1)
+}
+
+// triggerBisection triggers batch jobs for every half of a failed batch and
+// records each of them in the history.
+func (c *Controller) triggerBisection(sp subpool, halves [][]PullRequest) ([]PullRequest, error) {
+	var targets []PullRequest
+	var errs []error
+	for _, half := range halves {
+		targets = append(targets, half...)
+		var errorString string
+		presubmits, err := c.presubmitsForBatch(half, sp.org, sp.repo, sp.sha, sp.branch)
+		if err == nil {
+			// Halves of a single PR are tested as batches as well, so that
+			// their results are tracked in the same way.
+			err = c.triggerJobs(sp, presubmits, half, true)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			errorString = err.Error()
+		}
+		c.History.Record(poolKey(sp.org, sp.repo, sp.branch), string(TriggerBisection), sp.sha, errorString, prMeta(half...))
+	}
+	return targets, errorutil.NewAggregate(errs...)
+}
+
+// triggerJobs creates the ProwJobs for the presubmits, as batch jobs if batch
+// is set and as presubmit jobs of the single PR otherwise.
+func (c *Controller) triggerJobs(sp subpool, presubmits []config.Presubmit, prs []PullRequest, batch bool) error {
 	refs := prowapi.Refs{
 		Org:     sp.org,
 		Repo:    sp.repo,
@@ -1047,6 +1541,7 @@ func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []Pu
 			},
 		)
 	}
+	c.providerOf(sp.org).decorateRefs(&refs, prs)
 
 	// If PRs require the same job, we only want to trigger it once.
 	// If multiple required jobs have the same context, we assume the
@@ -1058,10 +1553,10 @@ func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []Pu
 		}
 		triggeredContexts.Insert(string(ps.Context))
 		var spec prowapi.ProwJobSpec
-		if len(prs) == 1 {
-			spec = pjutil.PresubmitSpec(ps, refs)
-		} else {
+		if batch {
 			spec = pjutil.BatchSpec(ps, refs)
+		} else {
+			spec = pjutil.PresubmitSpec(ps, refs)
 		}
 		pj := pjutil.NewProwJob(spec, ps.Labels, ps.Annotations)
 		pj.Namespace = c.config().ProwJobNamespace
@@ -1076,15 +1571,22 @@ func (c *Controller) trigger(sp subpool, presubmits []config.Presubmit, prs []Pu
 	return nil
 }
 
-func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, missings, batchMerges []PullRequest, missingSerialTests map[int][]config.Presubmit) (Action, []PullRequest, error) {
+// takeAction decides on the action for the subpool and carries it out. The
+// train is nil unless train mode is enabled for the repo.
+func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, missings, batchMerges []PullRequest, failedBatches [][]PullRequest, missingSerialTests map[int][]config.Presubmit, train *mergeTrain) (Action, []PullRequest, error) {
+	tide := c.config().Tide
 	// Merge the batch!
 	if len(batchMerges) > 0 {
-		return MergeBatch, batchMerges, c.mergePRs(sp, batchMerges)
+		err := c.mergePRs(sp, batchMerges)
+		if err == nil && train != nil {
+			c.recordTrainMerge(sp, batchMerges, train.length)
+		}
+		return MergeBatch, batchMerges, err
 	}
 	// Do not merge PRs while waiting for a batch to complete. We don't want to
 	// invalidate the old batch result.
 	if len(successes) > 0 && len(batchPending) == 0 {
-		if ok, pr := pickSmallestPassingNumber(sp.log, c.ghc, successes, sp.cc); ok {
+		if ok, pr := pickHighestPriorityPR(sp.log, c.ghc, successes, sp.cc, &tide); ok {
 			return Merge, []PullRequest{pr}, c.mergePRs(sp, []PullRequest{pr})
 		}
 	}
@@ -1092,9 +1594,32 @@ func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, m
 	if len(sp.presubmits) == 0 {
 		return Wait, nil, nil
 	}
-	// If we have no batch, trigger one.
-	if len(sp.prs) > 1 && len(batchPending) == 0 {
-		batch, presubmits, err := c.pickBatch(sp, sp.cc)
+	// If a batch failed, test its halves to find the PRs that broke it.
+	var isolated sets.Int
+	if tide.BisectFailedBatches {
+		isolated = isolatedPRs(failedBatches)
+	}
+	if tide.BisectFailedBatches && len(batchPending) == 0 {
+		if halves := pickBisection(sp, failedBatches); len(halves) > 0 {
+			targets, err := c.triggerBisection(sp, halves)
+			return TriggerBisection, targets, err
+		}
+	}
+	// In train mode, add a car on top of the last one instead of waiting
+	// for the pending batch.
+	if train != nil {
+		if len(train.cars) < train.length {
+			car, presubmits, err := c.pickBatchOnTopOf(sp, sp.cc, train.last(), train.ejected.Union(isolated))
+			if err != nil {
+				return Wait, nil, err
+			}
+			if len(car) > 1 || (len(car) > 0 && len(train.cars) > 0) {
+				return TriggerTrainCar, car, c.triggerJobs(sp, presubmits, car, true)
+			}
+		}
+	} else if len(sp.prs) > 1 && len(batchPending) == 0 {
+		// If we have no batch, trigger one.
+		batch, presubmits, err := c.pickBatchOnTopOf(sp, sp.cc, nil, isolated)
 		if err != nil {
 			return Wait, nil, err
 		}
@@ -1104,7 +1629,7 @@ func (c *Controller) takeAction(sp subpool, batchPending, successes, pendings, m
 	}
 	// If we have no serial jobs pending or successful, trigger one.
 	if len(missings) > 0 && len(pendings) == 0 && len(successes) == 0 {
-		if ok, pr := pickSmallestPassingNumber(sp.log, c.ghc, missings, sp.cc); ok {
+		if ok, pr := pickHighestPriorityPR(sp.log, c.ghc, missings, sp.cc, &tide); ok {
 			return Trigger, []PullRequest{pr}, c.trigger(sp, missingSerialTests[int(pr.Number)], []PullRequest{pr})
 		}
 	}
@@ -1175,6 +1700,19 @@ func (c *changedFilesAgent) prChanges(pr *PullRequest) config.ChangedFilesProvid
 	}
 }
 
+// add caches the files changed by the PR, for providers that list them along
+// with the PRs.
+func (c *changedFilesAgent) add(pr *PullRequest, changedFiles []string) {
+	c.Lock()
+	defer c.Unlock()
+	c.nextChangeCache[changeCacheKey{
+		org:    string(pr.Repository.Owner.Login),
+		repo:   string(pr.Repository.Name),
+		number: int(pr.Number),
+		sha:    string(pr.HeadRefOID),
+	}] = changedFiles
+}
+
 func (c *changedFilesAgent) batchChanges(prs []PullRequest) config.ChangedFilesProvider {
 	return func() ([]string, error) {
 		result := sets.String{}
@@ -1281,14 +1819,42 @@ func (c *Controller) presubmitsForBatch(prs []PullRequest, org, repo, baseSHA, b
 
 func (c *Controller) syncSubpool(sp subpool, blocks []blockers.Blocker) (Pool, error) {
 	sp.log.Infof("Syncing subpool: %d PRs, %d PJs.", len(sp.prs), len(sp.pjs))
+	pooled := len(sp.prs)
+	tide := c.config().Tide
+	sortByPriority(&tide, sp.prs)
+	conflicts, predictErr := c.predictConflicts(&sp)
+	if predictErr != nil {
+		sp.log.WithError(predictErr).Warn("Failed to predict conflicts between PRs.")
+	}
+	sp.conflicts = conflicts
+	c.labelConflicts(&sp, conflicts)
 	successes, pendings, missings, missingSerialTests := accumulate(sp.presubmits, sp.prs, sp.pjs, sp.log)
-	batchMerge, batchPending := c.accumulateBatch(sp)
+	poolSuccesses, poolPendings, poolMissings := successes, pendings, missings
+	pooledPRs := sp.prs
+	if sp.mergeWindow != nil {
+		// The pool lists all of its PRs, but only the PRs that are exempt
+		// from the closed merge window are tested and merged.
+		sp.prs = exemptPRs(&sp)
+		successes, pendings, missings, missingSerialTests = accumulate(sp.presubmits, sp.prs, sp.pjs, sp.log)
+	}
+	batchMerge, batchPending, batchFailures := c.accumulateBatch(sp)
+	if tide.BisectFailedBatches {
+		batchFailures = c.rememberFailedBatches(sp, pooledPRs, batchFailures)
+	}
+	var train *mergeTrain
+	var trainCars int
+	if length := c.config().Tide.MergeTrainLength(sp.org, sp.repo); length > 0 {
+		train = buildTrain(length, c.accumulateBatches(sp))
+		trainCars = len(train.cars)
+	}
 	sp.log.WithFields(logrus.Fields{
 		"prs-passing":   prNumbers(successes),
 		"prs-pending":   prNumbers(pendings),
 		"prs-missing":   prNumbers(missings),
 		"batch-passing": prNumbers(batchMerge),
 		"batch-pending": prNumbers(batchPending),
+		"batch-failed":  len(batchFailures),
+		"train-cars":    trainCars,
 	}).Info("Subpool accumulated.")
 
 	var act Action
@@ -1297,8 +1863,10 @@ func (c *Controller) syncSubpool(sp subpool, blocks []blockers.Blocker) (Pool, e
 	var errorString string
 	if len(blocks) > 0 {
 		act = PoolBlocked
+	} else if sp.mergeWindow != nil && len(sp.prs) == 0 {
+		act = PoolClosed
 	} else {
-		act, targets, err = c.takeAction(sp, batchPending, successes, pendings, missings, batchMerge, missingSerialTests)
+		act, targets, err = c.takeAction(sp, batchPending, successes, pendings, missings, batchMerge, batchFailures, missingSerialTests, train)
 		if err != nil {
 			errorString = err.Error()
 		}
@@ -1317,23 +1885,34 @@ func (c *Controller) syncSubpool(sp subpool, blocks []blockers.Blocker) (Pool, e
 		"action":  string(act),
 		"targets": prNumbers(targets),
 	}).Info("Subpool synced.")
-	tideMetrics.pooledPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(len(sp.prs)))
+	tideMetrics.pooledPRs.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(pooled))
 	tideMetrics.updateTime.WithLabelValues(sp.org, sp.repo, sp.branch).Set(float64(time.Now().Unix()))
+	// List the PRs in the order they are picked in.
+	for _, prs := range [][]PullRequest{poolSuccesses, poolPendings, poolMissings} {
+		sortByPriority(&tide, prs)
+	}
 	return Pool{
 			Org:    sp.org,
 			Repo:   sp.repo,
 			Branch: sp.branch,
 
-			SuccessPRs: successes,
-			PendingPRs: pendings,
-			MissingPRs: missings,
+			GerritInstance: c.gerritInstance(sp.org),
+
+			SuccessPRs: poolSuccesses,
+			PendingPRs: poolPendings,
+			MissingPRs: poolMissings,
 
 			BatchPending: batchPending,
 
+			Priorities: prioritiesOf(&tide, poolSuccesses, poolPendings, poolMissings),
+
 			Action:   act,
 			Target:   targets,
 			Blockers: blocks,
 			Error:    errorString,
+
+			MergeWindowClosed: sp.mergeWindow != nil,
+			MergeWindowOpens:  mergeWindowOpens(&sp),
 		},
 		err
 }
@@ -1390,6 +1969,17 @@ type subpool struct {
 	// presubmit contains all required presubmits for each PR
 	// in this subpool
 	presubmits map[int][]config.Presubmit
+	// missingApprovals describes the OWNERS approvals that PRs lack, if the
+	// repo requires them.
+	missingApprovals map[int]string
+	// mergeWindow is the merge window of the branch while it is closed.
+	mergeWindow *config.TideMergeWindow
+	// mergeWindowOpens is when the closed merge window opens, or zero if it
+	// does not open within the lookahead.
+	mergeWindowOpens time.Time
+	// conflicts maps PR numbers to the PRs they are predicted to conflict
+	// with, if the repo opted in to conflict prediction.
+	conflicts map[int]sets.Int
 }
 
 func poolKey(org, repo, branch string) string {
@@ -1398,18 +1988,26 @@ func poolKey(org, repo, branch string) string {
 
 // dividePool splits up the list of pull requests and prow jobs into a group
 // per repo and branch. It only keeps ProwJobs that match the latest branch.
+// Pools whose branch cannot be resolved are skipped until the next sync, so
+// that a failing provider does not block the others.
 func (c *Controller) dividePool(pool map[string]PullRequest) (map[string]*subpool, error) {
 	sps := make(map[string]*subpool)
+	skipped := sets.NewString()
 	for _, pr := range pool {
 		org := string(pr.Repository.Owner.Login)
 		repo := string(pr.Repository.Name)
 		branch := string(pr.BaseRef.Name)
 		branchRef := string(pr.BaseRef.Prefix) + string(pr.BaseRef.Name)
 		fn := poolKey(org, repo, branch)
+		if skipped.Has(fn) {
+			continue
+		}
 		if sps[fn] == nil {
-			sha, err := c.ghc.GetRef(org, repo, strings.TrimPrefix(branchRef, "refs/"))
+			sha, err := c.providerOf(org).getRef(org, repo, strings.TrimPrefix(branchRef, "refs/"))
 			if err != nil {
-				return nil, err
+				c.logger.WithError(err).WithField("subpool", fn).Warn("Failed to get the branch, skipping the subpool.")
+				skipped.Insert(fn)
+				continue
 			}
 			sps[fn] = &subpool{
 				log: c.logger.WithFields(logrus.Fields{
@@ -1439,6 +2037,14 @@ func (c *Controller) dividePool(pool map[string]PullRequest) (map[string]*subpoo
 		}
 		c.logger.WithField("subpool", subpoolkey).Debugf("Found %d prowjobs.", len(pjs.Items))
 		sps[subpoolkey].pjs = pjs.Items
+		if c.config().Tide.MergeTrainLength(sp.org, sp.repo) > 0 {
+			carried, err := c.carriedTrainJobs(sp)
+			if err != nil {
+				// The cars are tested again.
+				sp.log.WithError(err).Warn("Failed to carry the jobs of the merge train.")
+			}
+			sps[subpoolkey].pjs = append(sps[subpoolkey].pjs, carried...)
+		}
 	}
 	return sps, nil
 }
@@ -97,6 +97,7 @@ func TestAccumulateBatch(t *testing.T) {
 
 		merges  []int
 		pending bool
+		failed  [][]int
 	}{
 		{
 			name: "no batches running",
@@ -177,6 +178,7 @@ func TestAccumulateBatch(t *testing.T) {
 				{job: "baz", state: prowapi.FailureState, prs: []pull{{1, "a"}, {2, "b"}}},
 				{job: "foo", state: prowapi.FailureState, prs: []pull{{1, "c"}, {2, "b"}}},
 			},
+			failed: [][]int{{1, 2}},
 		},
 		{
 			name:       "missing job required by one PR",
@@ -277,11 +279,18 @@ func TestAccumulateBatch(t *testing.T) {
 				changedFiles: &changedFilesAgent{},
 				logger:       logrus.WithField("test", test.name),
 			}
-			merges, pending := c.accumulateBatch(subpool{org: "org", repo: "repo", prs: pulls, pjs: pjs, log: logrus.WithField("test", test.name)})
+			merges, pending, failed := c.accumulateBatch(subpool{org: "org", repo: "repo", prs: pulls, pjs: pjs, log: logrus.WithField("test", test.name)})
 			if (len(pending) > 0) != test.pending {
 				t.Errorf("For case \"%s\", got wrong pending.", test.name)
 			}
 			testPullsMatchList(t, test.name, merges, test.merges)
+			var failedNumbers [][]int
+			for _, batch := range failed {
+				failedNumbers = append(failedNumbers, prNumbers(batch))
+			}
+			if !reflect.DeepEqual(failedNumbers, test.failed) {
+				t.Errorf("expected failed batches %v, got %v", test.failed, failedNumbers)
+			}
 		})
 	}
 }
@@ -515,12 +524,17 @@ type fgc struct {
 	merged    int
 	setStatus bool
 	mergeErrs map[int]error
+	refErrs   map[string]error
 
 	expectedSHA    string
 	combinedStatus map[string]string
+	reviews        map[int][]github.Review
 }
 
 func (f *fgc) GetRef(o, r, ref string) (string, error) {
+	if err := f.refErrs[o+"/"+r+" "+ref]; err != nil {
+		return "", err
+	}
 	return f.refs[o+"/"+r+" "+ref], nil
 }
 
@@ -548,6 +562,10 @@ func (f *fgc) Merge(org, repo string, number int, details github.MergeDetails) e
 	return nil
 }
 
+func (f *fgc) ListReviews(org, repo string, number int) ([]github.Review, error) {
+	return f.reviews[number], nil
+}
+
 func (f *fgc) CreateStatus(org, repo, ref string, s github.Status) error {
 	switch s.State {
 	case github.StatusSuccess, github.StatusError, github.StatusPending, github.StatusFailure:
@@ -616,6 +634,12 @@ func TestDividePool(t *testing.T) {
 			number: 1000,
 			branch: "release-1.6",
 		},
+		{
+			org:    "k",
+			repo:   "broken",
+			number: 7,
+			branch: "master",
+		},
 	}
 	testPJs := []struct {
 		jobType prowapi.ProwJobType
@@ -676,6 +700,9 @@ func TestDividePool(t *testing.T) {
 			"k/k heads/master":      "456",
 			"k/k heads/release-1.6": "789",
 		},
+		refErrs: map[string]error{
+			"k/broken heads/master": errors.New("unavailable"),
+		},
 	}
 	configGetter := func() *config.Config {
 		return &config.Config{
@@ -735,8 +762,8 @@ func TestDividePool(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error dividing pool: %v", err)
 	}
-	if len(sps) == 0 {
-		t.Error("No subpools.")
+	if len(sps) != 3 {
+		t.Errorf("Expected 3 subpools without the one of the broken repo, got %d.", len(sps))
 	}
 	for _, sp := range sps {
 		name := fmt.Sprintf("%s/%s %s", sp.org, sp.repo, sp.branch)
@@ -931,6 +958,107 @@ func TestPickBatch(t *testing.T) {
 	}
 }
 
+func TestPickHighestPriorityPR(t *testing.T) {
+	tide := &config.Tide{
+		Priority: []config.TidePriority{
+			{Labels: []string{"priority/critical-urgent"}},
+			{Labels: []string{"priority/important-soon"}},
+		},
+	}
+	newPR := func(number int, passing bool, labels ...string) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("sha-%d", number))
+		pr.Commits.Nodes = []struct {
+			Commit Commit
+		}{{Commit: Commit{OID: pr.HeadRefOID}}}
+		state := githubql.StatusStateSuccess
+		if !passing {
+			state = githubql.StatusStateFailure
+		}
+		pr.Commits.Nodes[0].Commit.Status.Contexts = []Context{{Context: "test", State: state}}
+		for _, label := range labels {
+			pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: githubql.String(label)})
+		}
+		return pr
+	}
+
+	testCases := []struct {
+		name     string
+		prs      []PullRequest
+		expected int
+	}{
+		{
+			name:     "smallest number without priorities",
+			prs:      []PullRequest{newPR(3, true), newPR(1, true), newPR(2, true)},
+			expected: 1,
+		},
+		{
+			name:     "priority tier wins over number",
+			prs:      []PullRequest{newPR(1, true), newPR(5, true, "priority/important-soon"), newPR(9, true, "priority/critical-urgent")},
+			expected: 9,
+		},
+		{
+			name:     "smallest number within the tier",
+			prs:      []PullRequest{newPR(8, true, "priority/important-soon"), newPR(1, true), newPR(6, true, "priority/important-soon")},
+			expected: 6,
+		},
+		{
+			name:     "failing PRs are skipped",
+			prs:      []PullRequest{newPR(9, false, "priority/critical-urgent"), newPR(2, true), newPR(5, true, "priority/important-soon")},
+			expected: 5,
+		},
+		{
+			name:     "nothing is passing",
+			prs:      []PullRequest{newPR(1, false), newPR(2, false, "priority/critical-urgent")},
+			expected: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cc := map[int]contextChecker{}
+			for _, pr := range tc.prs {
+				cc[int(pr.Number)] = &config.TideContextPolicy{}
+			}
+			ok, pr := pickHighestPriorityPR(logrus.WithField("component", "tide"), &fgc{}, tc.prs, cc, tide)
+			if tc.expected == -1 {
+				if ok {
+					t.Errorf("expected no PR to be picked, got %d", pr.Number)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("expected PR %d to be picked, got none", tc.expected)
+			}
+			if int(pr.Number) != tc.expected {
+				t.Errorf("expected PR %d to be picked, got %d", tc.expected, pr.Number)
+			}
+		})
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	tide := &config.Tide{
+		Priority: []config.TidePriority{{Labels: []string{"priority/critical-urgent"}}},
+	}
+	var prs []PullRequest
+	for _, number := range []int{4, 2, 7, 3} {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		if number%2 == 1 {
+			pr.Labels.Nodes = append(pr.Labels.Nodes, struct{ Name githubql.String }{Name: "priority/critical-urgent"})
+		}
+		prs = append(prs, pr)
+	}
+	sortByPriority(tide, prs)
+	if got, expected := prNumbers(prs), []int{3, 7, 2, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected PRs in order %v, got %v", expected, got)
+	}
+	if got, expected := prioritiesOf(tide, prs), map[int]int{3: 0, 7: 0}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected priorities %v, got %v", expected, got)
+	}
+}
+
 func TestCheckMergeLabels(t *testing.T) {
 	squashLabel := "tide/squash"
 	mergeLabel := "tide/merge"
@@ -1410,7 +1538,7 @@ func TestTakeAction(t *testing.T) {
 			batchPending = []PullRequest{{}}
 		}
 		t.Logf("Test case: %s", tc.name)
-		if act, _, err := c.takeAction(sp, batchPending, genPulls(tc.successes), genPulls(tc.pendings), genPulls(tc.nones), genPulls(tc.batchMerges), sp.presubmits); err != nil && !tc.expectErr {
+		if act, _, err := c.takeAction(sp, batchPending, genPulls(tc.successes), genPulls(tc.pendings), genPulls(tc.nones), genPulls(tc.batchMerges), nil, sp.presubmits, nil); err != nil && !tc.expectErr {
 			t.Errorf("Unexpected error in takeAction: %v", err)
 			continue
 		} else if err == nil && tc.expectErr {
@@ -1453,6 +1581,259 @@ func TestTakeAction(t *testing.T) {
 	}
 }
 
+func TestRememberFailedBatches(t *testing.T) {
+	newPR := func(number int, sha string) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String(sha)
+		return pr
+	}
+	type syncRound struct {
+		pooled   []PullRequest
+		testable []PullRequest
+		failed   [][]PullRequest
+		expected [][]int
+	}
+	pr1, pr2, pr3, pr4 := newPR(1, "a"), newPR(2, "b"), newPR(3, "c"), newPR(4, "d")
+	testCases := []struct {
+		name  string
+		syncs []syncRound
+	}{
+		{
+			name: "failed batch is remembered after its passing half merged",
+			syncs: []syncRound{
+				{
+					pooled:   []PullRequest{pr1, pr2, pr3, pr4},
+					failed:   [][]PullRequest{{pr1, pr2, pr3, pr4}},
+					expected: [][]int{{1, 2, 3, 4}},
+				},
+				{
+					pooled:   []PullRequest{pr3, pr4},
+					expected: [][]int{{3, 4}},
+				},
+				{
+					pooled:   []PullRequest{pr3, pr4},
+					failed:   [][]PullRequest{{pr3}},
+					expected: [][]int{{3}, {3, 4}},
+				},
+			},
+		},
+		{
+			name: "failed batch is forgotten once the head of a PR changes",
+			syncs: []syncRound{
+				{
+					pooled:   []PullRequest{pr1, pr2},
+					failed:   [][]PullRequest{{pr1, pr2}},
+					expected: [][]int{{1, 2}},
+				},
+				{
+					pooled: []PullRequest{pr1, newPR(2, "e")},
+				},
+				{
+					pooled: []PullRequest{pr1, pr2},
+				},
+			},
+		},
+		{
+			name: "failed batches with PRs that cannot be tested are kept but not returned",
+			syncs: []syncRound{
+				{
+					pooled:   []PullRequest{pr1, pr2, pr3},
+					failed:   [][]PullRequest{{pr1, pr2}, {pr3}},
+					expected: [][]int{{1, 2}, {3}},
+				},
+				{
+					pooled:   []PullRequest{pr1, pr2, pr3},
+					testable: []PullRequest{pr3},
+					expected: [][]int{{3}},
+				},
+				{
+					pooled:   []PullRequest{pr1, pr2, pr3},
+					expected: [][]int{{1, 2}, {3}},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Controller{}
+			for i, s := range tc.syncs {
+				sp := subpool{org: "o", repo: "r", branch: "master", prs: s.pooled}
+				if s.testable != nil {
+					sp.prs = s.testable
+				}
+				var got [][]int
+				for _, batch := range c.rememberFailedBatches(sp, s.pooled, s.failed) {
+					got = append(got, prNumbers(batch))
+				}
+				if !reflect.DeepEqual(got, s.expected) {
+					t.Errorf("sync %d: expected failed batches %v, got %v", i, s.expected, got)
+				}
+			}
+			c.pruneFailedBatches(sets.NewString())
+			if len(c.bisections.batches) != 0 {
+				t.Errorf("expected pruning to forget all failed batches, got %v", c.bisections.batches)
+			}
+		})
+	}
+}
+
+func TestTakeActionBisectsFailedBatches(t *testing.T) {
+	newPR := func(number int) PullRequest {
+		var pr PullRequest
+		pr.Number = githubql.Int(number)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("sha-%d", number))
+		return pr
+	}
+	batchJob := func(numbers ...int) prowapi.ProwJob {
+		pj := prowapi.ProwJob{Spec: prowapi.ProwJobSpec{Type: prowapi.BatchJob, Refs: &prowapi.Refs{}}}
+		for _, number := range numbers {
+			pj.Spec.Refs.Pulls = append(pj.Spec.Refs.Pulls, prowapi.Pull{Number: number, SHA: fmt.Sprintf("sha-%d", number)})
+		}
+		return pj
+	}
+	prs := []PullRequest{newPR(1), newPR(2), newPR(3), newPR(4), newPR(5)}
+
+	testCases := []struct {
+		name          string
+		disabled      bool
+		batchPending  bool
+		failedBatches [][]PullRequest
+		pjs           []prowapi.ProwJob
+
+		action       Action
+		bisected     [][]int
+		batchesTotal int
+	}{
+		{
+			name:          "failed batch is split into two halves",
+			failedBatches: [][]PullRequest{prs},
+			pjs:           []prowapi.ProwJob{batchJob(1, 2, 3, 4, 5)},
+			action:        TriggerBisection,
+			bisected:      [][]int{{1, 2}, {3, 4, 5}},
+			batchesTotal:  2,
+		},
+		{
+			name:          "halves that were tested already are not triggered again",
+			failedBatches: [][]PullRequest{prs[:2], prs},
+			pjs:           []prowapi.ProwJob{batchJob(1, 2, 3, 4, 5), batchJob(1, 2), batchJob(3, 4, 5)},
+			action:        TriggerBisection,
+			bisected:      [][]int{{1}, {2}},
+			batchesTotal:  2,
+		},
+		{
+			name:          "batches containing a smaller failed batch are not split",
+			failedBatches: [][]PullRequest{prs[2:4], prs},
+			pjs:           []prowapi.ProwJob{batchJob(3, 4)},
+			action:        TriggerBisection,
+			bisected:      [][]int{{3}, {4}},
+			batchesTotal:  2,
+		},
+		{
+			name:          "bisection waits for pending batches",
+			batchPending:  true,
+			failedBatches: [][]PullRequest{prs},
+			pjs:           []prowapi.ProwJob{batchJob(1, 2, 3, 4, 5)},
+			action:        Wait,
+		},
+		{
+			name:          "failed batches are not bisected unless enabled",
+			disabled:      true,
+			failedBatches: [][]PullRequest{prs},
+			pjs:           []prowapi.ProwJob{batchJob(1, 2, 3, 4, 5)},
+			action:        Wait,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ca := &config.Agent{}
+			cfg := &config.Config{
+				ProwConfig: config.ProwConfig{
+					ProwJobNamespace: "default",
+					Tide: config.Tide{
+						BatchSizeLimitMap:   map[string]int{"*": -1},
+						BisectFailedBatches: !tc.disabled,
+					},
+				},
+			}
+			if err := cfg.SetPresubmits(map[string][]config.Presubmit{
+				"o/r": {{AlwaysRun: true, JobBase: config.JobBase{Name: "foo"}, Reporter: config.Reporter{Context: "foo"}}},
+			}); err != nil {
+				t.Fatalf("failed to set presubmits: %v", err)
+			}
+			ca.Set(cfg)
+			hist, err := history.New(100, nil, "")
+			if err != nil {
+				t.Fatalf("Failed to create history client: %v", err)
+			}
+			client := fakectrlruntimeclient.NewFakeClient()
+			c := &Controller{
+				logger:        logrus.WithField("controller", "tide"),
+				config:        ca.Config,
+				ghc:           &fgc{},
+				prowJobClient: client,
+				changedFiles: &changedFilesAgent{
+					ghc:             &fgc{},
+					nextChangeCache: make(map[changeCacheKey][]string),
+				},
+				History: hist,
+			}
+			sp := subpool{
+				log:        logrus.WithField("component", "tide"),
+				org:        "o",
+				repo:       "r",
+				branch:     "master",
+				sha:        "master",
+				prs:        prs,
+				pjs:        tc.pjs,
+				presubmits: map[int][]config.Presubmit{1: cfg.PresubmitsStatic["o/r"]},
+			}
+			var batchPending []PullRequest
+			if tc.batchPending {
+				batchPending = prs[:1]
+			}
+
+			act, _, err := c.takeAction(sp, batchPending, nil, nil, nil, nil, tc.failedBatches, nil, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error in takeAction: %v", err)
+			}
+			if act != tc.action {
+				t.Errorf("Wrong action. Got %v, wanted %v.", act, tc.action)
+			}
+
+			prowJobs := &prowapi.ProwJobList{}
+			if err := client.List(context.Background(), prowJobs); err != nil {
+				t.Fatalf("failed to list ProwJobs: %v", err)
+			}
+			var batches int
+			for _, pj := range prowJobs.Items {
+				if pj.Spec.Type == prowapi.BatchJob {
+					batches++
+				}
+			}
+			if batches != tc.batchesTotal {
+				t.Errorf("expected %d batch jobs to be triggered, got %d", tc.batchesTotal, batches)
+			}
+
+			var bisected [][]int
+			for _, record := range hist.AllRecords()[poolKey("o", "r", "master")] {
+				if record.Action != string(TriggerBisection) {
+					continue
+				}
+				var numbers []int
+				for _, pull := range record.Target {
+					numbers = append(numbers, pull.Number)
+				}
+				// Records are listed newest first.
+				bisected = append([][]int{numbers}, bisected...)
+			}
+			if !reflect.DeepEqual(bisected, tc.bisected) {
+				t.Errorf("expected bisected halves %v in the history, got %v", tc.bisected, bisected)
+			}
+		})
+	}
+}
+
 func TestServeHTTP(t *testing.T) {
 	pr1 := PullRequest{}
 	pr1.Commits.Nodes = append(pr1.Commits.Nodes, struct{ Commit Commit }{})
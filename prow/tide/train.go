@@ -0,0 +1,241 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/github"
+)
+
+// mergeTrain is the state of the merge train of a subpool. Every car of the
+// train is a batch that contains the PRs of the car in front of it followed
+// by more PRs, so it tests what the branch looks like once all cars up to
+// it are merged.
+type mergeTrain struct {
+	// length is the maximum number of cars.
+	length int
+	// cars are the pending or passing batches of the train, shortest first.
+	cars [][]PullRequest
+	// ejected are the PRs that failed in a car on top of the last car. They
+	// do not join the train again until the base of the subpool changes.
+	ejected sets.Int
+}
+
+// last returns the PRs of the last car of the train.
+func (t *mergeTrain) last() []PullRequest {
+	if len(t.cars) == 0 {
+		return nil
+	}
+	return t.cars[len(t.cars)-1]
+}
+
+// buildTrain assembles the merge train from the results of the batches of
+// the subpool: starting with the shortest batch, every pending or passing
+// batch that extends the previous car becomes the next car. Batches that
+// extend a failed batch are left out, as they contain the PRs that broke it.
+func buildTrain(length int, results []batchResult) *mergeTrain {
+	sorted := make([]batchResult, len(results))
+	copy(sorted, results)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].prs) < len(sorted[j].prs) })
+
+	var failed [][]PullRequest
+	for _, result := range sorted {
+		if result.state == failureState {
+			failed = append(failed, result.prs)
+		}
+	}
+
+	train := &mergeTrain{length: length, ejected: sets.NewInt()}
+outer:
+	for _, result := range sorted {
+		if result.state == failureState {
+			continue
+		}
+		for _, prs := range failed {
+			if isPrefix(prs, result.prs) {
+				continue outer
+			}
+		}
+		if last := train.last(); len(train.cars) > 0 && (len(last) == len(result.prs) || !isPrefix(last, result.prs)) {
+			continue
+		}
+		train.cars = append(train.cars, result.prs)
+	}
+	for _, prs := range failed {
+		if last := train.last(); len(prs) > len(last) && isPrefix(last, prs) {
+			train.ejected.Insert(prNumbers(prs[len(last):])...)
+		}
+	}
+	return train
+}
+
+// isPrefix returns whether the heads of the PRs in prefix are the first
+// heads in prs.
+func isPrefix(prefix, prs []PullRequest) bool {
+	if len(prefix) > len(prs) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i].Number != prs[i].Number || prefix[i].HeadRefOID != prs[i].HeadRefOID {
+			return false
+		}
+	}
+	return true
+}
+
+// trainStep records that merging the PRs of a train car moved the base of
+// a pool from one SHA to another.
+type trainStep struct {
+	from, to string
+	merged   []prowapi.Pull
+}
+
+// trainSteps remembers the recent train merges of every pool, so that the
+// jobs of the cars behind a merged car keep counting for the new base.
+type trainSteps struct {
+	sync.Mutex
+	steps map[string][]trainStep
+}
+
+// recordTrainMerge remembers that the PRs were merged as a train car, if the
+// branch has exactly the content that the car was tested with now. If
+// anything else was merged into the branch in the meantime, the jobs of the
+// cars behind it do not count for the new base and the step is dropped.
+func (c *Controller) recordTrainMerge(sp subpool, merged []PullRequest, length int) {
+	sha, err := c.providerOf(sp.org).getRef(sp.org, sp.repo, "heads/"+sp.branch)
+	if err != nil {
+		sp.log.WithError(err).Warn("Failed to get the branch after merging a train car, the cars behind it will be tested again.")
+		return
+	}
+	matches, err := c.trainMergeMatches(sp, merged, sha)
+	if err != nil {
+		sp.log.WithError(err).Warn("Failed to verify the branch after merging a train car, the cars behind it will be tested again.")
+		return
+	}
+	if !matches {
+		sp.log.WithField("head", sha).Info("The branch does not match the merged train car, the cars behind it will be tested again.")
+		return
+	}
+	c.addTrainStep(poolKey(sp.org, sp.repo, sp.branch), trainStep{from: sp.sha, to: sha, merged: prMeta(merged...)}, length)
+}
+
+// trainMergeMatches returns whether the head of the branch has the same tree
+// as the PRs merged on top of the base that the car was tested on. Comparing
+// trees rather than commits works for every merge method.
+func (c *Controller) trainMergeMatches(sp subpool, merged []PullRequest, head string) (bool, error) {
+	r, err := c.providerOf(sp.org).clone(sp.org, sp.repo)
+	if err != nil {
+		return false, err
+	}
+	if r == nil {
+		// The provider cannot check merges, so the merge cannot be verified.
+		return false, nil
+	}
+	defer r.Clean()
+	if err := prepareMerges(&sp, r); err != nil {
+		return false, err
+	}
+	var heads []string
+	for _, pr := range merged {
+		heads = append(heads, string(pr.HeadRefOID))
+	}
+	if err := r.MergeAndCheckout(sp.sha, heads, github.MergeMerge); err != nil {
+		return false, err
+	}
+	tested, err := r.RevParse("HEAD^{tree}")
+	if err != nil {
+		return false, err
+	}
+	actual, err := r.RevParse(head + "^{tree}")
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(tested) == strings.TrimSpace(actual), nil
+}
+
+// addTrainStep remembers a train step of the pool, keeping the last length
+// steps.
+func (c *Controller) addTrainStep(key string, step trainStep, length int) {
+	c.trains.Lock()
+	defer c.trains.Unlock()
+	if c.trains.steps == nil {
+		c.trains.steps = map[string][]trainStep{}
+	}
+	steps := append(c.trains.steps[key], step)
+	if len(steps) > length {
+		steps = steps[len(steps)-length:]
+	}
+	c.trains.steps[key] = steps
+}
+
+// carriedTrainJobs returns the batch jobs of earlier bases that tested the
+// PRs merged since then in front of other PRs, rewritten as batch jobs of
+// the current base for those other PRs.
+func (c *Controller) carriedTrainJobs(sp *subpool) ([]prowapi.ProwJob, error) {
+	c.trains.Lock()
+	steps := c.trains.steps[poolKey(sp.org, sp.repo, sp.branch)]
+	c.trains.Unlock()
+
+	var carried []prowapi.ProwJob
+	var merged []prowapi.Pull
+	base := sp.sha
+	for i := len(steps) - 1; i >= 0 && steps[i].to == base; i-- {
+		merged = append(append([]prowapi.Pull{}, steps[i].merged...), merged...)
+		base = steps[i].from
+
+		pjs := &prowapi.ProwJobList{}
+		if err := c.prowJobClient.List(
+			c.ctx,
+			pjs,
+			ctrlruntimeclient.MatchingField(cacheIndexName, cacheIndexKey(sp.org, sp.repo, sp.branch, base)),
+			ctrlruntimeclient.InNamespace(c.config().ProwJobNamespace)); err != nil {
+			return nil, fmt.Errorf("failed to list jobs of merge train base %s: %v", base, err)
+		}
+		for _, pj := range pjs.Items {
+			if pj.Spec.Type != prowapi.BatchJob || !hasPulls(pj.Spec.Refs.Pulls, merged) {
+				continue
+			}
+			pj := pj.DeepCopy()
+			pj.Spec.Refs.BaseSHA = sp.sha
+			pj.Spec.Refs.Pulls = pj.Spec.Refs.Pulls[len(merged):]
+			carried = append(carried, *pj)
+		}
+	}
+	return carried, nil
+}
+
+// hasPulls returns whether the pulls start with the merged pulls and
+// contain more pulls after them.
+func hasPulls(pulls, merged []prowapi.Pull) bool {
+	if len(pulls) <= len(merged) {
+		return false
+	}
+	for i := range merged {
+		if pulls[i].Number != merged[i].Number || pulls[i].SHA != merged[i].SHA {
+			return false
+		}
+	}
+	return true
+}
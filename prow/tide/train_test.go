@@ -0,0 +1,422 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tide
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	githubql "github.com/shurcooL/githubv4"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	fakectrlruntimeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	prowapi "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+	"k8s.io/test-infra/prow/git/localgit"
+)
+
+func TestBuildTrain(t *testing.T) {
+	prs := map[int]PullRequest{}
+	for i := 1; i <= 5; i++ {
+		var pr PullRequest
+		pr.Number = githubql.Int(i)
+		pr.HeadRefOID = githubql.String(fmt.Sprintf("sha-%d", i))
+		prs[i] = pr
+	}
+	result := func(state simpleState, numbers ...int) batchResult {
+		res := batchResult{state: state}
+		for _, number := range numbers {
+			res.prs = append(res.prs, prs[number])
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name    string
+		results []batchResult
+
+		cars    [][]int
+		ejected []int
+	}{
+		{
+			name: "no batches",
+		},
+		{
+			name:    "cars extend each other",
+			results: []batchResult{result(pendingState, 1, 2, 3, 4), result(successState, 1, 2), result(pendingState, 1, 2, 3)},
+			cars:    [][]int{{1, 2}, {1, 2, 3}, {1, 2, 3, 4}},
+		},
+		{
+			name:    "batches that do not extend the last car are no cars",
+			results: []batchResult{result(pendingState, 1, 2), result(pendingState, 1, 3), result(pendingState, 3, 4, 5)},
+			cars:    [][]int{{1, 2}},
+		},
+		{
+			name:    "failed extension of the last car ejects its PRs",
+			results: []batchResult{result(pendingState, 1, 2), result(failureState, 1, 2, 3, 4)},
+			cars:    [][]int{{1, 2}},
+			ejected: []int{3, 4},
+		},
+		{
+			name:    "batches behind a failed car are left out",
+			results: []batchResult{result(pendingState, 1, 2), result(failureState, 1, 2, 3), result(pendingState, 1, 2, 3, 4)},
+			cars:    [][]int{{1, 2}},
+			ejected: []int{3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			train := buildTrain(3, tc.results)
+			var cars [][]int
+			for _, car := range train.cars {
+				cars = append(cars, prNumbers(car))
+			}
+			if !reflect.DeepEqual(cars, tc.cars) {
+				t.Errorf("expected cars %v, got %v", tc.cars, cars)
+			}
+			if ejected := train.ejected.List(); len(ejected) > 0 || len(tc.ejected) > 0 {
+				if !reflect.DeepEqual(ejected, tc.ejected) {
+					t.Errorf("expected ejected PRs %v, got %v", tc.ejected, ejected)
+				}
+			}
+		})
+	}
+}
+
+func TestCarriedTrainJobs(t *testing.T) {
+	pull := func(number int) prowapi.Pull {
+		return prowapi.Pull{Number: number, SHA: fmt.Sprintf("sha-%d", number)}
+	}
+	batchJob := func(name, baseSHA string, pulls ...prowapi.Pull) *prowapi.ProwJob {
+		return &prowapi.ProwJob{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: prowapi.ProwJobSpec{
+				Type: prowapi.BatchJob,
+				Refs: &prowapi.Refs{Org: "o", Repo: "r", BaseRef: "master", BaseSHA: baseSHA, Pulls: pulls},
+			},
+		}
+	}
+
+	client := &indexingClient{
+		Client:     fakectrlruntimeclient.NewFakeClient(),
+		indexFuncs: map[string]ctrlruntimeclient.IndexerFunc{cacheIndexName: cacheIndexFunc},
+	}
+	for _, pj := range []*prowapi.ProwJob{
+		// The merged car and the cars behind it.
+		batchJob("car-1", "base-1", pull(1), pull(2)),
+		batchJob("car-2", "base-1", pull(1), pull(2), pull(3)),
+		batchJob("car-3", "base-1", pull(1), pull(2), pull(3), pull(4)),
+		// Not behind the merged car.
+		batchJob("other", "base-1", pull(1), pull(3)),
+		// Behind the car merged into base-1.
+		batchJob("older", "base-0", pull(5), pull(1), pull(2), pull(6)),
+	} {
+		if err := client.Create(context.Background(), pj); err != nil {
+			t.Fatalf("failed to create ProwJob: %v", err)
+		}
+	}
+
+	ca := &config.Agent{}
+	ca.Set(&config.Config{ProwConfig: config.ProwConfig{ProwJobNamespace: "default"}})
+	c := &Controller{
+		ctx:           context.Background(),
+		logger:        logrus.WithField("controller", "tide"),
+		config:        ca.Config,
+		prowJobClient: client,
+	}
+	merge := func(from, to string, numbers ...int) {
+		var pulls []prowapi.Pull
+		for _, number := range numbers {
+			pulls = append(pulls, pull(number))
+		}
+		c.addTrainStep(poolKey("o", "r", "master"), trainStep{from: from, to: to, merged: pulls}, 3)
+	}
+	merge("base-0", "base-1", 5)
+	merge("base-1", "base-2", 1, 2)
+
+	carried, err := c.carriedTrainJobs(&subpool{org: "o", repo: "r", branch: "master", sha: "base-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := map[string][]int{}
+	for _, pj := range carried {
+		if pj.Spec.Refs.BaseSHA != "base-2" {
+			t.Errorf("expected job %s to be carried to base-2, got %s", pj.Name, pj.Spec.Refs.BaseSHA)
+		}
+		for _, pull := range pj.Spec.Refs.Pulls {
+			got[pj.Name] = append(got[pj.Name], pull.Number)
+		}
+	}
+	expected := map[string][]int{
+		"car-2": {3},
+		"car-3": {3, 4},
+		"older": {6},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected carried jobs %v, got %v", expected, got)
+	}
+
+	// Once the branch moves without tide, nothing is carried.
+	carried, err = c.carriedTrainJobs(&subpool{org: "o", repo: "r", branch: "master", sha: "base-3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(carried) != 0 {
+		t.Errorf("expected no jobs to be carried to an unknown base, got %d", len(carried))
+	}
+}
+
+func TestRecordTrainMerge(t *testing.T) {
+	testCases := []struct {
+		name string
+		// pushed is pushed to the branch between the merges of the PRs.
+		pushed   bool
+		expected bool
+	}{
+		{
+			name:     "branch with only the merges of the car is recorded",
+			expected: true,
+		},
+		{
+			name:   "branch with another commit pushed in between is dropped",
+			pushed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lg, gc, err := localgit.New()
+			if err != nil {
+				t.Fatalf("Error making local git: %v", err)
+			}
+			defer gc.Clean()
+			defer lg.Clean()
+			if err := lg.MakeFakeRepo("o", "r"); err != nil {
+				t.Fatalf("Error making fake repo: %v", err)
+			}
+			if err := lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("foo")}); err != nil {
+				t.Fatalf("Adding initial commit: %v", err)
+			}
+			base, err := lg.RevParse("o", "r", "HEAD")
+			if err != nil {
+				t.Fatalf("Error getting the base SHA: %v", err)
+			}
+			var car []PullRequest
+			for i := 1; i <= 2; i++ {
+				if err := lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", i)); err != nil {
+					t.Fatalf("Error checking out new branch: %v", err)
+				}
+				if err := lg.AddCommit("o", "r", map[string][]byte{fmt.Sprintf("%d", i): []byte("WOW")}); err != nil {
+					t.Fatalf("Error adding commit: %v", err)
+				}
+				head, err := lg.RevParse("o", "r", "HEAD")
+				if err != nil {
+					t.Fatalf("Error getting the head SHA: %v", err)
+				}
+				if err := lg.Checkout("o", "r", "master"); err != nil {
+					t.Fatalf("Error checking out master: %v", err)
+				}
+				var pr PullRequest
+				pr.Number = githubql.Int(i)
+				pr.HeadRefOID = githubql.String(strings.TrimSpace(head))
+				car = append(car, pr)
+			}
+			for i, pr := range car {
+				if _, err := lg.Merge("o", "r", string(pr.HeadRefOID)); err != nil {
+					t.Fatalf("Error merging PR: %v", err)
+				}
+				if tc.pushed && i == 0 {
+					if err := lg.AddCommit("o", "r", map[string][]byte{"pushed": []byte("by hand")}); err != nil {
+						t.Fatalf("Error adding commit: %v", err)
+					}
+				}
+			}
+			head, err := lg.RevParse("o", "r", "HEAD")
+			if err != nil {
+				t.Fatalf("Error getting the branch SHA: %v", err)
+			}
+
+			c := &Controller{
+				ghc: &fgc{refs: map[string]string{"o/r heads/master": strings.TrimSpace(head)}},
+				gc:  gc,
+			}
+			sp := subpool{log: logrus.WithField("component", "tide"), org: "o", repo: "r", branch: "master", sha: strings.TrimSpace(base)}
+			c.recordTrainMerge(sp, car, 3)
+
+			steps := c.trains.steps[poolKey("o", "r", "master")]
+			if recorded := len(steps) == 1; recorded != tc.expected {
+				t.Fatalf("expected the merge to be recorded: %t, got steps %+v", tc.expected, steps)
+			}
+			if tc.expected && (steps[0].from != sp.sha || steps[0].to != strings.TrimSpace(head) || len(steps[0].merged) != 2) {
+				t.Errorf("expected a step from %s to %s merging 2 PRs, got %+v", sp.sha, strings.TrimSpace(head), steps[0])
+			}
+		})
+	}
+}
+
+func TestTakeActionExtendsTrain(t *testing.T) {
+	testCases := []struct {
+		name    string
+		length  int
+		cars    [][]int
+		ejected []int
+
+		action Action
+		car    []int
+	}{
+		{
+			name:   "first car is picked like a batch",
+			length: 3,
+			action: TriggerTrainCar,
+			car:    []int{1, 2, 3, 4},
+		},
+		{
+			name:   "car is added on top of the last car",
+			length: 3,
+			cars:   [][]int{{1, 2}},
+			action: TriggerTrainCar,
+			car:    []int{1, 2, 3, 4},
+		},
+		{
+			name:    "ejected PRs are not added",
+			length:  3,
+			cars:    [][]int{{1, 2}},
+			ejected: []int{3},
+			action:  TriggerTrainCar,
+			car:     []int{1, 2, 4},
+		},
+		{
+			name:   "full train waits",
+			length: 2,
+			cars:   [][]int{{1, 2}, {1, 2, 3}},
+			action: Wait,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ca := &config.Agent{}
+			cfg := &config.Config{ProwConfig: config.ProwConfig{ProwJobNamespace: "default"}}
+			if err := cfg.SetPresubmits(map[string][]config.Presubmit{
+				"o/r": {{AlwaysRun: true, JobBase: config.JobBase{Name: "foo"}, Reporter: config.Reporter{Context: "foo"}}},
+			}); err != nil {
+				t.Fatalf("failed to set presubmits: %v", err)
+			}
+			ca.Set(cfg)
+			lg, gc, err := localgit.New()
+			if err != nil {
+				t.Fatalf("Error making local git: %v", err)
+			}
+			defer gc.Clean()
+			defer lg.Clean()
+			if err := lg.MakeFakeRepo("o", "r"); err != nil {
+				t.Fatalf("Error making fake repo: %v", err)
+			}
+			if err := lg.AddCommit("o", "r", map[string][]byte{"foo": []byte("foo")}); err != nil {
+				t.Fatalf("Adding initial commit: %v", err)
+			}
+
+			sp := subpool{
+				log:        logrus.WithField("component", "tide"),
+				org:        "o",
+				repo:       "r",
+				branch:     "master",
+				sha:        "master",
+				cc:         map[int]contextChecker{},
+				presubmits: map[int][]config.Presubmit{},
+			}
+			prs := map[int]PullRequest{}
+			for i := 1; i <= 4; i++ {
+				if err := lg.CheckoutNewBranch("o", "r", fmt.Sprintf("pr-%d", i)); err != nil {
+					t.Fatalf("Error checking out new branch: %v", err)
+				}
+				if err := lg.AddCommit("o", "r", map[string][]byte{fmt.Sprintf("%d", i): []byte("WOW")}); err != nil {
+					t.Fatalf("Error adding commit: %v", err)
+				}
+				if err := lg.Checkout("o", "r", "master"); err != nil {
+					t.Fatalf("Error checking out master: %v", err)
+				}
+				oid := githubql.String(fmt.Sprintf("origin/pr-%d", i))
+				var pr PullRequest
+				pr.Number = githubql.Int(i)
+				pr.HeadRefOID = oid
+				pr.Commits.Nodes = []struct {
+					Commit Commit
+				}{{Commit: Commit{OID: oid}}}
+				prs[i] = pr
+				sp.prs = append(sp.prs, pr)
+				sp.cc[i] = &config.TideContextPolicy{}
+				sp.presubmits[i] = cfg.PresubmitsStatic["o/r"]
+			}
+			train := &mergeTrain{length: tc.length, ejected: sets.NewInt(tc.ejected...)}
+			for _, numbers := range tc.cars {
+				var car []PullRequest
+				for _, number := range numbers {
+					car = append(car, prs[number])
+				}
+				train.cars = append(train.cars, car)
+			}
+
+			client := fakectrlruntimeclient.NewFakeClient()
+			c := &Controller{
+				logger:        logrus.WithField("controller", "tide"),
+				gc:            gc,
+				config:        ca.Config,
+				ghc:           &fgc{},
+				prowJobClient: client,
+				changedFiles: &changedFilesAgent{
+					ghc:             &fgc{},
+					nextChangeCache: make(map[changeCacheKey][]string),
+				},
+			}
+			var batchPending []PullRequest
+			if len(train.cars) > 0 {
+				batchPending = train.last()
+			}
+			act, targets, err := c.takeAction(sp, batchPending, nil, nil, nil, nil, nil, nil, train)
+			if err != nil {
+				t.Fatalf("Unexpected error in takeAction: %v", err)
+			}
+			if act != tc.action {
+				t.Errorf("Wrong action. Got %v, wanted %v.", act, tc.action)
+			}
+			if got := prNumbers(targets); len(got) > 0 || len(tc.car) > 0 {
+				if !reflect.DeepEqual(got, tc.car) {
+					t.Errorf("expected car %v, got %v", tc.car, got)
+				}
+			}
+
+			prowJobs := &prowapi.ProwJobList{}
+			if err := client.List(context.Background(), prowJobs); err != nil {
+				t.Fatalf("failed to list ProwJobs: %v", err)
+			}
+			for _, pj := range prowJobs.Items {
+				if pj.Spec.Type != prowapi.BatchJob {
+					t.Errorf("expected only batch jobs to be triggered, got a %s job", pj.Spec.Type)
+				}
+			}
+			if len(tc.car) > 0 && len(prowJobs.Items) != 1 {
+				t.Errorf("expected one job for the new car, got %d", len(prowJobs.Items))
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package reporter contains a crier reporter that POSTs a templated JSON
+// payload to a webhook for every reported ProwJob.
+package reporter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+const (
+	reporterName = "webhookreporter"
+
+	// SignatureHeader carries the HMAC-SHA256 signature of the payload when
+	// the reporter has a secret.
+	SignatureHeader = "X-Prow-Signature"
+	// EventHeader carries the name of the reported ProwJob.
+	EventHeader = "X-Prow-Job"
+
+	// deliveryTimeout bounds a single delivery. Failed deliveries are not
+	// retried inline but requeued by crier, so they do not hold up a worker.
+	deliveryTimeout = 10 * time.Second
+)
+
+type webhookReporter struct {
+	client *http.Client
+	config func() *config.WebhookReporter
+	secret []byte
+	logger *logrus.Entry
+	dryRun bool
+}
+
+// endpoint returns the URL to report the job to. URLs set by the job are
+// checked again here as ProwJobs may be created without a config check.
+func endpoint(cfg *config.WebhookReporter, pj *v1.ProwJob) (string, error) {
+	if pj.Spec.ReporterConfig != nil && pj.Spec.ReporterConfig.Webhook != nil && pj.Spec.ReporterConfig.Webhook.URL != "" {
+		if err := cfg.ValidateJobURL(pj.Spec.ReporterConfig.Webhook.URL); err != nil {
+			return "", err
+		}
+		return pj.Spec.ReporterConfig.Webhook.URL, nil
+	}
+	return cfg.URL, nil
+}
+
+// Signature returns the value of the SignatureHeader for a payload.
+func Signature(payload, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (wr *webhookReporter) Report(pj *v1.ProwJob) ([]*v1.ProwJob, error) {
+	config := wr.config()
+	url, err := endpoint(config, pj)
+	if err != nil {
+		wr.logger.WithField("prowjob", pj.Name).WithError(err).Error("refusing to report to the url of the job")
+		return nil, err
+	}
+	logger := wr.logger.WithFields(logrus.Fields{"prowjob": pj.Name, "url": url})
+	payload, err := config.Payload(pj)
+	if err != nil {
+		logger.WithError(err).Error("failed to render payload")
+		return nil, err
+	}
+	if wr.dryRun {
+		logger.WithField("payload", string(payload)).Debug("Skipping reporting because dry-run is enabled")
+		return []*v1.ProwJob{pj}, nil
+	}
+
+	if retry, err := wr.deliver(url, pj.Spec.Job, payload); err != nil {
+		if !retry {
+			// Delivering the payload again cannot succeed, so the job is
+			// reported nonetheless rather than requeued.
+			logger.WithError(err).Error("webhook rejected the payload, not retrying")
+			return []*v1.ProwJob{pj}, nil
+		}
+		logger.WithError(err).Warning("failed to deliver webhook")
+		return nil, fmt.Errorf("failed to deliver webhook: %v", err)
+	}
+	return []*v1.ProwJob{pj}, nil
+}
+
+// deliver POSTs the payload once and returns whether a failure is worth retrying.
+func (wr *webhookReporter) deliver(url, job string, payload []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, job)
+	if len(wr.secret) > 0 {
+		req.Header.Set(SignatureHeader, Signature(payload, wr.secret))
+	}
+	resp, err := wr.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	err = fmt.Errorf("response has status %q and body %q", resp.Status, string(body))
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+func (wr *webhookReporter) GetName() string {
+	return reporterName
+}
+
+func (wr *webhookReporter) ShouldReport(pj *v1.ProwJob) bool {
+	config := wr.config()
+	if url, err := endpoint(config, pj); err != nil || url == "" {
+		return false
+	}
+
+	statesToReport := config.JobStatesToReport
+	// Jobs that opt in are reported whatever their type.
+	typeShouldReport := pj.Spec.ReporterConfig != nil && pj.Spec.ReporterConfig.Webhook != nil
+	if typeShouldReport && len(pj.Spec.ReporterConfig.Webhook.JobStatesToReport) > 0 {
+		statesToReport = pj.Spec.ReporterConfig.Webhook.JobStatesToReport
+	}
+	for _, typeToReport := range config.JobTypesToReport {
+		if typeToReport == pj.Spec.Type {
+			typeShouldReport = true
+			break
+		}
+	}
+
+	stateShouldReport := false
+	for _, stateToReport := range statesToReport {
+		if pj.Status.State == stateToReport {
+			stateShouldReport = true
+			break
+		}
+	}
+
+	wr.logger.WithField("prowjob", pj.Name).
+		Debugf("reporting=%t", stateShouldReport && typeShouldReport)
+	return stateShouldReport && typeShouldReport
+}
+
+// New returns a reporter that signs payloads with the secret in secretFile,
+// if set.
+func New(cfg func() *config.WebhookReporter, dryRun bool, secretFile string) (*webhookReporter, error) {
+	var secret []byte
+	if secretFile != "" {
+		var err error
+		if secret, err = ioutil.ReadFile(secretFile); err != nil {
+			return nil, fmt.Errorf("failed to read --webhook-hmac-secret-file: %v", err)
+		}
+		secret = bytes.TrimSpace(secret)
+	}
+
+	return &webhookReporter{
+		client: &http.Client{Timeout: deliveryTimeout},
+		config: cfg,
+		secret: secret,
+		logger: logrus.WithField("component", reporterName),
+		dryRun: dryRun,
+	}, nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reporter
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	v1 "k8s.io/test-infra/prow/apis/prowjobs/v1"
+	"k8s.io/test-infra/prow/config"
+)
+
+func TestShouldReport(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   config.WebhookReporter
+		pj       *v1.ProwJob
+		expected bool
+	}{
+		{
+			name: "Periodic job in a configured state should report",
+			config: config.WebhookReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				URL:               "http://dashboard",
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: true,
+		},
+		{
+			name: "Job of another type should not report",
+			config: config.WebhookReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				URL:               "http://dashboard",
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PresubmitJob},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job in another state should not report",
+			config: config.WebhookReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				URL:               "http://dashboard",
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.SuccessState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job without an endpoint should not report",
+			config: config.WebhookReporter{
+				JobTypesToReport:  []v1.ProwJobType{v1.PeriodicJob},
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+			},
+			pj: &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Type: v1.PeriodicJob},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job that opts in should report whatever its type",
+			config: config.WebhookReporter{
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				AllowedURLs:       []string{"http://chat"},
+			},
+			pj: &v1.ProwJob{
+				Spec: v1.ProwJobSpec{
+					Type:           v1.PresubmitJob,
+					ReporterConfig: &v1.ReporterConfig{Webhook: &v1.WebhookReporterConfig{URL: "http://chat"}},
+				},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: true,
+		},
+		{
+			name: "Job with a url that is not allowed should not report",
+			config: config.WebhookReporter{
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				AllowedURLs:       []string{"http://chat"},
+			},
+			pj: &v1.ProwJob{
+				Spec: v1.ProwJobSpec{
+					Type:           v1.PresubmitJob,
+					ReporterConfig: &v1.ReporterConfig{Webhook: &v1.WebhookReporterConfig{URL: "http://metadata.internal/computeMetadata"}},
+				},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			},
+			expected: false,
+		},
+		{
+			name: "Job can override the states to report",
+			config: config.WebhookReporter{
+				JobStatesToReport: []v1.ProwJobState{v1.FailureState},
+				URL:               "http://dashboard",
+			},
+			pj: &v1.ProwJob{
+				Spec: v1.ProwJobSpec{
+					Type: v1.PeriodicJob,
+					ReporterConfig: &v1.ReporterConfig{Webhook: &v1.WebhookReporterConfig{
+						JobStatesToReport: []v1.ProwJobState{v1.SuccessState},
+					}},
+				},
+				Status: v1.ProwJobStatus{State: v1.SuccessState},
+			},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgGetter := func() *config.WebhookReporter {
+				return &tc.config
+			}
+			reporter := &webhookReporter{
+				config: cfgGetter,
+				logger: logrus.WithField("component", reporterName),
+			}
+
+			if result := reporter.ShouldReport(tc.pj); result != tc.expected {
+				t.Errorf("expected result to be %t but was %t", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	testCases := []struct {
+		name      string
+		responses []int
+		dryRun    bool
+
+		expectErr      bool
+		expectRequests int
+	}{
+		{
+			name:           "successful delivery",
+			responses:      []int{http.StatusOK},
+			expectRequests: 1,
+		},
+		{
+			name:           "server errors are returned for crier to retry",
+			responses:      []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectErr:      true,
+			expectRequests: 1,
+		},
+		{
+			name:           "too many requests are returned for crier to retry",
+			responses:      []int{http.StatusTooManyRequests, http.StatusOK},
+			expectErr:      true,
+			expectRequests: 1,
+		},
+		{
+			name:           "client errors are reported without retrying",
+			responses:      []int{http.StatusBadRequest, http.StatusOK},
+			expectRequests: 1,
+		},
+		{
+			name:   "dry run does not deliver",
+			dryRun: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("failed to read body: %v", err)
+				}
+				if expected := `{"job": "nightly", "state": "failure"}`; string(body) != expected {
+					t.Errorf("expected payload %s, got %s", expected, string(body))
+				}
+				if signature, expected := r.Header.Get(SignatureHeader), Signature(body, []byte("secret")); signature != expected {
+					t.Errorf("expected signature %s, got %s", expected, signature)
+				}
+				if job := r.Header.Get(EventHeader); job != "nightly" {
+					t.Errorf("expected job header nightly, got %s", job)
+				}
+				w.WriteHeader(tc.responses[requests])
+				requests++
+			}))
+			defer server.Close()
+
+			cfg := &config.WebhookReporter{
+				URL:             server.URL,
+				PayloadTemplate: `{"job": {{json .Spec.Job}}, "state": {{json .Status.State}}}`,
+			}
+			reporter := &webhookReporter{
+				client: server.Client(),
+				config: func() *config.WebhookReporter { return cfg },
+				secret: []byte("secret"),
+				logger: logrus.WithField("component", reporterName),
+				dryRun: tc.dryRun,
+			}
+
+			pj := &v1.ProwJob{
+				Spec:   v1.ProwJobSpec{Job: "nightly"},
+				Status: v1.ProwJobStatus{State: v1.FailureState},
+			}
+			reported, err := reporter.Report(pj)
+			if tc.expectErr != (err != nil) {
+				t.Errorf("expected error %t, got %v", tc.expectErr, err)
+			}
+			if !tc.expectErr && (len(reported) != 1 || reported[0] != pj) {
+				t.Errorf("expected the job to be reported, got %v", reported)
+			}
+			if requests != tc.expectRequests {
+				t.Errorf("expected %d requests, got %d", tc.expectRequests, requests)
+			}
+		})
+	}
+}